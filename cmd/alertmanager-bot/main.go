@@ -7,8 +7,13 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/docker/libkv/store"
@@ -16,15 +21,31 @@ import (
 	"github.com/docker/libkv/store/consul"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
-	"github.com/hako/durafmt"
 	"github.com/joho/godotenv"
 	"github.com/oklog/run"
-	"github.com/prometheus/alertmanager/notify"
-	"github.com/prometheus/alertmanager/template"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tucnak/telebot"
 	"github.com/vu-long/alertmanager-bot/pkg/alertmanager"
+	configfile "github.com/vu-long/alertmanager-bot/pkg/config"
+	"github.com/vu-long/alertmanager-bot/pkg/deploy"
+	"github.com/vu-long/alertmanager-bot/pkg/grafana"
+	"github.com/vu-long/alertmanager-bot/pkg/kubernetes"
+	"github.com/vu-long/alertmanager-bot/pkg/leader"
+	"github.com/vu-long/alertmanager-bot/pkg/loki"
+	"github.com/vu-long/alertmanager-bot/pkg/mirror"
+	"github.com/vu-long/alertmanager-bot/pkg/outbound"
+	"github.com/vu-long/alertmanager-bot/pkg/pager"
+	"github.com/vu-long/alertmanager-bot/pkg/remediate"
+	"github.com/vu-long/alertmanager-bot/pkg/runbook"
+	"github.com/vu-long/alertmanager-bot/pkg/secret"
+	"github.com/vu-long/alertmanager-bot/pkg/sentry"
+	"github.com/vu-long/alertmanager-bot/pkg/shard"
+	"github.com/vu-long/alertmanager-bot/pkg/statuspage"
+	"github.com/vu-long/alertmanager-bot/pkg/systemd"
 	"github.com/vu-long/alertmanager-bot/pkg/telegram"
+	"github.com/vu-long/alertmanager-bot/pkg/ticket"
+	"github.com/vu-long/alertmanager-bot/pkg/tracing"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
@@ -36,8 +57,35 @@ const (
 	levelInfo  = "info"
 	levelWarn  = "warn"
 	levelError = "error"
+
+	formatLogfmt = "logfmt"
+	formatJSON   = "json"
+
+	trackerJira   = "jira"
+	trackerGitHub = "github"
+
+	pagerPagerDuty = "pagerduty"
+	pagerOpsgenie  = "opsgenie"
+
+	deployGitHub = "github"
+	deployGitLab = "gitlab"
+
+	mirrorMatrix = "matrix"
+	mirrorSlack  = "slack"
+
+	// botsPath is the path of the webhooks of the additional bots, followed by their name
+	botsPath = "/bots/"
+
+	// healthKey is read to check that the store answers, it doesn't need to exist
+	healthKey = "alertmanager-bot/health"
+
+	// telegramAdminFlag sets the admins, they are reloaded from the configuration file
+	telegramAdminFlag = "telegram.admin"
 )
 
+// botName is the format of the names of additional bots, used in their webhook path and store prefix
+var botName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
 var (
 	// Version of alertmanager-bot.
 	Version string
@@ -45,51 +93,386 @@ var (
 	Revision string
 	// BuildDate this binary was built.
 	BuildDate string
+	// Changes are the notable changes of this version separated by '|', taken from the CHANGELOG.md at build time.
+	Changes string
 	// GoVersion running this binary.
 	GoVersion = runtime.Version()
 	// StartTime has the time this was started.
 	StartTime = time.Now()
 )
 
+// configuration is set by the flags, their environment variables and the configuration file
+type configuration struct {
+	alertCooldown           time.Duration
+	alertDedupWindow        time.Duration
+	alertMaxOpen            int
+	alertSuppressResolved   bool
+	alertmanager            *url.URL
+	auditMaxEntries         int
+	boltPath                string
+	configFile              string
+	consul                  *url.URL
+	consulToken             string
+	consulTokenFile         string
+	consulTokenVault        string
+	deployProvider          string
+	deployURL               *url.URL
+	deployLabel             string
+	deployRepositories      map[string]string
+	deployEnvironment       string
+	deployWindow            time.Duration
+	deployToken             string
+	deployTokenFile         string
+	deployTokenVault        string
+	grafana                 *url.URL
+	grafanaToken            string
+	grafanaTokenFile        string
+	grafanaTokenVault       string
+	historyRetention        time.Duration
+	images                  bool
+	imagesMaxSize           int64
+	imagesTimeout           time.Duration
+	karmaURL                *url.URL
+	kubernetesEvents        bool
+	kubernetesURL           *url.URL
+	kubernetesNamespace     string
+	kubernetesEventReasons  []string
+	leaderElection          bool
+	leaderKey               string
+	leaderID                string
+	leaderTTL               time.Duration
+	listenAddr              string
+	logLevel                string
+	logFormat               string
+	logJSON                 bool
+	lokiURL                 *url.URL
+	lokiLabels              map[string]string
+	lokiLines               int
+	lokiWindow              time.Duration
+	mirrorService           string
+	mirrorURL               *url.URL
+	mirrorRoom              string
+	mirrorToken             string
+	mirrorTokenFile         string
+	mirrorTokenVault        string
+	notifyResolved          bool
+	onCallCalendar          string
+	onCallInterval          time.Duration
+	onCallMembers           map[string]string
+	outboundURLs            []string
+	outboundEvents          []string
+	outboundSecret          string
+	outboundSecretFile      string
+	outboundSecretVault     string
+	outbox                  bool
+	outboxMaxAge            time.Duration
+	pagerService            string
+	pagerURL                *url.URL
+	pagerKey                string
+	pagerKeyFile            string
+	pagerKeyVault           string
+	profile                 string
+	prometheus              *url.URL
+	quietOverrides          []string
+	remediationFile         string
+	routingFile             string
+	runbookFile             string
+	fallbackChat            int64
+	sentryDSN               string
+	sentryEnvironment       string
+	shard                   bool
+	shardKey                string
+	shardTTL                time.Duration
+	statusPageID            string
+	statusPageURL           *url.URL
+	statusPageImpact        string
+	statusPageSeverities    []string
+	statusPageKey           string
+	statusPageKeyFile       string
+	statusPageKeyVault      string
+	store                   string
+	storeChatsCacheTTL      time.Duration
+	telegramAdmins          []int
+	telegramAdminChats      []int64
+	telegramAllowedChats    []int64
+	telegramBots            map[string]string
+	botSendRates            map[string]string
+	botChatSendRates        map[string]string
+	telegramReadOnly        bool
+	telegramSendRate        float64
+	telegramChatAdmins      bool
+	telegramChatSendRate    float64
+	telegramDeliveryWorkers int
+	telegramErrorsChat      int64
+	telegramNotifyForbidden bool
+	telegramToken           string
+	telegramTokenFile       string
+	telegramTokenVault      string
+	vaultAddr               *url.URL
+	vaultToken              string
+	vaultTokenFile          string
+	watchdogAlertname       string
+	watchdogChats           []int64
+	watchdogTimeout         time.Duration
+	templatesPaths          []string
+	ticketTracker           string
+	ticketURL               *url.URL
+	ticketProject           string
+	ticketIssueType         string
+	ticketUser              string
+	ticketToken             string
+	ticketTokenFile         string
+	ticketTokenVault        string
+	tracingEndpoint         *url.URL
+	tracingService          string
+	receiverTemplates       map[string]string
+	templatesReloadInterval time.Duration
+}
+
+// commands are the subcommands besides serve and the arguments of send
+type commands struct {
+	checkConfig *kingpin.CmdClause
+	migrate     *kingpin.CmdClause
+	send        *kingpin.CmdClause
+
+	sendChat     *int64
+	sendMessage  *string
+	sendAlert    *bool
+	sendTemplate *string
+}
+
 func main() {
 	godotenv.Load()
 
-	config := struct {
-		alertmanager   *url.URL
-		boltPath       string
-		consul         *url.URL
-		listenAddr     string
-		logLevel       string
-		logJSON        bool
-		store          string
-		telegramAdmins []int
-		telegramToken  string
-		templatesPaths []string
-	}{}
+	config := newConfiguration()
+	a, cmds := newApp(config)
+
+	file, args, overridden, err := withConfigFile(a, os.Args[1:])
+	if err != nil {
+		fmt.Printf("error loading configuration file: %v\n", err)
+		os.Exit(2)
+	}
+
+	cmd, err := a.Parse(args)
+	if err != nil {
+		fmt.Printf("error parsing commandline arguments and configuration file: %v\n", err)
+		a.Usage(os.Args[1:])
+		os.Exit(2)
+	}
+
+	logger := newLogger(config)
 
+	switch cmd {
+	case cmds.checkConfig.FullCommand():
+		runCheckConfig(config, file, logger)
+	case cmds.migrate.FullCommand():
+		runMigrate(config, logger)
+	case cmds.send.FullCommand():
+		runSend(config, cmds, logger)
+	default:
+		runServe(config, a, file, overridden, logger)
+	}
+}
+
+// newConfiguration returns an empty configuration with its map flags ready to be parsed into.
+func newConfiguration() *configuration {
+	return &configuration{
+		deployRepositories: map[string]string{},
+		lokiLabels:         map[string]string{},
+		onCallMembers:      map[string]string{},
+		receiverTemplates:  map[string]string{},
+		telegramBots:       map[string]string{},
+		botSendRates:       map[string]string{},
+		botChatSendRates:   map[string]string{},
+	}
+}
+
+// newApp defines the flags setting the configuration and the commands
+func newApp(config *configuration) (*kingpin.Application, *commands) {
 	a := kingpin.New("alertmanager-bot", "Bot for Prometheus' Alertmanager")
 	a.HelpFlag.Short('h')
 
+	a.Flag("alert.cooldown", "Alerts firing again within this duration update their existing message instead of notifying and escalating again").
+		Envar("ALERT_COOLDOWN").
+		Default("0s").
+		DurationVar(&config.alertCooldown)
+
+	a.Flag("alert.dedup-window", "Identical alerts delivered to a chat again within this duration, e.g. through multiple receivers, are dropped, 0 disables it").
+		Envar("ALERT_DEDUP_WINDOW").
+		Default("0s").
+		DurationVar(&config.alertDedupWindow)
+
+	a.Flag("alert.max-open", "The number of alerts kept to resolve, update and escalate them, the oldest resolved or acknowledged ones, then the oldest others are evicted above it, 0 for no limit").
+		Envar("ALERT_MAX_OPEN").
+		Default("10000").
+		IntVar(&config.alertMaxOpen)
+
+	a.Flag("alert.suppress-resolved", "Don't send resolved notifications to chats that didn't choose otherwise with /resolved").
+		Envar("SUPPRESS_RESOLVED").
+		BoolVar(&config.alertSuppressResolved)
+
 	a.Flag("alertmanager.url", "The URL that's used to connect to the alertmanager").
 		Required().
 		Envar("ALERTMANAGER_URL").
 		URLVar(&config.alertmanager)
 
+	a.Flag("audit.max-entries", "The number of executed commands kept in the audit log, 0 keeps all").
+		Envar("AUDIT_MAX_ENTRIES").
+		Default("1000").
+		IntVar(&config.auditMaxEntries)
+
 	a.Flag("bolt.path", "The path to the file where bolt persists its data").
 		Envar("BOLT_PATH").
 		StringVar(&config.boltPath)
 
+	a.Flag(configfile.FileFlag, "The path to a YAML file setting any of these flags, see examples/config.yml. Flags and environment variables take precedence over it").
+		Envar("CONFIG_FILE").
+		StringVar(&config.configFile)
+
 	a.Flag("consul.url", "The URL that's used to connect to the consul store").
 		Envar("CONSUL_URL").
 		URLVar(&config.consul)
 
+	a.Flag("consul.token", "The ACL token used to connect to the consul store").
+		Envar("CONSUL_HTTP_TOKEN").
+		StringVar(&config.consulToken)
+
+	a.Flag("consul.token-file", "The file containing the ACL token used to connect to the consul store").
+		Envar("CONSUL_TOKEN_FILE").
+		ExistingFileVar(&config.consulTokenFile)
+
+	a.Flag("consul.token-vault", "The vault secret of the ACL token used to connect to the consul store, as path#key").
+		Envar("CONSUL_TOKEN_VAULT").
+		StringVar(&config.consulTokenVault)
+
+	a.Flag("deploy.provider", "Where the latest deployments of the services of alerts are looked up to append them to the alerts, disabled if empty").
+		Envar("DEPLOY_PROVIDER").
+		Default("").
+		EnumVar(&config.deployProvider, "", deployGitHub, deployGitLab)
+
+	a.Flag("deploy.url", "The URL of the GitHub API or of GitLab, default: https://api.github.com or https://gitlab.com").
+		Envar("DEPLOY_URL").
+		URLVar(&config.deployURL)
+
+	a.Flag("deploy.label", "The label of alerts naming their service").
+		Envar("DEPLOY_LABEL").
+		Default("service").
+		StringVar(&config.deployLabel)
+
+	a.Flag("deploy.repository", "The repository of a service, as service=owner/name. Can be repeated").
+		Envar("DEPLOY_REPOSITORIES").
+		StringMapVar(&config.deployRepositories)
+
+	a.Flag("deploy.environment", "The environment of the deployments, any if empty").
+		Envar("DEPLOY_ENVIRONMENT").
+		StringVar(&config.deployEnvironment)
+
+	a.Flag("deploy.window", "How long after a deployment it is appended to alerts").
+		Envar("DEPLOY_WINDOW").
+		Default("1h").
+		DurationVar(&config.deployWindow)
+
+	a.Flag("deploy.token", "The token used to list the deployments").
+		Envar("DEPLOY_TOKEN").
+		StringVar(&config.deployToken)
+
+	a.Flag("deploy.token-file", "The file containing the token used to list the deployments").
+		Envar("DEPLOY_TOKEN_FILE").
+		ExistingFileVar(&config.deployTokenFile)
+
+	a.Flag("deploy.token-vault", "The vault secret of the token used to list the deployments, as path#key").
+		Envar("DEPLOY_TOKEN_VAULT").
+		StringVar(&config.deployTokenVault)
+
+	a.Flag("grafana.url", "The URL of the Grafana annotated when alerts are acknowledged or resolved and rendering the panels of alerts, disabled if empty").
+		Envar("GRAFANA_URL").
+		URLVar(&config.grafana)
+
+	a.Flag("grafana.token", "The API token used to create annotations in Grafana").
+		Envar("GRAFANA_TOKEN").
+		StringVar(&config.grafanaToken)
+
+	a.Flag("grafana.token-file", "The file containing the API token used to create annotations in Grafana").
+		Envar("GRAFANA_TOKEN_FILE").
+		ExistingFileVar(&config.grafanaTokenFile)
+
+	a.Flag("grafana.token-vault", "The vault secret of the API token used to create annotations in Grafana, as path#key").
+		Envar("GRAFANA_TOKEN_VAULT").
+		StringVar(&config.grafanaTokenVault)
+
+	a.Flag("history.retention", "The duration delivered alerts are kept in the alert history listed by /history, 0 keeps them").
+		Envar("HISTORY_RETENTION").
+		Default("168h").
+		DurationVar(&config.historyRetention)
+
+	a.Flag("images", "Send the images and Grafana panels referenced in the annotations of firing alerts as album in reply to their messages").
+		Envar("IMAGES").
+		Default("false").
+		BoolVar(&config.images)
+
+	a.Flag("images.max-size", "The size in bytes of the largest image attached to alerts, larger ones are left out").
+		Envar("IMAGES_MAX_SIZE").
+		Default("5242880").
+		Int64Var(&config.imagesMaxSize)
+
+	a.Flag("images.timeout", "How long the images of an alert are downloaded and rendered, those that take longer are left out").
+		Envar("IMAGES_TIMEOUT").
+		Default("10s").
+		DurationVar(&config.imagesTimeout)
+
+	a.Flag("karma.url", "The URL of the Karma dashboard the alert groups are linked in, default: the Alertmanager UI").
+		Envar("KARMA_URL").
+		URLVar(&config.karmaURL)
+
+	a.Flag("kubernetes.events", "Watch the events of the Kubernetes cluster and deliver those with one of the reasons as alerts").
+		Envar("KUBERNETES_EVENTS").
+		BoolVar(&config.kubernetesEvents)
+
+	a.Flag("kubernetes.url", "The URL of the Kubernetes API, e.g. of kubectl proxy, default: the cluster's API with the pod's service account").
+		Envar("KUBERNETES_URL").
+		URLVar(&config.kubernetesURL)
+
+	a.Flag("kubernetes.namespace", "The namespace the events are watched in, all namespaces if empty").
+		Envar("KUBERNETES_NAMESPACE").
+		StringVar(&config.kubernetesNamespace)
+
+	a.Flag("kubernetes.event-reasons", "The reasons of the events delivered as alerts. Can be repeated").
+		Envar("KUBERNETES_EVENT_REASONS").
+		Default(kubernetes.DefaultReasons...).
+		StringsVar(&config.kubernetesEventReasons)
+
+	a.Flag("leader.election", "Elect a leader among the replicas of the bot with a lock of the consul store, only the leader polls Telegram and sends messages").
+		Envar("LEADER_ELECTION").
+		Default("false").
+		BoolVar(&config.leaderElection)
+
+	a.Flag("leader.key", "The key of the consul store locked by the leader").
+		Envar("LEADER_KEY").
+		Default("alertmanager-bot/leader").
+		StringVar(&config.leaderKey)
+
+	a.Flag("leader.id", "The ID of this replica stored as value of the leader lock, default: the hostname").
+		Envar("LEADER_ID").
+		StringVar(&config.leaderID)
+
+	a.Flag("leader.ttl", "The time after which a leader that can't reach the store loses the lock to a standby").
+		Envar("LEADER_TTL").
+		Default("15s").
+		DurationVar(&config.leaderTTL)
+
 	a.Flag("listen.addr", "The address the alertmanager-bot listens on for incoming webhooks").
 		Required().
 		Envar("LISTEN_ADDR").
 		StringVar(&config.listenAddr)
 
-	a.Flag("log.json", "Tell the application to log json and not key value pairs").
+	a.Flag("log.format", "The format of the logs, json for ingestion into Loki or ELK").
+		Envar("LOG_FORMAT").
+		Default(formatLogfmt).
+		EnumVar(&config.logFormat, formatLogfmt, formatJSON)
+
+	a.Flag("log.json", "Deprecated, use --log.format=json").
 		Envar("LOG_JSON").
+		Hidden().
 		BoolVar(&config.logJSON)
 
 	a.Flag("log.level", "The log level to use for filtering logs").
@@ -97,33 +480,380 @@ func main() {
 		Default(levelInfo).
 		EnumVar(&config.logLevel, levelError, levelWarn, levelInfo, levelDebug)
 
+	a.Flag("loki.url", "The URL of the Loki the recent log lines of firing alerts are queried from, disabled if empty").
+		Envar("LOKI_URL").
+		URLVar(&config.lokiURL)
+
+	a.Flag("loki.label", "Label of alerts matching the label of log streams with its value, as alert_label=loki_label. Can be repeated").
+		Envar("LOKI_LABELS").
+		Default("instance=instance", "namespace=namespace", "pod=pod").
+		StringMapVar(&config.lokiLabels)
+
+	a.Flag("loki.lines", "The number of log lines appended to the messages of firing alerts").
+		Envar("LOKI_LINES").
+		Default("10").
+		IntVar(&config.lokiLines)
+
+	a.Flag("loki.window", "How far back log lines are queried before an alert fired").
+		Envar("LOKI_WINDOW").
+		Default("15m").
+		DurationVar(&config.lokiWindow)
+
+	a.Flag("mirror.service", "The platform copies of the alert and escalation messages are sent to, disabled if empty").
+		Envar("MIRROR_SERVICE").
+		Default("").
+		EnumVar(&config.mirrorService, "", mirrorMatrix, mirrorSlack)
+
+	a.Flag("mirror.url", "The URL of the Matrix homeserver messages are mirrored to").
+		Envar("MIRROR_URL").
+		URLVar(&config.mirrorURL)
+
+	a.Flag("mirror.room", "The ID of the Matrix room messages are mirrored to").
+		Envar("MIRROR_ROOM").
+		StringVar(&config.mirrorRoom)
+
+	a.Flag("mirror.token", "The Matrix access token or the URL of the Slack incoming webhook").
+		Envar("MIRROR_TOKEN").
+		StringVar(&config.mirrorToken)
+
+	a.Flag("mirror.token-file", "The file containing the Matrix access token or the URL of the Slack incoming webhook").
+		Envar("MIRROR_TOKEN_FILE").
+		ExistingFileVar(&config.mirrorTokenFile)
+
+	a.Flag("mirror.token-vault", "The vault secret of the Matrix access token or the URL of the Slack incoming webhook, as path#key").
+		Envar("MIRROR_TOKEN_VAULT").
+		StringVar(&config.mirrorTokenVault)
+
+	a.Flag("notify.resolved", "Send resolved notifications, --no-notify.resolved only stops the escalation of resolved alerts and removes their buttons, whatever the chats chose with /resolved").
+		Envar("NOTIFY_RESOLVED").
+		Default("true").
+		BoolVar(&config.notifyResolved)
+
+	a.Flag("oncall.calendar-url", "The iCalendar URL, e.g. the secret address of a Google Calendar, of the shifts alerts are assigned to the members on call by, disabled if empty").
+		Envar("ONCALL_CALENDAR_URL").
+		StringVar(&config.onCallCalendar)
+
+	a.Flag("oncall.refresh-interval", "Interval in which the on-call calendar is read again").
+		Envar("ONCALL_REFRESH_INTERVAL").
+		Default("15m").
+		DurationVar(&config.onCallInterval)
+
+	a.Flag("oncall.member", "Username of the member attending the on-call calendar's events with an email, as email=username. Can be repeated").
+		Envar("ONCALL_MEMBERS").
+		StringMapVar(&config.onCallMembers)
+
+	a.Flag("outbound.url", "The URL events of alerts are posted to as JSON when they are acknowledged, forwarded, auto-forwarded or resolved. Can be repeated").
+		Envar("OUTBOUND_URLS").
+		StringsVar(&config.outboundURLs)
+
+	a.Flag("outbound.event", "The type of events posted to the outbound webhooks. Can be repeated").
+		Envar("OUTBOUND_EVENTS").
+		Default("acknowledged", "forwarded", "autoforwarded", "resolved").
+		EnumsVar(&config.outboundEvents, "fired", "refired", "acknowledged", "forwarded", "autoforwarded", "exhausted", "resolved", "ticket", "remediation")
+
+	a.Flag("outbound.secret", "The secret the bodies posted to the outbound webhooks are signed with in the X-Alertmanager-Bot-Signature header, unsigned if empty").
+		Envar("OUTBOUND_SECRET").
+		StringVar(&config.outboundSecret)
+
+	a.Flag("outbound.secret-file", "The file containing the secret of the outbound webhooks").
+		Envar("OUTBOUND_SECRET_FILE").
+		ExistingFileVar(&config.outboundSecretFile)
+
+	a.Flag("outbound.secret-vault", "The vault secret of the secret of the outbound webhooks, as path#key").
+		Envar("OUTBOUND_SECRET_VAULT").
+		StringVar(&config.outboundSecretVault)
+
+	a.Flag("outbox", "Record every message in the store before it is sent and retry those Telegram didn't accept, also after a restart").
+		Envar("OUTBOX").
+		Default("false").
+		BoolVar(&config.outbox)
+
+	a.Flag("outbox.max-age", "The age after which undelivered messages of the outbox are given up, 0 retries them forever").
+		Envar("OUTBOX_MAX_AGE").
+		Default("24h").
+		DurationVar(&config.outboxMaxAge)
+
+	a.Flag("pager.service", "The service an incident is triggered in when the escalation of an alert is exhausted without acknowledgement, disabled if empty").
+		Envar("PAGER_SERVICE").
+		Default("").
+		EnumVar(&config.pagerService, "", pagerPagerDuty, pagerOpsgenie)
+
+	a.Flag("pager.url", "The URL of the API of the paging service, default: https://events.pagerduty.com or https://api.opsgenie.com").
+		Envar("PAGER_URL").
+		URLVar(&config.pagerURL)
+
+	a.Flag("pager.key", "The PagerDuty integration key or Opsgenie API key").
+		Envar("PAGER_KEY").
+		StringVar(&config.pagerKey)
+
+	a.Flag("pager.key-file", "The file containing the PagerDuty integration key or Opsgenie API key").
+		Envar("PAGER_KEY_FILE").
+		ExistingFileVar(&config.pagerKeyFile)
+
+	a.Flag("pager.key-vault", "The vault secret of the PagerDuty integration key or Opsgenie API key, as path#key").
+		Envar("PAGER_KEY_VAULT").
+		StringVar(&config.pagerKeyVault)
+
+	a.Flag(configfile.ProfileFlag, "The profile of the configuration file overriding its other options, e.g. staging").
+		Envar("PROFILE").
+		StringVar(&config.profile)
+
+	a.Flag("prometheus.url", "The URL of the Prometheus queried by /graph, /query, /targets and /rules, without it only generatorURLs of alerts can be graphed").
+		Envar("PROMETHEUS_URL").
+		URLVar(&config.prometheus)
+
+	a.Flag("quiet.override-severity", "Severities of alerts that are delivered even during quiet hours and maintenance windows").
+		Envar("QUIET_OVERRIDE_SEVERITY").
+		Default("critical").
+		StringsVar(&config.quietOverrides)
+
+	a.Flag("remediation.file", "The path to the remediation actions run by the Remediate button of alerts after an admin confirmed").
+		Envar("REMEDIATION_FILE").
+		ExistingFileVar(&config.remediationFile)
+
+	a.Flag("routing.file", "The path to the routing configuration mapping alerts to chats, templates and escalation policies").
+		Envar("ROUTING_FILE").
+		ExistingFileVar(&config.routingFile)
+
+	a.Flag("routing.fallback-chat", "The ID of the chat receiving alerts that match no chat because of the routing configuration or the chats' filters").
+		Envar("FALLBACK_CHAT").
+		Int64Var(&config.fallbackChat)
+
+	a.Flag("runbook.file", "The path to the whitelisted actions admins and members request with /run and another admin approves").
+		Envar("RUNBOOK_FILE").
+		ExistingFileVar(&config.runbookFile)
+
+	a.Flag("sentry.dsn", "The Sentry DSN panics and failures that may hide alerts are reported to").
+		Envar("SENTRY_DSN").
+		StringVar(&config.sentryDSN)
+
+	a.Flag("sentry.environment", "The environment reported to Sentry, e.g. production").
+		Envar("SENTRY_ENVIRONMENT").
+		StringVar(&config.sentryEnvironment)
+
+	a.Flag("shard", "Split the chats among the replicas, each replica delivers the alerts of its chats. Requires leader election and a webhook of the Alertmanager for every replica").
+		Envar("SHARD").
+		Default("false").
+		BoolVar(&config.shard)
+
+	a.Flag("shard.key", "The key of the consul store the replicas sharing the chats register under").
+		Envar("SHARD_KEY").
+		Default("alertmanager-bot/shards").
+		StringVar(&config.shardKey)
+
+	a.Flag("shard.ttl", "The time after which the chats of a replica that can't reach the store move to the others").
+		Envar("SHARD_TTL").
+		Default("15s").
+		DurationVar(&config.shardTTL)
+
+	a.Flag("statuspage.page-id", "The ID of the Statuspage page an incident is opened on when an alert of the severities is acknowledged, disabled if empty").
+		Envar("STATUSPAGE_PAGE_ID").
+		StringVar(&config.statusPageID)
+
+	a.Flag("statuspage.url", "The URL of the Statuspage API").
+		Envar("STATUSPAGE_URL").
+		Default("https://api.statuspage.io").
+		URLVar(&config.statusPageURL)
+
+	a.Flag("statuspage.impact", "The impact of the opened incidents, derived from the affected components if empty").
+		Envar("STATUSPAGE_IMPACT").
+		Default("").
+		EnumVar(&config.statusPageImpact, "", "none", "minor", "major", "critical")
+
+	a.Flag("statuspage.severity", "The severity of acknowledged alerts an incident is opened for. Can be repeated").
+		Envar("STATUSPAGE_SEVERITIES").
+		Default("critical").
+		StringsVar(&config.statusPageSeverities)
+
+	a.Flag("statuspage.key", "The Statuspage API key").
+		Envar("STATUSPAGE_KEY").
+		StringVar(&config.statusPageKey)
+
+	a.Flag("statuspage.key-file", "The file containing the Statuspage API key").
+		Envar("STATUSPAGE_KEY_FILE").
+		ExistingFileVar(&config.statusPageKeyFile)
+
+	a.Flag("statuspage.key-vault", "The vault secret of the Statuspage API key, as path#key").
+		Envar("STATUSPAGE_KEY_VAULT").
+		StringVar(&config.statusPageKeyVault)
+
 	a.Flag("store", "The store to use").
 		Required().
 		Envar("STORE").
 		EnumVar(&config.store, storeBolt, storeConsul)
 
-	a.Flag("telegram.admin", "The ID of the initial Telegram Admin").
+	a.Flag("store.chats-cache-ttl", "The time the subscribed chats are cached for between webhooks, 0 to list them from the store for every webhook").
+		Envar("STORE_CHATS_CACHE_TTL").
+		Default("1m").
+		DurationVar(&config.storeChatsCacheTTL)
+
+	a.Flag(telegramAdminFlag, "The ID of the initial Telegram Admin, reloaded from the configuration file unless set by flag or environment variable").
 		Required().
 		Envar("TELEGRAM_ADMIN").
 		IntsVar(&config.telegramAdmins)
 
+	a.Flag("telegram.admin-chat", "The ID of a Telegram group whose members can all issue admin commands in it").
+		Envar("TELEGRAM_ADMIN_CHAT").
+		Int64ListVar(&config.telegramAdminChats)
+
+	a.Flag("telegram.allowed-chat", "The ID of a chat the bot may be used in, if set other chats are refused and groups are left").
+		Envar("TELEGRAM_ALLOWED_CHATS").
+		Int64ListVar(&config.telegramAllowedChats)
+
+	a.Flag("telegram.bot", "An additional bot sharing the stores, as name=token, e.g. a paging bot next to a noisy one. It has its own chats and receives webhooks at /bots/<name>. Can be repeated").
+		Envar("TELEGRAM_BOTS").
+		StringMapVar(&config.telegramBots)
+
+	a.Flag("telegram.bot-chat-send-rate", "The messages per minute an additional bot sends and edits in each chat, as name=rate, default: --telegram.chat-send-rate. Can be repeated").
+		Envar("TELEGRAM_BOT_CHAT_SEND_RATES").
+		StringMapVar(&config.botChatSendRates)
+
+	a.Flag("telegram.bot-send-rate", "The messages per second an additional bot sends and edits in all chats together, as name=rate, default: --telegram.send-rate. Can be repeated").
+		Envar("TELEGRAM_BOT_SEND_RATES").
+		StringMapVar(&config.botSendRates)
+
+	a.Flag("telegram.chat-admins", "Let the administrators of a group manage the members and nodes of their group").
+		Envar("TELEGRAM_CHAT_ADMINS").
+		BoolVar(&config.telegramChatAdmins)
+
+	a.Flag("telegram.chat-send-rate", "The messages per minute the bot sends and edits in each chat, 0 for no limit").
+		Envar("TELEGRAM_CHAT_SEND_RATE").
+		Default("20").
+		Float64Var(&config.telegramChatSendRate)
+
+	a.Flag("telegram.delivery-workers", "The number of chats the alerts of a webhook are delivered to at once").
+		Envar("TELEGRAM_DELIVERY_WORKERS").
+		Default("8").
+		IntVar(&config.telegramDeliveryWorkers)
+
+	a.Flag("telegram.errors-chat", "The ID of the chat the bot posts its own failures to that may hide alerts").
+		Envar("TELEGRAM_ERRORS_CHAT").
+		Int64Var(&config.telegramErrorsChat)
+
+	a.Flag("telegram.notify-forbidden", "Send the admins a summary of messages dropped from forbidden senders").
+		Envar("TELEGRAM_NOTIFY_FORBIDDEN").
+		BoolVar(&config.telegramNotifyForbidden)
+
+	a.Flag("telegram.read-only-commands", "Allow the read-only commands /alerts, /acked, /silences, /status, /version, /targets, /rules, /history, /stats and /help from any sender").
+		Envar("TELEGRAM_READ_ONLY_COMMANDS").
+		BoolVar(&config.telegramReadOnly)
+
+	a.Flag("telegram.send-rate", "The messages per second the bot sends and edits in all chats together, 0 for no limit").
+		Envar("TELEGRAM_SEND_RATE").
+		Default("25").
+		Float64Var(&config.telegramSendRate)
+
 	a.Flag("telegram.token", "The token used to connect with Telegram").
-		Required().
 		Envar("TELEGRAM_TOKEN").
 		StringVar(&config.telegramToken)
 
-	a.Flag("template.paths", "The paths to the template").
+	a.Flag("telegram.token-file", "The file containing the token used to connect with Telegram").
+		Envar("TELEGRAM_TOKEN_FILE").
+		ExistingFileVar(&config.telegramTokenFile)
+
+	a.Flag("telegram.token-vault", "The vault secret of the token used to connect with Telegram, as path#key").
+		Envar("TELEGRAM_TOKEN_VAULT").
+		StringVar(&config.telegramTokenVault)
+
+	a.Flag("template.paths", "The paths to templates overriding the built-in ones").
 		Envar("TEMPLATE_PATHS").
 		Default("./default.tmpl").
 		ExistingFilesVar(&config.templatesPaths)
 
-	_, err := a.Parse(os.Args[1:])
-	if err != nil {
-		fmt.Printf("error parsing commandline arguments: %v\n", err)
-		a.Usage(os.Args[1:])
-		os.Exit(2)
+	a.Flag("template.reload-interval", "Interval in which the template, routing and configuration files are checked for changes, e.g. of their ConfigMap, and reloaded, 0 only reloads them on SIGHUP").
+		Envar("TEMPLATE_RELOAD_INTERVAL").
+		Default("30s").
+		DurationVar(&config.templatesReloadInterval)
+
+	a.Flag("template.receiver", "Template used for the alerts of a receiver, as receiver=template. Can be repeated").
+		Envar("TEMPLATE_RECEIVERS").
+		StringMapVar(&config.receiverTemplates)
+
+	a.Flag("ticket.tracker", "The tracker the Create ticket button of alerts creates issues in, disabled if empty").
+		Envar("TICKET_TRACKER").
+		Default("").
+		EnumVar(&config.ticketTracker, "", trackerJira, trackerGitHub)
+
+	a.Flag("ticket.url", "The URL of Jira or of the GitHub API, default: https://api.github.com for GitHub").
+		Envar("TICKET_URL").
+		URLVar(&config.ticketURL)
+
+	a.Flag("ticket.project", "The key of the Jira project or the GitHub repository as owner/name issues are created in").
+		Envar("TICKET_PROJECT").
+		StringVar(&config.ticketProject)
+
+	a.Flag("ticket.issue-type", "The type of the issues created in Jira").
+		Envar("TICKET_ISSUE_TYPE").
+		Default("Task").
+		StringVar(&config.ticketIssueType)
+
+	a.Flag("ticket.user", "The email of the Jira Cloud user of the token, without it the token is used as personal access token").
+		Envar("TICKET_USER").
+		StringVar(&config.ticketUser)
+
+	a.Flag("ticket.token", "The API token used to create issues in the tracker").
+		Envar("TICKET_TOKEN").
+		StringVar(&config.ticketToken)
+
+	a.Flag("ticket.token-file", "The file containing the API token used to create issues in the tracker").
+		Envar("TICKET_TOKEN_FILE").
+		ExistingFileVar(&config.ticketTokenFile)
+
+	a.Flag("ticket.token-vault", "The vault secret of the API token used to create issues in the tracker, as path#key").
+		Envar("TICKET_TOKEN_VAULT").
+		StringVar(&config.ticketTokenVault)
+
+	a.Flag("tracing.otlp-endpoint", "The OTLP/HTTP endpoint traces of the webhook deliveries are exported to, e.g. http://tempo:4318").
+		Envar("OTEL_EXPORTER_OTLP_ENDPOINT").
+		URLVar(&config.tracingEndpoint)
+
+	a.Flag("tracing.service-name", "The service name of the exported traces").
+		Envar("OTEL_SERVICE_NAME").
+		Default("alertmanager-bot").
+		StringVar(&config.tracingService)
+
+	a.Flag("vault.addr", "The address of the HashiCorp Vault secrets are looked up in").
+		Envar("VAULT_ADDR").
+		URLVar(&config.vaultAddr)
+
+	a.Flag("vault.token", "The token used to connect to the vault").
+		Envar("VAULT_TOKEN").
+		StringVar(&config.vaultToken)
+
+	a.Flag("vault.token-file", "The file containing the token used to connect to the vault").
+		Envar("VAULT_TOKEN_FILE").
+		ExistingFileVar(&config.vaultTokenFile)
+
+	a.Flag("watchdog.alertname", "The alert that is expected to keep firing as heartbeat of the alerting pipeline, e.g. Watchdog").
+		Envar("WATCHDOG_ALERTNAME").
+		StringVar(&config.watchdogAlertname)
+
+	a.Flag("watchdog.chat", "The ID of a chat warned when the watchdog alert stops arriving, the admins if none").
+		Envar("WATCHDOG_CHATS").
+		Int64ListVar(&config.watchdogChats)
+
+	a.Flag("watchdog.timeout", "The duration after which a watchdog alert that didn't arrive is missing").
+		Envar("WATCHDOG_TIMEOUT").
+		Default("10m").
+		DurationVar(&config.watchdogTimeout)
+
+	// The flags above are shared by the commands, serve runs without a command
+	a.Command("serve", "Run the bot, receiving webhooks and commands").Default()
+	cmds := &commands{
+		checkConfig: a.Command("check-config", "Validate the configuration file, templates, routing, runbook and remediation files without connecting anywhere"),
+		migrate:     a.Command("migrate", "Migrate the data in the store to the layout of this version, e.g. ahead of a rollout"),
+		send:        a.Command("send", "Send a test message or example alert to a chat"),
 	}
+	cmds.sendChat = cmds.send.Arg("chat", "The ID of the chat").Required().Int64()
+	cmds.sendMessage = cmds.send.Arg("message", "The message to send, formatted as HTML").Default("Test message from the alertmanager-bot").String()
+	cmds.sendAlert = cmds.send.Flag("alert", "Send an example alert rendered with the template instead of the message").Bool()
+	cmds.sendTemplate = cmds.send.Flag("template", "The template the example alert is rendered with").Default("telegram.default").String()
 
+	return a, cmds
+}
+
+// newLogger creates the logger with the configured level and format
+func newLogger(config *configuration) log.Logger {
 	levelFilter := map[string]level.Option{
 		levelError: level.AllowError(),
 		levelWarn:  level.AllowWarn(),
@@ -131,8 +861,12 @@ func main() {
 		levelDebug: level.AllowDebug(),
 	}
 
-	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
 	if config.logJSON {
+		config.logFormat = formatJSON
+	}
+
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	if config.logFormat == formatJSON {
 		logger = log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
 	}
 
@@ -141,107 +875,931 @@ func main() {
 		"ts", log.DefaultTimestampUTC,
 		"caller", log.DefaultCaller,
 	)
+	return logger
+}
 
-	var tmpl *template.Template
-	{
-		funcs := template.DefaultFuncs
-		funcs["since"] = func(t time.Time) string {
-			return durafmt.Parse(time.Since(t)).String()
+// additionalBots validates the additional bots and returns their send rates and chat send rates
+func additionalBots(config *configuration, logger log.Logger) (map[string]float64, map[string]float64) {
+	for name, token := range config.telegramBots {
+		if !botName.MatchString(name) {
+			level.Error(logger).Log("msg", "the names of bots may only have lowercase letters, digits, - and _", "bot", name)
+			os.Exit(1)
 		}
-		funcs["duration"] = func(start time.Time, end time.Time) string {
-			return durafmt.Parse(end.Sub(start)).String()
+		if token == "" {
+			level.Error(logger).Log("msg", "please provide the telegram token of the bot as name=token", "bot", name)
+			os.Exit(1)
 		}
+	}
+	sendRates, err := botRates(config.telegramBots, config.botSendRates, config.telegramSendRate)
+	if err != nil {
+		level.Error(logger).Log("msg", "invalid send rates of bots", "err", err)
+		os.Exit(1)
+	}
+	chatSendRates, err := botRates(config.telegramBots, config.botChatSendRates, config.telegramChatSendRate)
+	if err != nil {
+		level.Error(logger).Log("msg", "invalid chat send rates of bots", "err", err)
+		os.Exit(1)
+	}
 
-		template.DefaultFuncs = funcs
+	return sendRates, chatSendRates
+}
 
-		tmpl, err = template.FromGlobs(config.templatesPaths...)
+// resolveSecrets reads the credentials that are given as files or vault secrets,
+// the Telegram token is required unless requireToken is false
+func resolveSecrets(config *configuration, logger log.Logger, requireToken bool) {
+	var vault *secret.Vault
+	if config.vaultAddr != nil {
+		token, err := secret.Resolve(config.vaultToken, config.vaultTokenFile, "", nil)
 		if err != nil {
-			level.Error(logger).Log("msg", "failed to parse templates", "err", err)
+			level.Error(logger).Log("msg", "failed to read vault token", "err", err)
 			os.Exit(1)
 		}
-		tmpl.ExternalURL = config.alertmanager
+		vault = secret.NewVault(config.vaultAddr, token)
 	}
 
-	var kvStore store.Store
-	{
-		switch strings.ToLower(config.store) {
-		case storeBolt:
-			kvStore, err = boltdb.New([]string{config.boltPath}, &store.Config{Bucket: "alertmanager"})
-			if err != nil {
-				level.Error(logger).Log("msg", "failed to create bolt store backend", "err", err)
-				os.Exit(1)
-			}
-		case storeConsul:
-			kvStore, err = consul.New([]string{config.consul.String()}, nil)
-			if err != nil {
-				level.Error(logger).Log("msg", "failed to create consul store backend", "err", err)
-				os.Exit(1)
-			}
-		default:
-			level.Error(logger).Log("msg", "please provide one of the following supported store backends: bolt, consul")
-			os.Exit(1)
-		}
+	var err error
+	config.telegramToken, err = secret.Resolve(config.telegramToken, config.telegramTokenFile, config.telegramTokenVault, vault)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read telegram token", "err", err)
+		os.Exit(1)
+	}
+	if requireToken && config.telegramToken == "" {
+		level.Error(logger).Log("msg", "please provide the telegram token with --telegram.token, --telegram.token-file or --telegram.token-vault")
+		os.Exit(1)
 	}
-	defer kvStore.Close()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	config.consulToken, err = secret.Resolve(config.consulToken, config.consulTokenFile, config.consulTokenVault, vault)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read consul token", "err", err)
+		os.Exit(1)
+	}
 
-	// TODO Needs fan out for multiple bots
-	webhooks := make(chan notify.WebhookMessage, 32)
+	config.grafanaToken, err = secret.Resolve(config.grafanaToken, config.grafanaTokenFile, config.grafanaTokenVault, vault)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read grafana token", "err", err)
+		os.Exit(1)
+	}
 
-	var g run.Group
-	{
-		tlogger := log.With(logger, "component", "telegram")
+	config.ticketToken, err = secret.Resolve(config.ticketToken, config.ticketTokenFile, config.ticketTokenVault, vault)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read ticket tracker token", "err", err)
+		os.Exit(1)
+	}
+
+	config.pagerKey, err = secret.Resolve(config.pagerKey, config.pagerKeyFile, config.pagerKeyVault, vault)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read pager key", "err", err)
+		os.Exit(1)
+	}
+
+	config.statusPageKey, err = secret.Resolve(config.statusPageKey, config.statusPageKeyFile, config.statusPageKeyVault, vault)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read statuspage key", "err", err)
+		os.Exit(1)
+	}
+
+	config.deployToken, err = secret.Resolve(config.deployToken, config.deployTokenFile, config.deployTokenVault, vault)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read deploy token", "err", err)
+		os.Exit(1)
+	}
+
+	config.mirrorToken, err = secret.Resolve(config.mirrorToken, config.mirrorTokenFile, config.mirrorTokenVault, vault)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read mirror token", "err", err)
+		os.Exit(1)
+	}
+
+	config.outboundSecret, err = secret.Resolve(config.outboundSecret, config.outboundSecretFile, config.outboundSecretVault, vault)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read outbound webhook secret", "err", err)
+		os.Exit(1)
+	}
+}
+
+// loadTemplates parses the message templates, at startup and on every reload
+func loadTemplates(config *configuration) (*telegram.Templates, error) {
+	t, err := telegram.LoadTemplates(config.templatesPaths...)
+	if err != nil {
+		return nil, err
+	}
+	t.ExternalURL = config.alertmanager
+	return t, nil
+}
 
-		chats, err := telegram.NewChatStore(kvStore)
+// openStore creates the configured store backend
+func openStore(config *configuration, logger log.Logger) store.Store {
+	var kvStore store.Store
+	var err error
+	switch strings.ToLower(config.store) {
+	case storeBolt:
+		kvStore, err = boltdb.New([]string{config.boltPath}, &store.Config{Bucket: "alertmanager"})
 		if err != nil {
-			level.Error(logger).Log("msg", "failed to create chat store", "err", err)
+			level.Error(logger).Log("msg", "failed to create bolt store backend", "err", err)
 			os.Exit(1)
 		}
-
-		// Key/Value store for saving members of chats
-		members, err := telegram.NewMemberStore(kvStore)
+	case storeConsul:
+		// The consul client reads its ACL token from the environment
+		if config.consulToken != "" {
+			os.Setenv("CONSUL_HTTP_TOKEN", config.consulToken)
+		}
+		kvStore, err = consul.New([]string{config.consul.String()}, nil)
 		if err != nil {
-			level.Error(logger).Log("msg", "failed to create chat store", "err", err)
+			level.Error(logger).Log("msg", "failed to create consul store backend", "err", err)
 			os.Exit(1)
 		}
+	default:
+		level.Error(logger).Log("msg", "please provide one of the following supported store backends: bolt, consul")
+		os.Exit(1)
+	}
 
-		// Key/Value store for saving node exported info
-		nodes, err := telegram.NewNodeStore(kvStore)
+	kvStore, err = telegram.NewInstrumentedStore(kvStore)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to instrument store backend", "err", err)
+		os.Exit(1)
+	}
+	return kvStore
+}
+
+// runCheckConfig validates the configuration without connecting anywhere
+func runCheckConfig(config *configuration, file *configfile.File, logger log.Logger) {
+	additionalBots(config, logger)
+
+	var routing *telegram.RoutingConfig
+	var err error
+	if file != nil {
+		routing = file.Routing
+	}
+	if config.routingFile != "" {
+		routing, err = telegram.LoadRoutingConfigFile(config.routingFile)
 		if err != nil {
-			level.Error(logger).Log("msg", "failed to create chat store", "err", err)
+			level.Error(logger).Log("msg", "invalid routing configuration", "err", err)
 			os.Exit(1)
 		}
+	}
+	if err := checkConfig(config.templatesPaths, config.receiverTemplates, routing, config.runbookFile, config.remediationFile); err != nil {
+		level.Error(logger).Log("msg", "invalid configuration", "err", err)
+		os.Exit(1)
+	}
+	level.Info(logger).Log("msg", "configuration is valid")
+}
 
-		bot, err := telegram.NewBot(
-			chats, members, nodes, config.telegramToken, config.telegramAdmins[0],
-			telegram.WithLogger(tlogger),
-			telegram.WithAddr(config.listenAddr),
-			telegram.WithAlertmanager(config.alertmanager),
-			telegram.WithTemplates(tmpl),
-			telegram.WithRevision(Revision),
-			telegram.WithStartTime(StartTime),
-			telegram.WithExtraAdmins(config.telegramAdmins[1:]...),
-		)
-		if err != nil {
-			level.Error(tlogger).Log("msg", "failed to create bot", "err", err)
-			os.Exit(2)
-		}
+// runMigrate migrates the data in the store to the layout of this version
+func runMigrate(config *configuration, logger log.Logger) {
+	resolveSecrets(config, logger, false)
+	kvStore := openStore(config, logger)
+	defer kvStore.Close()
 
-		g.Add(func() error {
-			level.Info(tlogger).Log(
-				"msg", "starting alertmanager-bot",
-				"version", Version,
-				"revision", Revision,
-				"buildDate", BuildDate,
-				"goVersion", GoVersion,
-			)
+	if err := telegram.Migrate(kvStore); err != nil {
+		level.Error(logger).Log("msg", "failed to migrate store", "err", err)
+		os.Exit(1)
+	}
+	level.Info(logger).Log("msg", "migrated store", "store", config.store)
+}
+
+// runSend sends a test message or example alert to a chat
+func runSend(config *configuration, cmds *commands, logger log.Logger) {
+	resolveSecrets(config, logger, true)
+	tmpl, err := loadTemplates(config)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to parse templates", "err", err)
+		os.Exit(1)
+	}
+
+	text, mode := *cmds.sendMessage, telebot.ModeHTML
+	if *cmds.sendAlert {
+		text, mode, err = telegram.RenderSample(tmpl, *cmds.sendTemplate)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to render example alert", "template", *cmds.sendTemplate, "err", err)
+			os.Exit(1)
+		}
+	}
+	bot, err := telebot.NewBot(config.telegramToken)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to connect to telegram", "err", err)
+		os.Exit(1)
+	}
+	if _, err := bot.SendMessage(telebot.Chat{ID: *cmds.sendChat}, text, &telebot.SendOptions{ParseMode: mode}); err != nil {
+		level.Error(logger).Log("msg", "failed to send message", "chat_id", *cmds.sendChat, "err", err)
+		os.Exit(1)
+	}
+	level.Info(logger).Log("msg", "sent message", "chat_id", *cmds.sendChat)
+}
+
+// runServe runs the bots, receiving webhooks and commands until it is interrupted
+func runServe(config *configuration, a *kingpin.Application, file *configfile.File, overridden func(name string) bool, logger log.Logger) {
+	botSendRates, botChatSendRates := additionalBots(config, logger)
+	resolveSecrets(config, logger, true)
+	tmpl, err := loadTemplates(config)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to parse templates", "err", err)
+		os.Exit(1)
+	}
+	kvStore := openStore(config, logger)
+	defer kvStore.Close()
+
+	// Without leader election every replica sends the messages
+	var elector *leader.Elector
+	if config.leaderElection {
+		if config.leaderID == "" {
+			config.leaderID, _ = os.Hostname()
+		}
+		elector = leader.New(kvStore, config.leaderKey, config.leaderID, config.leaderTTL, log.With(logger, "component", "leader"))
+		prometheus.MustRegister(elector.Collector())
+	}
+
+	// With shards every replica delivers the alerts of its chats, only the leader polls Telegram
+	var shards *shard.Shard
+	if config.shard {
+		if elector == nil {
+			level.Error(logger).Log("msg", "sharding the chats requires --leader.election")
+			os.Exit(1)
+		}
+		shards = shard.New(kvStore, config.shardKey, config.leaderID, config.shardTTL, log.With(logger, "component", "shard"))
+		prometheus.MustRegister(shards.Collector())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Every additional bot receives the webhooks at its own path
+	webhooks := make(chan alertmanager.Webhook, 32)
+	botWebhooks := make(map[string]chan alertmanager.Webhook, len(config.telegramBots))
+	for name := range config.telegramBots {
+		botWebhooks[name] = make(chan alertmanager.Webhook, 32)
+	}
+
+	// Tracing is disabled without an OTLP endpoint
+	var tracer *tracing.Tracer
+	if config.tracingEndpoint != nil {
+		tracer = tracing.NewTracer(config.tracingService, config.tracingEndpoint, log.With(logger, "component", "tracing"))
+	}
+
+	// Error reporting is disabled without a Sentry DSN
+	var sentryClient *sentry.Client
+	if config.sentryDSN != "" {
+		release := Version
+		if release == "" {
+			release = Revision
+		}
+		sentryClient, err = sentry.New(config.sentryDSN, release, config.sentryEnvironment, log.With(logger, "component", "sentry"))
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to create sentry client", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	// Annotations are disabled without a Grafana URL
+	var grafanaClient *grafana.Client
+	if config.grafana != nil {
+		grafanaClient = grafana.New(config.grafana, config.grafanaToken, log.With(logger, "component", "grafana"))
+	}
+
+	// The Create ticket button is hidden without a tracker
+	var tracker ticket.Tracker
+	switch config.ticketTracker {
+	case trackerJira:
+		if config.ticketURL == nil || config.ticketProject == "" {
+			level.Error(logger).Log("msg", "please provide the Jira URL and project with --ticket.url and --ticket.project")
+			os.Exit(1)
+		}
+		tracker = &ticket.Jira{
+			URL:       config.ticketURL,
+			Project:   config.ticketProject,
+			IssueType: config.ticketIssueType,
+			User:      config.ticketUser,
+			Token:     config.ticketToken,
+		}
+	case trackerGitHub:
+		if config.ticketURL == nil {
+			config.ticketURL, _ = url.Parse("https://api.github.com")
+		}
+		if strings.Count(config.ticketProject, "/") != 1 {
+			level.Error(logger).Log("msg", "please provide the GitHub repository as owner/name with --ticket.project")
+			os.Exit(1)
+		}
+		tracker = &ticket.GitHub{
+			URL:        config.ticketURL,
+			Repository: config.ticketProject,
+			Token:      config.ticketToken,
+		}
+	}
+
+	// Nobody is paged outside of Telegram without a paging service
+	var pagerClient *pager.Client
+	if config.pagerService != "" {
+		if config.pagerKey == "" {
+			level.Error(logger).Log("msg", "please provide the key of the paging service with --pager.key, --pager.key-file or --pager.key-vault")
+			os.Exit(1)
+		}
+		var service pager.Service
+		switch config.pagerService {
+		case pagerPagerDuty:
+			if config.pagerURL == nil {
+				config.pagerURL, _ = url.Parse("https://events.pagerduty.com")
+			}
+			service = &pager.PagerDuty{URL: config.pagerURL, RoutingKey: config.pagerKey}
+		case pagerOpsgenie:
+			if config.pagerURL == nil {
+				config.pagerURL, _ = url.Parse("https://api.opsgenie.com")
+			}
+			service = &pager.Opsgenie{URL: config.pagerURL, APIKey: config.pagerKey}
+		}
+		pagerClient = pager.New(service, log.With(logger, "component", "pager"))
+	}
+
+	// Messages are only sent to Telegram without a mirror
+	var mirrorClient *mirror.Client
+	if config.mirrorService != "" {
+		if config.mirrorToken == "" {
+			level.Error(logger).Log("msg", "please provide the Matrix access token or Slack webhook URL with --mirror.token, --mirror.token-file or --mirror.token-vault")
+			os.Exit(1)
+		}
+		var notifier mirror.Notifier
+		switch config.mirrorService {
+		case mirrorMatrix:
+			if config.mirrorURL == nil || config.mirrorRoom == "" {
+				level.Error(logger).Log("msg", "please provide the Matrix homeserver with --mirror.url and the room with --mirror.room")
+				os.Exit(1)
+			}
+			notifier = &mirror.Matrix{URL: config.mirrorURL, Room: config.mirrorRoom, AccessToken: config.mirrorToken}
+		case mirrorSlack:
+			notifier = &mirror.Slack{WebhookURL: config.mirrorToken}
+		}
+		mirrorClient = mirror.New(notifier, log.With(logger, "component", "mirror"))
+	}
+
+	// Events are only posted with outbound webhook URLs
+	var outboundClient *outbound.Client
+	if len(config.outboundURLs) > 0 {
+		outboundClient = outbound.New(config.outboundURLs, config.outboundSecret, log.With(logger, "component", "outbound"))
+	}
+
+	var g run.Group
+	if elector != nil {
+		ectx, ecancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return elector.Run(ectx)
+		}, func(err error) {
+			ecancel()
+		})
+	}
+	if shards != nil {
+		sctx, scancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return shards.Run(sctx)
+		}, func(err error) {
+			scancel()
+		})
+	}
+	if tracer != nil {
+		tctx, tcancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return tracer.Run(tctx)
+		}, func(err error) {
+			tcancel()
+		})
+	}
+	if sentryClient != nil {
+		sctx, scancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return sentryClient.Run(sctx)
+		}, func(err error) {
+			scancel()
+		})
+	}
+	if pagerClient != nil {
+		pctx, pcancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return pagerClient.Run(pctx)
+		}, func(err error) {
+			pcancel()
+		})
+	}
+	if mirrorClient != nil {
+		mctx, mcancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return mirrorClient.Run(mctx)
+		}, func(err error) {
+			mcancel()
+		})
+	}
+	if outboundClient != nil {
+		octx, ocancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return outboundClient.Run(octx)
+		}, func(err error) {
+			ocancel()
+		})
+	}
+	if grafanaClient != nil {
+		gctx, gcancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return grafanaClient.Run(gctx)
+		}, func(err error) {
+			gcancel()
+		})
+	}
+	if config.kubernetesEvents {
+		klogger := log.With(logger, "component", "kubernetes")
 
-			// Runs the bot itself communicating with Telegram
-			return bot.Run(ctx, webhooks)
+		var watcher *kubernetes.Watcher
+		if config.kubernetesURL != nil {
+			watcher = kubernetes.NewWatcher(config.kubernetesURL, "", http.DefaultClient, config.kubernetesNamespace, config.kubernetesEventReasons, klogger)
+		} else {
+			watcher, err = kubernetes.InClusterWatcher(config.kubernetesNamespace, config.kubernetesEventReasons, klogger)
+			if err != nil {
+				level.Error(logger).Log("msg", "failed to watch kubernetes events", "err", err)
+				os.Exit(1)
+			}
+		}
+
+		kctx, kcancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			// Only the leader delivers the events, every shard those of its chats
+			if elector != nil && shards == nil {
+				select {
+				case <-elector.Elected():
+				case <-kctx.Done():
+					return nil
+				}
+			}
+			level.Info(klogger).Log("msg", "watching kubernetes events", "namespace", config.kubernetesNamespace)
+			return watcher.Run(kctx, webhooks)
 		}, func(err error) {
+			kcancel()
+		})
+	}
+	{
+		tlogger := log.With(logger, "component", "telegram")
+
+		// Key/Value store for saving members of chats
+		members, err := telegram.NewMemberStore(kvStore)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to create chat store", "err", err)
+			os.Exit(1)
+		}
+
+		// Key/Value store for saving node exported info
+		nodes, err := telegram.NewNodeStore(kvStore)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to create chat store", "err", err)
+			os.Exit(1)
+		}
+
+		// Key/Value store for saving the settings of chats
+		settings, err := telegram.NewSettingsStore(kvStore)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to create settings store", "err", err)
+			os.Exit(1)
+		}
+
+		// Key/Value store for saving teams
+		teams, err := telegram.NewTeamStore(kvStore)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to create team store", "err", err)
+			os.Exit(1)
+		}
+
+		// Key/Value store for the audit log of executed commands
+		audit, err := telegram.NewAuditStore(kvStore, config.auditMaxEntries)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to create audit store", "err", err)
+			os.Exit(1)
+		}
+
+		// Key/Value store for the history of delivered alerts
+		history, err := telegram.NewHistoryStore(kvStore, config.historyRetention)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to create history store", "err", err)
+			os.Exit(1)
+		}
+
+		// Key/Value store for the admins added with /addadmin
+		admins, err := telegram.NewAdminStore(kvStore)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to create admin store", "err", err)
+			os.Exit(1)
+		}
+
+		// Key/Value store for the users banned with /ban
+		bans, err := telegram.NewBanStore(kvStore)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to create ban store", "err", err)
+			os.Exit(1)
+		}
+
+		opts := []telegram.BotOption{
+			telegram.WithAddr(config.listenAddr),
+			telegram.WithAlertmanager(config.alertmanager),
+			telegram.WithPrometheus(config.prometheus),
+			telegram.WithTemplates(tmpl),
+			telegram.WithRevision(Revision),
+			telegram.WithBuildInfo(buildInfo()),
+			telegram.WithStartTime(StartTime),
+			telegram.WithExtraAdmins(config.telegramAdmins[1:]...),
+			telegram.WithAdminChats(config.telegramAdminChats...),
+			telegram.WithReadOnlyCommands(config.telegramReadOnly),
+			telegram.WithChatAdmins(config.telegramChatAdmins),
+			telegram.WithAllowedChats(config.telegramAllowedChats...),
+			telegram.WithForbiddenNotices(config.telegramNotifyForbidden),
+			telegram.WithQuietOverrides(config.quietOverrides...),
+			telegram.WithCooldown(config.alertCooldown),
+			telegram.WithDeliveryWorkers(config.telegramDeliveryWorkers),
+			telegram.WithSuppressResolved(config.alertSuppressResolved),
+			telegram.WithResolvedNotifications(config.notifyResolved),
+			telegram.WithDedupWindow(config.alertDedupWindow),
+			telegram.WithMaxOpenAlerts(config.alertMaxOpen),
+			telegram.WithReceiverTemplates(config.receiverTemplates),
+			telegram.WithTeams(teams),
+			telegram.WithAudit(audit),
+			telegram.WithHistory(history),
+			telegram.WithAdminStore(admins),
+			telegram.WithBans(bans),
+			telegram.WithFallbackChat(config.fallbackChat),
+			telegram.WithErrorsChat(config.telegramErrorsChat),
+			telegram.WithTracer(tracer),
+			telegram.WithSentry(sentryClient),
+			telegram.WithGrafana(grafanaClient),
+			telegram.WithTicketTracker(tracker),
+			telegram.WithPager(pagerClient),
+			telegram.WithMirror(mirrorClient),
+		}
+
+		if config.watchdogAlertname != "" {
+			opts = append(opts, telegram.WithWatchdog(config.watchdogAlertname, config.watchdogTimeout, config.watchdogChats...))
+		}
+
+		if config.statusPageID != "" {
+			if config.statusPageKey == "" {
+				level.Error(logger).Log("msg", "please provide the Statuspage API key with --statuspage.key, --statuspage.key-file or --statuspage.key-vault")
+				os.Exit(1)
+			}
+			opts = append(opts, telegram.WithStatusPage(&statuspage.Client{
+				URL:    config.statusPageURL,
+				PageID: config.statusPageID,
+				APIKey: config.statusPageKey,
+				Impact: config.statusPageImpact,
+			}, config.statusPageSeverities...))
+		}
+
+		if config.deployProvider != "" {
+			var source deploy.Source
+			switch config.deployProvider {
+			case deployGitHub:
+				if config.deployURL == nil {
+					config.deployURL, _ = url.Parse("https://api.github.com")
+				}
+				source = &deploy.GitHub{URL: config.deployURL, Token: config.deployToken}
+			case deployGitLab:
+				if config.deployURL == nil {
+					config.deployURL, _ = url.Parse("https://gitlab.com")
+				}
+				source = &deploy.GitLab{URL: config.deployURL, Token: config.deployToken}
+			}
+			opts = append(opts, telegram.WithDeployments(source, config.deployLabel, config.deployRepositories, config.deployEnvironment, config.deployWindow))
+		}
+
+		if config.karmaURL != nil {
+			opts = append(opts, telegram.WithKarma(config.karmaURL))
+		}
+
+		if config.images {
+			opts = append(opts, telegram.WithAlertImages(config.imagesMaxSize, config.imagesTimeout))
+		}
+
+		if config.lokiURL != nil {
+			opts = append(opts, telegram.WithLoki(loki.New(config.lokiURL), config.lokiLabels, config.lokiLines, config.lokiWindow))
+		}
+
+		if outboundClient != nil {
+			opts = append(opts, telegram.WithOutboundWebhooks(outboundClient, config.outboundEvents...))
+		}
+
+		if config.onCallCalendar != "" {
+			opts = append(opts, telegram.WithOnCallCalendar(config.onCallCalendar, config.onCallInterval, config.onCallMembers))
+		}
+
+		if config.remediationFile != "" {
+			remediations, err := remediate.LoadFile(config.remediationFile)
+			if err != nil {
+				level.Error(logger).Log("msg", "failed to load remediation actions", "err", err)
+				os.Exit(1)
+			}
+			opts = append(opts, telegram.WithRemediations(remediations))
+		}
+
+		if config.runbookFile != "" {
+			actions, err := runbook.LoadFile(config.runbookFile)
+			if err != nil {
+				level.Error(logger).Log("msg", "failed to load runbook", "err", err)
+				os.Exit(1)
+			}
+			opts = append(opts, telegram.WithRunbook(actions))
+		}
+
+		var router *telegram.Router
+		if config.routingFile != "" || (file != nil && file.Routing != nil) {
+			load := func() (*telegram.RoutingConfig, error) {
+				return telegram.LoadRoutingConfigFile(config.routingFile)
+			}
+			if config.routingFile == "" {
+				// The inline routing configuration is reloaded from the configuration file,
+				// its other options only change on restart
+				load = func() (*telegram.RoutingConfig, error) {
+					f, err := configfile.LoadFile(config.configFile, a.Model().Flags, config.profile)
+					if err != nil {
+						return nil, err
+					}
+					if f.Routing == nil {
+						return nil, fmt.Errorf("the configuration file %s has no routing configuration anymore", config.configFile)
+					}
+					return f.Routing, nil
+				}
+			}
+			router, err = telegram.NewRouterFunc(load)
+			if err != nil {
+				level.Error(logger).Log("msg", "failed to load routing configuration", "err", err)
+				os.Exit(1)
+			}
+			opts = append(opts, telegram.WithRouter(router))
+		}
+
+		reloadSuccessful := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "alertmanagerbot",
+			Name:      "config_last_reload_successful",
+			Help:      "Whether the last reload of the configuration file, routing configuration and templates succeeded",
+		})
+		reloadSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "alertmanagerbot",
+			Name:      "config_last_reload_success_timestamp_seconds",
+			Help:      "Time of the last successful reload of the configuration file, routing configuration and templates",
+		})
+		prometheus.MustRegister(reloadSuccessful, reloadSuccess)
+		reloadSuccessful.Set(1)
+		reloadSuccess.SetToCurrentTime()
+
+		// reload loads the configuration file, the routing configuration with its escalation policies and the templates
+		// on SIGHUP, /reload and changes of their files, and only applies them if all of them are valid and
+		// no option of the configuration file needing a restart changed
+		var bots []*telegram.Bot
+		var reloadMu sync.Mutex
+		reload := func() error {
+			reloadMu.Lock()
+			defer reloadMu.Unlock()
+
+			reloadSuccessful.Set(0)
+			// The admins follow the configuration file unless the flag or its environment variable sets them
+			admins := config.telegramAdmins
+			if config.configFile != "" {
+				f, err := configfile.LoadFile(config.configFile, a.Model().Flags, config.profile)
+				if err != nil {
+					return fmt.Errorf("invalid configuration file: %v", err)
+				}
+				// Only the admins are applied, the other options would be silently ignored until the restart
+				changed := file.Changed(f, func(name string) bool {
+					return name == telegramAdminFlag || overridden(name)
+				})
+				if len(changed) > 0 {
+					return fmt.Errorf("the options %s of the configuration file only change on restart", strings.Join(changed, ", "))
+				}
+				if !overridden(telegramAdminFlag) {
+					if admins, err = fileAdmins(f); err != nil {
+						return fmt.Errorf("invalid configuration file: %v", err)
+					}
+				}
+			}
+			t, err := loadTemplates(config)
+			if err != nil {
+				return fmt.Errorf("invalid templates: %v", err)
+			}
+			if router != nil {
+				routing, err := router.Load()
+				if err != nil {
+					return fmt.Errorf("invalid routing configuration: %v", err)
+				}
+				router.Set(routing)
+			}
+			for _, bot := range bots {
+				bot.SetTemplates(t)
+				bot.SetAdmins(admins)
+			}
+			reloadSuccessful.Set(1)
+			reloadSuccess.SetToCurrentTime()
+			return nil
+		}
+		opts = append(opts, telegram.WithReload(reload))
+
+		// newBot creates a bot with the shared options and stores. Its chats, alerts, outbox and handovers
+		// are kept in kv and its metrics are registered with reg.
+		newBot := func(token string, kv store.Store, blogger log.Logger, reg prometheus.Registerer, sendRate, chatSendRate float64) (*telegram.Bot, error) {
+			chatStore, err := telegram.NewChatStore(kv)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create chat store: %v", err)
+			}
+
+			var chats telegram.BotChatStore = chatStore
+			if config.storeChatsCacheTTL > 0 {
+				cache := telegram.NewCachedChatStore(chatStore, config.storeChatsCacheTTL, log.With(blogger, "component", "chatcache"))
+				if err := reg.Register(cache.Collector()); err != nil {
+					return nil, err
+				}
+				chats = cache
+
+				cctx, ccancel := context.WithCancel(ctx)
+				g.Add(func() error {
+					return cache.Watch(cctx, kv)
+				}, func(err error) {
+					ccancel()
+				})
+			}
+
+			// Key/Value store for the alerts sent to the chats, their escalations are resumed after a restart
+			alertStore, err := telegram.NewAlertStore(kv)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create alert store: %v", err)
+			}
+
+			// Key/Value store for the payloads of the alerts' buttons, Telegram limits the data of a button to 64 bytes
+			callbackStore, err := telegram.NewCallbackStore(kv)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create callback store: %v", err)
+			}
+
+			botOpts := append(opts[:len(opts):len(opts)],
+				telegram.WithLogger(blogger),
+				telegram.WithRegisterer(reg),
+				telegram.WithAlertStore(alertStore),
+				telegram.WithCallbackStore(callbackStore),
+				telegram.WithSendRate(sendRate),
+				telegram.WithChatSendRate(chatSendRate),
+			)
+
+			if config.outbox {
+				outbox, err := telegram.NewOutboxStore(kv)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create outbox store: %v", err)
+				}
+				botOpts = append(botOpts, telegram.WithOutbox(outbox, config.outboxMaxAge))
+			}
+
+			if shards != nil {
+				handover, err := telegram.NewHandoverStore(kv)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create handover store: %v", err)
+				}
+				botOpts = append(botOpts,
+					telegram.WithShard(shards, handover),
+					telegram.WithPolling(elector.Elected()),
+				)
+			}
+
+			return telegram.NewBot(chats, members, nodes, settings, token, config.telegramAdmins[0], botOpts...)
+		}
+
+		// The metrics of several bots are labeled by bot
+		reg := prometheus.DefaultRegisterer
+		if len(config.telegramBots) > 0 {
+			reg = prometheus.WrapRegistererWith(prometheus.Labels{"bot": "default"}, prometheus.DefaultRegisterer)
+		}
+		bot, err := newBot(config.telegramToken, kvStore, tlogger, reg, config.telegramSendRate, config.telegramChatSendRate)
+		if err != nil {
+			level.Error(tlogger).Log("msg", "failed to create bot", "err", err)
+			os.Exit(2)
+		}
+		bots = append(bots, bot)
+		botsWebhooks := []chan alertmanager.Webhook{webhooks}
+
+		names := make([]string, 0, len(config.telegramBots))
+		for name := range config.telegramBots {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			bot, err := newBot(
+				config.telegramBots[name],
+				telegram.NewPrefixedStore(kvStore, "bots/"+name),
+				log.With(tlogger, "bot", name),
+				prometheus.WrapRegistererWith(prometheus.Labels{"bot": name}, prometheus.DefaultRegisterer),
+				botSendRates[name],
+				botChatSendRates[name],
+			)
+			if err != nil {
+				level.Error(tlogger).Log("msg", "failed to create bot", "bot", name, "err", err)
+				os.Exit(2)
+			}
+			bots = append(bots, bot)
+			botsWebhooks = append(botsWebhooks, botWebhooks[name])
+		}
+
+		// The files of the templates and the routing configuration, inline in the configuration file or not
+		watched := append([]string{}, config.templatesPaths...)
+		for _, path := range []string{config.routingFile, config.configFile} {
+			if path != "" {
+				watched = append(watched, path)
+			}
+		}
+		reloadFiles := func(trigger string) {
+			if err := reload(); err != nil {
+				level.Warn(logger).Log("msg", "failed to reload configuration", "trigger", trigger, "err", err)
+				return
+			}
+			level.Info(logger).Log("msg", "reloaded configuration", "trigger", trigger, "paths", strings.Join(watched, ","))
+		}
+
+		// Reload the configuration file, routing configuration and templates on SIGHUP and when their files change,
+		// e.g. when Kubernetes updates their mounted ConfigMap
+		hup := notifySignals(syscall.SIGHUP)
+
+		g.Add(func() error {
+			var poll <-chan time.Time
+			if config.templatesReloadInterval > 0 {
+				ticker := time.NewTicker(config.templatesReloadInterval)
+				defer ticker.Stop()
+				poll = ticker.C
+			}
+			modTime := latestModTime(watched)
+
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-hup:
+					modTime = latestModTime(watched)
+					reloadFiles("signal")
+				case <-poll:
+					// A ConfigMap update swaps the files behind their symlinks, with older times too on rollbacks
+					if t := latestModTime(watched); !t.Equal(modTime) {
+						modTime = t
+						reloadFiles("change")
+					}
+				}
+			}
+		}, func(err error) {
+			signal.Stop(hup)
 			cancel()
 		})
+
+		level.Info(tlogger).Log(
+			"msg", "starting alertmanager-bot",
+			"version", Version,
+			"revision", Revision,
+			"build_date", BuildDate,
+			"go_version", GoVersion,
+			"bots", len(bots),
+		)
+		for i, bot := range bots {
+			bot, webhooks := bot, botsWebhooks[i]
+			g.Add(func() error {
+				// Standbys only start polling Telegram once elected, shards deliver alerts meanwhile
+				if elector != nil && shards == nil {
+					select {
+					case <-elector.Elected():
+					case <-ctx.Done():
+						return nil
+					}
+				}
+
+				// Runs the bot itself communicating with Telegram
+				return bot.Run(ctx, webhooks)
+			}, func(err error) {
+				cancel()
+			})
+		}
+
+		// systemd starts a service of Type=notify once it's ready and restarts it when its watchdog isn't petted
+		notifier, err := systemd.New(log.With(logger, "component", "systemd"))
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to read the systemd watchdog", "err", err)
+			os.Exit(1)
+		}
+		if notifier != nil {
+			alive := func() error {
+				if err := storeAlive(kvStore); err != nil {
+					return fmt.Errorf("store unreachable: %v", err)
+				}
+				for _, bot := range bots {
+					if err := bot.Alive(time.Now()); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			nctx, ncancel := context.WithCancel(context.Background())
+			g.Add(func() error {
+				// The bots connected to Telegram when they were created, the store has to answer too
+				if err := storeAlive(kvStore); err != nil {
+					return fmt.Errorf("failed to reach the store: %v", err)
+				}
+				if err := notifier.Notify(systemd.Ready); err != nil {
+					level.Warn(logger).Log("msg", "failed to notify systemd", "err", err)
+				}
+				return notifier.Run(nctx, alive)
+			}, func(err error) {
+				notifier.Notify(systemd.Stopping)
+				ncancel()
+			})
+		}
 	}
 	{
 		wlogger := log.With(logger, "component", "webserver")
@@ -259,8 +1817,22 @@ func main() {
 
 		prometheus.MustRegister(webhooksCounter)
 
+		// standby refuses the webhooks on standbys, the Alertmanager retries them until they reach the leader
+		standby := func(handleWebhook http.HandlerFunc) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				if elector != nil && shards == nil && !elector.Leading() {
+					http.Error(w, "standing by, not the leader", http.StatusServiceUnavailable)
+					return
+				}
+				handleWebhook(w, r)
+			}
+		}
+
 		m := http.NewServeMux()
-		m.HandleFunc("/", alertmanager.HandleWebhook(wlogger, webhooksCounter, webhooks))
+		m.HandleFunc("/", standby(alertmanager.HandleWebhook(wlogger, webhooksCounter, tracer, webhooks)))
+		for name, ch := range botWebhooks {
+			m.HandleFunc(botsPath+name, standby(alertmanager.HandleWebhook(log.With(wlogger, "bot", name), webhooksCounter, tracer, ch)))
+		}
 		m.Handle("/metrics", promhttp.Handler())
 		m.HandleFunc("/health", handleHealth)
 		m.HandleFunc("/healthz", handleHealth)
@@ -278,14 +1850,14 @@ func main() {
 		})
 	}
 	{
-		sig := make(chan os.Signal)
-		signal.Notify(sig, os.Interrupt, os.Kill)
+		sig := notifySignals(os.Interrupt, os.Kill)
 
 		g.Add(func() error {
 			<-sig
 			return nil
 		}, func(err error) {
 			cancel()
+			signal.Stop(sig)
 			close(sig)
 		})
 	}
@@ -295,3 +1867,171 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// checkConfig loads the templates and configuration files like serve does and renders the templates
+// in use with an example alert, without connecting anywhere
+func checkConfig(templatesPaths []string, receiverTemplates map[string]string, routing *telegram.RoutingConfig, runbookFile, remediationFile string) error {
+	t, err := telegram.LoadTemplates(templatesPaths...)
+	if err != nil {
+		return fmt.Errorf("invalid templates: %v", err)
+	}
+
+	names := []string{"telegram.default"}
+	for _, name := range receiverTemplates {
+		names = append(names, name)
+	}
+	if routing != nil {
+		names = append(names, routing.Templates()...)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, _, err := telegram.RenderSample(t, name); err != nil {
+			return fmt.Errorf("template %s fails to render an example alert: %v", name, err)
+		}
+	}
+
+	if runbookFile != "" {
+		if _, err := runbook.LoadFile(runbookFile); err != nil {
+			return fmt.Errorf("invalid runbook: %v", err)
+		}
+	}
+	if remediationFile != "" {
+		if _, err := remediate.LoadFile(remediationFile); err != nil {
+			return fmt.Errorf("invalid remediation configuration: %v", err)
+		}
+	}
+	return nil
+}
+
+// botRates parses the rates of the additional bots given as name=rate, the bots without one get def
+func botRates(bots, rates map[string]string, def float64) (map[string]float64, error) {
+	parsed := make(map[string]float64, len(bots))
+	for name := range bots {
+		parsed[name] = def
+	}
+	for name, rate := range rates {
+		if _, ok := bots[name]; !ok {
+			return nil, fmt.Errorf("unknown bot %q", name)
+		}
+		f, err := strconv.ParseFloat(rate, 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("invalid rate %q of bot %q", rate, name)
+		}
+		parsed[name] = f
+	}
+	return parsed, nil
+}
+
+// withConfigFile loads the configuration file given by flag or environment variable with the selected profile
+// and prepends its options to the arguments, except for the flags set on the command line or by their environment variable.
+// It returns whether a flag is set that way, overriding the file.
+func withConfigFile(a *kingpin.Application, args []string) (*configfile.File, []string, func(name string) bool, error) {
+	set := map[string]bool{}
+	path, profile := os.Getenv("CONFIG_FILE"), os.Getenv("PROFILE")
+	// Errors are reported when parsing the arguments with the options of the file
+	ctx, _ := a.ParseContext(args)
+	if ctx != nil {
+		for _, e := range ctx.Elements {
+			flag, ok := e.Clause.(*kingpin.FlagClause)
+			if !ok {
+				continue
+			}
+			name := flag.Model().Name
+			set[name] = true
+			if name == configfile.FileFlag && e.Value != nil {
+				path = *e.Value
+			}
+			if name == configfile.ProfileFlag && e.Value != nil {
+				profile = *e.Value
+			}
+		}
+	}
+	overridden := func(name string) bool {
+		return set[name] || a.GetFlag(name).HasEnvarValue()
+	}
+	if path == "" {
+		if profile != "" {
+			return nil, nil, nil, fmt.Errorf("the profile %q needs a configuration file", profile)
+		}
+		return nil, args, overridden, nil
+	}
+
+	file, err := configfile.LoadFile(path, a.Model().Flags, profile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return file, append(file.Args(overridden), args...), overridden, nil
+}
+
+// fileAdmins returns the admin IDs set by the configuration file
+func fileAdmins(file *configfile.File) ([]int, error) {
+	values := file.Values(telegramAdminFlag)
+	if len(values) == 0 {
+		return nil, fmt.Errorf("the configuration file sets no %s", telegramAdminFlag)
+	}
+	admins := make([]int, 0, len(values))
+	for _, v := range values {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %v", telegramAdminFlag, v, err)
+		}
+		admins = append(admins, id)
+	}
+	return admins, nil
+}
+
+// notifySignals relays the signals to the returned channel. It is buffered, as signal.Notify drops
+// the signals arriving while nobody receives, e.g. an interrupt before the actor waits for it.
+func notifySignals(signals ...os.Signal) chan os.Signal {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+	return c
+}
+
+// storeAlive returns an error if the store doesn't answer, a missing key is an answer
+func storeAlive(kv store.Store) error {
+	_, err := kv.Exists(healthKey)
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+// latestModTime returns the latest modification time of the files, following the symlinks of mounted ConfigMaps
+func latestModTime(paths []string) time.Time {
+	var latest time.Time
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
+
+// buildInfo returns the build of this binary shown by /version
+func buildInfo() telegram.BuildInfo {
+	var changes []string
+	for _, c := range strings.Split(Changes, "|") {
+		if strings.TrimSpace(c) != "" {
+			changes = append(changes, c)
+		}
+	}
+
+	ref := "master"
+	if Version != "" {
+		ref = Version
+	}
+
+	return telegram.BuildInfo{
+		Version:      Version,
+		Revision:     Revision,
+		BuildDate:    BuildDate,
+		GoVersion:    GoVersion,
+		Changes:      changes,
+		ChangelogURL: fmt.Sprintf("https://github.com/vu-long/alertmanager-bot/blob/%s/CHANGELOG.md", ref),
+	}
+}
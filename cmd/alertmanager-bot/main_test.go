@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifySignals(t *testing.T) {
+	sig := notifySignals(syscall.SIGUSR1)
+	defer signal.Stop(sig)
+
+	// The signal arrives before anyone receives it
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	time.Sleep(100 * time.Millisecond)
+
+	select {
+	case s := <-sig:
+		assert.Equal(t, syscall.SIGUSR1, s)
+	case <-time.After(time.Second):
+		t.Error("the signal was dropped")
+	}
+}
+
+func TestNewApp(t *testing.T) {
+	flags := []string{"--alertmanager.url=http://alertmanager:9093", "--listen.addr=0.0.0.0:8080", "--store=bolt", "--telegram.admin=1", "--template.paths=../../default.tmpl"}
+
+	config := newConfiguration()
+	a, cmds := newApp(config)
+	cmd, err := a.Parse(append(flags, "send", "--alert", "--", "-100"))
+	assert.NoError(t, err)
+	assert.Equal(t, cmds.send.FullCommand(), cmd)
+	assert.Equal(t, int64(-100), *cmds.sendChat)
+	assert.True(t, *cmds.sendAlert)
+	assert.Equal(t, "telegram.default", *cmds.sendTemplate)
+	assert.Equal(t, "alertmanager:9093", config.alertmanager.Host)
+
+	a, _ = newApp(newConfiguration())
+	cmd, err = a.Parse(flags)
+	assert.NoError(t, err)
+	assert.Equal(t, "serve", cmd, "serve runs without a command")
+}
@@ -84,4 +84,5 @@ require (
 	golang.org/x/net v0.0.0-20181213202711-891ebc4b82d6 // indirect
 	google.golang.org/grpc v1.17.0 // indirect
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
+	gopkg.in/yaml.v2 v2.2.1
 )
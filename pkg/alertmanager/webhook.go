@@ -1,6 +1,7 @@
 package alertmanager
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
@@ -8,11 +9,27 @@ import (
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/alertmanager/notify"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vu-long/alertmanager-bot/pkg/tracing"
 )
 
+// Webhook is a message received from the Alertmanager
+type Webhook struct {
+	notify.WebhookMessage
+	// Trace is the span of the webhook's receipt, its delivery is traced as child of it
+	Trace tracing.SpanContext
+}
+
 // HandleWebhook returns a HandlerFunc that forwards webhooks to all bots via a channel
-func HandleWebhook(logger log.Logger, counter prometheus.Counter, webhooks chan<- notify.WebhookMessage) http.HandlerFunc {
+func HandleWebhook(logger log.Logger, counter prometheus.Counter, tracer *tracing.Tracer, webhooks chan<- Webhook) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Continue the trace of the sender if it propagates one
+		ctx := context.Background()
+		if sc, err := tracing.ParseTraceparent(r.Header.Get("traceparent")); err == nil {
+			ctx = tracing.ContextWithSpanContext(ctx, sc)
+		}
+		_, span := tracer.Start(ctx, "webhook receive", tracing.KindServer)
+		defer span.End()
+
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
@@ -32,6 +49,7 @@ func HandleWebhook(logger log.Logger, counter prometheus.Counter, webhooks chan<
 				"msg", "failed to decode webhook message",
 				"err", err,
 			)
+			span.SetError(err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
@@ -41,7 +59,13 @@ func HandleWebhook(logger log.Logger, counter prometheus.Counter, webhooks chan<
 			"alerts", len(webhook.Alerts),
 		)
 
-		webhooks <- webhook
+		span.SetAttributes(
+			tracing.String("receiver", webhook.Receiver),
+			tracing.String("status", webhook.Status),
+			tracing.Int("alerts", int64(len(webhook.Alerts))),
+		)
+
+		webhooks <- Webhook{WebhookMessage: webhook, Trace: span.Context()}
 		counter.Inc()
 	}
 }
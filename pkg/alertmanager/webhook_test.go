@@ -20,9 +20,9 @@ const validWebhook = `{"receiver":"telegram","status":"firing","alerts":[{"statu
 func TestHandleWebhook(t *testing.T) {
 	logger := log.NewNopLogger()
 	counter := prometheus.NewCounter(prometheus.CounterOpts{})
-	webhooks := make(chan notify.WebhookMessage, 1)
+	webhooks := make(chan Webhook, 1)
 
-	h := HandleWebhook(logger, counter, webhooks)
+	h := HandleWebhook(logger, counter, nil, webhooks)
 
 	type checkFunc func(*http.Response) error
 
@@ -89,7 +89,7 @@ func TestHandleWebhook(t *testing.T) {
 					}
 
 					webhook := <-webhooks
-					if !assert.Equal(t, expected, webhook) {
+					if !assert.Equal(t, expected, webhook.WebhookMessage) {
 						return errors.New("")
 					}
 					return nil
@@ -0,0 +1,385 @@
+// Package config reads the YAML configuration file of the bot. Its options are named like the flags,
+// nested by the dots of their names, and the routing configuration can be given inline.
+// Values reference environment variables as ${ENV_VAR} and files as file:/path,
+// so that the file can be committed without secrets. Profiles override the options
+// for an environment, e.g. prod and staging, selected by --profile.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vu-long/alertmanager-bot/pkg/internal/levenshtein"
+	"github.com/vu-long/alertmanager-bot/pkg/secret"
+	"github.com/vu-long/alertmanager-bot/pkg/telegram"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// FileFlag is the flag of the configuration file, it can't be set by the file itself
+	FileFlag = "config.file"
+	// ProfileFlag is the flag selecting the profile, it can't be set by the file either
+	ProfileFlag = "profile"
+	// profilesSection maps the names of the profiles to the options they override
+	profilesSection = "profiles"
+	// routingSection holds the inline routing configuration besides the routing options
+	routingSection = "routing"
+	// filePrefix makes a value the content of the file at the path following it
+	filePrefix = "file:"
+)
+
+// envReference matches the ${ENV_VAR} references and the escaped $$ in values
+var envReference = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// routingKeys are the keys of the routing section that belong to the inline routing configuration
+var routingKeys = map[string]bool{"route": true, "escalation_policies": true}
+
+// option is the value of a flag given by the file
+type option struct {
+	values []string
+	bool   bool
+}
+
+// File is the content of the configuration file
+type File struct {
+	options map[string]option
+	// Routing is the inline routing configuration, nil if the file has none
+	Routing *telegram.RoutingConfig
+}
+
+// Load parses the configuration from YAML with the options of the profile, if not empty,
+// overriding the others. The options of all profiles are validated against the flags.
+func Load(b []byte, flags []*kingpin.FlagModel, profile string) (*File, error) {
+	var content yaml.MapSlice
+	if err := yaml.Unmarshal(b, &content); err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]*kingpin.FlagModel, len(flags))
+	for _, f := range flags {
+		known[f.Name] = f
+	}
+
+	var base, profiles yaml.MapSlice
+	for _, item := range content {
+		if item.Key != profilesSection {
+			base = append(base, item)
+			continue
+		}
+		if item.Value == nil {
+			continue
+		}
+		p, ok := item.Value.(yaml.MapSlice)
+		if !ok {
+			return nil, fmt.Errorf("section %q needs to map the names of profiles to their options", profilesSection)
+		}
+		profiles = p
+	}
+
+	f, err := load(base, known)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, item := range profiles {
+		name := fmt.Sprint(item.Key)
+		names = append(names, name)
+		section, ok := item.Value.(yaml.MapSlice)
+		if !ok && item.Value != nil {
+			return nil, fmt.Errorf("profile %q needs a section of options", name)
+		}
+		p, err := load(section, known)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %v", name, err)
+		}
+		if name == profile {
+			f.override(p)
+		}
+	}
+	if profile != "" && !contains(names, profile) {
+		return nil, fmt.Errorf("unknown profile %q, the file has the profiles %q", profile, names)
+	}
+	return f, nil
+}
+
+// load parses the options of the base configuration or of a profile
+func load(content yaml.MapSlice, known map[string]*kingpin.FlagModel) (*File, error) {
+	f := &File{options: make(map[string]option)}
+	if err := f.parse("", content, known); err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.options[FileFlag]; ok {
+		return nil, fmt.Errorf("option %q can't be set by the configuration file itself", FileFlag)
+	}
+	if _, ok := f.options[ProfileFlag]; ok {
+		return nil, fmt.Errorf("option %q can't be set by the configuration file, select the profile with --%s", ProfileFlag, ProfileFlag)
+	}
+	if _, ok := f.options["routing.file"]; ok && f.Routing != nil {
+		return nil, fmt.Errorf("option \"routing.file\" and the inline routing configuration exclude each other")
+	}
+	return f, nil
+}
+
+// override the options with those of the profile. The inline routing configuration
+// and routing.file replace each other.
+func (f *File) override(p *File) {
+	for name, o := range p.options {
+		f.options[name] = o
+	}
+	if p.Routing != nil {
+		f.Routing = p.Routing
+		delete(f.options, "routing.file")
+	}
+	if _, ok := p.options["routing.file"]; ok {
+		f.Routing = nil
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadFile parses the configuration file with the options of the profile, see Load
+func LoadFile(path string, flags []*kingpin.FlagModel, profile string) (*File, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Load(b, flags, profile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// parse the options of the section, nested sections are joined to the names of the flags by dots
+func (f *File) parse(section string, content yaml.MapSlice, known map[string]*kingpin.FlagModel) error {
+	var routing yaml.MapSlice
+	for _, item := range content {
+		key, ok := item.Key.(string)
+		if !ok {
+			return fmt.Errorf("key %v of section %q isn't a string", item.Key, section)
+		}
+		name := key
+		if section != "" {
+			name = section + "." + key
+		}
+
+		if section == routingSection && routingKeys[key] {
+			routing = append(routing, item)
+			continue
+		}
+		if flag, ok := known[name]; ok {
+			o, err := newOption(flag, item.Value)
+			if err != nil {
+				return err
+			}
+			f.options[name] = o
+			continue
+		}
+		if nested, ok := item.Value.(yaml.MapSlice); ok {
+			if err := f.parse(name, nested, known); err != nil {
+				return err
+			}
+			continue
+		}
+		return unknownOption(name, known)
+	}
+
+	if routing == nil {
+		return nil
+	}
+	b, err := yaml.Marshal(routing)
+	if err != nil {
+		return err
+	}
+	c, err := telegram.LoadRoutingConfig(b)
+	if err != nil {
+		return fmt.Errorf("invalid inline routing configuration: %v", err)
+	}
+	f.Routing = c
+	return nil
+}
+
+// newOption validates the value of the flag
+func newOption(flag *kingpin.FlagModel, value interface{}) (option, error) {
+	values, err := optionValues(flag.Name, value)
+	if err != nil {
+		return option{}, err
+	}
+	o := option{values: values, bool: flag.IsBoolFlag()}
+
+	if c, ok := flag.Value.(interface{ IsCumulative() bool }); (!ok || !c.IsCumulative()) && len(values) != 1 {
+		return option{}, fmt.Errorf("option %q takes a single value, not %d", flag.Name, len(values))
+	}
+	if o.bool && values[0] != "true" && values[0] != "false" {
+		return option{}, fmt.Errorf("option %q needs true or false, not %q", flag.Name, values[0])
+	}
+	return o, nil
+}
+
+// optionValues returns the values of a flag: a scalar, a list of a repeatable flag
+// or a map of a flag of key=value pairs
+func optionValues(name string, value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, fmt.Errorf("option %q has no value", name)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			s, err := scalar(name, e)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	case yaml.MapSlice:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			s, err := scalar(name, e.Value)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, fmt.Sprintf("%v=%s", e.Key, s))
+		}
+		return values, nil
+	default:
+		s, err := scalar(name, v)
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+}
+
+func scalar(name string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case []interface{}, yaml.MapSlice, nil:
+		return "", fmt.Errorf("option %q needs a string, number or boolean, not %v", name, value)
+	case string:
+		return resolve(name, v)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// resolve replaces the ${ENV_VAR} references of the value by the environment variables and $$ by $.
+// A value starting with file: is then replaced by the content of the file, e.g. file:/run/secrets/${NAME}.
+func resolve(name, value string) (string, error) {
+	var err error
+	value = envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		env := ref[2 : len(ref)-1]
+		v, ok := os.LookupEnv(env)
+		if !ok && err == nil {
+			err = fmt.Errorf("option %q references the unset environment variable %s", name, env)
+		}
+		return v
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(value, filePrefix) {
+		return value, nil
+	}
+	v, err := secret.FromFile(strings.TrimPrefix(value, filePrefix))
+	if err != nil {
+		return "", fmt.Errorf("option %q references a file that can't be read: %v", name, err)
+	}
+	return v, nil
+}
+
+// unknownOption returns the error of an option without flag, suggesting the closest flag
+func unknownOption(name string, known map[string]*kingpin.FlagModel) error {
+	closest, distance := "", len(name)/3+1
+	for k := range known {
+		if d := levenshtein.Distance(name, k); d < distance || (d == distance && k < closest) {
+			closest, distance = k, d
+		}
+	}
+	if closest != "" {
+		return fmt.Errorf("unknown option %q, did you mean %q?", name, closest)
+	}
+	return fmt.Errorf("unknown option %q", name)
+}
+
+// Values returns the values of the option, nil if the file doesn't set it
+func (f *File) Values(name string) []string {
+	return f.options[name].values
+}
+
+// Changed returns the sorted names of the options set differently by the file p, skipping those
+// for which skip returns true. Only the names are returned, the values may be secrets.
+func (f *File) Changed(p *File, skip func(name string) bool) []string {
+	var names []string
+	for _, options := range []map[string]option{f.options, p.options} {
+		for name := range options {
+			if skip(name) || contains(names, name) {
+				continue
+			}
+			if !equal(f.options[name].values, p.options[name].values) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Args returns the command line arguments of the options, skipping the flags set already
+// on the command line or by their environment variable, which take precedence over the file
+func (f *File) Args(set func(name string) bool) []string {
+	names := make([]string, 0, len(f.options))
+	for name := range f.options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var args []string
+	for _, name := range names {
+		if set(name) {
+			continue
+		}
+		o := f.options[name]
+		// Boolean flags don't take a value
+		if o.bool {
+			if o.values[0] == "true" {
+				args = append(args, "--"+name)
+			} else {
+				args = append(args, "--no-"+name)
+			}
+			continue
+		}
+		for _, v := range o.values {
+			args = append(args, fmt.Sprintf("--%s=%s", name, v))
+		}
+	}
+	return args
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+func testFlags() []*kingpin.FlagModel {
+	a := kingpin.New("test", "")
+	a.Flag("config.file", "").String()
+	a.Flag("profile", "").String()
+	a.Flag("alertmanager.url", "").URL()
+	a.Flag("alert.cooldown", "").Duration()
+	a.Flag("log.json", "").Bool()
+	a.Flag("telegram.admin", "").Int64List()
+	a.Flag("loki.label", "").StringMap()
+	a.Flag("routing.file", "").String()
+	a.Flag("routing.fallback-chat", "").Int64()
+	return a.Model().Flags
+}
+
+func TestLoad(t *testing.T) {
+	f, err := Load([]byte(`
+alertmanager.url: http://localhost:9093
+alert:
+  cooldown: 5m
+log:
+  json: false
+telegram:
+  admin: [1, 2]
+loki:
+  label:
+    pod: pod
+routing:
+  fallback-chat: -100
+  route:
+    chats: [-100]
+`), testFlags(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{-100}, f.Routing.Route.Chats)
+
+	set := map[string]bool{"alertmanager.url": true}
+	assert.Equal(t, []string{
+		"--alert.cooldown=5m",
+		"--no-log.json",
+		"--loki.label=pod=pod",
+		"--routing.fallback-chat=-100",
+		"--telegram.admin=1",
+		"--telegram.admin=2",
+	}, f.Args(func(name string) bool { return set[name] }))
+	assert.Equal(t, []string{"1", "2"}, f.Values("telegram.admin"))
+	assert.Empty(t, f.Values("telegram.token"))
+}
+
+func TestChanged(t *testing.T) {
+	f, err := Load([]byte("alertmanager.url: http://localhost:9093\nalert.cooldown: 5m\ntelegram.admin: [1]"), testFlags(), "")
+	assert.NoError(t, err)
+	p, err := Load([]byte("alert.cooldown: 10m\nlog.json: true\ntelegram.admin: [1, 2]\nrouting: {route: {chats: [-100]}}"), testFlags(), "")
+	assert.NoError(t, err)
+
+	set := map[string]bool{"log.json": true}
+	assert.Equal(t, []string{"alert.cooldown", "alertmanager.url", "telegram.admin"}, f.Changed(p, func(name string) bool { return set[name] }))
+	assert.Empty(t, f.Changed(f, func(string) bool { return false }))
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for invalid, msg := range map[string]string{
+		"alert:\n  cooldownn: 5m":                     `unknown option "alert.cooldownn", did you mean "alert.cooldown"?`,
+		"foo: bar":                                    `unknown option "foo"`,
+		"log.json: maybe":                             `option "log.json" needs true or false, not "maybe"`,
+		"alert.cooldown: [1m, 2m]":                    `option "alert.cooldown" takes a single value, not 2`,
+		"telegram.admin: [[1]]":                       `option "telegram.admin" needs a string, number or boolean, not [1]`,
+		"alertmanager.url:":                           `option "alertmanager.url" has no value`,
+		"config.file: other.yml":                      `option "config.file" can't be set by the configuration file itself`,
+		"routing: {file: r.yml, route: {chats: [1]}}": `option "routing.file" and the inline routing configuration exclude each other`,
+	} {
+		_, err := Load([]byte(invalid), testFlags(), "")
+		if assert.Error(t, err, invalid) {
+			assert.Equal(t, msg, err.Error(), invalid)
+		}
+	}
+
+	_, err := Load([]byte("routing: {route: {chatz: [1]}}"), testFlags(), "")
+	assert.Error(t, err)
+}
+
+func TestLoadReferences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "url"), []byte("http://alertmanager:9093\n"), 0600))
+
+	os.Setenv("CONFIG_TEST_DIR", dir)
+	os.Setenv("CONFIG_TEST_CHAT", "-100")
+	defer os.Unsetenv("CONFIG_TEST_DIR")
+	defer os.Unsetenv("CONFIG_TEST_CHAT")
+
+	f, err := Load([]byte(`
+alertmanager.url: file:${CONFIG_TEST_DIR}/url
+routing.file: /etc/$${CONFIG_TEST_CHAT}.yml
+telegram.admin: ["${CONFIG_TEST_CHAT}"]
+`), testFlags(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"--alertmanager.url=http://alertmanager:9093",
+		"--routing.file=/etc/${CONFIG_TEST_CHAT}.yml",
+		"--telegram.admin=-100",
+	}, f.Args(func(string) bool { return false }))
+
+	_, err = Load([]byte("alertmanager.url: ${CONFIG_TEST_UNSET}"), testFlags(), "")
+	assert.EqualError(t, err, `option "alertmanager.url" references the unset environment variable CONFIG_TEST_UNSET`)
+
+	_, err = Load([]byte("alertmanager.url: file:"+filepath.Join(dir, "missing")), testFlags(), "")
+	assert.Error(t, err)
+}
+
+func TestLoadProfiles(t *testing.T) {
+	config := []byte(`
+alertmanager.url: http://alertmanager.prod:9093
+routing:
+  fallback-chat: -100
+  route:
+    chats: [-100]
+profiles:
+  staging:
+    alertmanager.url: http://alertmanager.staging:9093
+    routing:
+      route:
+        chats: [-200]
+  dev:
+    routing.file: dev.yml
+`)
+
+	f, err := Load(config, testFlags(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{-100}, f.Routing.Route.Chats)
+
+	f, err = Load(config, testFlags(), "staging")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{-200}, f.Routing.Route.Chats)
+	assert.Equal(t, []string{
+		"--alertmanager.url=http://alertmanager.staging:9093",
+		"--routing.fallback-chat=-100",
+	}, f.Args(func(string) bool { return false }))
+
+	f, err = Load(config, testFlags(), "dev")
+	assert.NoError(t, err)
+	assert.Nil(t, f.Routing)
+	assert.Contains(t, f.Args(func(string) bool { return false }), "--routing.file=dev.yml")
+
+	_, err = Load(config, testFlags(), "prod")
+	assert.EqualError(t, err, `unknown profile "prod", the file has the profiles ["staging" "dev"]`)
+
+	_, err = Load([]byte("profiles: {staging: {alertmanager.urll: x}}"), testFlags(), "")
+	assert.EqualError(t, err, `profile "staging": unknown option "alertmanager.urll", did you mean "alertmanager.url"?`)
+
+	_, err = Load([]byte("profile: staging"), testFlags(), "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+// Package deploy looks up the latest deployments of services in GitHub or GitLab,
+// so that alerts caused by a regression can be traced back to the deployment.
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Deployment of a repository to an environment
+type Deployment struct {
+	// Ref deployed, e.g. a tag, branch or commit
+	Ref         string
+	Environment string
+	// Creator is the username of who deployed
+	Creator   string
+	CreatedAt time.Time
+}
+
+// Source returns the latest deployment of the repository to the environment, any if empty.
+// It is nil without deployments.
+type Source interface {
+	Latest(repository, environment string) (*Deployment, error)
+}
+
+// client is the HTTP client of all sources
+var client = &http.Client{Timeout: 5 * time.Second}
+
+// get decodes the JSON response of the URL into v
+func get(url string, headers map[string]string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("deployments returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
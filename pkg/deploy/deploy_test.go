@@ -0,0 +1,50 @@
+package deploy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitHub(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/acme/shop/deployments", r.URL.Path)
+		assert.Equal(t, "production", r.URL.Query().Get("environment"))
+		assert.Equal(t, "token secret", r.Header.Get("Authorization"))
+		w.Write([]byte(`[{"ref":"v1.2.0","environment":"production","created_at":"2026-10-15T09:00:00Z","creator":{"login":"dev"}}]`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	d, err := (&GitHub{URL: u, Token: "secret"}).Latest("acme/shop", "production")
+	assert.NoError(t, err)
+	assert.Equal(t, &Deployment{Ref: "v1.2.0", Environment: "production", Creator: "dev", CreatedAt: time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)}, d)
+}
+
+func TestGitLab(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v4/projects/acme%2Fshop/deployments", r.URL.EscapedPath())
+		assert.Equal(t, "desc", r.URL.Query().Get("sort"))
+		assert.Equal(t, "secret", r.Header.Get("PRIVATE-TOKEN"))
+		w.Write([]byte(`[{"ref":"main","created_at":"2026-10-15T09:00:00Z","environment":{"name":"production"},"user":{"username":"dev"}}]`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	d, err := (&GitLab{URL: u, Token: "secret"}).Latest("acme/shop", "")
+	assert.NoError(t, err)
+	assert.Equal(t, &Deployment{Ref: "main", Environment: "production", Creator: "dev", CreatedAt: time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)}, d)
+
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer empty.Close()
+	u, _ = url.Parse(empty.URL)
+	d, err = (&GitLab{URL: u}).Latest("acme/shop", "")
+	assert.NoError(t, err)
+	assert.Nil(t, d)
+}
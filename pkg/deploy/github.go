@@ -0,0 +1,51 @@
+package deploy
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitHub lists the deployments of repositories of GitHub or GitHub Enterprise
+type GitHub struct {
+	// URL of the API, e.g. https://api.github.com
+	URL   *url.URL
+	Token string
+}
+
+type githubDeployment struct {
+	Ref         string    `json:"ref"`
+	Environment string    `json:"environment"`
+	CreatedAt   time.Time `json:"created_at"`
+	Creator     struct {
+		Login string `json:"login"`
+	} `json:"creator"`
+}
+
+// Latest deployment of the repository, as owner/name
+func (g *GitHub) Latest(repository, environment string) (*Deployment, error) {
+	params := url.Values{"per_page": {"1"}}
+	if environment != "" {
+		params.Set("environment", environment)
+	}
+	u := *g.URL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/repos/" + repository + "/deployments"
+	u.RawQuery = params.Encode()
+
+	headers := map[string]string{}
+	if g.Token != "" {
+		headers["Authorization"] = "token " + g.Token
+	}
+
+	// GitHub lists the newest deployments first
+	var deployments []githubDeployment
+	if err := get(u.String(), headers, &deployments); err != nil {
+		return nil, err
+	}
+	if len(deployments) == 0 {
+		return nil, nil
+	}
+
+	d := deployments[0]
+	return &Deployment{Ref: d.Ref, Environment: d.Environment, Creator: d.Creator.Login, CreatedAt: d.CreatedAt}, nil
+}
@@ -0,0 +1,55 @@
+package deploy
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitLab lists the deployments of projects of GitLab
+type GitLab struct {
+	// URL of the instance, e.g. https://gitlab.com
+	URL   *url.URL
+	Token string
+}
+
+type gitlabDeployment struct {
+	Ref         string    `json:"ref"`
+	CreatedAt   time.Time `json:"created_at"`
+	Environment struct {
+		Name string `json:"name"`
+	} `json:"environment"`
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+}
+
+// Latest successful deployment of the project, as namespace/name
+func (g *GitLab) Latest(repository, environment string) (*Deployment, error) {
+	params := url.Values{
+		"order_by": {"created_at"},
+		"sort":     {"desc"},
+		"status":   {"success"},
+		"per_page": {"1"},
+	}
+	if environment != "" {
+		params.Set("environment", environment)
+	}
+	endpoint := strings.TrimSuffix(g.URL.String(), "/") + "/api/v4/projects/" + url.PathEscape(repository) + "/deployments?" + params.Encode()
+
+	headers := map[string]string{}
+	if g.Token != "" {
+		headers["PRIVATE-TOKEN"] = g.Token
+	}
+
+	var deployments []gitlabDeployment
+	if err := get(endpoint, headers, &deployments); err != nil {
+		return nil, err
+	}
+	if len(deployments) == 0 {
+		return nil, nil
+	}
+
+	d := deployments[0]
+	return &Deployment{Ref: d.Ref, Environment: d.Environment.Name, Creator: d.User.Username, CreatedAt: d.CreatedAt}, nil
+}
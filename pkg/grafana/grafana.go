@@ -0,0 +1,129 @@
+// Package grafana creates annotations in Grafana, so that dashboards show
+// when alerts were acknowledged and resolved, and renders the panels of alerts as images.
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// queueSize is the most annotations waiting to be created, more are dropped
+	queueSize = 100
+	// flushTimeout is how long pending annotations are created on shutdown
+	flushTimeout = 5 * time.Second
+)
+
+// Annotation of the Grafana HTTP API, Time in milliseconds since the epoch
+type Annotation struct {
+	Time int64    `json:"time"`
+	Tags []string `json:"tags,omitempty"`
+	Text string   `json:"text"`
+}
+
+// NewAnnotation creates an annotation at the time
+func NewAnnotation(t time.Time, text string, tags ...string) Annotation {
+	return Annotation{Time: t.UnixNano() / int64(time.Millisecond), Tags: tags, Text: text}
+}
+
+// Client posts the annotations to Grafana in the background, Annotate on a nil Client is a no-op for deployments without Grafana.
+type Client struct {
+	url      url.URL
+	endpoint string
+	token    string
+	client   *http.Client
+	logger   log.Logger
+	queue    chan Annotation
+}
+
+// New creates a client for the Grafana at the URL authenticating with the API token
+func New(u *url.URL, token string, logger log.Logger) *Client {
+	endpoint := *u
+	endpoint.Path = path.Join(endpoint.Path, "/api/annotations")
+
+	return &Client{
+		url:      *u,
+		endpoint: endpoint.String(),
+		token:    token,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logger,
+		queue:    make(chan Annotation, queueSize),
+	}
+}
+
+// Annotate queues the annotation to be created, it doesn't block
+func (c *Client) Annotate(a Annotation) {
+	if c == nil {
+		return
+	}
+
+	select {
+	case c.queue <- a:
+	default:
+		level.Warn(c.logger).Log("msg", "dropped grafana annotation because creating is too slow", "text", a.Text)
+	}
+}
+
+// Run creates the annotations until the context is canceled, pending annotations are created before returning
+func (c *Client) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			deadline := time.After(flushTimeout)
+			for {
+				select {
+				case a := <-c.queue:
+					c.create(a)
+				case <-deadline:
+					return nil
+				default:
+					return nil
+				}
+			}
+		case a := <-c.queue:
+			c.create(a)
+		}
+	}
+}
+
+func (c *Client) create(a Annotation) {
+	if err := c.post(a); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to create grafana annotation", "text", a.Text, "err", err)
+	}
+}
+
+func (c *Client) post(a Annotation) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("grafana returned %s", resp.Status)
+	}
+	return nil
+}
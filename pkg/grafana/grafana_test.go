@@ -0,0 +1,77 @@
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	u, _ := url.Parse("https://grafana.example.com/prefix/")
+	c := New(u, "secret", log.NewNopLogger())
+	assert.Equal(t, "https://grafana.example.com/prefix/api/annotations", c.endpoint)
+}
+
+func TestAnnotate(t *testing.T) {
+	annotations := make(chan Annotation, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/annotations", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var a Annotation
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&a))
+		annotations <- a
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	c := New(u, "secret", log.NewNopLogger())
+
+	at := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	c.Annotate(NewAnnotation(at, "HighCPU acknowledged by @vu_long", "alertname=HighCPU"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, c.Run(ctx))
+
+	assert.Equal(t, Annotation{
+		Time: at.UnixNano() / int64(time.Millisecond),
+		Tags: []string{"alertname=HighCPU"},
+		Text: "HighCPU acknowledged by @vu_long",
+	}, <-annotations)
+
+	var disabled *Client
+	disabled.Annotate(NewAnnotation(at, "ignored"))
+}
+
+func TestRender(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/grafana/render/d-solo/node-exporter/_", r.URL.Path)
+		assert.Equal(t, "3", r.URL.Query().Get("panelId"))
+		assert.Equal(t, "1792051200000", r.URL.Query().Get("from"))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL + "/grafana")
+	c := New(u, "secret", log.NewNopLogger())
+
+	to := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	from := to.Add(-time.Hour)
+	img, err := c.Render(context.Background(), "node-exporter", "3", from, to, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("png"), img)
+
+	_, err = c.Render(context.Background(), "node-exporter", "3", from, to, 2)
+	assert.Error(t, err, "the image is too large")
+}
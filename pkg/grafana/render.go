@@ -0,0 +1,67 @@
+package grafana
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// renderWidth and renderHeight of the rendered panels in pixels
+	renderWidth  = 1000
+	renderHeight = 500
+)
+
+// Render returns the PNG of the dashboard's panel showing the time range, rendered by the image renderer plugin
+// of Grafana. Images larger than maxSize bytes fail, the context limits how long rendering may take.
+func (c *Client) Render(ctx context.Context, dashboardUID, panelID string, from, to time.Time, maxSize int64) ([]byte, error) {
+	u := c.url
+	u.Path = path.Join(u.Path, "/render/d-solo", dashboardUID, "_")
+	q := url.Values{}
+	q.Set("panelId", panelID)
+	q.Set("from", strconv.FormatInt(from.UnixNano()/int64(time.Millisecond), 10))
+	q.Set("to", strconv.FormatInt(to.UnixNano()/int64(time.Millisecond), 10))
+	q.Set("width", strconv.Itoa(renderWidth))
+	q.Set("height", strconv.Itoa(renderHeight))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	// Rendering takes longer than creating annotations, the context limits it instead of the client's timeout
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("grafana returned %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/") {
+		return nil, fmt.Errorf("grafana returned %s instead of an image, is the image renderer installed?", ct)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("rendered panel has %d bytes, more than %d", resp.ContentLength, maxSize)
+	}
+	img, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(img)) > maxSize {
+		return nil, fmt.Errorf("rendered panel has more than %d bytes", maxSize)
+	}
+	return img, nil
+}
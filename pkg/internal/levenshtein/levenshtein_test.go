@@ -0,0 +1,15 @@
+package levenshtein
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	assert.Equal(t, 0, Distance("/alerts", "/alerts"))
+	assert.Equal(t, 1, Distance("/silence", "/silences"))
+	assert.Equal(t, 2, Distance("/silenecs", "/silences"))
+	assert.Equal(t, 3, Distance("kitten", "sitting"))
+	assert.Equal(t, 1, Distance("grün", "grun"), "runes are edited, not bytes")
+}
@@ -0,0 +1,286 @@
+// Package kubernetes watches the events of a Kubernetes cluster with its HTTP API
+// and turns the problems among them, like crash looping pods, into alerts.
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/common/model"
+	"github.com/vu-long/alertmanager-bot/pkg/alertmanager"
+)
+
+const (
+	// serviceAccountDir has the credentials of the pod's service account
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// retryInterval after which a failed watch is started again
+	retryInterval = 5 * time.Second
+
+	// Receiver of the webhooks of events, templates can be chosen for it like for the Alertmanager's receivers
+	Receiver = "kubernetes"
+)
+
+// DefaultReasons of the events turned into alerts
+var DefaultReasons = []string{"BackOff", "OOMKilling", "NodeNotReady"}
+
+// alertnames of the events by their reason, others are named Kubernetes<Reason>
+var alertnames = map[string]string{
+	"BackOff":      "KubernetesCrashLoopBackOff",
+	"OOMKilling":   "KubernetesOOMKilled",
+	"NodeNotReady": "KubernetesNodeNotReady",
+}
+
+// ObjectMeta of the API objects
+type ObjectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// ObjectReference to the object an event is about
+type ObjectReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// EventSource is the component and host reporting an event
+type EventSource struct {
+	Component string `json:"component,omitempty"`
+	Host      string `json:"host,omitempty"`
+}
+
+// Event of the core API
+type Event struct {
+	Metadata       ObjectMeta      `json:"metadata"`
+	InvolvedObject ObjectReference `json:"involvedObject"`
+	Reason         string          `json:"reason"`
+	Message        string          `json:"message"`
+	Type           string          `json:"type"`
+	Source         EventSource     `json:"source"`
+	FirstTimestamp time.Time       `json:"firstTimestamp"`
+	LastTimestamp  time.Time       `json:"lastTimestamp"`
+}
+
+type eventList struct {
+	Metadata ObjectMeta `json:"metadata"`
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Watcher sends the events with one of the reasons as webhooks, like they were alerts of the Alertmanager
+type Watcher struct {
+	url       *url.URL
+	token     string
+	namespace string
+	reasons   map[string]bool
+	client    *http.Client
+	logger    log.Logger
+}
+
+// NewWatcher watches the events of the namespace, all namespaces if empty, with the API at the URL
+func NewWatcher(u *url.URL, token string, client *http.Client, namespace string, reasons []string, logger log.Logger) *Watcher {
+	w := &Watcher{
+		url:       u,
+		token:     token,
+		namespace: namespace,
+		reasons:   make(map[string]bool, len(reasons)),
+		client:    client,
+		logger:    logger,
+	}
+	for _, r := range reasons {
+		w.reasons[r] = true
+	}
+	return w
+}
+
+// InClusterWatcher watches the events with the service account of the pod it runs in
+func InClusterWatcher(namespace string, reasons []string, logger log.Logger) (*Watcher, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are empty")
+	}
+	token, err := ioutil.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in %s/ca.crt", serviceAccountDir)
+	}
+
+	u := &url.URL{Scheme: "https", Host: net.JoinHostPort(host, port)}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	return NewWatcher(u, strings.TrimSpace(string(token)), client, namespace, reasons, logger), nil
+}
+
+// Run sends the webhooks of the events until the context is canceled.
+// Only events added after it started are sent, failed watches are started again.
+func (w *Watcher) Run(ctx context.Context, webhooks chan<- alertmanager.Webhook) error {
+	for {
+		err := w.watch(ctx, func(e Event) {
+			if !w.reasons[e.Reason] {
+				return
+			}
+			level.Debug(w.logger).Log("msg", "received kubernetes event", "reason", e.Reason, "kind", e.InvolvedObject.Kind, "name", e.InvolvedObject.Name)
+			select {
+			case webhooks <- EventWebhook(e):
+			case <-ctx.Done():
+			}
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		level.Warn(w.logger).Log("msg", "failed to watch kubernetes events", "err", err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// watch lists the events for the current resource version and calls fn for every event added after it
+func (w *Watcher) watch(ctx context.Context, fn func(Event)) error {
+	var list eventList
+	resp, err := w.get(ctx, url.Values{})
+	if err != nil {
+		return err
+	}
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	resp, err = w.get(ctx, url.Values{"watch": {"1"}, "resourceVersion": {list.Metadata.ResourceVersion}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var we watchEvent
+		if err := dec.Decode(&we); err != nil {
+			return err
+		}
+		switch we.Type {
+		case "ADDED":
+			var e Event
+			if err := json.Unmarshal(we.Object, &e); err != nil {
+				return err
+			}
+			fn(e)
+		case "ERROR":
+			// Like 410 Gone when the resource version is too old
+			return fmt.Errorf("watch failed: %s", we.Object)
+		}
+	}
+}
+
+func (w *Watcher) get(ctx context.Context, params url.Values) (*http.Response, error) {
+	u := *w.url
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v1/events"
+	if w.namespace != "" {
+		u.Path = strings.TrimSuffix(w.url.Path, "/") + "/api/v1/namespaces/" + w.namespace + "/events"
+	}
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("kubernetes returned %s for %s", resp.Status, u.Path)
+	}
+	return resp, nil
+}
+
+// EventWebhook turns the event into the webhook of a firing alert labeled with the involved object.
+// Events of pods are labeled with the node reporting them, so that chats subscribed to nodes receive them.
+func EventWebhook(e Event) alertmanager.Webhook {
+	alertname, ok := alertnames[e.Reason]
+	if !ok {
+		alertname = "Kubernetes" + e.Reason
+	}
+
+	labels := template.KV{
+		"alertname": alertname,
+		"reason":    e.Reason,
+		"severity":  "warning",
+		"kind":      e.InvolvedObject.Kind,
+	}
+	// Like pod=api-7d9f or deployment=api
+	labels[strings.ToLower(e.InvolvedObject.Kind)] = e.InvolvedObject.Name
+	if e.InvolvedObject.Namespace != "" {
+		labels["namespace"] = e.InvolvedObject.Namespace
+	}
+	if e.InvolvedObject.Kind == "Node" {
+		labels["node"] = e.InvolvedObject.Name
+	} else if e.Source.Host != "" {
+		labels["node"] = e.Source.Host
+	}
+
+	object := e.InvolvedObject.Name
+	if e.InvolvedObject.Namespace != "" {
+		object = e.InvolvedObject.Namespace + "/" + object
+	}
+	startsAt := e.LastTimestamp
+	if startsAt.IsZero() {
+		startsAt = e.FirstTimestamp
+	}
+
+	alert := template.Alert{
+		Status: string(model.AlertFiring),
+		Labels: labels,
+		Annotations: template.KV{
+			"summary":     fmt.Sprintf("%s %s: %s", e.InvolvedObject.Kind, object, e.Reason),
+			"description": e.Message,
+		},
+		StartsAt: startsAt,
+	}
+
+	return alertmanager.Webhook{WebhookMessage: notify.WebhookMessage{
+		Data: &template.Data{
+			Receiver:          Receiver,
+			Status:            string(model.AlertFiring),
+			Alerts:            template.Alerts{alert},
+			GroupLabels:       template.KV{"alertname": alertname},
+			CommonLabels:      labels,
+			CommonAnnotations: alert.Annotations,
+		},
+		Version: "4",
+	}}
+}
@@ -0,0 +1,93 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/vu-long/alertmanager-bot/pkg/alertmanager"
+)
+
+func TestEventWebhook(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	w := EventWebhook(Event{
+		InvolvedObject: ObjectReference{Kind: "Pod", Namespace: "shop", Name: "api-7d9f"},
+		Reason:         "BackOff",
+		Message:        "Back-off restarting failed container",
+		Source:         EventSource{Component: "kubelet", Host: "node-1"},
+		LastTimestamp:  now,
+	})
+	assert.Equal(t, Receiver, w.Receiver)
+	assert.Equal(t, "firing", w.Status)
+	assert.Len(t, w.Alerts, 1)
+
+	alert := w.Alerts[0]
+	assert.Equal(t, "KubernetesCrashLoopBackOff", alert.Labels["alertname"])
+	assert.Equal(t, "api-7d9f", alert.Labels["pod"])
+	assert.Equal(t, "shop", alert.Labels["namespace"])
+	assert.Equal(t, "node-1", alert.Labels["node"])
+	assert.Equal(t, "Pod shop/api-7d9f: BackOff", alert.Annotations["summary"])
+	assert.Equal(t, "Back-off restarting failed container", alert.Annotations["description"])
+	assert.Equal(t, now, alert.StartsAt)
+
+	w = EventWebhook(Event{
+		InvolvedObject: ObjectReference{Kind: "Node", Name: "node-2"},
+		Reason:         "NodeNotReady",
+		FirstTimestamp: now,
+	})
+	alert = w.Alerts[0]
+	assert.Equal(t, "KubernetesNodeNotReady", alert.Labels["alertname"])
+	assert.Equal(t, "node-2", alert.Labels["node"])
+	assert.NotContains(t, alert.Labels, "namespace")
+	assert.Equal(t, now, alert.StartsAt)
+
+	w = EventWebhook(Event{InvolvedObject: ObjectReference{Kind: "Pod", Name: "db-0"}, Reason: "FailedMount"})
+	assert.Equal(t, "KubernetesFailedMount", w.Alerts[0].Labels["alertname"])
+}
+
+func TestWatcher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/shop/events", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		if r.URL.Query().Get("watch") == "" {
+			fmt.Fprint(w, `{"metadata":{"resourceVersion":"42"}}`)
+			return
+		}
+		assert.Equal(t, "42", r.URL.Query().Get("resourceVersion"))
+		fmt.Fprintln(w, `{"type":"ADDED","object":{"involvedObject":{"kind":"Pod","namespace":"shop","name":"api"},"reason":"Pulled"}}`)
+		fmt.Fprintln(w, `{"type":"ADDED","object":{"involvedObject":{"kind":"Pod","namespace":"shop","name":"api"},"reason":"BackOff"}}`)
+		fmt.Fprintln(w, `{"type":"MODIFIED","object":{"involvedObject":{"kind":"Pod","namespace":"shop","name":"api"},"reason":"BackOff"}}`)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	watcher := NewWatcher(u, "secret", srv.Client(), "shop", DefaultReasons, log.NewNopLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	webhooks := make(chan alertmanager.Webhook, 1)
+	go watcher.Run(ctx, webhooks)
+
+	select {
+	case w := <-webhooks:
+		assert.Equal(t, "KubernetesCrashLoopBackOff", w.Alerts[0].Labels["alertname"])
+		assert.Equal(t, "api", w.Alerts[0].Labels["pod"])
+	case <-time.After(time.Second):
+		t.Fatal("no webhook received")
+	}
+
+	select {
+	case w := <-webhooks:
+		t.Fatalf("unexpected webhook for %s", w.Alerts[0].Labels["alertname"])
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,123 @@
+// Package leader elects one of several replicas of the bot with a lock of the kv store,
+// so that only the leader polls Telegram and sends messages while the others stand by.
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// retryInterval after which a failed attempt to acquire the lock is repeated
+var retryInterval = 5 * time.Second
+
+// ErrLost is returned by Run when the lock was lost, e.g. because the store was unreachable longer than the TTL.
+// The leader can't stop polling Telegram, so it exits and comes back as standby.
+var ErrLost = errors.New("lost leadership")
+
+// Elector acquires the lock of the key and holds it until the context is canceled or it is lost
+type Elector struct {
+	kv     store.Store
+	key    string
+	id     string
+	ttl    time.Duration
+	logger log.Logger
+	gauge  prometheus.Gauge
+
+	leading int32 // atomic
+	elected chan struct{}
+}
+
+// New creates an elector of the lock of the key, whose value is the ID of this replica.
+// A leader that can't renew the lock within the TTL loses it to a standby.
+func New(kv store.Store, key, id string, ttl time.Duration, logger log.Logger) *Elector {
+	return &Elector{
+		kv:     kv,
+		key:    key,
+		id:     id,
+		ttl:    ttl,
+		logger: logger,
+		gauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "alertmanagerbot",
+			Name:      "leader",
+			Help:      "Whether this replica is the leader sending the messages, 1, or standing by, 0",
+		}),
+		elected: make(chan struct{}),
+	}
+}
+
+// Collector returns the gauge of the leadership to be registered
+func (e *Elector) Collector() prometheus.Collector {
+	return e.gauge
+}
+
+// Leading returns whether this replica holds the lock
+func (e *Elector) Leading() bool {
+	return atomic.LoadInt32(&e.leading) == 1
+}
+
+// Elected is closed once this replica acquired the lock
+func (e *Elector) Elected() <-chan struct{} {
+	return e.elected
+}
+
+// Run acquires the lock, retrying until it is free, and holds it.
+// It returns nil when the context is canceled and ErrLost once the lock was lost.
+func (e *Elector) Run(ctx context.Context) error {
+	stop := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(stop)
+	}()
+
+	level.Info(e.logger).Log("msg", "standing by until elected leader", "key", e.key, "id", e.id)
+	for {
+		lock, err := e.kv.NewLock(e.key, &store.LockOptions{Value: []byte(e.id), TTL: e.ttl, RenewLock: make(chan struct{})})
+		if err == store.ErrCallNotSupported {
+			return errors.New("the store doesn't support locks, leader election needs consul")
+		}
+		var lost <-chan struct{}
+		if err == nil {
+			lost, err = lock.Lock(stop)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil || lost == nil {
+			level.Warn(e.logger).Log("msg", "failed to acquire leader lock", "err", err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(retryInterval):
+			}
+			continue
+		}
+
+		atomic.StoreInt32(&e.leading, 1)
+		e.gauge.Set(1)
+		close(e.elected)
+		level.Info(e.logger).Log("msg", "elected leader", "key", e.key, "id", e.id)
+
+		select {
+		case <-lost:
+			atomic.StoreInt32(&e.leading, 0)
+			e.gauge.Set(0)
+			level.Error(e.logger).Log("msg", "lost leadership", "key", e.key, "id", e.id)
+			return ErrLost
+		case <-ctx.Done():
+			atomic.StoreInt32(&e.leading, 0)
+			e.gauge.Set(0)
+			// Releasing the lock lets a standby take over right away instead of after the TTL
+			if err := lock.Unlock(); err != nil {
+				level.Warn(e.logger).Log("msg", "failed to release leader lock", "err", err)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStore hands out the lock once it was released, only NewLock is implemented
+type fakeStore struct {
+	store.Store
+	free chan struct{}
+	lost chan struct{}
+}
+
+func (s *fakeStore) NewLock(key string, options *store.LockOptions) (store.Locker, error) {
+	return &fakeLock{s: s}, nil
+}
+
+type fakeLock struct {
+	s *fakeStore
+}
+
+func (l *fakeLock) Lock(stop chan struct{}) (<-chan struct{}, error) {
+	select {
+	case <-l.s.free:
+		return l.s.lost, nil
+	case <-stop:
+		return nil, nil
+	}
+}
+
+func (l *fakeLock) Unlock() error {
+	l.s.free <- struct{}{}
+	return nil
+}
+
+func TestElector(t *testing.T) {
+	s := &fakeStore{free: make(chan struct{}, 1), lost: make(chan struct{})}
+	s.free <- struct{}{}
+
+	first := New(s, "alertmanager-bot/leader", "a", time.Second, log.NewNopLogger())
+	second := New(s, "alertmanager-bot/leader", "b", time.Second, log.NewNopLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- first.Run(ctx) }()
+	<-first.Elected()
+	assert.True(t, first.Leading())
+
+	// The standby takes over once the leader stops
+	sctx, scancel := context.WithCancel(context.Background())
+	defer scancel()
+	lost := make(chan error)
+	go func() { lost <- second.Run(sctx) }()
+	assert.False(t, second.Leading())
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.False(t, first.Leading())
+
+	select {
+	case <-second.Elected():
+	case <-time.After(time.Second):
+		t.Fatal("the standby wasn't elected")
+	}
+	assert.True(t, second.Leading())
+
+	close(s.lost)
+	assert.Equal(t, ErrLost, <-lost)
+	assert.False(t, second.Leading())
+}
+
+func TestElectorStandby(t *testing.T) {
+	s := &fakeStore{free: make(chan struct{}, 1), lost: make(chan struct{})}
+	e := New(s, "alertmanager-bot/leader", "a", time.Second, log.NewNopLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, e.Run(ctx), "a standby returns when canceled")
+	assert.False(t, e.Leading())
+}
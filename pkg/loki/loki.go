@@ -0,0 +1,107 @@
+// Package loki queries the recent log lines of a stream from Loki,
+// so that alerts can show what their instance logged before they fired.
+package loki
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Line is a log line of a stream
+type Line struct {
+	Time time.Time
+	Text string
+}
+
+// Client queries the logs of a Loki
+type Client struct {
+	endpoint string
+	client   *http.Client
+}
+
+// New creates a client for the Loki at the URL
+func New(u *url.URL) *Client {
+	endpoint := *u
+	endpoint.Path = path.Join(endpoint.Path, "/loki/api/v1/query_range")
+
+	return &Client{
+		endpoint: endpoint.String(),
+		// Alerts wait for their logs, so slow queries are given up early
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Selector matches the streams with all of the labels
+func Selector(labels map[string]string) string {
+	var matchers []string
+	for name, value := range labels {
+		matchers = append(matchers, fmt.Sprintf("%s=%s", name, strconv.Quote(value)))
+	}
+	sort.Strings(matchers)
+	return "{" + strings.Join(matchers, ",") + "}"
+}
+
+type queryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Result []struct {
+			Values [][2]string `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// Tail returns the last lines of the streams matching the query logged in the window before the time, oldest first
+func (c *Client) Tail(query string, end time.Time, window time.Duration, limit int) ([]Line, error) {
+	params := url.Values{
+		"query":     {query},
+		"start":     {strconv.FormatInt(end.Add(-window).UnixNano(), 10)},
+		"end":       {strconv.FormatInt(end.UnixNano(), 10)},
+		"limit":     {strconv.Itoa(limit)},
+		"direction": {"backward"},
+	}
+
+	resp, err := c.client.Get(c.endpoint + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("loki returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var r queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	if r.Status != "success" {
+		return nil, fmt.Errorf("loki query failed: %s", r.Error)
+	}
+
+	// The lines of all streams are merged by their time
+	var lines []Line
+	for _, stream := range r.Data.Result {
+		for _, v := range stream.Values {
+			ns, err := strconv.ParseInt(v[0], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, Line{Time: time.Unix(0, ns), Text: v[1]})
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Time.Before(lines[j].Time) })
+	if len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	return lines, nil
+}
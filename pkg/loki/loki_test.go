@@ -0,0 +1,48 @@
+package loki
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelector(t *testing.T) {
+	assert.Equal(t, `{instance="web-1",namespace="shop \"a\""}`, Selector(map[string]string{"namespace": `shop "a"`, "instance": "web-1"}))
+}
+
+func TestTail(t *testing.T) {
+	end := time.Unix(1600000000, 0)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/loki/api/v1/query_range", r.URL.Path)
+		assert.Equal(t, `{instance="web-1"}`, r.URL.Query().Get("query"))
+		assert.Equal(t, "1599999400000000000", r.URL.Query().Get("start"))
+		assert.Equal(t, "2", r.URL.Query().Get("limit"))
+		assert.Equal(t, "backward", r.URL.Query().Get("direction"))
+		w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[
+			{"stream":{"app":"api"},"values":[["1599999990000000000","panic: nil map"],["1599999900000000000","starting"]]},
+			{"stream":{"app":"worker"},"values":[["1599999950000000000","connection refused"]]}
+		]}}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	lines, err := New(u).Tail(`{instance="web-1"}`, end, 10*time.Minute, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []Line{
+		{Time: time.Unix(1599999950, 0), Text: "connection refused"},
+		{Time: time.Unix(1599999990, 0), Text: "panic: nil map"},
+	}, lines)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "parse error", http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	u, _ = url.Parse(failing.URL)
+	_, err = New(u).Tail("{", end, time.Minute, 10)
+	assert.EqualError(t, err, "loki returned 400 Bad Request: parse error")
+}
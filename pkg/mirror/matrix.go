@@ -0,0 +1,45 @@
+package mirror
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Matrix sends the messages to a room with the client-server API
+type Matrix struct {
+	// URL of the homeserver, e.g. https://matrix.example.com
+	URL *url.URL
+	// Room ID, e.g. !abc:example.com, the user of the token has to be a member
+	Room        string
+	AccessToken string
+
+	txn uint64
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+// Notify sends the message as m.notice event, which clients show like a bot's message. HTML is kept as formatted body
+func (m *Matrix) Notify(msg Message) error {
+	event := matrixMessage{
+		MsgType: "m.notice",
+		Body:    fmt.Sprintf("[%s] %s", msg.Chat, plainText(msg)),
+	}
+	if msg.HTML {
+		event.Format = "org.matrix.custom.html"
+		event.FormattedBody = fmt.Sprintf("<b>[%s]</b> %s", msg.Chat, strings.Replace(msg.Text, "\n", "<br>", -1))
+	}
+
+	// Transaction IDs make retried requests idempotent, they only need to be unique per access token
+	txn := fmt.Sprintf("alertmanager-bot.%d.%d", time.Now().UnixNano(), atomic.AddUint64(&m.txn, 1))
+	u := strings.TrimSuffix(m.URL.String(), "/") + "/_matrix/client/v3/rooms/" + url.PathEscape(m.Room) + "/send/m.room.message/" + txn
+
+	return request("PUT", u, map[string]string{"Authorization": "Bearer " + m.AccessToken}, event)
+}
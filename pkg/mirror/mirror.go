@@ -0,0 +1,143 @@
+// Package mirror delivers copies of the alert and escalation messages to Matrix or Slack,
+// so that teams moving away from Telegram can follow alerts on both platforms.
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// queueSize is the most messages waiting to be mirrored, more are dropped
+	queueSize = 100
+	// flushTimeout is how long pending messages are sent on shutdown
+	flushTimeout = 5 * time.Second
+)
+
+// Message mirrored from a Telegram chat
+type Message struct {
+	// Chat is the title of the Telegram chat the message was sent to
+	Chat string
+	Text string
+	// HTML is set if the text is formatted with Telegram's HTML
+	HTML bool
+}
+
+// Notifier delivers messages to a chat platform
+type Notifier interface {
+	Notify(Message) error
+}
+
+// Client copies the messages to Matrix or Slack in the background, a nil Client leaves Telegram the only platform.
+type Client struct {
+	notifier Notifier
+	logger   log.Logger
+	queue    chan Message
+}
+
+// New creates a client of the notifier
+func New(notifier Notifier, logger log.Logger) *Client {
+	return &Client{
+		notifier: notifier,
+		logger:   logger,
+		queue:    make(chan Message, queueSize),
+	}
+}
+
+// Notify queues the message to be mirrored, it doesn't block
+func (c *Client) Notify(m Message) {
+	if c == nil {
+		return
+	}
+
+	select {
+	case c.queue <- m:
+	default:
+		level.Warn(c.logger).Log("msg", "dropped message because mirroring is too slow", "chat", m.Chat)
+	}
+}
+
+// Run sends the messages until the context is canceled, pending messages are sent before returning
+func (c *Client) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			deadline := time.After(flushTimeout)
+			for {
+				select {
+				case m := <-c.queue:
+					c.send(m)
+				case <-deadline:
+					return nil
+				default:
+					return nil
+				}
+			}
+		case m := <-c.queue:
+			c.send(m)
+		}
+	}
+}
+
+func (c *Client) send(m Message) {
+	if err := c.notifier.Notify(m); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to mirror message", "chat", m.Chat, "err", err)
+	}
+}
+
+var (
+	tags = regexp.MustCompile(`<[^>]*>`)
+	// links of Telegram's HTML, which only knows double quoted href attributes
+	links = regexp.MustCompile(`<a href="([^"]*)">(.*?)</a>`)
+)
+
+// plainText returns the text of the message without formatting
+func plainText(m Message) string {
+	if !m.HTML {
+		return m.Text
+	}
+	return html.UnescapeString(tags.ReplaceAllString(m.Text, ""))
+}
+
+// httpClient is the HTTP client of all notifiers
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// request sends the body as JSON with the headers
+func request(method, url string, headers map[string]string, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("mirror returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNotifier struct {
+	messages []Message
+}
+
+func (n *fakeNotifier) Notify(m Message) error {
+	n.messages = append(n.messages, m)
+	return nil
+}
+
+func TestClient(t *testing.T) {
+	n := &fakeNotifier{}
+	c := New(n, log.NewNopLogger())
+
+	c.Notify(Message{Chat: "ops", Text: "HighCPU is firing"})
+	c.Notify(Message{Chat: "ops", Text: "HighCPU is resolved"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, c.Run(ctx))
+	assert.Len(t, n.messages, 2)
+
+	// A nil client mirrors nothing
+	var disabled *Client
+	disabled.Notify(Message{Text: "HighCPU is firing"})
+}
+
+func TestPlainText(t *testing.T) {
+	assert.Equal(t, "a < b", plainText(Message{Text: "a < b"}))
+	assert.Equal(t, "HighCPU on web-1 & web-2", plainText(Message{Text: "<b>HighCPU</b> on <code>web-1 &amp; web-2</code>", HTML: true}))
+}
+
+func TestMrkdwn(t *testing.T) {
+	for _, tc := range []struct {
+		m    Message
+		want string
+	}{
+		{m: Message{Text: "a < b"}, want: "a &lt; b"},
+		{m: Message{Text: "<b>HighCPU</b> on <code>web-1</code>", HTML: true}, want: "*HighCPU* on `web-1`"},
+		{m: Message{Text: `<a href="https://grafana/d?a=1&amp;b=2">Dashboard</a> of 1 &lt; 2`, HTML: true}, want: "<https://grafana/d?a=1&amp;b=2|Dashboard> of 1 &lt; 2"},
+	} {
+		assert.Equal(t, tc.want, mrkdwn(tc.m))
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	var got matrixMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.True(t, strings.HasPrefix(r.URL.EscapedPath(), "/_matrix/client/v3/rooms/%21abc:example.com/send/m.room.message/"), r.URL.EscapedPath())
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Write([]byte(`{"event_id":"$1"}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	m := &Matrix{URL: u, Room: "!abc:example.com", AccessToken: "secret"}
+	assert.NoError(t, m.Notify(Message{Chat: "ops", Text: "<b>HighCPU</b>\nis firing", HTML: true}))
+
+	assert.Equal(t, "m.notice", got.MsgType)
+	assert.Equal(t, "[ops] HighCPU\nis firing", got.Body)
+	assert.Equal(t, "org.matrix.custom.html", got.Format)
+	assert.Equal(t, "<b>[ops]</b> <b>HighCPU</b><br>is firing", got.FormattedBody)
+}
+
+func TestSlack(t *testing.T) {
+	var got slackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	s := &Slack{WebhookURL: srv.URL}
+	assert.NoError(t, s.Notify(Message{Chat: "ops", Text: "@vu_long acknowledged HighCPU"}))
+	assert.Equal(t, "*[ops]* @vu_long acknowledged HighCPU", got.Text)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer failing.Close()
+	assert.EqualError(t, (&Slack{WebhookURL: failing.URL}).Notify(Message{Text: "HighCPU"}), "mirror returned 403 Forbidden: invalid_token")
+}
@@ -0,0 +1,54 @@
+package mirror
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Slack posts the messages to the channel of an incoming webhook
+type Slack struct {
+	// WebhookURL of the Slack app, e.g. https://hooks.slack.com/services/T000/B000/XXXX
+	WebhookURL string
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// slackFormatting replaces the tags of Telegram's HTML with Slack's mrkdwn
+var slackFormatting = strings.NewReplacer(
+	"<b>", "*", "</b>", "*",
+	"<strong>", "*", "</strong>", "*",
+	"<i>", "_", "</i>", "_",
+	"<em>", "_", "</em>", "_",
+	"<s>", "~", "</s>", "~",
+	"<code>", "`", "</code>", "`",
+	"<pre>", "```", "</pre>", "```",
+)
+
+// slackEscape escapes the characters Slack reserves for its formatting
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// mrkdwn converts the text of the message to Slack's formatting
+func mrkdwn(m Message) string {
+	if !m.HTML {
+		return slackEscape.Replace(m.Text)
+	}
+
+	text := slackFormatting.Replace(m.Text)
+	text = links.ReplaceAllStringFunc(text, func(link string) string {
+		parts := links.FindStringSubmatch(link)
+		return fmt.Sprintf("\x00%s|%s\x01", html.UnescapeString(parts[1]), html.UnescapeString(parts[2]))
+	})
+	text = slackEscape.Replace(html.UnescapeString(tags.ReplaceAllString(text, "")))
+	// The links are only put in angle brackets once the text is escaped
+	return strings.NewReplacer("\x00", "<", "\x01", ">").Replace(text)
+}
+
+// Notify posts the message with the chat in bold
+func (s *Slack) Notify(m Message) error {
+	return request("POST", s.WebhookURL, nil, slackMessage{
+		Text: fmt.Sprintf("*[%s]* %s", slackEscape.Replace(m.Chat), mrkdwn(m)),
+	})
+}
@@ -0,0 +1,140 @@
+// Package outbound calls HTTP endpoints when alerts are acknowledged, forwarded or resolved in Telegram,
+// so that dashboards and incident tooling can follow the state of incidents handled in the chats.
+package outbound
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// queueSize is the most events waiting to be sent, more are dropped
+	queueSize = 100
+	// flushTimeout is how long pending events are sent on shutdown
+	flushTimeout = 5 * time.Second
+
+	// SignatureHeader holds the hex HMAC-SHA256 of the body with the secret, prefixed with sha256=
+	SignatureHeader = "X-Alertmanager-Bot-Signature"
+)
+
+// Event of an alert sent as JSON to the webhooks
+type Event struct {
+	Type    string            `json:"type"`
+	Time    time.Time         `json:"time"`
+	ChatID  int64             `json:"chat_id"`
+	AlertID string            `json:"alert_id"`
+	User    string            `json:"user,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Detail  string            `json:"detail,omitempty"`
+}
+
+// Client posts the events to every webhook URL in the background, Notify ignores the events of a nil Client.
+type Client struct {
+	urls   []string
+	secret string
+	logger log.Logger
+	queue  chan Event
+	client *http.Client
+}
+
+// New creates a client posting to the webhook URLs, signing the bodies if the secret isn't empty
+func New(urls []string, secret string, logger log.Logger) *Client {
+	return &Client{
+		urls:   urls,
+		secret: secret,
+		logger: logger,
+		queue:  make(chan Event, queueSize),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify queues the event to be sent, it doesn't block
+func (c *Client) Notify(e Event) {
+	if c == nil {
+		return
+	}
+
+	select {
+	case c.queue <- e:
+	default:
+		level.Warn(c.logger).Log("msg", "dropped event because the outbound webhooks are too slow", "type", e.Type, "alert_id", e.AlertID)
+	}
+}
+
+// Run sends the events until the context is canceled, pending events are sent before returning
+func (c *Client) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			deadline := time.After(flushTimeout)
+			for {
+				select {
+				case e := <-c.queue:
+					c.send(e)
+				case <-deadline:
+					return nil
+				default:
+					return nil
+				}
+			}
+		case e := <-c.queue:
+			c.send(e)
+		}
+	}
+}
+
+func (c *Client) send(e Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to encode event", "type", e.Type, "alert_id", e.AlertID, "err", err)
+		return
+	}
+
+	for _, u := range c.urls {
+		if err := c.post(u, body); err != nil {
+			level.Warn(c.logger).Log("msg", "failed to send event", "url", u, "type", e.Type, "alert_id", e.AlertID, "err", err)
+		}
+	}
+}
+
+func (c *Client) post(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(c.secret, body))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Sign returns the value of the SignatureHeader of the body, receivers compare it with hmac.Equal
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
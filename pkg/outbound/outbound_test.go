@@ -0,0 +1,43 @@
+package outbound
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	var events []Event
+	var signatures []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var e Event
+		assert.NoError(t, json.Unmarshal(body, &e))
+		events = append(events, e)
+		signatures = append(signatures, r.Header.Get(SignatureHeader))
+		assert.Equal(t, Sign("secret", body), r.Header.Get(SignatureHeader))
+	}))
+	defer srv.Close()
+
+	c := New([]string{srv.URL, srv.URL + "/second"}, "secret", log.NewNopLogger())
+	c.Notify(Event{Type: "acknowledged", Time: time.Unix(0, 0).UTC(), ChatID: -100, AlertID: "HighCPU", User: "bob"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, c.Run(ctx))
+
+	assert.Len(t, events, 2, "every webhook gets the event")
+	assert.Equal(t, Event{Type: "acknowledged", Time: time.Unix(0, 0).UTC(), ChatID: -100, AlertID: "HighCPU", User: "bob"}, events[0])
+	assert.Contains(t, signatures[0], "sha256=")
+
+	// A nil client sends nothing
+	var disabled *Client
+	disabled.Notify(Event{Type: "resolved"})
+}
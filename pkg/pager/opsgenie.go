@@ -0,0 +1,78 @@
+package pager
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Opsgenie creates alerts with the Alert API
+type Opsgenie struct {
+	// URL of the API, e.g. https://api.opsgenie.com or https://api.eu.opsgenie.com
+	URL    *url.URL
+	APIKey string
+}
+
+type opsgenieAlert struct {
+	Message  string            `json:"message"`
+	Alias    string            `json:"alias"`
+	Source   string            `json:"source"`
+	Priority string            `json:"priority"`
+	Details  map[string]string `json:"details,omitempty"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source"`
+}
+
+// opsgeniePriorities map the severities to the priorities of Opsgenie
+var opsgeniePriorities = map[string]string{
+	SeverityCritical: "P1",
+	SeverityError:    "P2",
+	SeverityWarning:  "P3",
+	SeverityInfo:     "P5",
+}
+
+// Trigger the incident as alert
+func (o *Opsgenie) Trigger(i Incident) error {
+	priority, ok := opsgeniePriorities[i.Severity]
+	if !ok {
+		priority = "P3"
+	}
+
+	return post(o.endpoint("/v2/alerts", nil), o.headers(), opsgenieAlert{
+		// Opsgenie limits the message to 130 characters
+		Message:  truncate(130, i.Summary),
+		Alias:    i.Key,
+		Source:   i.Source,
+		Priority: priority,
+		Details:  i.Details,
+	})
+}
+
+// Resolve closes the alert with the key as alias
+func (o *Opsgenie) Resolve(key string) error {
+	return post(o.endpoint("/v2/alerts/"+url.PathEscape(key)+"/close", url.Values{"identifierType": {"alias"}}), o.headers(), opsgenieClose{
+		Source: "alertmanager-bot",
+	})
+}
+
+func (o *Opsgenie) headers() map[string]string {
+	return map[string]string{"Authorization": "GenieKey " + o.APIKey}
+}
+
+// endpoint of the escaped path with the params
+func (o *Opsgenie) endpoint(path string, params url.Values) string {
+	endpoint := strings.TrimSuffix(o.URL.String(), "/") + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+	return endpoint
+}
+
+// truncate shortens the text to n runes
+func truncate(n int, s string) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
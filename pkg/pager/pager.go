@@ -0,0 +1,167 @@
+// Package pager triggers incidents in PagerDuty or Opsgenie, so that their paging policies
+// catch alerts whose escalation in Telegram wasn't acknowledged.
+package pager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// queueSize is the most incidents waiting to be triggered or resolved, more are dropped
+	queueSize = 100
+	// flushTimeout is how long pending incidents are sent on shutdown
+	flushTimeout = 5 * time.Second
+)
+
+// Severities of incidents
+const (
+	SeverityCritical = "critical"
+	SeverityError    = "error"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// Incident triggered for an alert
+type Incident struct {
+	// Key deduplicates the incident and resolves it later
+	Key      string
+	Summary  string
+	Source   string
+	Severity string
+	Details  map[string]string
+}
+
+// Service triggers and resolves incidents in a paging service
+type Service interface {
+	Trigger(Incident) error
+	Resolve(key string) error
+}
+
+// Client pages the service in the background and only resolves the incidents it triggered, a nil Client never pages.
+type Client struct {
+	service Service
+	logger  log.Logger
+	queue   chan func() error
+
+	mu sync.Mutex
+	// triggered are the keys of the open incidents, only these are resolved
+	triggered map[string]bool
+}
+
+// New creates a client of the service
+func New(service Service, logger log.Logger) *Client {
+	return &Client{
+		service:   service,
+		logger:    logger,
+		queue:     make(chan func() error, queueSize),
+		triggered: make(map[string]bool),
+	}
+}
+
+// Trigger queues the incident to be triggered, it doesn't block
+func (c *Client) Trigger(i Incident) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.triggered[i.Key] = true
+	c.mu.Unlock()
+
+	c.enqueue(i.Key, func() error { return c.service.Trigger(i) })
+}
+
+// Resolve queues the incident to be resolved if it was triggered, it doesn't block
+func (c *Client) Resolve(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	triggered := c.triggered[key]
+	delete(c.triggered, key)
+	c.mu.Unlock()
+
+	if triggered {
+		c.enqueue(key, func() error { return c.service.Resolve(key) })
+	}
+}
+
+func (c *Client) enqueue(key string, send func() error) {
+	select {
+	case c.queue <- send:
+	default:
+		level.Warn(c.logger).Log("msg", "dropped incident because paging is too slow", "key", key)
+	}
+}
+
+// Run sends the incidents until the context is canceled, pending incidents are sent before returning
+func (c *Client) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			deadline := time.After(flushTimeout)
+			for {
+				select {
+				case send := <-c.queue:
+					c.send(send)
+				case <-deadline:
+					return nil
+				default:
+					return nil
+				}
+			}
+		case send := <-c.queue:
+			c.send(send)
+		}
+	}
+}
+
+func (c *Client) send(send func() error) {
+	if err := send(); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to page", "err", err)
+	}
+}
+
+// httpClient is the HTTP client of all services
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// post sends the body as JSON with the headers
+func post(url string, headers map[string]string, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("paging service returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package pager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeService struct {
+	triggered []string
+	resolved  []string
+}
+
+func (s *fakeService) Trigger(i Incident) error {
+	s.triggered = append(s.triggered, i.Key)
+	return nil
+}
+
+func (s *fakeService) Resolve(key string) error {
+	s.resolved = append(s.resolved, key)
+	return nil
+}
+
+func TestClient(t *testing.T) {
+	s := &fakeService{}
+	c := New(s, log.NewNopLogger())
+
+	c.Trigger(Incident{Key: "-100/HighCPU"})
+	c.Resolve("-100/HighCPU")
+	c.Resolve("-100/DiskFull")
+	c.Resolve("-100/HighCPU")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, c.Run(ctx))
+
+	assert.Equal(t, []string{"-100/HighCPU"}, s.triggered)
+	assert.Equal(t, []string{"-100/HighCPU"}, s.resolved, "only triggered incidents are resolved, once")
+
+	var disabled *Client
+	disabled.Trigger(Incident{Key: "ignored"})
+	disabled.Resolve("ignored")
+}
+
+func TestPagerDuty(t *testing.T) {
+	events := make(chan pagerDutyEvent, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/enqueue", r.URL.Path)
+
+		var e pagerDutyEvent
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		events <- e
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	p := &PagerDuty{URL: u, RoutingKey: "secret"}
+	assert.NoError(t, p.Trigger(Incident{Key: "-100/HighCPU", Summary: "HighCPU", Source: "alertmanager-bot", Severity: SeverityCritical}))
+	assert.NoError(t, p.Resolve("-100/HighCPU"))
+
+	e := <-events
+	assert.Equal(t, "secret", e.RoutingKey)
+	assert.Equal(t, "trigger", e.EventAction)
+	assert.Equal(t, "-100/HighCPU", e.DedupKey)
+	assert.Equal(t, &pagerDutyPayload{Summary: "HighCPU", Source: "alertmanager-bot", Severity: SeverityCritical}, e.Payload)
+
+	e = <-events
+	assert.Equal(t, pagerDutyEvent{RoutingKey: "secret", EventAction: "resolve", DedupKey: "-100/HighCPU"}, e)
+}
+
+func TestOpsgenie(t *testing.T) {
+	requests := make(chan *http.Request, 2)
+	alerts := make(chan opsgenieAlert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GenieKey secret", r.Header.Get("Authorization"))
+		if r.URL.Path == "/v2/alerts" {
+			var a opsgenieAlert
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&a))
+			alerts <- a
+		}
+		requests <- r
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	o := &Opsgenie{URL: u, APIKey: "secret"}
+	assert.NoError(t, o.Trigger(Incident{Key: "-100/HighCPU", Summary: "HighCPU", Source: "alertmanager-bot", Severity: SeverityWarning}))
+	assert.NoError(t, o.Resolve("-100/HighCPU"))
+
+	<-requests
+	assert.Equal(t, opsgenieAlert{Message: "HighCPU", Alias: "-100/HighCPU", Source: "alertmanager-bot", Priority: "P3"}, <-alerts)
+
+	r := <-requests
+	assert.Equal(t, "/v2/alerts/-100%2FHighCPU/close", r.URL.EscapedPath())
+	assert.Equal(t, "alias", r.URL.Query().Get("identifierType"))
+}
@@ -0,0 +1,58 @@
+package pager
+
+import (
+	"net/url"
+	"strings"
+)
+
+// PagerDuty triggers incidents of a service with the Events API v2
+type PagerDuty struct {
+	// URL of the Events API, e.g. https://events.pagerduty.com
+	URL *url.URL
+	// RoutingKey is the integration key of the service
+	RoutingKey string
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// Trigger the incident
+func (p *PagerDuty) Trigger(i Incident) error {
+	return post(p.endpoint(), nil, pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    i.Key,
+		Payload: &pagerDutyPayload{
+			Summary:       i.Summary,
+			Source:        i.Source,
+			Severity:      i.Severity,
+			CustomDetails: i.Details,
+		},
+	})
+}
+
+// Resolve the incident with the key
+func (p *PagerDuty) Resolve(key string) error {
+	return post(p.endpoint(), nil, pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "resolve",
+		DedupKey:    key,
+	})
+}
+
+func (p *PagerDuty) endpoint() string {
+	u := *p.URL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v2/enqueue"
+	return u.String()
+}
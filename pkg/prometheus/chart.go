@@ -0,0 +1,153 @@
+package prometheus
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// ChartWidth and ChartHeight are the size of rendered charts in pixels
+	ChartWidth  = 800
+	ChartHeight = 400
+
+	// chartMargin around the plot area
+	chartMargin = 20
+	// chartGridLines are drawn horizontally across the plot area
+	chartGridLines = 4
+
+	// MaxChartSeries is the most series drawn in a chart, one per color of the palette
+	MaxChartSeries = 8
+)
+
+var (
+	// palette of the series
+	palette = [MaxChartSeries]color.RGBA{
+		{R: 0xe5, G: 0x39, B: 0x35, A: 0xff},
+		{R: 0x1e, G: 0x88, B: 0xe5, A: 0xff},
+		{R: 0x43, G: 0xa0, B: 0x47, A: 0xff},
+		{R: 0xfb, G: 0x8c, B: 0x00, A: 0xff},
+		{R: 0x8e, G: 0x24, B: 0xaa, A: 0xff},
+		{R: 0xfd, G: 0xd8, B: 0x35, A: 0xff},
+		{R: 0x6d, G: 0x4c, B: 0x41, A: 0xff},
+		{R: 0x21, G: 0x21, B: 0x21, A: 0xff},
+	}
+	// Markers are the emoji of the palette's colors to write a legend with
+	Markers = [MaxChartSeries]string{"🟥", "🟦", "🟩", "🟧", "🟪", "🟨", "🟫", "⬛"}
+
+	gridColor = color.RGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff}
+	axisColor = color.RGBA{R: 0x75, G: 0x75, B: 0x75, A: 0xff}
+)
+
+// Bounds returns the lowest and highest finite value of the series, ok is false without any
+func Bounds(matrix model.Matrix) (min, max float64, ok bool) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, s := range matrix {
+		for _, p := range s.Values {
+			v := float64(p.Value)
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			min, max = math.Min(min, v), math.Max(max, v)
+			ok = true
+		}
+	}
+	return min, max, ok
+}
+
+// RenderChart draws the series, up to MaxChartSeries, as lines from start to end into a PNG.
+// There are no labels, the legend and the bounds are left to the caption.
+func RenderChart(matrix model.Matrix, start, end model.Time) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, ChartWidth, ChartHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	plot := image.Rect(chartMargin, chartMargin, ChartWidth-chartMargin, ChartHeight-chartMargin)
+	for i := 0; i <= chartGridLines; i++ {
+		y := plot.Min.Y + i*plot.Dy()/chartGridLines
+		line(img, plot.Min.X, y, plot.Max.X, y, gridColor)
+	}
+	line(img, plot.Min.X, plot.Min.Y, plot.Min.X, plot.Max.Y, axisColor)
+	line(img, plot.Min.X, plot.Max.Y, plot.Max.X, plot.Max.Y, axisColor)
+
+	min, max, ok := Bounds(matrix)
+	if ok && end > start {
+		// Constant series are drawn in the middle
+		if max == min {
+			min, max = min-1, max+1
+		}
+		x := func(t model.Time) int {
+			return plot.Min.X + int(float64(t-start)/float64(end-start)*float64(plot.Dx()))
+		}
+		y := func(v float64) int {
+			return plot.Max.Y - int((v-min)/(max-min)*float64(plot.Dy()))
+		}
+
+		for i, s := range matrix {
+			if i >= MaxChartSeries {
+				break
+			}
+			c := palette[i]
+			var prevX, prevY int
+			connected := false
+			for _, p := range s.Values {
+				v := float64(p.Value)
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					connected = false
+					continue
+				}
+				px, py := x(p.Timestamp), y(v)
+				if connected {
+					line(img, prevX, prevY, px, py, c)
+					line(img, prevX, prevY+1, px, py+1, c)
+				} else {
+					img.Set(px, py, c)
+				}
+				prevX, prevY, connected = px, py, true
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// line draws a line from x0,y0 to x1,y1 with Bresenham's algorithm
+func line(img draw.Image, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * e; e2 >= dy {
+			e += dy
+			x0 += sx
+		} else {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,33 @@
+package prometheus
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderChart(t *testing.T) {
+	matrix := model.Matrix{
+		{Metric: model.Metric{"job": "nginx"}, Values: []model.SamplePair{{Timestamp: 0, Value: 1}, {Timestamp: 30000, Value: model.SampleValue(math.NaN())}, {Timestamp: 60000, Value: 3}}},
+		{Metric: model.Metric{"job": "httpd"}, Values: []model.SamplePair{{Timestamp: 0, Value: 2}, {Timestamp: 60000, Value: 2}}},
+	}
+
+	min, max, ok := Bounds(matrix)
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, min)
+	assert.Equal(t, 3.0, max)
+
+	chart, err := RenderChart(matrix, 0, 60000)
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(chart))
+	assert.NoError(t, err)
+	assert.Equal(t, ChartWidth, img.Bounds().Dx())
+	assert.Equal(t, ChartHeight, img.Bounds().Dy())
+
+	_, _, ok = Bounds(model.Matrix{{Values: []model.SamplePair{{Value: model.SampleValue(math.Inf(1))}}}})
+	assert.False(t, ok)
+}
@@ -0,0 +1,141 @@
+// Package prometheus queries the HTTP API of Prometheus and renders the results as charts.
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// timeout of a request to Prometheus, Telegram shows "typing" max 5 seconds
+const timeout = 5 * time.Second
+
+// response is the envelope of all responses of the Prometheus API
+type response struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+}
+
+// queryData is the result of a query
+type queryData struct {
+	ResultType model.ValueType `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// get requests the API path with the params and decodes the data of the response into v
+func get(prometheusURL, path string, params url.Values, v interface{}) error {
+	u, err := url.Parse(prometheusURL)
+	if err != nil {
+		return err
+	}
+	u.Path = u.Path + path
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Prometheus explains failed queries in the body with 400 and 422
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("status code is %d: %v", resp.StatusCode, err)
+	}
+	if r.Status != "success" {
+		return fmt.Errorf("%s: %s", r.ErrorType, r.Error)
+	}
+	return json.Unmarshal(r.Data, v)
+}
+
+// QueryRange evaluates the expression from start to end with the step and returns the series
+func QueryRange(prometheusURL, expr string, start, end time.Time, step time.Duration) (model.Matrix, error) {
+	params := url.Values{}
+	params.Set("query", expr)
+	params.Set("start", formatTime(start))
+	params.Set("end", formatTime(end))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	var data queryData
+	if err := get(prometheusURL, "/api/v1/query_range", params, &data); err != nil {
+		return nil, err
+	}
+	if data.ResultType != model.ValMatrix {
+		return nil, fmt.Errorf("expected a range vector, got %s", data.ResultType)
+	}
+
+	var matrix model.Matrix
+	if err := json.Unmarshal(data.Result, &matrix); err != nil {
+		return nil, err
+	}
+	return matrix, nil
+}
+
+// Query evaluates the expression at the time and returns the vector, scalar or string result
+func Query(prometheusURL, expr string, at time.Time) (model.Value, error) {
+	params := url.Values{}
+	params.Set("query", expr)
+	params.Set("time", formatTime(at))
+
+	var data queryData
+	if err := get(prometheusURL, "/api/v1/query", params, &data); err != nil {
+		return nil, err
+	}
+
+	switch data.ResultType {
+	case model.ValVector:
+		var vector model.Vector
+		err := json.Unmarshal(data.Result, &vector)
+		return vector, err
+	case model.ValScalar:
+		var scalar model.Scalar
+		err := json.Unmarshal(data.Result, &scalar)
+		return &scalar, err
+	case model.ValString:
+		var str model.String
+		err := json.Unmarshal(data.Result, &str)
+		return &str, err
+	default:
+		return nil, fmt.Errorf("unexpected result type %s", data.ResultType)
+	}
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', -1, 64)
+}
+
+// ExprFromGeneratorURL returns the expression and the Prometheus URL of an alert's generatorURL,
+// e.g. http://prometheus:9090/graph?g0.expr=up+%3D%3D+0&g0.tab=1
+func ExprFromGeneratorURL(generatorURL string) (expr, prometheusURL string, err error) {
+	u, err := url.Parse(generatorURL)
+	if err != nil {
+		return "", "", err
+	}
+	expr = u.Query().Get("g0.expr")
+	if expr == "" {
+		return "", "", fmt.Errorf("%s has no expression", generatorURL)
+	}
+
+	base := *u
+	base.RawQuery = ""
+	base.Fragment = ""
+	// Prometheus served below a prefix links to <prefix>/graph
+	base.Path = strings.TrimSuffix(base.Path, "/graph")
+	return expr, base.String(), nil
+}
@@ -0,0 +1,106 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExprFromGeneratorURL(t *testing.T) {
+	expr, u, err := ExprFromGeneratorURL("http://prometheus:9090/prom/graph?g0.expr=up+%3D%3D+0&g0.tab=1")
+	assert.NoError(t, err)
+	assert.Equal(t, "up == 0", expr)
+	assert.Equal(t, "http://prometheus:9090/prom", u)
+
+	_, _, err = ExprFromGeneratorURL("http://prometheus:9090/graph")
+	assert.Error(t, err)
+}
+
+func TestQueryRange(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/query_range", r.URL.Path)
+		assert.Equal(t, "up", r.URL.Query().Get("query"))
+		assert.Equal(t, "60", r.URL.Query().Get("step"))
+		if r.URL.Query().Get("start") == "0" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"invalid start"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"nginx"},"values":[[1600000000,"1"],[1600000060,"0"]]}]}}`))
+	}))
+	defer srv.Close()
+
+	matrix, err := QueryRange(srv.URL, "up", time.Unix(1600000000, 0), time.Unix(1600000060, 0), time.Minute)
+	assert.NoError(t, err)
+	if assert.Len(t, matrix, 1) {
+		assert.Equal(t, model.LabelValue("nginx"), matrix[0].Metric["job"])
+		assert.Equal(t, model.SampleValue(0), matrix[0].Values[1].Value)
+	}
+
+	_, err = QueryRange(srv.URL, "up", time.Unix(0, 0), time.Unix(60, 0), time.Minute)
+	assert.EqualError(t, err, "bad_data: invalid start")
+}
+
+func TestQuery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/query", r.URL.Path)
+		switch r.URL.Query().Get("query") {
+		case "up":
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"nginx"},"value":[1600000000,"1"]}]}}`))
+		default:
+			w.Write([]byte(`{"status":"success","data":{"resultType":"scalar","result":[1600000000,"2"]}}`))
+		}
+	}))
+	defer srv.Close()
+
+	v, err := Query(srv.URL, "up", time.Unix(1600000000, 0))
+	assert.NoError(t, err)
+	if assert.IsType(t, model.Vector{}, v) {
+		assert.Len(t, v.(model.Vector), 1)
+	}
+
+	v, err = Query(srv.URL, "1+1", time.Unix(1600000000, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Scalar{Value: 2, Timestamp: 1600000000000}, v)
+}
+
+func TestListTargets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/targets", r.URL.Path)
+		w.Write([]byte(`{"status":"success","data":{"activeTargets":[{"labels":{"job":"nginx","instance":"web-1:9113"},"scrapeUrl":"http://web-1:9113/metrics","lastError":"connection refused","health":"down"}]}}`))
+	}))
+	defer srv.Close()
+
+	targets, err := ListTargets(srv.URL)
+	assert.NoError(t, err)
+	if assert.Len(t, targets, 1) {
+		assert.Equal(t, "nginx", targets[0].Job())
+		assert.Equal(t, "web-1:9113", targets[0].Instance())
+		assert.Equal(t, HealthDown, targets[0].Health)
+		assert.Equal(t, "connection refused", targets[0].LastError)
+	}
+}
+
+func TestListAlertingRules(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/rules", r.URL.Path)
+		assert.Equal(t, "alert", r.URL.Query().Get("type"))
+		w.Write([]byte(`{"status":"success","data":{"groups":[{"name":"node","file":"/etc/prometheus/node.yml","rules":[{"name":"HighCPU","query":"cpu_usage > 90","duration":300,"labels":{"severity":"warning"},"state":"pending","health":"ok","type":"alerting","alerts":[{"labels":{"instance":"web-1"},"state":"pending"}]}]}]}}`))
+	}))
+	defer srv.Close()
+
+	groups, err := ListAlertingRules(srv.URL)
+	assert.NoError(t, err)
+	if assert.Len(t, groups, 1) && assert.Len(t, groups[0].Rules, 1) {
+		r := groups[0].Rules[0]
+		assert.Equal(t, "HighCPU", r.Name)
+		assert.Equal(t, "cpu_usage > 90", r.Query)
+		assert.Equal(t, float64(300), r.Duration)
+		assert.Equal(t, RulePending, r.State)
+		assert.Len(t, r.Alerts, 1)
+	}
+}
@@ -0,0 +1,55 @@
+package prometheus
+
+import (
+	"net/url"
+
+	"github.com/prometheus/common/model"
+)
+
+// States of alerting rules
+const (
+	RuleInactive = "inactive"
+	RulePending  = "pending"
+	RuleFiring   = "firing"
+)
+
+// Rule is an alerting rule of Prometheus
+type Rule struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	// Duration in seconds the expression has to be true before the rule fires
+	Duration    float64        `json:"duration"`
+	Labels      model.LabelSet `json:"labels"`
+	Annotations model.LabelSet `json:"annotations"`
+	State       string         `json:"state"`
+	Health      string         `json:"health"`
+	LastError   string         `json:"lastError"`
+	// Alerts are the pending and firing alerts of the rule
+	Alerts []RuleAlert `json:"alerts"`
+}
+
+// RuleAlert is a pending or firing alert of a rule
+type RuleAlert struct {
+	Labels model.LabelSet `json:"labels"`
+	State  string         `json:"state"`
+}
+
+// RuleGroup is a group of rules evaluated together
+type RuleGroup struct {
+	Name  string `json:"name"`
+	File  string `json:"file"`
+	Rules []Rule `json:"rules"`
+}
+
+type rulesData struct {
+	Groups []RuleGroup `json:"groups"`
+}
+
+// ListAlertingRules returns the groups of alerting rules of Prometheus
+func ListAlertingRules(prometheusURL string) ([]RuleGroup, error) {
+	var data rulesData
+	if err := get(prometheusURL, "/api/v1/rules", url.Values{"type": {"alert"}}, &data); err != nil {
+		return nil, err
+	}
+	return data.Groups, nil
+}
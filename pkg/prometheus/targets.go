@@ -0,0 +1,45 @@
+package prometheus
+
+import (
+	"net/url"
+
+	"github.com/prometheus/common/model"
+)
+
+// Health of a scrape target
+const (
+	HealthUp      = "up"
+	HealthDown    = "down"
+	HealthUnknown = "unknown"
+)
+
+// Target is an active scrape target of Prometheus
+type Target struct {
+	Labels    model.LabelSet `json:"labels"`
+	ScrapeURL string         `json:"scrapeUrl"`
+	LastError string         `json:"lastError"`
+	Health    string         `json:"health"`
+}
+
+// Job of the target
+func (t Target) Job() string {
+	return string(t.Labels[model.JobLabel])
+}
+
+// Instance of the target
+func (t Target) Instance() string {
+	return string(t.Labels[model.InstanceLabel])
+}
+
+type targetsData struct {
+	ActiveTargets []Target `json:"activeTargets"`
+}
+
+// ListTargets returns the active scrape targets of Prometheus
+func ListTargets(prometheusURL string) ([]Target, error) {
+	var data targetsData
+	if err := get(prometheusURL, "/api/v1/targets", url.Values{}, &data); err != nil {
+		return nil, err
+	}
+	return data.ActiveTargets, nil
+}
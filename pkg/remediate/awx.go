@@ -0,0 +1,82 @@
+package remediate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// awxPollInterval in which the status of launched jobs is checked
+var awxPollInterval = 5 * time.Second
+
+// AWX launches a job template of Ansible AWX or Tower with the alert as extra variables and waits for the job
+type AWX struct {
+	// URL of AWX, e.g. https://awx.example.com
+	URL         string `yaml:"url"`
+	JobTemplate int    `yaml:"job_template"`
+	// Token is an OAuth2 token of AWX, or read from the TokenFile
+	Token     string `yaml:"token,omitempty"`
+	TokenFile string `yaml:"token_file,omitempty"`
+}
+
+type awxLaunch struct {
+	ExtraVars Alert `json:"extra_vars"`
+}
+
+type awxJob struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+}
+
+func (a *AWX) run(ctx context.Context, alert Alert) (string, error) {
+	token := a.Token
+	if a.TokenFile != "" {
+		b, err := ioutil.ReadFile(a.TokenFile)
+		if err != nil {
+			return "", err
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	headers := map[string]string{"Authorization": "Bearer " + token}
+	base := strings.TrimSuffix(a.URL, "/")
+
+	body, err := do(ctx, http.MethodPost, fmt.Sprintf("%s/api/v2/job_templates/%d/launch/", base, a.JobTemplate), headers, awxLaunch{ExtraVars: alert})
+	if err != nil {
+		return "", err
+	}
+	var job awxJob
+	if err := json.Unmarshal(body, &job); err != nil {
+		return "", err
+	}
+	if job.ID == 0 {
+		return "", fmt.Errorf("awx returned no job ID")
+	}
+	link := fmt.Sprintf("%s/#/jobs/playbook/%d", base, job.ID)
+
+	// The job is pending, waiting or running until it finished
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("awx job %d didn't finish in time: %s", job.ID, link)
+		case <-time.After(awxPollInterval):
+		}
+
+		body, err := do(ctx, http.MethodGet, fmt.Sprintf("%s/api/v2/jobs/%d/", base, job.ID), headers, nil)
+		if err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal(body, &job); err != nil {
+			return "", err
+		}
+		switch job.Status {
+		case "successful":
+			return fmt.Sprintf("AWX job %d successful: %s", job.ID, link), nil
+		case "failed", "error", "canceled":
+			return "", fmt.Errorf("awx job %d %s: %s", job.ID, job.Status, link)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Package remediate runs the remediation actions configured for alerts,
+// outbound webhooks or job templates of Ansible AWX, and reports their result.
+package remediate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// maxResultLength is the longest response body shown as result, longer ones are cut off
+const maxResultLength = 500
+
+// Alert remediated by an action
+type Alert struct {
+	Name        string            `json:"alertname"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	// User is the username of who ran the action
+	User string `json:"user"`
+}
+
+// Action remediates the alerts with one of the alertnames by calling a webhook or launching an AWX job template
+type Action struct {
+	Name       string   `yaml:"name"`
+	Alertnames []string `yaml:"alertnames"`
+	Webhook    *Webhook `yaml:"webhook,omitempty"`
+	AWX        *AWX     `yaml:"awx,omitempty"`
+}
+
+// Config is the content of the remediation configuration file
+type Config struct {
+	Actions []Action `yaml:"actions"`
+
+	byAlertname map[string]*Action
+}
+
+// Load parses the remediation configuration from YAML
+func Load(b []byte) (*Config, error) {
+	var c Config
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(c.Actions))
+	c.byAlertname = make(map[string]*Action)
+	for i, a := range c.Actions {
+		if a.Name == "" {
+			return nil, fmt.Errorf("remediation action without name")
+		}
+		if names[a.Name] {
+			return nil, fmt.Errorf("duplicate remediation action %q", a.Name)
+		}
+		names[a.Name] = true
+		if (a.Webhook == nil) == (a.AWX == nil) {
+			return nil, fmt.Errorf("remediation action %q needs either a webhook or an awx job template", a.Name)
+		}
+		for _, alertname := range a.Alertnames {
+			if other, ok := c.byAlertname[alertname]; ok {
+				return nil, fmt.Errorf("alert %q has the remediation actions %q and %q", alertname, other.Name, a.Name)
+			}
+			c.byAlertname[alertname] = &c.Actions[i]
+		}
+	}
+	return &c, nil
+}
+
+// LoadFile parses the remediation configuration file
+func LoadFile(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Load(b)
+}
+
+// ForAlert returns the action remediating the alerts with the alertname, nil if there is none
+func (c *Config) ForAlert(alertname string) *Action {
+	if c == nil {
+		return nil
+	}
+	return c.byAlertname[alertname]
+}
+
+// Run the action for the alert and return its result
+func (a *Action) Run(ctx context.Context, alert Alert) (string, error) {
+	if a.Webhook != nil {
+		return a.Webhook.run(ctx, a.Name, alert)
+	}
+	return a.AWX.run(ctx, alert)
+}
+
+// do sends the body as JSON, if any, with the headers and returns the response body
+func do(ctx context.Context, method, url string, headers map[string]string, body interface{}) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	msg, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s returned %s: %s", url, resp.Status, truncate(strings.TrimSpace(string(msg))))
+	}
+	return msg, nil
+}
+
+func truncate(s string) string {
+	if r := []rune(s); len(r) > maxResultLength {
+		return string(r[:maxResultLength]) + "…"
+	}
+	return s
+}
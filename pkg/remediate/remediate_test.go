@@ -0,0 +1,96 @@
+package remediate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	c, err := Load([]byte(`
+actions:
+- name: restart-nginx
+  alertnames: [NginxDown, NginxErrors]
+  webhook:
+    url: https://hooks.example.com/restart
+- name: clean-disk
+  alertnames: [DiskFull]
+  awx:
+    url: https://awx.example.com
+    job_template: 42
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, "restart-nginx", c.ForAlert("NginxErrors").Name)
+	assert.Equal(t, 42, c.ForAlert("DiskFull").AWX.JobTemplate)
+	assert.Nil(t, c.ForAlert("HighCPU"))
+
+	var disabled *Config
+	assert.Nil(t, disabled.ForAlert("DiskFull"))
+
+	for _, invalid := range []string{
+		"actions:\n- alertnames: [A]\n  webhook: {url: http://x}",
+		"actions:\n- name: a\n  alertnames: [A]",
+		"actions:\n- name: a\n  alertnames: [A]\n  webhook: {url: http://x}\n- name: b\n  alertnames: [A]\n  webhook: {url: http://x}",
+		"actions:\n- name: a\n  unknown: true",
+	} {
+		_, err := Load([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got webhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Token"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Write([]byte("restarted nginx on web-1\n"))
+	}))
+	defer srv.Close()
+
+	a := &Action{Name: "restart-nginx", Webhook: &Webhook{URL: srv.URL, Headers: map[string]string{"X-Token": "secret"}}}
+	alert := Alert{Name: "NginxDown", Labels: map[string]string{"instance": "web-1"}, User: "alice"}
+	result, err := a.Run(context.Background(), alert)
+	assert.NoError(t, err)
+	assert.Equal(t, "restarted nginx on web-1", result)
+	assert.Equal(t, webhookPayload{Action: "restart-nginx", Alert: alert}, got)
+}
+
+func TestAWX(t *testing.T) {
+	awxPollInterval = time.Millisecond
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/api/v2/job_templates/42/launch/":
+			var launch awxLaunch
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&launch))
+			assert.Equal(t, "DiskFull", launch.ExtraVars.Name)
+			w.Write([]byte(`{"job":7,"id":7,"status":"pending"}`))
+		case "/api/v2/jobs/7/":
+			polls++
+			status := "running"
+			if polls > 1 {
+				status = "successful"
+			}
+			fmt.Fprintf(w, `{"id":7,"status":%q}`, status)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	a := &Action{Name: "clean-disk", AWX: &AWX{URL: srv.URL, JobTemplate: 42, Token: "secret"}}
+	result, err := a.Run(context.Background(), Alert{Name: "DiskFull"})
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("AWX job 7 successful: %s/#/jobs/playbook/7", srv.URL), result)
+
+	a.AWX.JobTemplate = 43
+	_, err = a.Run(context.Background(), Alert{Name: "DiskFull"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,29 @@
+package remediate
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Webhook posts the alert as JSON to the URL
+type Webhook struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+type webhookPayload struct {
+	Action string `json:"action"`
+	Alert
+}
+
+func (w *Webhook) run(ctx context.Context, action string, alert Alert) (string, error) {
+	body, err := do(ctx, http.MethodPost, w.URL, w.Headers, webhookPayload{Action: action, Alert: alert})
+	if err != nil {
+		return "", err
+	}
+	if result := strings.TrimSpace(string(body)); result != "" {
+		return truncate(result), nil
+	}
+	return "done", nil
+}
@@ -0,0 +1,41 @@
+package runbook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// commandEnv is the environment of a command: PATH of the bot, the env of its action and the user in RUNBOOK_USER.
+// The rest of the bot's environment holds its tokens and isn't passed on.
+func commandEnv(env map[string]string, user string) []string {
+	vars := []string{"PATH=" + os.Getenv("PATH")}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vars = append(vars, name+"="+env[name])
+	}
+	return append(vars, "RUNBOOK_USER="+user)
+}
+
+// runCommand executes the command with the env and returns its combined output
+func runCommand(ctx context.Context, command []string, env map[string]string, user string) (string, error) {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = commandEnv(env, user)
+
+	out, err := cmd.CombinedOutput()
+	output := tail(strings.TrimSpace(string(out)))
+	if ctx.Err() == context.DeadlineExceeded {
+		return output, fmt.Errorf("timed out")
+	}
+	if err != nil {
+		return output, err
+	}
+	return output, nil
+}
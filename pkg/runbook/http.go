@@ -0,0 +1,59 @@
+package runbook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// HTTP request of an action, a POST of the action and user as JSON by default
+type HTTP struct {
+	URL     string            `yaml:"url"`
+	Method  string            `yaml:"method,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Body replaces the default JSON body
+	Body string `yaml:"body,omitempty"`
+}
+
+func (h *HTTP) run(ctx context.Context, action, user string) (string, error) {
+	method := h.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	body := h.Body
+	if body == "" {
+		b, err := json.Marshal(map[string]string{"action": action, "user": user})
+		if err != nil {
+			return "", err
+		}
+		body = string(b)
+	}
+
+	req, err := http.NewRequest(method, h.URL, strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range h.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	output := tail(strings.TrimSpace(string(b)))
+	if resp.StatusCode/100 != 2 {
+		return output, fmt.Errorf("%s returned %s", h.URL, resp.Status)
+	}
+	return output, nil
+}
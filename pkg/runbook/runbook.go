@@ -0,0 +1,119 @@
+// Package runbook runs the whitelisted ChatOps actions of the runbook configuration,
+// commands executed on the bot's host or HTTP requests, and returns their output.
+package runbook
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// defaultTimeout of actions without timeout
+	defaultTimeout = time.Minute
+	// maxOutputLength is the longest output returned, longer output is cut off at the start
+	maxOutputLength = 3000
+)
+
+// Action of the runbook, either a command or an HTTP request
+type Action struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	// Command is executed without a shell, its first element is the program and the rest are its arguments
+	Command []string `yaml:"command,omitempty"`
+	// Env of the command next to PATH and RUNBOOK_USER, the bot's own environment isn't passed on
+	Env     map[string]string `yaml:"env,omitempty"`
+	HTTP    *HTTP             `yaml:"http,omitempty"`
+	Timeout model.Duration    `yaml:"timeout,omitempty"`
+}
+
+// Config is the content of the runbook configuration file
+type Config struct {
+	Actions []Action `yaml:"actions"`
+
+	byName map[string]*Action
+}
+
+// Load parses the runbook configuration from YAML
+func Load(b []byte) (*Config, error) {
+	var c Config
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return nil, err
+	}
+
+	c.byName = make(map[string]*Action, len(c.Actions))
+	for i, a := range c.Actions {
+		if a.Name == "" {
+			return nil, fmt.Errorf("runbook action without name")
+		}
+		if _, ok := c.byName[a.Name]; ok {
+			return nil, fmt.Errorf("duplicate runbook action %q", a.Name)
+		}
+		if (len(a.Command) == 0) == (a.HTTP == nil) {
+			return nil, fmt.Errorf("runbook action %q needs either a command or an http request", a.Name)
+		}
+		if a.HTTP != nil && len(a.Env) > 0 {
+			return nil, fmt.Errorf("runbook action %q sets env, only commands have one", a.Name)
+		}
+		if a.HTTP != nil && a.HTTP.URL == "" {
+			return nil, fmt.Errorf("runbook action %q needs the url of its http request", a.Name)
+		}
+		c.byName[a.Name] = &c.Actions[i]
+	}
+	return &c, nil
+}
+
+// LoadFile parses the runbook configuration file
+func LoadFile(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Load(b)
+}
+
+// Get returns the action with the name, nil if there is none
+func (c *Config) Get(name string) *Action {
+	if c == nil {
+		return nil
+	}
+	return c.byName[name]
+}
+
+// List returns the actions sorted by name
+func (c *Config) List() []Action {
+	if c == nil {
+		return nil
+	}
+	actions := append([]Action(nil), c.Actions...)
+	sort.Slice(actions, func(i, j int) bool { return actions[i].Name < actions[j].Name })
+	return actions
+}
+
+// Run the action on behalf of the user and return its output
+func (a *Action) Run(ctx context.Context, user string) (string, error) {
+	timeout := time.Duration(a.Timeout)
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if a.HTTP != nil {
+		return a.HTTP.run(ctx, a.Name, user)
+	}
+	return runCommand(ctx, a.Command, a.Env, user)
+}
+
+// tail cuts off the start of long output, the end usually tells what went wrong
+func tail(s string) string {
+	if r := []rune(s); len(r) > maxOutputLength {
+		return "…" + string(r[len(r)-maxOutputLength:])
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package runbook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	c, err := Load([]byte(`
+actions:
+- name: restart-nginx
+  description: Restart nginx on the web servers
+  command: [systemctl, restart, nginx]
+- name: flush-cache
+  http:
+    url: https://cache.example.com/flush
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"systemctl", "restart", "nginx"}, c.Get("restart-nginx").Command)
+	assert.Nil(t, c.Get("rm"))
+	assert.Equal(t, "flush-cache", c.List()[0].Name)
+
+	for _, invalid := range []string{
+		"actions: [{command: [true]}]",
+		"actions: [{name: a, command: [true]}, {name: a, command: [false]}]",
+		"actions: [{name: a}]",
+		"actions: [{name: a, command: [true], http: {url: https://example.com}}]",
+		"actions: [{name: a, http: {}}]",
+		"actions: [{name: a, http: {url: https://example.com}, env: {A: b}}]",
+		"actions: [{name: a, shell: rm -rf /}]",
+	} {
+		_, err := Load([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+
+	var disabled *Config
+	assert.Nil(t, disabled.Get("restart-nginx"))
+}
+
+func TestCommand(t *testing.T) {
+	a := &Action{Name: "whoami", Command: []string{"sh", "-c", `echo "run by $RUNBOOK_USER"`}}
+	output, err := a.Run(context.Background(), "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, "run by bob", output)
+
+	// Only PATH, the env of the action and RUNBOOK_USER are passed on
+	os.Setenv("TELEGRAM_TOKEN", "secret")
+	defer os.Unsetenv("TELEGRAM_TOKEN")
+	a = &Action{Name: "env", Command: []string{"sh", "-c", `echo "$TELEGRAM_TOKEN$ANSIBLE_CONFIG"`}, Env: map[string]string{"ANSIBLE_CONFIG": "/etc/ansible.cfg"}}
+	output, err = a.Run(context.Background(), "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/ansible.cfg", output)
+
+	a = &Action{Name: "fail", Command: []string{"sh", "-c", "echo broken; exit 3"}}
+	output, err = a.Run(context.Background(), "bob")
+	assert.Error(t, err)
+	assert.Equal(t, "broken", output)
+}
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]string{"action": "flush-cache", "user": "bob"}, body)
+		assert.Equal(t, "secret", r.Header.Get("X-Token"))
+		w.Write([]byte("flushed\n"))
+	}))
+	defer srv.Close()
+
+	a := &Action{Name: "flush-cache", HTTP: &HTTP{URL: srv.URL, Headers: map[string]string{"X-Token": "secret"}}}
+	output, err := a.Run(context.Background(), "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, "flushed", output)
+}
+
+func TestTail(t *testing.T) {
+	long := strings.Repeat("a", maxOutputLength) + "end"
+	assert.Equal(t, "…"+long[3:], tail(long))
+}
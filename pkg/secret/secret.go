@@ -0,0 +1,105 @@
+// Package secret reads credentials from files, like secrets mounted by Kubernetes, and HashiCorp Vault,
+// so they don't have to be passed as environment variables or process arguments.
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FromFile reads a secret from a file, surrounding whitespace like a trailing newline is removed
+func FromFile(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Vault looks up secrets in the key value secrets engine of a HashiCorp Vault
+type Vault struct {
+	Addr   *url.URL
+	Token  string
+	Client *http.Client
+}
+
+// NewVault creates a Vault client for the address authenticating with the token
+func NewVault(addr *url.URL, token string) *Vault {
+	return &Vault{
+		Addr:   addr,
+		Token:  token,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type vaultResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []string               `json:"errors"`
+}
+
+// Lookup returns the secret referenced as 'path#key', e.g. 'secret/data/alertmanager-bot#token'.
+// Both versions of the key value secrets engine are supported.
+func (v *Vault) Lookup(ref string) (string, error) {
+	parts := strings.SplitN(ref, "#", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid vault secret %q, expected path#key", ref)
+	}
+	path, key := strings.Trim(parts[0], "/"), parts[1]
+
+	u := *v.Addr
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/" + path
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var vr vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&vr); err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s for %s: %s", resp.Status, path, strings.Join(vr.Errors, ", "))
+	}
+
+	data := vr.Data
+	// Version 2 of the engine nests the secret's data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+
+	value, ok := data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no string key %s", path, key)
+	}
+	return value, nil
+}
+
+// Resolve returns the secret read from the file if set, otherwise looked up
+// in the vault if a reference is set, otherwise the plain value.
+func Resolve(value, file, ref string, vault *Vault) (string, error) {
+	switch {
+	case file != "":
+		return FromFile(file)
+	case ref != "":
+		if vault == nil {
+			return "", fmt.Errorf("vault secret %q requires a vault address", ref)
+		}
+		return vault.Lookup(ref)
+	}
+	return value, nil
+}
@@ -0,0 +1,67 @@
+package secret
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "token")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("123:abc\n")
+	assert.NoError(t, err)
+	f.Close()
+
+	token, err := Resolve("ignored", f.Name(), "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "123:abc", token)
+}
+
+func TestVaultLookup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/bot":
+			w.Write([]byte(`{"data":{"data":{"token":"v2"},"metadata":{"version":1}}}`))
+		case "/v1/kv/bot":
+			w.Write([]byte(`{"data":{"token":"v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	addr, _ := url.Parse(srv.URL)
+	v := NewVault(addr, "root")
+
+	token, err := v.Lookup("secret/data/bot#token")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", token)
+
+	token, err = Resolve("", "", "kv/bot#token", v)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", token)
+
+	_, err = v.Lookup("kv/bot#missing")
+	assert.Error(t, err)
+	_, err = v.Lookup("kv/other#token")
+	assert.Error(t, err)
+	_, err = v.Lookup("kv/bot")
+	assert.Error(t, err)
+
+	_, err = NewVault(addr, "wrong").Lookup("kv/bot#token")
+	assert.Contains(t, err.Error(), "permission denied")
+}
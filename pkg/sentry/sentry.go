@@ -0,0 +1,250 @@
+// Package sentry reports errors and panics to Sentry, so that maintainers get
+// aggregated error reports of many deployments.
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// queueSize is the most events waiting to be sent, more are dropped
+	queueSize = 100
+	// flushTimeout is how long pending events are sent on shutdown, events still pending then are dropped
+	flushTimeout = 5 * time.Second
+)
+
+// Levels of events
+const (
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+// Exception of an event
+type Exception struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Exceptions of an event, the errors that caused it
+type Exceptions struct {
+	Values []Exception `json:"values"`
+}
+
+// Event reported to Sentry
+type Event struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Platform    string                 `json:"platform"`
+	Logger      string                 `json:"logger,omitempty"`
+	Message     string                 `json:"message,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	ServerName  string                 `json:"server_name,omitempty"`
+	Fingerprint []string               `json:"fingerprint,omitempty"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	Exception   *Exceptions            `json:"exception,omitempty"`
+}
+
+// Client reports the events to the project of a Sentry DSN, a nil Client is what the bot uses without SENTRY_DSN.
+type Client struct {
+	dsn         string
+	endpoint    string
+	auth        string
+	release     string
+	environment string
+	serverName  string
+	client      *http.Client
+	logger      log.Logger
+	queue       chan *Event
+}
+
+// New creates a client for the DSN, e.g. https://public@sentry.example.com/1
+func New(dsn, release, environment string, logger log.Logger) (*Client, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %v", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("invalid DSN: missing public key")
+	}
+
+	i := strings.LastIndex(u.Path, "/")
+	project := u.Path[i+1:]
+	if project == "" {
+		return nil, fmt.Errorf("invalid DSN: missing project id")
+	}
+
+	endpoint := url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   u.Path[:i] + "/api/" + project + "/envelope/",
+	}
+	hostname, _ := os.Hostname()
+
+	return &Client{
+		dsn:         dsn,
+		endpoint:    endpoint.String(),
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_client=alertmanager-bot/%s, sentry_key=%s", release, u.User.Username()),
+		release:     release,
+		environment: environment,
+		serverName:  hostname,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		logger:      logger,
+		queue:       make(chan *Event, queueSize),
+	}, nil
+}
+
+// CaptureError reports the error with the message and context.
+// Events with the same message are grouped, independent of the error and context.
+func (c *Client) CaptureError(msg string, err error, tags map[string]string, extra map[string]interface{}) {
+	if c == nil {
+		return
+	}
+
+	e := c.event(LevelError, msg, tags, extra)
+	e.Fingerprint = []string{msg}
+	if err != nil {
+		e.Exception = &Exceptions{Values: []Exception{{Type: reflect.TypeOf(err).String(), Value: err.Error()}}}
+	}
+	c.enqueue(e)
+}
+
+// CapturePanic reports the recovered value of a panic and the stack trace of the panicking goroutine
+func (c *Client) CapturePanic(recovered interface{}, stack []byte, tags map[string]string) {
+	if c == nil {
+		return
+	}
+
+	e := c.event(LevelFatal, "panic", tags, map[string]interface{}{"stack": string(stack)})
+	e.Exception = &Exceptions{Values: []Exception{{Type: "panic", Value: fmt.Sprint(recovered)}}}
+	c.enqueue(e)
+}
+
+func (c *Client) event(lvl, msg string, tags map[string]string, extra map[string]interface{}) *Event {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return &Event{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   time.Now().UTC().Format("2006-01-02T15:04:05"),
+		Level:       lvl,
+		Platform:    "go",
+		Logger:      "alertmanager-bot",
+		Message:     msg,
+		Release:     c.release,
+		Environment: c.environment,
+		ServerName:  c.serverName,
+		Tags:        tags,
+		Extra:       extra,
+	}
+}
+
+func (c *Client) enqueue(e *Event) {
+	select {
+	case c.queue <- e:
+	default:
+		level.Warn(c.logger).Log("msg", "dropped sentry event because sending is too slow", "message", e.Message)
+	}
+}
+
+// Run sends the events until the context is canceled. The pending events are then sent
+// until the queue is empty or flushTimeout passed.
+func (c *Client) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			c.flush()
+			return nil
+		case e := <-c.queue:
+			// The event is sent completely even if the context is canceled meanwhile
+			c.send(context.Background(), e)
+		}
+	}
+}
+
+// flush sends the queued events until the queue is empty or flushTimeout passed
+func (c *Client) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if n := len(c.queue); n > 0 {
+				level.Warn(c.logger).Log("msg", "dropped pending sentry events on shutdown", "count", n)
+			}
+			return
+		case e := <-c.queue:
+			c.send(ctx, e)
+		default:
+			// Nothing is enqueued anymore once the bot stopped
+			return
+		}
+	}
+}
+
+// envelope wraps the event in the envelope format of Sentry's envelope endpoint
+func (c *Client) envelope(e *Event) ([]byte, error) {
+	event, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(map[string]string{
+		"event_id": e.EventID,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339),
+		"dsn":      c.dsn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.Write(header)
+	fmt.Fprintf(&b, "\n{\"type\":\"event\",\"length\":%d}\n", len(event))
+	b.Write(event)
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+func (c *Client) send(ctx context.Context, e *Event) {
+	body, err := c.envelope(e)
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to encode sentry event", "err", err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to create sentry request", "err", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", c.auth)
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to send sentry event", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		level.Warn(c.logger).Log("msg", "failed to send sentry event", "err", fmt.Errorf("sentry returned %s", resp.Status))
+	}
+}
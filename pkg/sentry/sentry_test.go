@@ -0,0 +1,72 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	c, err := New("https://public@sentry.example.com/prefix/42", "v1.0.0", "production", log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Equal(t, "https://sentry.example.com/prefix/api/42/envelope/", c.endpoint)
+	assert.Contains(t, c.auth, "sentry_key=public")
+
+	_, err = New("https://sentry.example.com/42", "", "", log.NewNopLogger())
+	assert.Error(t, err, "missing public key")
+	_, err = New("https://public@sentry.example.com/", "", "", log.NewNopLogger())
+	assert.Error(t, err, "missing project id")
+}
+
+func TestCapture(t *testing.T) {
+	events := make(chan Event, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/42/envelope/", r.URL.Path)
+		assert.Contains(t, r.Header.Get("X-Sentry-Auth"), "sentry_key=public")
+
+		// The envelope header, the item header and the event, one per line
+		var header, item map[string]interface{}
+		var e Event
+		dec := json.NewDecoder(r.Body)
+		assert.NoError(t, dec.Decode(&header))
+		assert.NoError(t, dec.Decode(&item))
+		assert.NoError(t, dec.Decode(&e))
+		assert.Equal(t, e.EventID, header["event_id"])
+		assert.Equal(t, "event", item["type"])
+		events <- e
+	}))
+	defer srv.Close()
+
+	c, err := New(strings.Replace(srv.URL, "://", "://public@", 1)+"/42", "v1.0.0", "production", log.NewNopLogger())
+	assert.NoError(t, err)
+
+	c.CaptureError("failed to send alert", errors.New("Too Many Requests"), map[string]string{"chat_id": "-100"}, nil)
+	c.CapturePanic("index out of range", []byte("goroutine 1 [running]:"), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, c.Run(ctx))
+
+	e := <-events
+	assert.Equal(t, LevelError, e.Level)
+	assert.Equal(t, []string{"failed to send alert"}, e.Fingerprint)
+	assert.Equal(t, "-100", e.Tags["chat_id"])
+	assert.Equal(t, "Too Many Requests", e.Exception.Values[0].Value)
+	assert.Equal(t, "v1.0.0", e.Release)
+	assert.Len(t, e.EventID, 32)
+
+	e = <-events
+	assert.Equal(t, LevelFatal, e.Level)
+	assert.Equal(t, "index out of range", e.Exception.Values[0].Value)
+	assert.Equal(t, "goroutine 1 [running]:", e.Extra["stack"])
+
+	var disabled *Client
+	disabled.CaptureError("ignored", nil, nil, nil)
+}
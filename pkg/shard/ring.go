@@ -0,0 +1,65 @@
+package shard
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// virtualNodes of every member on the ring spread the keys evenly
+const virtualNodes = 100
+
+// Ring assigns keys to members by consistent hashing, so that a member joining
+// or leaving only moves the keys of its own share
+type Ring struct {
+	members []string
+	hashes  []uint32
+	owners  map[uint32]string
+}
+
+// NewRing places the members on the ring
+func NewRing(members ...string) *Ring {
+	r := &Ring{owners: make(map[uint32]string)}
+	for _, m := range members {
+		r.members = append(r.members, m)
+		for i := 0; i < virtualNodes; i++ {
+			h := hash(m + "#" + strconv.Itoa(i))
+			// Collisions keep the lexically smaller member, to be the same on every replica
+			if owner, ok := r.owners[h]; ok {
+				if m < owner {
+					r.owners[h] = m
+				}
+				continue
+			}
+			r.hashes = append(r.hashes, h)
+			r.owners[h] = m
+		}
+	}
+	sort.Strings(r.members)
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// Members returns the sorted members of the ring
+func (r *Ring) Members() []string {
+	return append([]string(nil), r.members...)
+}
+
+// Owner returns the member owning the key, the empty string if the ring is empty
+func (r *Ring) Owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+func hash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
@@ -0,0 +1,32 @@
+package shard
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing(t *testing.T) {
+	assert.Empty(t, NewRing().Owner("-100"))
+
+	r := NewRing("b", "a", "c")
+	assert.Equal(t, []string{"a", "b", "c"}, r.Members())
+
+	owned := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		owned[r.Owner(strconv.Itoa(-100000-i))]++
+	}
+	for _, m := range r.Members() {
+		assert.InDelta(t, 1000, owned[m], 400, "member %s owns about a third of the keys", m)
+	}
+
+	// Only the keys of a leaving member move
+	without := NewRing("a", "b")
+	for i := 0; i < 3000; i++ {
+		key := strconv.Itoa(-100000 - i)
+		if owner := r.Owner(key); owner != "c" {
+			assert.Equal(t, owner, without.Owner(key))
+		}
+	}
+}
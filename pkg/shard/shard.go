@@ -0,0 +1,147 @@
+// Package shard splits the chats among several replicas of the bot. Every replica registers
+// itself in the kv store and owns the chats the consistent hash ring of the live replicas assigns to it.
+package shard
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// member is the value of the key of a registered replica
+type member struct {
+	ID   string    `json:"id"`
+	Seen time.Time `json:"seen"`
+}
+
+// Shard registers this replica under the prefix of the kv store and keeps the ring of the live replicas
+type Shard struct {
+	kv     store.Store
+	prefix string
+	id     string
+	ttl    time.Duration
+	logger log.Logger
+	gauge  prometheus.Gauge
+
+	mu   sync.RWMutex
+	ring *Ring
+}
+
+// New creates the shard of the replica with the ID. Replicas that didn't renew
+// their registration within the TTL are dropped from the ring and their chats move to the others.
+func New(kv store.Store, prefix, id string, ttl time.Duration, logger log.Logger) *Shard {
+	return &Shard{
+		kv:     kv,
+		prefix: strings.TrimSuffix(prefix, "/"),
+		id:     id,
+		ttl:    ttl,
+		logger: logger,
+		gauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "alertmanagerbot",
+			Name:      "shard_members",
+			Help:      "Number of live replicas the chats are split among",
+		}),
+		ring: NewRing(id),
+	}
+}
+
+// Collector returns the gauge of the live replicas to be registered
+func (s *Shard) Collector() prometheus.Collector {
+	return s.gauge
+}
+
+// ID returns the ID of this replica
+func (s *Shard) ID() string {
+	return s.id
+}
+
+// Owner returns the ID of the replica owning the key
+func (s *Shard) Owner(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ring.Owner(key)
+}
+
+// Owns returns whether this replica owns the key
+func (s *Shard) Owns(key string) bool {
+	return s.Owner(key) == s.id
+}
+
+// Run renews the registration of this replica and refreshes the ring of the live replicas
+// until the context is canceled, then it deregisters the replica so the others take over its chats.
+func (s *Shard) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		if err := s.register(time.Now()); err != nil {
+			level.Warn(s.logger).Log("msg", "failed to register replica", "err", err)
+		}
+		if err := s.refresh(time.Now()); err != nil {
+			level.Warn(s.logger).Log("msg", "failed to list replicas", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := s.kv.Delete(s.key(s.id)); err != nil && err != store.ErrKeyNotFound {
+				level.Warn(s.logger).Log("msg", "failed to deregister replica", "err", err)
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Shard) key(id string) string {
+	return path.Join(s.prefix, "members", id)
+}
+
+func (s *Shard) register(now time.Time) error {
+	value, err := json.Marshal(member{ID: s.id, Seen: now})
+	if err != nil {
+		return err
+	}
+	return s.kv.Put(s.key(s.id), value, nil)
+}
+
+// refresh rebuilds the ring of the replicas seen within the TTL, this replica is always part of it
+func (s *Shard) refresh(now time.Time) error {
+	pairs, err := s.kv.List(path.Join(s.prefix, "members"))
+	if err != nil && err != store.ErrKeyNotFound {
+		return err
+	}
+
+	ids := []string{s.id}
+	for _, kv := range pairs {
+		var m member
+		if err := json.Unmarshal(kv.Value, &m); err != nil {
+			level.Warn(s.logger).Log("msg", "failed to decode replica", "key", kv.Key, "err", err)
+			continue
+		}
+		if m.ID == s.id || now.Sub(m.Seen) > s.ttl {
+			continue
+		}
+		ids = append(ids, m.ID)
+	}
+
+	ring := NewRing(ids...)
+	s.mu.Lock()
+	changed := !reflect.DeepEqual(s.ring.Members(), ring.Members())
+	s.ring = ring
+	s.mu.Unlock()
+
+	s.gauge.Set(float64(len(ids)))
+	if changed {
+		level.Info(s.logger).Log("msg", "replicas changed, chats are reassigned", "replicas", strings.Join(ring.Members(), ","))
+	}
+	return nil
+}
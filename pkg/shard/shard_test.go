@@ -0,0 +1,48 @@
+package shard
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shard")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	defer kv.Close()
+
+	a := New(kv, "alertmanager-bot/shards", "a", 15*time.Second, log.NewNopLogger())
+	b := New(kv, "alertmanager-bot/shards", "b", 15*time.Second, log.NewNopLogger())
+	assert.True(t, a.Owns("-100"), "a replica alone owns all chats")
+
+	now := time.Now()
+	assert.NoError(t, a.register(now))
+	assert.NoError(t, b.register(now))
+	assert.NoError(t, a.refresh(now))
+	assert.NoError(t, b.refresh(now))
+
+	// Both replicas agree on the owner of every chat
+	owners := make(map[string]bool)
+	for _, chat := range []string{"-100", "-200", "-300", "-400", "-500", "-600"} {
+		assert.Equal(t, a.Owner(chat), b.Owner(chat))
+		assert.NotEqual(t, a.Owns(chat), b.Owns(chat))
+		owners[a.Owner(chat)] = true
+	}
+	assert.Len(t, owners, 2)
+
+	// Replicas that stopped renewing their registration are dropped
+	assert.NoError(t, a.refresh(now.Add(time.Minute)))
+	assert.True(t, a.Owns("-100"))
+	assert.Equal(t, []string{"a"}, a.ring.Members())
+}
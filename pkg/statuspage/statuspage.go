@@ -0,0 +1,112 @@
+// Package statuspage opens and resolves incidents on a page of Atlassian Statuspage,
+// so that users learn about outages responders are working on.
+package statuspage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Statuses of incidents
+const (
+	StatusInvestigating = "investigating"
+	StatusIdentified    = "identified"
+	StatusMonitoring    = "monitoring"
+	StatusResolved      = "resolved"
+)
+
+// Incident on the status page
+type Incident struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Shortlink is the public link of the incident
+	Shortlink string `json:"shortlink"`
+}
+
+// Client creates and updates the incidents of a page with the Statuspage API
+type Client struct {
+	// URL of the API, e.g. https://api.statuspage.io
+	URL    *url.URL
+	PageID string
+	APIKey string
+	// Impact shown for created incidents, e.g. major, empty lets Statuspage derive it from the components
+	Impact string
+}
+
+type incidentRequest struct {
+	Incident incidentFields `json:"incident"`
+}
+
+type incidentFields struct {
+	Name           string `json:"name,omitempty"`
+	Status         string `json:"status"`
+	Body           string `json:"body,omitempty"`
+	ImpactOverride string `json:"impact_override,omitempty"`
+}
+
+// client is the HTTP client of the API
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Create opens an incident that is being investigated
+func (c *Client) Create(name, body string) (Incident, error) {
+	var created Incident
+	err := c.do(http.MethodPost, "/incidents", incidentRequest{Incident: incidentFields{
+		Name:           name,
+		Status:         StatusInvestigating,
+		Body:           body,
+		ImpactOverride: c.Impact,
+	}}, &created)
+	if err != nil {
+		return Incident{}, err
+	}
+	if created.ID == "" {
+		return Incident{}, fmt.Errorf("statuspage returned no incident ID")
+	}
+	return created, nil
+}
+
+// Update sets the status of the incident and posts the body as update
+func (c *Client) Update(id, status, body string) (Incident, error) {
+	var updated Incident
+	err := c.do(http.MethodPatch, "/incidents/"+url.PathEscape(id), incidentRequest{Incident: incidentFields{
+		Status: status,
+		Body:   body,
+	}}, &updated)
+	return updated, err
+}
+
+// do sends the body as JSON to the path of the page and decodes the response into v
+func (c *Client) do(method, path string, body, v interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	u := *c.URL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/pages/" + url.PathEscape(c.PageID) + path
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "OAuth "+c.APIKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("statuspage returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
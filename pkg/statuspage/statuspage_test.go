@@ -0,0 +1,52 @@
+package statuspage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	var requests []incidentRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "OAuth secret", r.Header.Get("Authorization"))
+
+		var req incidentRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/pages/p1/incidents":
+			w.Write([]byte(`{"id":"inc1","name":"HighCPU","status":"investigating","shortlink":"https://stspg.io/abc"}`))
+		case r.Method == http.MethodPatch && r.URL.Path == "/v1/pages/p1/incidents/inc1":
+			w.Write([]byte(`{"id":"inc1","name":"HighCPU","status":"resolved","shortlink":"https://stspg.io/abc"}`))
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	c := &Client{URL: u, PageID: "p1", APIKey: "secret", Impact: "major"}
+
+	incident, err := c.Create("HighCPU", "We are investigating.")
+	assert.NoError(t, err)
+	assert.Equal(t, Incident{ID: "inc1", Name: "HighCPU", Status: StatusInvestigating, Shortlink: "https://stspg.io/abc"}, incident)
+
+	incident, err = c.Update("inc1", StatusResolved, "Resolved.")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusResolved, incident.Status)
+
+	assert.Equal(t, []incidentRequest{
+		{Incident: incidentFields{Name: "HighCPU", Status: StatusInvestigating, Body: "We are investigating.", ImpactOverride: "major"}},
+		{Incident: incidentFields{Status: StatusResolved, Body: "Resolved."}},
+	}, requests)
+
+	c.PageID = "missing"
+	_, err = c.Create("HighCPU", "")
+	assert.EqualError(t, err, "statuspage returned 404 Not Found: not found")
+}
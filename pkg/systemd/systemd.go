@@ -0,0 +1,106 @@
+// Package systemd tells systemd about the state of the bot with the sd_notify protocol, so that a service
+// of Type=notify is only started once the bot is ready and restarted when it stops petting its watchdog.
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// Ready tells systemd that the service finished starting up
+	Ready = "READY=1"
+	// Stopping tells systemd that the service is shutting down
+	Stopping = "STOPPING=1"
+	// Watchdog pets the watchdog of the service, which is restarted without it within WatchdogSec
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notifier sends the states of the service to the socket systemd passed in $NOTIFY_SOCKET
+type Notifier struct {
+	socket   string
+	interval time.Duration // of the watchdog, disabled if 0
+	logger   log.Logger
+}
+
+// New returns the notifier of the service, nil if the bot isn't run by systemd with Type=notify.
+// The watchdog is enabled if systemd passed its interval in $WATCHDOG_USEC for this process.
+func New(logger log.Logger) (*Notifier, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil, nil
+	}
+	n := &Notifier{socket: socket, logger: logger}
+
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return n, nil
+	}
+	// The watchdog may be meant for the parent process of the bot, e.g. a shell wrapper
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return n, nil
+	}
+	us, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || us <= 0 {
+		return nil, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+	n.interval = time.Duration(us) * time.Microsecond
+	return n, nil
+}
+
+// WatchdogInterval returns the time after which systemd restarts the service without a petted watchdog, 0 if disabled
+func (n *Notifier) WatchdogInterval() time.Duration {
+	return n.interval
+}
+
+// Notify sends the state, e.g. Ready, to systemd
+func (n *Notifier) Notify(state string) error {
+	// Names starting with @ are abstract sockets
+	addr := &net.UnixAddr{Name: n.socket, Net: "unixgram"}
+	if addr.Name[0] == '@' {
+		addr.Name = "\x00" + addr.Name[1:]
+	}
+	conn, err := net.DialUnix(addr.Net, nil, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Run pets the watchdog twice per interval as long as alive returns nil, until the context is canceled.
+// Once alive fails systemd restarts the service within the interval, unless it recovers meanwhile.
+func (n *Notifier) Run(ctx context.Context, alive func() error) error {
+	if n.interval == 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	level.Info(n.logger).Log("msg", "petting the systemd watchdog", "interval", n.interval)
+	ticker := time.NewTicker(n.interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := alive(); err != nil {
+				level.Error(n.logger).Log("msg", "not petting the systemd watchdog", "err", err)
+				continue
+			}
+			if err := n.Notify(Watchdog); err != nil {
+				level.Warn(n.logger).Log("msg", "failed to pet the systemd watchdog", "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// listen creates the socket of $NOTIFY_SOCKET, removed by the returned func
+func listen(t *testing.T) (*net.UnixConn, func()) {
+	dir, err := ioutil.TempDir("", "systemd")
+	assert.NoError(t, err)
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("NOTIFY_SOCKET", socket)
+
+	return conn, func() {
+		os.Unsetenv("NOTIFY_SOCKET")
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// receive returns the next state sent to the socket
+func receive(t *testing.T, conn *net.UnixConn) string {
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	assert.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestNewWithoutSystemd(t *testing.T) {
+	n, err := New(log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Nil(t, n)
+}
+
+func TestNotify(t *testing.T) {
+	conn, cleanup := listen(t)
+	defer cleanup()
+
+	n, err := New(log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), n.WatchdogInterval())
+
+	assert.NoError(t, n.Notify(Ready))
+	assert.Equal(t, "READY=1", receive(t, conn))
+}
+
+func TestWatchdog(t *testing.T) {
+	conn, cleanup := listen(t)
+	defer cleanup()
+	os.Setenv("WATCHDOG_USEC", "20000")
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	n, err := New(log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Equal(t, 20*time.Millisecond, n.WatchdogInterval())
+
+	stalled := make(chan error, 1)
+	stalled <- errors.New("stalled")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx, func() error {
+		select {
+		case err := <-stalled:
+			return err
+		default:
+			return nil
+		}
+	})
+
+	// The stalled first check isn't petted, the next one is
+	assert.Equal(t, "WATCHDOG=1", receive(t, conn))
+	assert.Empty(t, stalled)
+
+	// The watchdog of another process, e.g. a wrapping shell, isn't petted
+	os.Setenv("WATCHDOG_PID", "1")
+	n, err = New(log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), n.WatchdogInterval())
+
+	os.Setenv("WATCHDOG_PID", "")
+	os.Setenv("WATCHDOG_USEC", "soon")
+	_, err = New(log.NewNopLogger())
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const commandAcked = "/acked"
+
+// ackedLine formats the acknowledged alert as a line of /acked
+func ackedLine(a StoredAlert, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s acknowledged", a.ID)
+	if a.AcknowledgedBy != "" {
+		fmt.Fprintf(&b, " by @%s", a.AcknowledgedBy)
+	}
+	// The alert fired first when the Alertmanager says, it may have fired again since
+	since := a.Alert.StartsAt
+	if since.IsZero() {
+		since = a.FiredAt
+	}
+	fmt.Fprintf(&b, " %s ago, firing for %s", now.Sub(a.AcknowledgedAt).Round(time.Second), now.Sub(since).Round(time.Second))
+	if a.Ticket != nil {
+		fmt.Fprintf(&b, ", ticket %s", a.Ticket.ID)
+	}
+	return b.String()
+}
+
+// acknowledgedAlerts returns the acknowledged alerts of the chat whose resolved webhook didn't arrive yet,
+// acknowledged longest ago first. The alert store holds those of all replicas, without it those this bot tracks are listed.
+func (b *Bot) acknowledgedAlerts(chat telebot.Chat) ([]StoredAlert, error) {
+	var alerts []StoredAlert
+	if b.alertStore != nil {
+		// Resolved alerts are removed from the store
+		stored, err := b.alertStore.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range stored {
+			if a.Chat.ID == chat.ID {
+				alerts = append(alerts, a)
+			}
+		}
+	} else {
+		for _, a := range b.alerts.Chat(chat) {
+			if atomic.LoadInt32(&a.resolved) == 0 {
+				alerts = append(alerts, a.stored())
+			}
+		}
+	}
+
+	acked := alerts[:0]
+	for _, a := range alerts {
+		if !a.AcknowledgedAt.IsZero() {
+			acked = append(acked, a)
+		}
+	}
+	sort.SliceStable(acked, func(i, j int) bool {
+		return acked[i].AcknowledgedAt.Before(acked[j].AcknowledgedAt)
+	})
+	return acked, nil
+}
+
+func (b *Bot) handleAcked(message telebot.Message) {
+	alerts, err := b.acknowledgedAlerts(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to list stored alerts", "err", err)
+		b.reply(message, "I can't get the acknowledged alerts.", nil)
+		return
+	}
+	if len(alerts) == 0 {
+		b.reply(message, "No acknowledged alerts of this chat are waiting to be resolved.", nil)
+		return
+	}
+
+	now := time.Now()
+	var list []string
+	for _, a := range alerts {
+		list = append(list, ackedLine(a, now))
+	}
+
+	b.sendPages(message, paginate("Acknowledged alerts that aren't resolved yet:\n", list, itemsPerPage), "")
+}
@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/ticket"
+)
+
+func TestAckedLine(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	a := StoredAlert{
+		ID:             "HighCPU",
+		Alert:          template.Alert{StartsAt: now.Add(-3 * time.Hour)},
+		FiredAt:        now.Add(-time.Minute),
+		AcknowledgedBy: "vu_long",
+		AcknowledgedAt: now.Add(-2 * time.Hour),
+	}
+	assert.Equal(t, "HighCPU acknowledged by @vu_long 2h0m0s ago, firing for 3h0m0s", ackedLine(a, now))
+
+	a.AcknowledgedBy = ""
+	a.Alert.StartsAt = time.Time{}
+	a.Ticket = &ticket.Ticket{ID: "OPS-42"}
+	assert.Equal(t, "HighCPU acknowledged 2h0m0s ago, firing for 1m0s, ticket OPS-42", ackedLine(a, now))
+}
+
+func TestAcknowledgedAlerts(t *testing.T) {
+	ops := telebot.Chat{ID: -100}
+	dev := telebot.Chat{ID: -200}
+	now := time.Now()
+
+	early := &HandleAlert{ID: "DiskFull", Group: "DiskFull", Chat: ops, MessageID: 1, AcknowledgedBy: "sre", AcknowledgedAt: now.Add(-2 * time.Hour)}
+	late := &HandleAlert{ID: "HighCPU", Group: "HighCPU", Chat: ops, MessageID: 2, AcknowledgedBy: "vu_long", AcknowledgedAt: now.Add(-time.Hour)}
+	open := &HandleAlert{ID: "HighLoad", Group: "HighLoad", Chat: ops, MessageID: 3}
+	resolved := &HandleAlert{ID: "HighMemory", Group: "HighMemory", Chat: ops, MessageID: 4, AcknowledgedBy: "sre", AcknowledgedAt: now, resolved: 1}
+	other := &HandleAlert{ID: "HighCPU", Group: "HighCPU", Chat: dev, MessageID: 5, AcknowledgedBy: "dev", AcknowledgedAt: now}
+
+	b := &Bot{alerts: NewAlertRegistry()}
+	for _, a := range []*HandleAlert{late, early, open, resolved, other} {
+		b.alerts.Add(a)
+	}
+
+	// Without store the alerts tracked by the bot are listed, acknowledged longest ago first
+	alerts, err := b.acknowledgedAlerts(ops)
+	assert.NoError(t, err)
+	assert.Equal(t, []StoredAlert{early.stored(), late.stored()}, alerts)
+
+	// The store holds the unresolved alerts of all replicas
+	dir, err := ioutil.TempDir("", "acked")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	defer kv.Close()
+	b.alertStore, err = NewAlertStore(kv)
+	assert.NoError(t, err)
+
+	for _, a := range []*HandleAlert{late, early, open, other} {
+		assert.NoError(t, b.alertStore.Put(a.stored()))
+	}
+	b.alerts = NewAlertRegistry()
+
+	alerts, err = b.acknowledgedAlerts(ops)
+	assert.NoError(t, err)
+	assert.Len(t, alerts, 2)
+	assert.Equal(t, "DiskFull", alerts[0].ID)
+	assert.Equal(t, "vu_long", alerts[1].AcknowledgedBy)
+}
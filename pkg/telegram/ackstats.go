@@ -0,0 +1,109 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandStats = "/stats"
+
+	// defaultStatsWindow is summarized by /stats without a window
+	defaultStatsWindow = 7 * 24 * time.Hour
+)
+
+// ackStats are the acknowledgements of a member
+type ackStats struct {
+	Username string
+	Count    int
+	// Total time from firing to the acknowledgement of all acknowledged alerts
+	Total time.Duration
+}
+
+// Average time to acknowledge an alert
+func (s ackStats) Average() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// ackLeaderboard sums up the acknowledgements per member, the most acknowledgements first
+func ackLeaderboard(entries []HistoryEntry) (stats []ackStats, unacknowledged int) {
+	byUser := make(map[string]*ackStats)
+	for _, e := range entries {
+		if e.AcknowledgedBy == "" {
+			unacknowledged++
+			continue
+		}
+		s, ok := byUser[e.AcknowledgedBy]
+		if !ok {
+			s = &ackStats{Username: e.AcknowledgedBy}
+			byUser[e.AcknowledgedBy] = s
+		}
+		s.Count++
+		s.Total += e.AcknowledgedAt.Sub(e.FiredAt)
+	}
+
+	for _, s := range byUser {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].Username < stats[j].Username
+	})
+	return stats, unacknowledged
+}
+
+// ackLeaderboardMessage formats the leaderboard of /stats
+func ackLeaderboardMessage(window time.Duration, stats []ackStats, unacknowledged int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Acknowledgements in the last %s:\n", window)
+	for i, s := range stats {
+		fmt.Fprintf(&b, "%d. @%s: %d, %s on average\n", i+1, s.Username, s.Count, s.Average().Round(time.Second))
+	}
+	fmt.Fprintf(&b, "Not acknowledged: %d", unacknowledged)
+	return b.String()
+}
+
+func (b *Bot) handleStats(message telebot.Message) {
+	// Right format: '/stats [window]'.
+	// Ex: /stats 168h
+	params := strings.Fields(message.Text)[1:]
+
+	if b.history == nil {
+		b.reply(message, "The alert history is not enabled.", nil)
+		return
+	}
+
+	window := defaultStatsWindow
+	if len(params) > 0 {
+		d, err := time.ParseDuration(params[0])
+		if err != nil || d <= 0 || len(params) > 1 {
+			b.reply(message, "Please send right format: '/stats [window]'. Ex: /stats 168h", nil)
+			return
+		}
+		window = d
+	}
+
+	entries, err := b.history.List(message.Chat.ID, time.Now().Add(-window))
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to list alert history from store", "err", err)
+		b.reply(message, "I can't get the alert history.", nil)
+		return
+	}
+	if len(entries) == 0 {
+		b.reply(message, fmt.Sprintf("No alerts were delivered to this chat in the last %s.", window), nil)
+		return
+	}
+
+	stats, unacknowledged := ackLeaderboard(entries)
+	b.reply(message, ackLeaderboardMessage(window, stats, unacknowledged), nil)
+}
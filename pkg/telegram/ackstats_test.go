@@ -0,0 +1,35 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAckLeaderboard(t *testing.T) {
+	at := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	acked := func(user string, after time.Duration) HistoryEntry {
+		return HistoryEntry{AlertID: "HighCPU", FiredAt: at, AcknowledgedBy: user, AcknowledgedAt: at.Add(after)}
+	}
+
+	stats, unacknowledged := ackLeaderboard([]HistoryEntry{
+		acked("techleader", 4*time.Minute),
+		acked("vu_long", time.Minute),
+		acked("vu_long", 3*time.Minute),
+		acked("sre", 10*time.Minute),
+		{AlertID: "DiskFull", FiredAt: at},
+	})
+	assert.Equal(t, 1, unacknowledged)
+	assert.Equal(t, []ackStats{
+		{Username: "vu_long", Count: 2, Total: 4 * time.Minute},
+		{Username: "sre", Count: 1, Total: 10 * time.Minute},
+		{Username: "techleader", Count: 1, Total: 4 * time.Minute},
+	}, stats)
+	assert.Equal(t, 2*time.Minute, stats[0].Average())
+
+	assert.Equal(t,
+		"Acknowledgements in the last 168h0m0s:\n1. @vu_long: 2, 2m0s on average\n2. @sre: 1, 10m0s on average\n3. @techleader: 1, 4m0s on average\nNot acknowledged: 1",
+		ackLeaderboardMessage(defaultStatsWindow, stats, unacknowledged),
+	)
+}
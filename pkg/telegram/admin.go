@@ -0,0 +1,445 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandAddAdmin    = "/addadmin"
+	commandRemoveAdmin = "/rmadmin"
+	commandBan         = "/ban"
+	commandUnban       = "/unban"
+
+	telegramAdminsDirectory = "telegram/admins"
+	telegramBansDirectory   = "telegram/bans"
+
+	// forbiddenNoticeWindow limits the admins' notifications to one per sender and chat in this window
+	forbiddenNoticeWindow = 10 * time.Minute
+	// userRefCacheTTL is how long the admins and bans are kept in memory, changes of other replicas apply after it
+	userRefCacheTTL = time.Minute
+)
+
+// UserRef identifies a Telegram user by ID or, if the ID isn't known, by username
+type UserRef struct {
+	ID       int    `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// Matches returns whether the user is the referenced one
+func (u UserRef) Matches(user telebot.User) bool {
+	if u.ID != 0 {
+		return u.ID == user.ID
+	}
+	return u.Username != "" && strings.EqualFold(u.Username, user.Username)
+}
+
+// key identifies the referenced user in a store directory
+func (u UserRef) key() string {
+	if u.ID != 0 {
+		return strconv.Itoa(u.ID)
+	}
+	return "@" + strings.ToLower(u.Username)
+}
+
+func (u UserRef) String() string {
+	if u.ID != 0 && u.Username != "" {
+		return fmt.Sprintf("@%s (%d)", u.Username, u.ID)
+	}
+	if u.ID != 0 {
+		return strconv.Itoa(u.ID)
+	}
+	return "@" + u.Username
+}
+
+// parseUserRef parses '@username' or a user ID.
+// Without a parameter the sender of the message the command replies to is referenced.
+func parseUserRef(message telebot.Message, params []string) (UserRef, error) {
+	if len(params) == 0 {
+		if message.ReplyTo == nil {
+			return UserRef{}, fmt.Errorf("missing user")
+		}
+		return UserRef{ID: message.ReplyTo.Sender.ID, Username: message.ReplyTo.Sender.Username}, nil
+	}
+	if len(params) > 1 {
+		return UserRef{}, fmt.Errorf("too many parameters")
+	}
+
+	if id, err := strconv.Atoi(params[0]); err == nil {
+		return UserRef{ID: id}, nil
+	}
+
+	username := strings.TrimPrefix(params[0], "@")
+	if username == "" {
+		return UserRef{}, fmt.Errorf("missing username")
+	}
+	return UserRef{Username: username}, nil
+}
+
+// UserRefStore writes a list of users to a directory of a libkv store backend.
+// The list is read on every message, it is kept in memory for up to the TTL.
+type UserRefStore struct {
+	kv  store.Store
+	dir string
+	ttl time.Duration
+
+	mu       sync.Mutex
+	users    []UserRef
+	loadedAt time.Time
+}
+
+// NewAdminStore stores the admins added with /addadmin in the provided kv backend
+func NewAdminStore(kv store.Store) (*UserRefStore, error) {
+	return &UserRefStore{kv: kv, dir: telegramAdminsDirectory, ttl: userRefCacheTTL}, nil
+}
+
+// NewBanStore stores the users banned with /ban in the provided kv backend
+func NewBanStore(kv store.Store) (*UserRefStore, error) {
+	return &UserRefStore{kv: kv, dir: telegramBansDirectory, ttl: userRefCacheTTL}, nil
+}
+
+// List all users saved in the kv backend, loaded again once they are older than the TTL
+func (s *UserRefStore) List() ([]UserRef, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.loadedAt.IsZero() || time.Since(s.loadedAt) >= s.ttl {
+		users, err := s.load()
+		if err != nil {
+			return nil, err
+		}
+		s.users = users
+		s.loadedAt = time.Now()
+	}
+	return append([]UserRef(nil), s.users...), nil
+}
+
+// load reads the users from the kv backend
+func (s *UserRefStore) load() ([]UserRef, error) {
+	kvPairs, err := s.kv.List(s.dir)
+	if err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+
+	var users []UserRef
+	for _, kv := range kvPairs {
+		var u UserRef
+		if err := json.Unmarshal(kv.Value, &u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+func (s *UserRefStore) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedAt = time.Time{}
+}
+
+// Add a user to the kv backend
+func (s *UserRefStore) Add(u UserRef) error {
+	defer s.invalidate()
+
+	b, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+
+	return s.kv.Put(fmt.Sprintf("%s/%s", s.dir, u.key()), b, nil)
+}
+
+// Remove all entries referencing the user from the kv backend,
+// store.ErrKeyNotFound is returned if there are none
+func (s *UserRefStore) Remove(u UserRef) error {
+	defer s.invalidate()
+
+	users, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	removed := false
+	for _, stored := range users {
+		sameID := u.ID != 0 && stored.ID == u.ID
+		sameName := u.Username != "" && strings.EqualFold(stored.Username, u.Username)
+		if !sameID && !sameName {
+			continue
+		}
+		if err := s.kv.Delete(fmt.Sprintf("%s/%s", s.dir, stored.key())); err != nil {
+			return err
+		}
+		removed = true
+	}
+
+	if !removed {
+		return store.ErrKeyNotFound
+	}
+	return nil
+}
+
+// Contains returns whether the user is saved in the kv backend
+func (s *UserRefStore) Contains(user telebot.User) (bool, error) {
+	users, err := s.List()
+	if err != nil {
+		return false, err
+	}
+	for _, u := range users {
+		if u.Matches(user) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isDynamicAdmin returns whether the user was made an admin with /addadmin.
+// Only the user ID counts, usernames can be changed and then taken by someone else.
+func (b *Bot) isDynamicAdmin(user telebot.User) bool {
+	if b.adminStore == nil {
+		return false
+	}
+
+	admins, err := b.adminStore.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get admins from store", "err", err)
+		return false
+	}
+	for _, admin := range admins {
+		if admin.ID != 0 && admin.ID == user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// isBanned returns whether the user was banned with /ban, the admins of --telegram.admin never are
+func (b *Bot) isBanned(user telebot.User) bool {
+	if b.bans == nil || b.isAdminID(user.ID) {
+		return false
+	}
+
+	ok, err := b.bans.Contains(user)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get banned users from store", "err", err)
+		return false
+	}
+	return ok
+}
+
+// adminUsername returns the current username of the admin, it's looked up once.
+// An empty username is returned if the lookup fails.
+func (b *Bot) adminUsername(id int) string {
+	b.adminsMu.RLock()
+	name, ok := b.adminNames[id]
+	b.adminsMu.RUnlock()
+	if ok || b.telegram == nil {
+		return name
+	}
+
+	chat, err := b.telegram.GetChat(telebot.User{ID: id})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get username of admin", "admin_id", id, "err", err)
+		return ""
+	}
+
+	b.adminsMu.Lock()
+	defer b.adminsMu.Unlock()
+	if b.adminNames == nil {
+		b.adminNames = make(map[int]string)
+	}
+	b.adminNames[id] = chat.Username
+	return chat.Username
+}
+
+// isConfiguredAdmin returns whether the user is one of the admins of --telegram.admin, by ID or by username
+func (b *Bot) isConfiguredAdmin(u UserRef) bool {
+	if b.isAdminID(u.ID) {
+		return true
+	}
+	if u.Username == "" {
+		return false
+	}
+	for _, id := range b.currentAdmins() {
+		if name := b.adminUsername(id); name != "" && strings.EqualFold(name, u.Username) {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyForbidden tells the admins about a message of a forbidden sender,
+// so that hijack attempts and groups the bot was added to by mistake are noticed
+func (b *Bot) notifyForbidden(message telebot.Message, text string) {
+	if b.forbiddenNotices == nil || b.forbiddenNotices.Duplicate(message.Chat.ID, uint64(message.Sender.ID), time.Now()) {
+		return
+	}
+
+	chat := message.Chat.Title
+	if chat == "" {
+		chat = "private chat"
+	}
+	notice := fmt.Sprintf(
+		"Dropped a message from the forbidden sender %s in %s (%d):\n%s",
+		UserRef{ID: message.Sender.ID, Username: message.Sender.Username}, chat, message.Chat.ID, truncate(maxAuditText, text),
+	)
+	for _, admin := range b.currentAdmins() {
+		b.SendAdminMessage(admin, notice)
+	}
+}
+
+func (b *Bot) handleAddAdmin(message telebot.Message) {
+	// Right format: '/addadmin userID' or '/addadmin' replying to a message of the user.
+	// Ex: /addadmin 12345
+	if b.adminStore == nil {
+		b.reply(message, "Dynamic admins are not enabled.", nil)
+		return
+	}
+
+	user, err := parseUserRef(message, strings.Fields(message.Text)[1:])
+	if err != nil {
+		b.reply(message, "Please send right format: '/addadmin userID' or reply to a message of the user with '/addadmin'. Ex: /addadmin 12345", nil)
+		return
+	}
+	// Whoever takes over a username would become admin
+	if user.ID == 0 {
+		b.reply(message, fmt.Sprintf("I need the user ID of %s, please reply to one of their messages with '/addadmin'.", user), nil)
+		return
+	}
+
+	if err := b.adminStore.Add(user); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to add admin to store", "err", err)
+		b.reply(message, fmt.Sprintf("I can't add %s as admin.", user), nil)
+		return
+	}
+
+	b.reply(message, responseMember, nil)
+	level.Info(b.logger).Log("msg", "admin added", "user", user.String(), "sender_username", message.Sender.Username)
+}
+
+func (b *Bot) handleRemoveAdmin(message telebot.Message) {
+	// Right format: '/rmadmin @username', '/rmadmin userID' or '/rmadmin' replying to a message of the user.
+	// Ex: /rmadmin @vu_long
+	if b.adminStore == nil {
+		b.reply(message, "Dynamic admins are not enabled.", nil)
+		return
+	}
+
+	user, err := parseUserRef(message, strings.Fields(message.Text)[1:])
+	if err != nil {
+		b.reply(message, "Please send right format: '/rmadmin @username' or '/rmadmin userID'. Ex: /rmadmin @vu_long", nil)
+		return
+	}
+
+	if b.isAdminID(user.ID) {
+		b.reply(message, fmt.Sprintf("%s is configured with --telegram.admin and can't be removed.", user), nil)
+		return
+	}
+
+	err = b.adminStore.Remove(user)
+	if err == store.ErrKeyNotFound {
+		b.reply(message, fmt.Sprintf("%s is not an admin.", user), nil)
+		return
+	}
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to remove admin from store", "err", err)
+		b.reply(message, fmt.Sprintf("I can't remove %s as admin.", user), nil)
+		return
+	}
+
+	b.reply(message, responseMember, nil)
+	level.Info(b.logger).Log("msg", "admin removed", "user", user.String(), "sender_username", message.Sender.Username)
+}
+
+func (b *Bot) handleBan(message telebot.Message) {
+	// Right format: '/ban @username', '/ban userID' or '/ban' replying to a message of the user.
+	// Ex: /ban @spammer
+	if b.bans == nil {
+		b.reply(message, "The banlist is not enabled.", nil)
+		return
+	}
+
+	user, err := parseUserRef(message, strings.Fields(message.Text)[1:])
+	if err != nil {
+		b.reply(message, "Please send right format: '/ban @username' or '/ban userID'. Ex: /ban @spammer", nil)
+		return
+	}
+
+	if b.isConfiguredAdmin(user) || user.Matches(message.Sender) {
+		b.reply(message, fmt.Sprintf("%s can't be banned.", user), nil)
+		return
+	}
+
+	if err := b.bans.Add(user); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to add banned user to store", "err", err)
+		b.reply(message, fmt.Sprintf("I can't ban %s.", user), nil)
+		return
+	}
+
+	b.reply(message, responseMember, nil)
+	level.Info(b.logger).Log("msg", "user banned", "user", user.String(), "sender_username", message.Sender.Username)
+}
+
+func (b *Bot) handleUnban(message telebot.Message) {
+	// Right format: '/unban @username', '/unban userID' or '/unban' replying to a message of the user.
+	// Ex: /unban @spammer
+	if b.bans == nil {
+		b.reply(message, "The banlist is not enabled.", nil)
+		return
+	}
+
+	params := strings.Fields(message.Text)[1:]
+	if len(params) == 0 && message.ReplyTo == nil {
+		b.listBans(message)
+		return
+	}
+
+	user, err := parseUserRef(message, params)
+	if err != nil {
+		b.reply(message, "Please send right format: '/unban @username' or '/unban userID'. Ex: /unban @spammer", nil)
+		return
+	}
+
+	err = b.bans.Remove(user)
+	if err == store.ErrKeyNotFound {
+		b.reply(message, fmt.Sprintf("%s is not banned.", user), nil)
+		return
+	}
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to remove banned user from store", "err", err)
+		b.reply(message, fmt.Sprintf("I can't unban %s.", user), nil)
+		return
+	}
+
+	b.reply(message, responseMember, nil)
+	level.Info(b.logger).Log("msg", "user unbanned", "user", user.String(), "sender_username", message.Sender.Username)
+}
+
+// listBans shows the banned users
+func (b *Bot) listBans(message telebot.Message) {
+	users, err := b.bans.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to list banned users from store", "err", err)
+		b.reply(message, "I can't list the banned users.", nil)
+		return
+	}
+	if len(users) == 0 {
+		b.reply(message, "Nobody is banned.", nil)
+		return
+	}
+
+	list := make([]string, 0, len(users))
+	for _, u := range users {
+		list = append(list, u.String())
+	}
+	b.reply(message, "Currently these users are banned:\n"+strings.Join(list, "\n"), nil)
+}
@@ -0,0 +1,63 @@
+package telegram
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestUserRefStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admins")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	defer kv.Close()
+	s, err := NewAdminStore(kv)
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Add(UserRef{ID: 7, Username: "vu_long"}))
+	ok, err := s.Contains(telebot.User{ID: 7})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Admins added by other replicas are seen once the cache expired
+	other, _ := NewAdminStore(kv)
+	assert.NoError(t, other.Add(UserRef{ID: 8}))
+	users, _ := s.List()
+	assert.Len(t, users, 1)
+	s.ttl = 0
+	users, _ = s.List()
+	assert.Len(t, users, 2)
+
+	s.ttl = userRefCacheTTL
+	assert.NoError(t, s.Remove(UserRef{Username: "VU_LONG"}))
+	users, _ = s.List()
+	assert.Equal(t, []UserRef{{ID: 8}}, users)
+	assert.Equal(t, store.ErrKeyNotFound, s.Remove(UserRef{ID: 7}))
+}
+
+func TestDynamicAdmins(t *testing.T) {
+	admins := &fakeUserRefStore{users: []UserRef{{ID: 7}, {Username: "vu_long"}}}
+	s := newFakeSender()
+	b := &Bot{adminStore: admins, sender: s, logger: log.NewNopLogger()}
+
+	assert.True(t, b.isDynamicAdmin(telebot.User{ID: 7}))
+	assert.False(t, b.isDynamicAdmin(telebot.User{ID: 9, Username: "vu_long"}), "usernames can be taken over")
+
+	b.handleAddAdmin(telebot.Message{Text: "/addadmin @techleader"})
+	assert.Len(t, admins.users, 2, "admins are only added by user ID")
+
+	reply := &telebot.Message{Sender: telebot.User{ID: 9, Username: "techleader"}}
+	b.handleAddAdmin(telebot.Message{Text: "/addadmin", ReplyTo: reply})
+	b.handleAddAdmin(telebot.Message{Text: "/addadmin 10"})
+	assert.Equal(t, []UserRef{{ID: 7}, {Username: "vu_long"}, {ID: 9, Username: "techleader"}, {ID: 10}}, admins.users)
+}
@@ -3,17 +3,26 @@ package telegram
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"sort"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/common/model"
 	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/mirror"
+	"github.com/vu-long/alertmanager-bot/pkg/remediate"
+	"github.com/vu-long/alertmanager-bot/pkg/ticket"
 )
 
 // HandleAlert shows all of Alert in the
 type HandleAlert struct {
 	ID              string
+	Group           string // identifies the alert's group in the registry and the callbacks of its buttons
 	MessageID       int
 	MemberStore     BotMemberStore
 	NodeStore       BotNodeStore
@@ -22,11 +31,135 @@ type HandleAlert struct {
 	Level           HandleLevel
 	LastUpdate      time.Time
 	AutoForwardFlag bool
+	// ForwardTimeout after which the alert is forwarded to the next level
+	ForwardTimeout time.Duration
+	// Fingerprints identify the alerts the message shows by their labels, like the Alertmanager does
+	Fingerprints []model.Fingerprint
+	// FiredAt is the last time a firing webhook for the alert was received
+	FiredAt time.Time
+	// AcknowledgedBy is the username of the member who acknowledged the alert first at AcknowledgedAt, zero if nobody did
+	AcknowledgedBy string
+	AcknowledgedAt time.Time
+	// Templates render the escalation messages of the alert
+	Templates func() *Templates
+	// Events publishes the escalation events of the alert
+	Events *eventBus
+	// Tracker creates a ticket for the alert, nil hides the Create ticket button
+	Tracker ticket.Tracker
+	// Ticket created for the alert, if any
+	Ticket *ticket.Ticket
+	// Remediation is the action shown as Remediate button, nil hides it
+	Remediation *remediate.Action
+	// GroupLink links the alert's group in the Alertmanager UI or Karma, if its URL is valid
+	GroupLink *telebot.KeyboardButton
+	// OnCall returns whether a member is on call, nil picks a random member of the level
+	OnCall func(username string) bool
+	// Mirror receives copies of the alert's messages, nil mirrors nothing
+	Mirror *mirror.Client
+	// Callbacks keep the payloads of the alert's buttons, the buttons hold the payloads themselves if nil
+	Callbacks *callbacks
+	// resolved is set once the alert was published as resolved
+	resolved int32
+
+	// exhausted is set once the highest level didn't acknowledge the alert either
+	exhausted bool
+
+	// mu guards MessageID, Level, LastUpdate, AutoForwardFlag, Fingerprints, FiredAt, AcknowledgedBy, AcknowledgedAt, Ticket and exhausted,
+	// which the escalation, webhooks and callbacks change concurrently once the alert is sent
+	mu sync.Mutex
+}
+
+// publish an escalation event of the alert
+func (a *HandleAlert) publish(event, detail string) {
+	a.publishBy(event, "", detail)
+}
+
+// publishBy publishes an escalation event of the alert caused by the member
+func (a *HandleAlert) publishBy(event, user, detail string) {
+	a.Events.Publish(Event{Type: event, ChatID: a.Chat.ID, AlertID: a.ID, User: user, Labels: a.Alert.Labels, Detail: detail})
 }
 
 // Destination is internal inline message ID.
-func (a HandleAlert) Destination() string {
-	return strconv.Itoa(a.MessageID)
+func (a *HandleAlert) Destination() string {
+	return strconv.Itoa(a.messageID())
+}
+
+// messageID returns the ID of the alert's message
+func (a *HandleAlert) messageID() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.MessageID
+}
+
+// level returns the level the alert is escalated to
+func (a *HandleAlert) level() HandleLevel {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.Level
+}
+
+// escalating returns whether the alert is neither acknowledged nor resolved
+func (a *HandleAlert) escalating() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.AutoForwardFlag
+}
+
+// stopEscalation ends the escalation of an acknowledged or resolved alert
+func (a *HandleAlert) stopEscalation() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.AutoForwardFlag = false
+}
+
+// forwardDue returns whether the escalating alert wasn't acknowledged within the timeout
+// and restarts the timeout if so
+func (a *HandleAlert) forwardDue(now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.AutoForwardFlag || now.Sub(a.LastUpdate) < a.ForwardTimeout {
+		return false
+	}
+	a.LastUpdate = now
+	return true
+}
+
+// nextForward returns when the escalating alert is forwarded next, zero once the escalation stopped
+func (a *HandleAlert) nextForward() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.AutoForwardFlag {
+		return time.Time{}
+	}
+	return a.LastUpdate.Add(a.ForwardTimeout)
+}
+
+// exhaust marks the escalation as exhausted and returns whether it wasn't before
+func (a *HandleAlert) exhaust() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.exhausted {
+		return false
+	}
+	a.exhausted = true
+	return true
+}
+
+// firedAt returns when the alert fired last
+func (a *HandleAlert) firedAt() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.FiredAt
+}
+
+// settled returns whether the alert was resolved or acknowledged, so that nobody needs to be alerted about it anymore
+func (a *HandleAlert) settled() bool {
+	if atomic.LoadInt32(&a.resolved) == 1 {
+		return true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return !a.AcknowledgedAt.IsZero()
 }
 
 // HandleLevel shows the level of member is handling the firing alert
@@ -40,16 +173,38 @@ const (
 	// AutoForwardTimeout If no one action that message in 5 minutes, then do auto forward
 	AutoForwardTimeout time.Duration = 5 * time.Minute
 
-	strAcknowledge string = "Acknowledge by: @%s"
-	strForward     string = "@%s forward to @%s"
-	strAutoForward string = "Auto forward to next level @%s"
+	// Templates of the escalation messages
+	tmplAssign      = "telegram.assign"
+	tmplAcknowledge = "telegram.acknowledge"
+	tmplForward     = "telegram.forward"
+	tmplAutoForward = "telegram.autoforward"
 )
 
-// BotAlertStore is all the Bot needs to store and read
+// escalationData is passed to the templates of the escalation messages
+type escalationData struct {
+	Alert template.Alert
+	Level HandleLevel
+	// From is the username of the member acting on the alert
+	From string
+	// To is the username of the member the alert is escalated to
+	To string
+}
+
+// escalationMessage renders the named escalation message template
+func (a *HandleAlert) escalationMessage(name, from, to string) (string, error) {
+	return a.Templates().execute(fmt.Sprintf(`{{ template %q . }}`, name), false, escalationData{
+		Alert: a.Alert,
+		Level: a.level(),
+		From:  from,
+		To:    to,
+	})
+}
+
+// BotAlertStore is all the Bot needs to store and read the alerts sent to the chats
 type BotAlertStore interface {
-	List() ([]HandleAlert, error)
-	Add(HandleAlert) error
-	Remove(HandleAlert) error
+	List() ([]StoredAlert, error)
+	Put(StoredAlert) error
+	Remove(StoredAlert) error
 }
 
 /* TODO:
@@ -62,9 +217,8 @@ type BotAlertStore interface {
  *		v Response callback RESOLVED: Hide all buttons of previous alert message, stop auto forward to next Level of previous alert message.
  */
 
-// NewAlert creates the Handle Alert object
-func NewAlert(id string, chat telebot.Chat, alert template.Alert, b *Bot, out string) (*HandleAlert, error) {
-	// Prepare source to send the message
+// alertKeyboard creates the inline keyboard with the Acknowledge and Forward buttons
+func alertKeyboard(id string) ([][]telebot.KeyboardButton, error) {
 	ackData, err := NewCallbackData(strAcknowledgeData, id)
 	if err != nil {
 		return nil, err
@@ -82,31 +236,75 @@ func NewAlert(id string, chat telebot.Chat, alert template.Alert, b *Bot, out st
 		return nil, err
 	}
 
-	respMsg, err := b.telegram.SendMessage(chat, out, &telebot.SendOptions{
-		ParseMode: telebot.ModeHTML,
-		ReplyMarkup: telebot.ReplyMarkup{
-			InlineKeyboard: [][]telebot.KeyboardButton{
-				[]telebot.KeyboardButton{
-					telebot.KeyboardButton{
-						Text: strAcknowledgeData,
-						Data: string(jsonAckStr), // Callback query
-					},
-					telebot.KeyboardButton{
-						Text: strForwardData,
-						Data: string(jsonFwdStr), // Callback query
-					},
-				},
+	return [][]telebot.KeyboardButton{
+		[]telebot.KeyboardButton{
+			telebot.KeyboardButton{
+				Text: strAcknowledgeData,
+				Data: string(jsonAckStr), // Callback query
+			},
+			telebot.KeyboardButton{
+				Text: strForwardData,
+				Data: string(jsonFwdStr), // Callback query
 			},
 		},
-	})
-	if err != nil {
-		return nil, err
+	}, nil
+}
+
+// linkAnnotations are the annotations shown as URL buttons below an alert's message
+var linkAnnotations = []struct {
+	text  string
+	names []string
+}{
+	{text: "Runbook", names: []string{"runbook_url", "runbook"}},
+	{text: "Dashboard", names: []string{"dashboard_url", "dashboard"}},
+}
+
+// validButtonURL returns whether Telegram accepts the link as URL of a button,
+// it rejects messages with buttons of invalid URLs
+func validButtonURL(link string) bool {
+	u, err := url.Parse(link)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// linkButtons creates URL buttons for the runbook, dashboard and graph of an alert
+func linkButtons(alert template.Alert) []telebot.KeyboardButton {
+	var buttons []telebot.KeyboardButton
+	add := func(text, link string) {
+		if validButtonURL(link) {
+			buttons = append(buttons, telebot.KeyboardButton{Text: text, URL: link})
+		}
 	}
-	level.Debug(b.logger).Log("message_id", strconv.Itoa(respMsg.ID))
 
-	a := &HandleAlert{
+	for _, l := range linkAnnotations {
+		for _, name := range l.names {
+			if link := alert.Annotations[name]; link != "" {
+				add(l.text, link)
+				break
+			}
+		}
+	}
+	add("Graph", alert.GeneratorURL)
+
+	return buttons
+}
+
+// replyMarkup puts the action buttons above the alert's link buttons, followed by the extra links
+func replyMarkup(alert template.Alert, actions []telebot.KeyboardButton, extra ...telebot.KeyboardButton) telebot.ReplyMarkup {
+	var keyboard [][]telebot.KeyboardButton
+	if len(actions) > 0 {
+		keyboard = append(keyboard, actions)
+	}
+	if links := append(linkButtons(alert), extra...); len(links) > 0 {
+		keyboard = append(keyboard, links)
+	}
+	return telebot.ReplyMarkup{InlineKeyboard: keyboard}
+}
+
+// newHandleAlert creates the alert at the first level of its escalation
+func (b *Bot) newHandleAlert(id, group string, chat telebot.Chat, alert template.Alert, fingerprints []model.Fingerprint, timeout time.Duration, groupLink *telebot.KeyboardButton) *HandleAlert {
+	return &HandleAlert{
 		ID:              id,
-		MessageID:       respMsg.ID,
+		Group:           group,
 		MemberStore:     b.members,
 		NodeStore:       b.nodes,
 		Chat:            chat,
@@ -114,9 +312,50 @@ func NewAlert(id string, chat telebot.Chat, alert template.Alert, b *Bot, out st
 		Level:           levelOne,
 		LastUpdate:      time.Now(),
 		AutoForwardFlag: true,
+		ForwardTimeout:  timeout,
+		Fingerprints:    fingerprints,
+		FiredAt:         time.Now(),
+		Templates:       b.currentTemplates,
+		Events:          b.events,
+		Tracker:         b.tracker,
+		GroupLink:       groupLink,
+		Remediation:     b.remediations.ForAlert(id),
+		OnCall:          b.onCallCheck(),
+		Mirror:          b.mirror,
+		Callbacks:       b.callbacks,
 	}
+}
+
+// NewAlert creates the Handle Alert object of the message showing the sorted alerts, the first one escalates it
+func NewAlert(id, group string, chat telebot.Chat, alerts template.Alerts, b *Bot, out string, mode telebot.ParseMode, timeout time.Duration, groupLink *telebot.KeyboardButton) (*HandleAlert, error) {
+	a := b.newHandleAlert(id, group, chat, alerts[0], alertFingerprints(alerts), timeout, groupLink)
 
-	nodes, err := a.NodeStore.List()
+	// Prepare source to send the message
+	actions, err := a.actions()
+	if err != nil {
+		return nil, err
+	}
+	markup, err := a.replyMarkup(actions)
+	if err != nil {
+		return nil, err
+	}
+
+	respMsg, err := b.sender.SendMessage(chat, out, &telebot.SendOptions{
+		ParseMode:   mode,
+		ReplyMarkup: markup,
+	})
+	if err != nil {
+		return nil, err
+	}
+	level.Debug(b.logger).Log("msg", "alert sent", "alert_id", id, "chat_id", chat.ID, "message_id", respMsg.ID)
+
+	a.mu.Lock()
+	a.MessageID = respMsg.ID
+	a.mu.Unlock()
+	a.mirror(out, mode)
+	a.publish(eventFired, fmt.Sprintf("message %d", respMsg.ID))
+
+	nodes, err := a.NodeStore.GetByChat(a.Chat)
 	if err != nil {
 		return nil, err
 	}
@@ -127,34 +366,54 @@ func NewAlert(id string, chat telebot.Chat, alert template.Alert, b *Bot, out st
 		}
 	}
 	if memberID == "" {
-		randMember, err := a.MemberStore.GetRandomMemberByChatandLevel(a.Chat, string(a.Level))
+		randMember, err := a.assignee()
 		if err != nil {
 			return nil, err
 		}
 		memberID = randMember.Username
 	}
-	go a.AutoForward(b.telegram, 5*time.Second)
+	b.escalations.Schedule(a, a.nextForward())
 
-	respString := fmt.Sprintf("@%s", memberID)
-	_, err = b.telegram.SendMessage(a.Chat, respString, nil)
+	respString, err := a.escalationMessage(tmplAssign, "", memberID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = b.sender.SendMessage(a.Chat, respString, nil)
 	if err != nil {
 		return nil, err
 	}
+	a.mirror(respString, "")
 
 	return a, nil
 }
 
 // Acknowledge is function to process callback whenever member press the Acknowledge button
-func (a *HandleAlert) Acknowledge(bot *telebot.Bot, callback telebot.Callback) error {
-	a.AutoForwardFlag = false
+func (a *HandleAlert) Acknowledge(sender MessageSender, callback telebot.Callback) error {
+	a.stopEscalation()
+	a.mu.Lock()
+	if a.AcknowledgedAt.IsZero() {
+		a.AcknowledgedBy = callback.Sender.Username
+		a.AcknowledgedAt = time.Now()
+	}
+	a.mu.Unlock()
+	a.publishBy(eventAcknowledged, callback.Sender.Username, "by @"+callback.Sender.Username)
 
-	respString := fmt.Sprintf(strAcknowledge, callback.Sender.Username)
-	_, err := bot.SendMessage(a.Chat, respString, nil)
+	respString, err := a.escalationMessage(tmplAcknowledge, callback.Sender.Username, "")
+	if err != nil {
+		return err
+	}
+	_, err = sender.SendMessage(a.Chat, respString, nil)
 	if err != nil {
 		return err
 	}
-	err = bot.EditMessageReplyMakeup(a.Chat, a.MessageID, &telebot.SendOptions{
-		ParseMode: telebot.ModeHTML,
+	a.mirror(respString, "")
+	markup, err := a.replyMarkup(nil)
+	if err != nil {
+		return err
+	}
+	err = sender.EditMessageReplyMarkup(a.Chat, a.messageID(), &telebot.SendOptions{
+		ParseMode:   telebot.ModeHTML,
+		ReplyMarkup: markup,
 	})
 	if err != nil {
 		return err
@@ -164,80 +423,241 @@ func (a *HandleAlert) Acknowledge(bot *telebot.Bot, callback telebot.Callback) e
 }
 
 // Forward is function to process callback whenever member press the Forward button
-func (a *HandleAlert) Forward(bot *telebot.Bot, callback telebot.Callback, data string) error {
+func (a *HandleAlert) Forward(sender MessageSender, callback telebot.Callback, data string) error {
 	a.IncreaseLevel()
-	randMember, err := a.MemberStore.GetRandomMemberByChatandLevel(a.Chat, string(a.Level))
+	a.publishBy(eventForwarded, callback.Sender.Username, fmt.Sprintf("by @%s to level %s", callback.Sender.Username, a.level()))
+	randMember, err := a.assignee()
 	if err != nil {
 		return err
 	}
 
-	respString := fmt.Sprintf(strForward, callback.Sender.Username, randMember.Username)
-	_, err = bot.SendMessage(a.Chat, respString, nil)
+	respString, err := a.escalationMessage(tmplForward, callback.Sender.Username, randMember.Username)
+	if err != nil {
+		return err
+	}
+	_, err = sender.SendMessage(a.Chat, respString, nil)
 	if err != nil {
 		return err
 	}
+	a.mirror(respString, "")
 
-	err = bot.EditMessageReplyMakeup(a.Chat, a.MessageID, &telebot.SendOptions{
-		ParseMode: telebot.ModeHTML,
-		ReplyMarkup: telebot.ReplyMarkup{
-			InlineKeyboard: [][]telebot.KeyboardButton{
-				[]telebot.KeyboardButton{
-					telebot.KeyboardButton{
-						Text: strAcknowledgeData,
-						Data: data, // Callback query
-					},
-				},
-			},
+	markup, err := a.replyMarkup([]telebot.KeyboardButton{
+		telebot.KeyboardButton{
+			Text: strAcknowledgeData,
+			Data: data, // Callback query
 		},
 	})
 	if err != nil {
 		return err
 	}
+	err = sender.EditMessageReplyMarkup(a.Chat, a.messageID(), &telebot.SendOptions{
+		ParseMode:   telebot.ModeHTML,
+		ReplyMarkup: markup,
+	})
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
-// AutoForward job run to auto forward and push the alert to telegram alert group
-func (a *HandleAlert) AutoForward(bot *telebot.Bot, timeout time.Duration) error {
-	for a.AutoForwardFlag == true {
-		if time.Since(a.LastUpdate) >= AutoForwardTimeout {
-			a.LastUpdate = time.Now()
-			a.IncreaseLevel()
-			randMember, err := a.MemberStore.GetRandomMemberByChatandLevel(a.Chat, string(a.Level))
-			if err != nil {
-				return err
-			}
+// AutoForward forwards the alert to the next level if nobody acknowledged it within the timeout
+// and returns when it is due next, zero once the escalation stopped or failed
+func (a *HandleAlert) AutoForward(sender MessageSender, now time.Time) (time.Time, error) {
+	if a.forwardDue(now) {
+		// The escalation is exhausted once the highest level didn't acknowledge either
+		if !a.IncreaseLevel() && a.exhaust() {
+			a.publish(eventExhausted, fmt.Sprintf("at level %s", a.level()))
+		}
+		a.publish(eventAutoForwarded, fmt.Sprintf("to level %s", a.level()))
+		randMember, err := a.assignee()
+		if err != nil {
+			return time.Time{}, err
+		}
 
-			respString := fmt.Sprintf(strAutoForward, randMember.Username)
-			bot.SendMessage(a.Chat, respString, nil)
+		respString, err := a.escalationMessage(tmplAutoForward, "", randMember.Username)
+		if err != nil {
+			return time.Time{}, err
 		}
-		// Wait for a bit and try again.
-		time.Sleep(timeout)
+		sender.SendMessage(a.Chat, respString, nil)
+		a.mirror(respString, "")
 	}
 
-	return nil
+	return a.nextForward(), nil
+}
+
+// Refire updates the message of an alert that fired again within the cooldown to show the alerts with the fingerprints,
+// instead of sending a new message. An alert resolved meanwhile gets its action buttons back and restarts its escalation
+// at the first level, Refire returns when its escalation is due then, zero if the alert was still firing.
+func (a *HandleAlert) Refire(sender MessageSender, fingerprints []model.Fingerprint, out string, mode telebot.ParseMode) (time.Time, error) {
+	reopened := a.reopen()
+	a.mu.Lock()
+	a.FiredAt = time.Now()
+	a.Fingerprints = fingerprints
+	a.mu.Unlock()
+	a.publish(eventRefired, "")
+
+	actions, err := a.actions()
+	if err != nil {
+		return time.Time{}, err
+	}
+	markup, err := a.replyMarkup(actions)
+	if err != nil {
+		return time.Time{}, err
+	}
+	options := &telebot.SendOptions{ParseMode: mode, ReplyMarkup: markup}
+
+	if err := sender.EditMessageText(a.Chat, a.messageID(), out, options); err != nil {
+		return time.Time{}, err
+	}
+	if !reopened {
+		return time.Time{}, nil
+	}
+	return a.nextForward(), nil
+}
+
+// reopen restarts the escalation of a resolved alert at the first level and returns whether it was resolved
+func (a *HandleAlert) reopen() bool {
+	if !atomic.CompareAndSwapInt32(&a.resolved, 1, 0) {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Level = levelOne
+	a.LastUpdate = time.Now()
+	a.AutoForwardFlag = true
+	a.AcknowledgedBy = ""
+	a.AcknowledgedAt = time.Time{}
+	a.exhausted = false
+	return true
+}
+
+// actions are the buttons of the alert's escalation, none once acknowledged or resolved
+func (a *HandleAlert) actions() ([]telebot.KeyboardButton, error) {
+	if !a.escalating() {
+		return nil, nil
+	}
+	keyboard, err := alertKeyboard(a.Group)
+	if err != nil {
+		return nil, err
+	}
+	// Forwarded alerts only keep the Acknowledge button
+	if a.level() != levelOne {
+		return keyboard[0][:1], nil
+	}
+	return keyboard[0], nil
 }
 
 // Resolved handle resolve signal from callback
-func (a *HandleAlert) Resolved(bot *telebot.Bot, out string) error {
-	a.AutoForwardFlag = false
-	_, err := bot.SendMessage(a.Chat, out, &telebot.SendOptions{
-		ParseMode: telebot.ModeHTML,
+func (a *HandleAlert) Resolved(sender MessageSender, out string, mode telebot.ParseMode) error {
+	_, err := sender.SendMessage(a.Chat, out, &telebot.SendOptions{
+		ParseMode: mode,
 	})
 	if err != nil {
 		return err
 	}
+	a.mirror(out, mode)
 
-	err = bot.EditMessageReplyMakeup(a.Chat, a.MessageID, &telebot.SendOptions{
-		ParseMode: telebot.ModeHTML,
-	})
+	return a.Clear(sender)
+}
+
+// Clear stops the escalation of the alert and hides the action buttons of its message without notifying the chat
+func (a *HandleAlert) Clear(sender MessageSender) error {
+	a.stopEscalation()
+	// The Alertmanager can repeat its resolved notification
+	if atomic.CompareAndSwapInt32(&a.resolved, 0, 1) {
+		a.publish(eventResolved, "")
+	}
+	markup, err := a.replyMarkup(nil)
 	if err != nil {
 		return err
 	}
-	return nil
+	return sender.EditMessageReplyMarkup(a.Chat, a.messageID(), &telebot.SendOptions{
+		ParseMode:   telebot.ModeHTML,
+		ReplyMarkup: markup,
+	})
+}
+
+// recentAlert returns the alert of the chat showing the alert with the fingerprint that fired within the cooldown
+func recentAlert(alerts []*HandleAlert, chat telebot.Chat, fp model.Fingerprint, cooldown time.Duration) *HandleAlert {
+	var recent *HandleAlert
+	for _, h := range alerts {
+		if h.Chat.ID != chat.ID || !h.shows(fp) || time.Since(h.firedAt()) >= cooldown {
+			continue
+		}
+		if recent == nil || h.firedAt().After(recent.firedAt()) {
+			recent = h
+		}
+	}
+	return recent
+}
+
+// alertFingerprints returns the sorted fingerprints of the alerts
+func alertFingerprints(alerts template.Alerts) []model.Fingerprint {
+	fps := make([]model.Fingerprint, 0, len(alerts))
+	for _, a := range alerts {
+		fps = append(fps, alertLabelSet(a).Fingerprint())
+	}
+	sort.Slice(fps, func(i, j int) bool { return fps[i] < fps[j] })
+	return fps
+}
+
+// shows returns whether the message shows the alert with the fingerprint
+func (a *HandleAlert) shows(fp model.Fingerprint) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, f := range a.Fingerprints {
+		if f == fp {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvedIn returns whether all alerts the message shows are resolved
+func (a *HandleAlert) resolvedIn(resolved map[model.Fingerprint]bool) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, f := range a.Fingerprints {
+		if !resolved[f] {
+			return false
+		}
+	}
+	return len(a.Fingerprints) > 0
+}
+
+// firingIn returns whether any alert the message shows is still firing
+func (a *HandleAlert) firingIn(firing map[model.Fingerprint]bool) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, f := range a.Fingerprints {
+		if firing[f] {
+			return true
+		}
+	}
+	return false
+}
+
+// assignee picks the member of the chat and level on call, a random one if nobody of the level is on call
+func (a *HandleAlert) assignee() (Member, error) {
+	lvl := a.level()
+	if a.OnCall != nil {
+		members, err := a.MemberStore.GetMembersByChat(a.Chat)
+		if err != nil {
+			return Member{}, err
+		}
+		for _, m := range members {
+			if m.Level == lvl && a.OnCall(m.Username) {
+				return m, nil
+			}
+		}
+	}
+	return a.MemberStore.GetRandomMemberByChatandLevel(a.Chat, string(lvl))
 }
 
 // IncreaseLevel increase the level on alert
 func (a *HandleAlert) IncreaseLevel() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	if a.Level == levelOne {
 		a.Level = levelTwo
 	} else if a.Level == levelTwo {
@@ -0,0 +1,31 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestReplyMarkup(t *testing.T) {
+	alert := template.Alert{
+		Annotations: template.KV{
+			"runbook_url": "https://wiki.example.com/runbooks/HighCPU",
+			"dashboard":   "not a url",
+		},
+		GeneratorURL: "http://prometheus:9090/graph?g0.expr=up",
+	}
+	ack := telebot.KeyboardButton{Text: strAcknowledgeData, Data: "{}"}
+
+	markup := replyMarkup(alert, []telebot.KeyboardButton{ack})
+	assert.Equal(t, [][]telebot.KeyboardButton{
+		{ack},
+		{
+			{Text: "Runbook", URL: "https://wiki.example.com/runbooks/HighCPU"},
+			{Text: "Graph", URL: "http://prometheus:9090/graph?g0.expr=up"},
+		},
+	}, markup.InlineKeyboard)
+
+	assert.Nil(t, replyMarkup(template.Alert{}, nil).InlineKeyboard)
+}
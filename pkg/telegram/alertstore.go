@@ -0,0 +1,220 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/common/model"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/alertmanager"
+	"github.com/vu-long/alertmanager-bot/pkg/ticket"
+)
+
+const telegramAlertsDirectory = "telegram/alerts"
+
+// StoredAlert is the state of an alert sent to a chat, stored to resume its escalation after a restart
+type StoredAlert struct {
+	ID             string                  `json:"id"`
+	Group          string                  `json:"group"`
+	MessageID      int                     `json:"messageId"`
+	Chat           telebot.Chat            `json:"chat"`
+	Alert          template.Alert          `json:"alert"`
+	Fingerprints   []model.Fingerprint     `json:"fingerprints,omitempty"`
+	Level          HandleLevel             `json:"level"`
+	LastUpdate     time.Time               `json:"lastUpdate"`
+	AutoForward    bool                    `json:"autoForward"`
+	ForwardTimeout time.Duration           `json:"forwardTimeout"`
+	FiredAt        time.Time               `json:"firedAt"`
+	AcknowledgedBy string                  `json:"acknowledgedBy,omitempty"`
+	AcknowledgedAt time.Time               `json:"acknowledgedAt,omitempty"`
+	Exhausted      bool                    `json:"exhausted,omitempty"`
+	Ticket         *ticket.Ticket          `json:"ticket,omitempty"`
+	GroupLink      *telebot.KeyboardButton `json:"groupLink,omitempty"`
+}
+
+// AlertStore writes the alerts sent to the chats to a libkv store backend
+type AlertStore struct {
+	kv store.Store
+}
+
+// NewAlertStore stores the alerts in the provided kv backend
+func NewAlertStore(kv store.Store) (*AlertStore, error) {
+	return &AlertStore{kv: kv}, nil
+}
+
+// List the stored alerts from the kv backend
+func (s *AlertStore) List() ([]StoredAlert, error) {
+	kvPairs, err := s.kv.List(telegramAlertsDirectory)
+	if err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+
+	var alerts []StoredAlert
+	for _, kv := range kvPairs {
+		var a StoredAlert
+		if err := json.Unmarshal(kv.Value, &a); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, nil
+}
+
+// Put adds or updates the alert in the kv backend
+func (s *AlertStore) Put(a StoredAlert) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return s.kv.Put(alertKey(a), b, nil)
+}
+
+// Remove the alert from the kv backend
+func (s *AlertStore) Remove(a StoredAlert) error {
+	err := s.kv.Delete(alertKey(a))
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+// alertKey identifies the alert by its message, alerts with the same ID are sent to many chats
+func alertKey(a StoredAlert) string {
+	return fmt.Sprintf("%s/%d/%d", telegramAlertsDirectory, a.Chat.ID, a.MessageID)
+}
+
+// stored returns the state of the alert to be stored
+func (a *HandleAlert) stored() StoredAlert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return StoredAlert{
+		ID:             a.ID,
+		Group:          a.Group,
+		MessageID:      a.MessageID,
+		Chat:           a.Chat,
+		Alert:          a.Alert,
+		Fingerprints:   a.Fingerprints,
+		Level:          a.Level,
+		LastUpdate:     a.LastUpdate,
+		AutoForward:    a.AutoForwardFlag,
+		ForwardTimeout: a.ForwardTimeout,
+		FiredAt:        a.FiredAt,
+		AcknowledgedBy: a.AcknowledgedBy,
+		AcknowledgedAt: a.AcknowledgedAt,
+		Exhausted:      a.exhausted,
+		Ticket:         a.Ticket,
+		GroupLink:      a.GroupLink,
+	}
+}
+
+// restoreAlert creates the alert of the stored state again
+func (b *Bot) restoreAlert(s StoredAlert) *HandleAlert {
+	// The buttons of alerts stored without group refer to them by their ID
+	group := s.Group
+	if group == "" {
+		group = s.ID
+	}
+	// Alerts stored without fingerprints are identified by the alert escalating them
+	fingerprints := s.Fingerprints
+	if len(fingerprints) == 0 {
+		fingerprints = alertFingerprints(template.Alerts{s.Alert})
+	}
+	a := b.newHandleAlert(s.ID, group, s.Chat, s.Alert, fingerprints, s.ForwardTimeout, s.GroupLink)
+	a.MessageID = s.MessageID
+	a.Level = s.Level
+	a.LastUpdate = s.LastUpdate
+	a.AutoForwardFlag = s.AutoForward
+	a.FiredAt = s.FiredAt
+	a.AcknowledgedBy = s.AcknowledgedBy
+	a.AcknowledgedAt = s.AcknowledgedAt
+	a.exhausted = s.Exhausted
+	a.Ticket = s.Ticket
+	return a
+}
+
+// saveAlert stores the changed alert, resolved alerts are removed as there is nothing to resume
+func (b *Bot) saveAlert(a *HandleAlert) {
+	if b.alertStore == nil {
+		return
+	}
+	var err error
+	if atomic.LoadInt32(&a.resolved) == 1 {
+		err = b.alertStore.Remove(a.stored())
+	} else {
+		err = b.alertStore.Put(a.stored())
+	}
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to store alert", "chat_id", a.Chat.ID, "alertname", a.ID, "err", err)
+	}
+}
+
+// forgetAlert removes the alert the bot doesn't track anymore from the store
+func (b *Bot) forgetAlert(a *HandleAlert) {
+	if b.alertStore == nil {
+		return
+	}
+	if err := b.alertStore.Remove(a.stored()); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to remove alert from store", "chat_id", a.Chat.ID, "alertname", a.ID, "err", err)
+	}
+}
+
+// recoverAlerts tracks the stored alerts again after a restart. The alerts the Alertmanager resolved meanwhile
+// get their buttons removed, the escalation of those still firing is resumed where it stopped.
+// If the Alertmanager can't be reached all of them are resumed, rather than missing an escalation.
+func (b *Bot) recoverAlerts() {
+	stored, err := b.alertStore.List()
+	if err != nil {
+		b.reportError("failed to list stored alerts, their escalations aren't resumed", "err", err)
+		return
+	}
+	if len(stored) == 0 {
+		return
+	}
+
+	var firing map[model.Fingerprint]bool
+	active, err := alertmanager.ListAlerts(b.logger, b.alertmanager.String())
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to list alerts of the alertmanager, resuming all stored alerts", "err", err)
+	} else {
+		firing = make(map[model.Fingerprint]bool, len(active))
+		for _, a := range active {
+			if !a.Resolved() {
+				firing[a.Fingerprint()] = true
+			}
+		}
+	}
+
+	resumed, resolved := 0, 0
+	for _, s := range stored {
+		// Other replicas recover the alerts of their chats
+		if !b.ownsChat(s.Chat.ID) {
+			continue
+		}
+		a := b.restoreAlert(s)
+
+		// The message is kept as long as any of its alerts fires
+		if firing != nil && !a.firingIn(firing) {
+			if err := a.Clear(b.sender); err != nil {
+				level.Warn(b.logger).Log("msg", "failed to remove buttons of alert resolved meanwhile", "chat_id", a.Chat.ID, "alertname", a.ID, "err", err)
+			}
+			b.forgetAlert(a)
+			resolved++
+			continue
+		}
+
+		for _, evicted := range b.alerts.Add(a) {
+			evicted.stopEscalation()
+			b.forgetAlert(evicted)
+		}
+		if a.escalating() {
+			b.escalations.Schedule(a, a.nextForward())
+		}
+		resumed++
+	}
+	level.Info(b.logger).Log("msg", "recovered stored alerts", "resumed", resumed, "resolved", resolved)
+}
@@ -0,0 +1,100 @@
+package telegram
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestRecoverAlerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alerts")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	defer kv.Close()
+	s, err := NewAlertStore(kv)
+	assert.NoError(t, err)
+
+	// Only DiskFull is still firing in the Alertmanager
+	am := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data": []map[string]interface{}{
+				{"labels": map[string]string{"alertname": "DiskFull"}, "startsAt": time.Now().Add(-time.Hour)},
+			},
+		})
+	}))
+	defer am.Close()
+	u, _ := url.Parse(am.URL)
+
+	chat := telebot.Chat{ID: -100}
+	lastUpdate := time.Now().Add(-time.Minute)
+	for i, name := range []string{"DiskFull", "HighCPU"} {
+		assert.NoError(t, s.Put(StoredAlert{
+			ID:             name,
+			MessageID:      i + 1,
+			Chat:           chat,
+			Alert:          template.Alert{Status: "firing", Labels: template.KV{"alertname": name}},
+			Level:          levelTwo,
+			LastUpdate:     lastUpdate,
+			AutoForward:    true,
+			ForwardTimeout: 5 * time.Minute,
+			FiredAt:        lastUpdate,
+		}))
+	}
+
+	sender := newFakeSender()
+	b := &Bot{
+		logger:       log.NewNopLogger(),
+		sender:       sender,
+		alertmanager: u,
+		alertStore:   s,
+		alerts:       NewAlertRegistry(),
+		escalations:  newEscalations(),
+		events:       newEventBus(),
+	}
+	b.recoverAlerts()
+
+	// The escalation of the firing alert is resumed where it stopped
+	recovered := b.alerts.Get("DiskFull")
+	assert.Len(t, recovered, 1)
+	assert.Equal(t, levelTwo, recovered[0].level())
+	assert.Equal(t, 1, recovered[0].messageID())
+	assert.Equal(t, lastUpdate.Add(5*time.Minute).Unix(), recovered[0].nextForward().Unix())
+	assert.Equal(t, 1, b.escalations.Len())
+
+	// The alert resolved meanwhile isn't tracked and stored anymore
+	assert.Empty(t, b.alerts.Get("HighCPU"))
+	stored, err := s.List()
+	assert.NoError(t, err)
+	assert.Len(t, stored, 1)
+	assert.Equal(t, "DiskFull", stored[0].ID)
+
+	// Acknowledged alerts stay stored until they are resolved
+	recovered[0].stopEscalation()
+	b.saveAlert(recovered[0])
+	stored, err = s.List()
+	assert.NoError(t, err)
+	assert.False(t, stored[0].AutoForward)
+
+	assert.NoError(t, recovered[0].Clear(sender))
+	b.saveAlert(recovered[0])
+	stored, err = s.List()
+	assert.NoError(t, err)
+	assert.Empty(t, stored)
+}
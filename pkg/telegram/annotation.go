@@ -0,0 +1,29 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vu-long/alertmanager-bot/pkg/grafana"
+)
+
+// annotation of an acknowledged or resolved alert tagged with the event and the alert's labels,
+// ok is false for the other events
+func annotation(e Event) (a grafana.Annotation, ok bool) {
+	if e.Type != eventAcknowledged && e.Type != eventResolved {
+		return grafana.Annotation{}, false
+	}
+
+	text := fmt.Sprintf("%s %s", e.AlertID, e.Type)
+	if e.User != "" {
+		text += " by @" + e.User
+	}
+
+	tags := []string{e.Type}
+	for name, value := range e.Labels {
+		tags = append(tags, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(tags[1:])
+
+	return grafana.NewAnnotation(e.Time, text, tags...), true
+}
@@ -0,0 +1,31 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vu-long/alertmanager-bot/pkg/grafana"
+)
+
+func TestAnnotation(t *testing.T) {
+	at := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+
+	a, ok := annotation(Event{
+		Time:    at,
+		Type:    eventAcknowledged,
+		AlertID: "HighCPU",
+		User:    "vu_long",
+		Labels:  map[string]string{"alertname": "HighCPU", "instance": "web01:9100"},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, grafana.NewAnnotation(at, "HighCPU acknowledged by @vu_long", "acknowledged", "alertname=HighCPU", "instance=web01:9100"), a)
+
+	a, ok = annotation(Event{Time: at, Type: eventResolved, AlertID: "HighCPU"})
+	assert.True(t, ok)
+	assert.Equal(t, "HighCPU resolved", a.Text)
+	assert.Equal(t, []string{"resolved"}, a.Tags)
+
+	_, ok = annotation(Event{Time: at, Type: eventFired, AlertID: "HighCPU"})
+	assert.False(t, ok, "only acknowledged and resolved alerts are annotated")
+}
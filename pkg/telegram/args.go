@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Args are the arguments of a command, split on spaces except inside quotes.
+// Arguments like key=value are named, the others positional.
+type Args struct {
+	Positional []string
+	Named      map[string]string
+}
+
+// parseArgs splits the text of a message after the command into its arguments.
+// Single and double quotes keep spaces and '=' in arguments, inside double quotes a backslash escapes the next rune.
+// Ex: /addmember vu_long 1 "web server" => [vu_long 1 web server]
+// Ex: /mute HighCPU for=2h => [HighCPU] map[for:2h]
+func parseArgs(text string) (Args, error) {
+	args := Args{Named: make(map[string]string)}
+
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return args, nil
+	}
+	rest := []rune(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), fields[0])))
+
+	var (
+		current  strings.Builder
+		inToken  bool
+		quote    rune
+		eq       = -1 // position of the first unquoted '=' in the current argument
+		position int
+	)
+	flush := func() {
+		if !inToken {
+			return
+		}
+		arg := current.String()
+		if eq > 0 && isArgName(arg[:eq]) {
+			args.Named[arg[:eq]] = arg[eq+1:]
+		} else {
+			args.Positional = append(args.Positional, arg)
+		}
+		current.Reset()
+		inToken, eq = false, -1
+	}
+
+	for i := 0; i < len(rest); i++ {
+		r := rest[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' && i+1 < len(rest) {
+				i++
+				current.WriteRune(rest[i])
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inToken, position = r, true, i
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			if r == '=' && eq < 0 {
+				eq = current.Len()
+			}
+			current.WriteRune(r)
+			inToken = true
+		}
+	}
+	if quote != 0 {
+		return Args{}, fmt.Errorf("unterminated quote at %q", string(rest[position:]))
+	}
+	flush()
+
+	return args, nil
+}
+
+// isArgName returns whether the text can name an argument
+func isArgName(name string) bool {
+	for i, r := range name {
+		if r != '_' && r != '-' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// Unknown returns an error naming the named arguments that aren't one of the known names
+func (a Args) Unknown(known ...string) error {
+	var unknown []string
+	for name := range a.Named {
+		found := false
+		for _, k := range known {
+			if name == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("unknown arguments: %s", strings.Join(unknown, ", "))
+}
@@ -0,0 +1,32 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseArgs(t *testing.T) {
+	args, err := parseArgs(`/addmember vu_long 1 "web server"`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vu_long", "1", "web server"}, args.Positional)
+	assert.Empty(t, args.Named)
+
+	args, err = parseArgs(`/addmember  vu_long level=1 node='web server' "a=b" comment="say \"hi\""`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vu_long", "a=b"}, args.Positional, "quoted '=' doesn't name an argument")
+	assert.Equal(t, map[string]string{"level": "1", "node": "web server", "comment": `say "hi"`}, args.Named)
+	assert.EqualError(t, args.Unknown("level", "node"), "unknown arguments: comment")
+	assert.NoError(t, args.Unknown("level", "node", "comment"))
+
+	args, err = parseArgs(`/mute "" 2h =x`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "2h", "=x"}, args.Positional, "empty quotes are an argument")
+
+	args, err = parseArgs("/mute")
+	assert.NoError(t, err)
+	assert.Empty(t, args.Positional)
+
+	_, err = parseArgs(`/mute "High CPU 2h`)
+	assert.EqualError(t, err, `unterminated quote at "\"High CPU 2h"`)
+}
@@ -0,0 +1,213 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandAudit = "/audit"
+
+	telegramAuditDirectory = "telegram/audit"
+
+	// Outcomes of audited commands
+	auditExecuted = "executed"
+	auditUnknown  = "unknown command"
+
+	// defaultAuditEntries are shown by /audit without a count
+	defaultAuditEntries = 20
+	// maxAuditText limits the command text stored per entry
+	maxAuditText = 200
+	// auditTrimInterval is the number of entries added between removing the entries exceeding the max
+	auditTrimInterval = 100
+	// maxMessageLength is the longest message Telegram accepts
+	maxMessageLength = 4096
+)
+
+// AuditEntry records a command sent to the bot
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	UserID   int       `json:"userID"`
+	Username string    `json:"username"`
+	ChatID   int64     `json:"chatID"`
+	Command  string    `json:"command"`
+	Text     string    `json:"text"`
+	Outcome  string    `json:"outcome"`
+}
+
+// String formats the entry as a line of /audit
+func (e AuditEntry) String() string {
+	return fmt.Sprintf("%s @%s (%d) in %d: %s [%s]",
+		e.Time.Format("2006-01-02 15:04:05"), e.Username, e.UserID, e.ChatID, e.Text, e.Outcome,
+	)
+}
+
+// AuditStore writes the audit log to a libkv store backend
+type AuditStore struct {
+	kv store.Store
+	// max is the number of entries kept, older ones are removed
+	max int
+
+	mu    sync.Mutex
+	added int // entries added since the last trim
+}
+
+// NewAuditStore stores the audit log in the provided kv backend, keeping the max newest entries
+func NewAuditStore(kv store.Store, max int) (*AuditStore, error) {
+	return &AuditStore{kv: kv, max: max}, nil
+}
+
+// List the audit log from the kv backend, newest first, at most the max entries
+func (s *AuditStore) List() ([]AuditEntry, error) {
+	entries, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	if s.max > 0 {
+		entries = entries[:min(len(entries), s.max)]
+	}
+	return entries, nil
+}
+
+// list reads all entries including those not trimmed yet, newest first
+func (s *AuditStore) list() ([]AuditEntry, error) {
+	kvPairs, err := s.kv.List(telegramAuditDirectory)
+	if err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+
+	var entries []AuditEntry
+	for _, kv := range kvPairs {
+		var e AuditEntry
+		if err := json.Unmarshal(kv.Value, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+
+	return entries, nil
+}
+
+// Add an entry to the kv backend. The entries exceeding the max are removed every auditTrimInterval entries,
+// instead of reading the whole log for every command.
+func (s *AuditStore) Add(e AuditEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err := s.kv.Put(auditKey(e), b, nil); err != nil {
+		return err
+	}
+
+	if s.max <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.added++
+	trim := s.added >= auditTrimInterval
+	if trim {
+		s.added = 0
+	}
+	s.mu.Unlock()
+	if !trim {
+		return nil
+	}
+
+	entries, err := s.list()
+	if err != nil {
+		return err
+	}
+	for _, old := range entries[min(len(entries), s.max):] {
+		if err := s.kv.Delete(auditKey(old)); err != nil && err != store.ErrKeyNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditKey orders the entries by time and keeps entries of the same time apart
+func auditKey(e AuditEntry) string {
+	return fmt.Sprintf("%s/%020d-%d", telegramAuditDirectory, e.Time.UnixNano(), e.UserID)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// auditCommand records a command that passed the permission check in the audit log, if it is enabled.
+// Forbidden commands are only counted, so that senders without permission can't fill the log.
+func (b *Bot) auditCommand(message telebot.Message, command, text, outcome string) {
+	if b.audit == nil {
+		return
+	}
+
+	err := b.audit.Add(AuditEntry{
+		Time:     time.Now(),
+		UserID:   message.Sender.ID,
+		Username: message.Sender.Username,
+		ChatID:   message.Chat.ID,
+		Command:  command,
+		Text:     truncate(maxAuditText, text),
+		Outcome:  outcome,
+	})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to add command to audit log", "err", err)
+	}
+}
+
+func (b *Bot) handleAudit(message telebot.Message) {
+	// Right format: '/audit [count]'.
+	// Ex: /audit 50
+	params := strings.Fields(message.Text)[1:]
+
+	if b.audit == nil {
+		b.reply(message, "The audit log is not enabled.", nil)
+		return
+	}
+
+	count := defaultAuditEntries
+	if len(params) > 0 {
+		n, err := strconv.Atoi(params[0])
+		if err != nil || n <= 0 || len(params) > 1 {
+			b.reply(message, "Please send right format: '/audit [count]'. Ex: /audit 50", nil)
+			return
+		}
+		count = n
+	}
+
+	entries, err := b.audit.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to list audit log from store", "err", err)
+		b.reply(message, "I can't get the audit log.", nil)
+		return
+	}
+	if len(entries) == 0 {
+		b.reply(message, "The audit log is empty.", nil)
+		return
+	}
+
+	var list []string
+	for _, e := range entries[:min(len(entries), count)] {
+		list = append(list, e.String())
+	}
+
+	b.sendPages(message, paginate("Recently executed commands:\n", list, itemsPerPage), "")
+}
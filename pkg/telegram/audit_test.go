@@ -0,0 +1,54 @@
+package telegram
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditEntry(t *testing.T) {
+	at := time.Date(2026, 10, 15, 9, 12, 44, 0, time.UTC)
+	e := AuditEntry{Time: at, UserID: 12345, Username: "vu_long", ChatID: -100, Command: commandMute, Text: "/mute HighCPU 2h", Outcome: auditExecuted}
+
+	assert.Equal(t, "2026-10-15 09:12:44 @vu_long (12345) in -100: /mute HighCPU 2h [executed]", e.String())
+
+	later := e
+	later.Time = at.Add(time.Nanosecond)
+	assert.True(t, auditKey(e) < auditKey(later))
+}
+
+func TestAuditStoreTrim(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	defer kv.Close()
+	s, err := NewAuditStore(kv, 10)
+	assert.NoError(t, err)
+
+	at := time.Now()
+	for i := 0; i < auditTrimInterval-1; i++ {
+		assert.NoError(t, s.Add(AuditEntry{Time: at.Add(time.Duration(i)), UserID: i}))
+	}
+	all, err := s.list()
+	assert.NoError(t, err)
+	assert.Len(t, all, auditTrimInterval-1, "entries are only removed every auditTrimInterval entries")
+	entries, err := s.List()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 10, "only the max newest entries are listed")
+	assert.Equal(t, auditTrimInterval-2, entries[0].UserID)
+
+	assert.NoError(t, s.Add(AuditEntry{Time: at.Add(time.Hour), UserID: auditTrimInterval}))
+	all, err = s.list()
+	assert.NoError(t, err)
+	assert.Len(t, all, 10)
+	assert.Equal(t, auditTrimInterval, all[0].UserID)
+}
@@ -4,23 +4,36 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/url"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/docker/libkv/store"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/hako/durafmt"
 	"github.com/oklog/run"
-	"github.com/prometheus/alertmanager/notify"
 	"github.com/prometheus/alertmanager/template"
 	"github.com/prometheus/alertmanager/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/tucnak/telebot"
 	"github.com/vu-long/alertmanager-bot/pkg/alertmanager"
+	"github.com/vu-long/alertmanager-bot/pkg/deploy"
+	"github.com/vu-long/alertmanager-bot/pkg/grafana"
+	"github.com/vu-long/alertmanager-bot/pkg/loki"
+	"github.com/vu-long/alertmanager-bot/pkg/mirror"
+	"github.com/vu-long/alertmanager-bot/pkg/outbound"
+	"github.com/vu-long/alertmanager-bot/pkg/pager"
+	"github.com/vu-long/alertmanager-bot/pkg/remediate"
+	"github.com/vu-long/alertmanager-bot/pkg/runbook"
+	"github.com/vu-long/alertmanager-bot/pkg/sentry"
+	"github.com/vu-long/alertmanager-bot/pkg/statuspage"
+	"github.com/vu-long/alertmanager-bot/pkg/ticket"
+	"github.com/vu-long/alertmanager-bot/pkg/tracing"
 )
 
 const (
@@ -35,6 +48,7 @@ const (
 	commandRemoveMember = "/rmmember"
 	commandMembers      = "/members"
 	commandNodes        = "/nodes"
+	commandFilter       = "/filter"
 
 	commandStatus     = "/status"
 	commandAlerts     = "/alerts"
@@ -51,19 +65,79 @@ I'm a Prometheus AlertManager Bot for Telegram. I will notify you about alerts.
 You can also ask me about my ` + commandStatus + `, ` + commandAlerts + ` & ` + commandSilences + `
 
 Available commands:
-` + commandStart + ` - Subscribe for alerts.
-` + commandStop + ` - Unsubscribe for alerts.
-` + commandStatus + ` - Print the current status.
-` + commandAlerts + ` - List all alerts.
-` + commandSilences + ` - List all silences.
-` + commandChats + ` - List all users and group chats that subscribed.
-` + commandMembers + ` - List all members.
-` + commandAddMember + ` - Add a member.
-` + commandRemoveMember + ` - Remove a member.
-` + commandNodes + ` - List all nodes.
 `
 )
 
+// botCommand is a command the bot understands
+type botCommand struct {
+	name        string
+	handler     func(message telebot.Message)
+	description string
+}
+
+// registeredCommands returns the commands of the bot in the order of /help
+func (b *Bot) registeredCommands() []botCommand {
+	return []botCommand{
+		{commandStart, b.handleStart, "Subscribe for alerts."},
+		{commandStop, b.handleStop, "Unsubscribe for alerts."},
+		{commandHelp, b.handleHelp, "Show the commands you can use."},
+		{commandSubscribe, b.handleSubscribe, "Subscribe for alerts of certain nodes only."},
+		{commandStatus, b.handleStatus, "Print the current status."},
+		{commandVersion, b.handleVersion, "Show the version of the bot and its notable changes."},
+		{commandAlerts, b.handleAlerts, "List all alerts."},
+		{commandAcked, b.handleAcked, "List the acknowledged alerts of this chat that aren't resolved yet."},
+		{commandSilences, b.handleSilences, "List all silences."},
+		{commandTargets, b.handleTargets, "Show the health of the Prometheus scrape targets per job."},
+		{commandRules, b.handleRules, "List the Prometheus alerting rules and whether they are pending or firing."},
+		{commandGraph, b.handleGraph, "Send a chart of a PromQL expression or an alert's generatorURL."},
+		{commandQuery, b.handleQuery, "Execute an instant PromQL query."},
+		{commandChats, b.handleChats, "List all users and group chats that subscribed."},
+		{commandMembers, b.handleMembers, "List all members."},
+		{commandAddMember, b.handleAddMember, "Add a member."},
+		{commandIAm, b.handleIAm, "Ask the admins to add you as a member of this chat."},
+		{commandRemoveMember, b.handleRemoveMember, "Remove a member."},
+		{commandAddAdmin, b.handleAddAdmin, "Add an admin by user ID."},
+		{commandRemoveAdmin, b.handleRemoveAdmin, "Remove an admin."},
+		{commandBan, b.handleBan, "Ignore all messages of a user."},
+		{commandUnban, b.handleUnban, "List banned users or unban a user."},
+		{commandNodes, b.handleNodes, "List all nodes."},
+		{commandTeam, b.handleTeam, "List, set or remove teams."},
+		{commandSettings, b.handleSettings, "Show and change the settings of this chat."},
+		{commandFilter, b.handleFilter, "Show or set the label matchers alerts for this chat have to match."},
+		{commandQuiet, b.handleQuiet, "Show or set the daily quiet hours of this chat."},
+		{commandMaintenance, b.handleMaintenance, "Show or start a maintenance window for this chat."},
+		{commandMute, b.handleMute, "Mute an alert in this chat for a while."},
+		{commandUnmute, b.handleUnmute, "Unmute an alert in this chat."},
+		{commandMutes, b.handleMutes, "List all muted alerts of this chat."},
+		{commandDigest, b.handleDigest, "Show or set the alerts only sent as periodic digest to this chat."},
+		{commandResolved, b.handleResolved, "Show or set whether resolved notifications are sent to this chat."},
+		{commandSetTemplate, b.handleSetTemplate, "Show or set the message template of this chat."},
+		{commandTemplateTest, b.handleTemplateTest, "Render a sample alert or the last webhook with the template of this chat."},
+		{commandMode, b.handleMode, "Show or set the compact or verbose mode of this chat, optionally by severity."},
+		{commandHistory, b.handleHistory, "List the alerts delivered to this chat recently."},
+		{commandStats, b.handleStats, "Show who acknowledged how many alerts of this chat and how fast."},
+		{commandRun, b.handleRun, "List the runbook actions or request one, which runs once another admin approved it."},
+		{commandAudit, b.handleAudit, "List the recently executed commands."},
+		{commandBotStats, b.handleBotStats, "Show the health of the bot."},
+		{commandReload, b.handleReload, "Reload the admins, routing configuration and templates."},
+		{commandDebug, b.handleDebug, "Stream the webhook, escalation and callback events to you for a while."},
+	}
+}
+
+// readOnlyCommands don't change anything and can be permitted for non-admins with WithReadOnlyCommands
+var readOnlyCommands = map[string]bool{
+	commandAlerts:   true,
+	commandAcked:    true,
+	commandSilences: true,
+	commandStatus:   true,
+	commandVersion:  true,
+	commandHelp:     true,
+	commandHistory:  true,
+	commandTargets:  true,
+	commandRules:    true,
+	commandStats:    true,
+}
+
 // BotChatStore is all the Bot needs to store and read
 type BotChatStore interface {
 	List() ([]telebot.Chat, error)
@@ -75,42 +149,186 @@ type BotChatStore interface {
 type BotMemberStore interface {
 	List() ([]Member, error)
 	Add(Member) error
+	AddAll([]Member) error
 	Remove(Member) error
 	GetMembersByChat(telebot.Chat) ([]Member, error)
 	GetRandomMemberByChatandLevel(telebot.Chat, string) (Member, error)
 }
 
+// BotTeamStore is all the Bot needs to store and read teams
+type BotTeamStore interface {
+	List() ([]Team, error)
+	Add(Team) error
+	Remove(Team) error
+}
+
+// BotAuditStore is all the Bot needs to store and read the audit log
+type BotAuditStore interface {
+	List() ([]AuditEntry, error)
+	Add(AuditEntry) error
+}
+
+// BotHistoryStore is all the Bot needs to record and read the history of delivered alerts
+type BotHistoryStore interface {
+	List(chatID int64, since time.Time) ([]HistoryEntry, error)
+	Record(Event) error
+}
+
+// BotOutboxStore is all the Bot needs to record the messages until they are delivered
+type BotOutboxStore interface {
+	List() ([]OutboxEntry, error)
+	Put(OutboxEntry) error
+	Remove(OutboxEntry) error
+}
+
+// BotUserRefStore is all the Bot needs to store and read lists of users
+type BotUserRefStore interface {
+	List() ([]UserRef, error)
+	Add(UserRef) error
+	Remove(UserRef) error
+	Contains(telebot.User) (bool, error)
+}
+
 // BotNodeStore is all the Bot needs to store and read
 type BotNodeStore interface {
 	List() ([]NodeExported, error)
 	Add(NodeExported) error
+	AddAll([]NodeExported) error
 	Remove(NodeExported) error
+	GetByChat(telebot.Chat) ([]NodeExported, error)
+}
+
+// BotSettingsStore is all the Bot needs to store and read
+type BotSettingsStore interface {
+	Get(telebot.Chat) (ChatSettings, error)
+	Set(telebot.Chat, ChatSettings) error
 }
 
 // Bot runs the alertmanager telegram
 type Bot struct {
-	addr         string
-	admins       []int // must be kept sorted
-	alertmanager *url.URL
-	templates    *template.Template
-	chats        BotChatStore
-	members      BotMemberStore
-	nodes        BotNodeStore
-	logger       log.Logger
-	revision     string
-	startTime    time.Time
+	addr          string
+	admins        []int          // must be kept sorted
+	adminNames    map[int]string // usernames of the admins, looked up when a ban needs them
+	adminsMu      sync.RWMutex
+	adminChats    []int64
+	allowedChats  []int64 // restrict the bot to these chats if not empty
+	allowReadOnly bool    // permits the readOnlyCommands from any sender
+	chatAdmins    bool    // permits the chatAdminCommands from group administrators
+	alertmanager  *url.URL
+	prometheus    *url.URL
+	templates     *Templates
+	templatesMu   sync.RWMutex
+
+	stats         botStats
+	lastWebhookMu sync.Mutex
+	lastWebhook   *template.Data
+	chats         BotChatStore
+	members       BotMemberStore
+	nodes         BotNodeStore
+	settings      BotSettingsStore
+	logger        log.Logger
+	revision      string
+	buildInfo     BuildInfo
+	startTime     time.Time
+
+	quietOverrides []string
+	held           *heldAlerts
+	digests        *heldAlerts
+	alerts         *AlertRegistry
+	escalations    *escalations
+	confirmations  *confirmations
+	onboardings    *onboardings
+	pages          *paginator
+	router         *Router
+	teams          BotTeamStore
+	audit          BotAuditStore
+	history        BotHistoryStore
+	historyEvents  chan Event
+	adminStore     BotUserRefStore
+	bans           BotUserRefStore
+	fallbackChat   int64
+	errorsChat     int64 // receives the operational failures, disabled if 0
+	errorNotices   *deduplicator
+
+	suppressResolved bool // chats without an own choice aren't sent resolved notifications
+	noResolved       bool // no resolved notifications are sent, chats can't turn them on
+	dedup            *deduplicator
+	forbiddenNotices *deduplicator // nil disables the notifications about forbidden senders
+	// receiverTemplates maps the receivers of webhooks to the template of their alerts
+	receiverTemplates map[string]string
+	cooldown          time.Duration
+	watchdog          *watchdog
+	unknownCommands   *unknownCommands
+	onCall            *onCallSchedule
+	onCallURL         string
+	onCallInterval    time.Duration
 
 	telegram *telebot.Bot
+	// sender delivers all messages of the bot, Telegram unless another transport was chosen
+	sender MessageSender
 
 	commandsCounter *prometheus.CounterVec
+	commandDuration *prometheus.HistogramVec
 	webhooksCounter prometheus.Counter
+	unroutedCounter prometheus.Counter
+	alertMetrics    *alertMetrics
+	events          *eventBus
+	debugMu         sync.Mutex
+	debugTaps       map[int]*debugTap // keyed by the admin's user ID
+	tracer          *tracing.Tracer
+	sentry          *sentry.Client
+	grafana         *grafana.Client
+	tracker         ticket.Tracker
+	pager           *pager.Client
+	mirror          *mirror.Client
+	karma           *url.URL
+	loki            *loki.Client
+	// lokiLabels map the labels of alerts to the labels of their log streams
+	lokiLabels map[string]string
+	lokiLines  int
+	lokiWindow time.Duration
+	// imagesMaxSize enables sending the images referenced by firing alerts
+	imagesMaxSize int64
+	imagesTimeout time.Duration
+	statusPage    *statuspage.Client
+	// statusPageSeverities of the acknowledged alerts an incident is opened for
+	statusPageSeverities map[string]bool
+	statusPageEvents     chan Event
+	deploys              deploy.Source
+	// deployRepositories are the repositories of the services by the value of the deployLabel of alerts
+	deployRepositories map[string]string
+	deployLabel        string
+	deployEnvironment  string
+	deployWindow       time.Duration
+	remediations       *remediate.Config
+	runbook            *runbook.Config
+	outbox             *outbox
+	outboxStore        BotOutboxStore
+	outboxMaxAge       time.Duration
+	alertStore         BotAlertStore // persists the alerts to resume their escalations, nothing is resumed if nil
+	callbackStore      BotCallbackStore
+	callbacks          *callbacks // the payloads of the buttons of alerts
+	outbound           *outbound.Client
+	// outboundTypes of the events sent to the outbound webhooks
+	outboundTypes map[string]bool
+	// deliveryWorkers deliver a webhook to this many chats at once
+	deliveryWorkers int
+	deliveryLatency *prometheus.HistogramVec
+	sendRate        float64      // calls to the sender per second, unlimited if 0
+	chatSendRate    float64      // messages per minute to each chat, unlimited if 0
+	reload          func() error // reloads the configuration for /reload, unsupported if nil
+	shard           BotShard     // assigns the chats to replicas, all chats are this replica's if nil
+	handover        BotHandoverStore
+	pollStart       <-chan struct{} // polling Telegram starts once closed, right away if nil
+	polled          int64           // unix nanoseconds Telegram was last polled at, atomic
+	registerer      prometheus.Registerer
 }
 
 // BotOption passed to NewBot to change the default instance
 type BotOption func(b *Bot)
 
 // NewBot creates a Bot with the UserStore and telegram telegram
-func NewBot(chats BotChatStore, members BotMemberStore, nodes BotNodeStore, token string, admin int, opts ...BotOption) (*Bot, error) {
+func NewBot(chats BotChatStore, members BotMemberStore, nodes BotNodeStore, settings BotSettingsStore, token string, admin int, opts ...BotOption) (*Bot, error) {
 	bot, err := telebot.NewBot(token)
 	if err != nil {
 		return nil, err
@@ -121,27 +339,136 @@ func NewBot(chats BotChatStore, members BotMemberStore, nodes BotNodeStore, toke
 		Name:      "commands_total",
 		Help:      "Number of commands received by command name",
 	}, []string{"command"})
-	if err := prometheus.Register(commandsCounter); err != nil {
+
+	commandDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "alertmanagerbot",
+		Name:      "command_duration_seconds",
+		Help:      "Latency of processing commands by command name, including the permission checks and the handler",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"command"})
+
+	unroutedCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "alertmanagerbot",
+		Name:      "alerts_unrouted_total",
+		Help:      "Number of alerts that matched no chat",
+	})
+
+	deliveryLatency := newDeliveryLatency()
+
+	alertMetrics := newAlertMetrics()
+	alerts := NewAlertRegistry()
+
+	templates, err := LoadTemplates()
+	if err != nil {
 		return nil, err
 	}
 
 	b := &Bot{
 		logger:          log.NewNopLogger(),
 		telegram:        bot,
+		sender:          newTelegramSender(bot, token),
 		chats:           chats,
 		members:         members,
 		nodes:           nodes,
+		settings:        settings,
 		addr:            "127.0.0.1:8080",
 		admins:          []int{admin},
 		alertmanager:    &url.URL{Host: "localhost:9093"},
 		commandsCounter: commandsCounter,
-		// TODO: initialize templates with default?
+		commandDuration: commandDuration,
+		unroutedCounter: unroutedCounter,
+		alertMetrics:    alertMetrics,
+		deliveryWorkers: defaultDeliveryWorkers,
+		deliveryLatency: deliveryLatency,
+		events:          newEventBus(),
+		debugTaps:       make(map[int]*debugTap),
+		quietOverrides:  []string{"critical"},
+		held:            newHeldAlerts(),
+		digests:         newHeldAlerts(),
+		alerts:          alerts,
+		escalations:     newEscalations(),
+		confirmations:   newConfirmations(),
+		onboardings:     newOnboardings(),
+		pages:           newPaginator(),
+		unknownCommands: newUnknownCommands(),
+		dedup:           newDeduplicator(0),
+		errorNotices:    newDeduplicator(errorsWindow),
+		templates:       templates,
+		registerer:      prometheus.DefaultRegisterer,
 	}
 
 	for _, opt := range opts {
 		opt(b)
 	}
 
+	b.callbacks = newCallbacks(b.callbackStore, log.With(b.logger, "component", "callbacks"))
+
+	// The metrics are registered once the options chose the registerer
+	for _, c := range []prometheus.Collector{commandsCounter, commandDuration, unroutedCounter, deliveryLatency, alerts.Collector()} {
+		if err := b.registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	if err := alertMetrics.register(b.registerer); err != nil {
+		return nil, err
+	}
+
+	// All deliveries share the rate limits, the outbox retries are limited too
+	if b.sendRate > 0 || b.chatSendRate > 0 {
+		limited := &rateLimitedSender{MessageSender: b.sender}
+		if b.sendRate > 0 {
+			limited.limiter = newRateLimiter(b.sendRate)
+		}
+		if b.chatSendRate > 0 {
+			limited.chats = newChatLimiter(b.chatSendRate, chatSendBurst)
+		}
+		b.sender = limited
+	}
+
+	// The outbox wraps whichever sender was configured
+	if b.outboxStore != nil {
+		b.outbox = newOutbox(b.sender, b.outboxStore, b.outboxMaxAge, log.With(b.logger, "component", "outbox"))
+		if b.shard != nil {
+			b.outbox.owns = b.owns
+		}
+		b.sender = b.outbox
+	}
+
+	b.events.Subscribe(b.alertMetrics.Observe)
+	if b.pager != nil {
+		b.events.Subscribe(b.page)
+	}
+	if b.grafana != nil {
+		b.events.Subscribe(func(e Event) {
+			if a, ok := annotation(e); ok {
+				b.grafana.Annotate(a)
+			}
+		})
+	}
+	if b.outbound != nil {
+		b.events.Subscribe(b.notifyOutbound)
+	}
+	if b.statusPage != nil {
+		b.statusPageEvents = make(chan Event, statusPageBuffer)
+		b.events.Subscribe(b.queueStatusPage)
+	}
+	if b.history != nil {
+		b.historyEvents = make(chan Event, historyBuffer)
+		b.events.Subscribe(func(e Event) {
+			select {
+			case b.historyEvents <- e:
+			default:
+				level.Warn(b.logger).Log("msg", "dropped event of the alert history", "chat_id", e.ChatID, "alert_id", e.AlertID)
+			}
+		})
+	}
+
+	if b.watchdog != nil {
+		if err := b.watchdog.register(b.registerer); err != nil {
+			return nil, err
+		}
+	}
+
 	return b, nil
 }
 
@@ -150,370 +477,1289 @@ func WithLogger(l log.Logger) BotOption {
 	return func(b *Bot) {
 		b.logger = l
 	}
-}
+}
+
+// WithRegisterer registers the metrics of the bot with reg instead of the default registerer,
+// e.g. to label the metrics of several bots in one process
+func WithRegisterer(reg prometheus.Registerer) BotOption {
+	return func(b *Bot) {
+		b.registerer = reg
+	}
+}
+
+// WithAddr sets the internal listening addr of the bot's web server receiving webhooks
+func WithAddr(addr string) BotOption {
+	return func(b *Bot) {
+		b.addr = addr
+	}
+}
+
+// WithAlertmanager sets the connection url for the Alertmanager
+func WithAlertmanager(u *url.URL) BotOption {
+	return func(b *Bot) {
+		b.alertmanager = u
+	}
+}
+
+// WithPrometheus sets the URL of the Prometheus queried by /graph, /query, /targets and /rules
+func WithPrometheus(u *url.URL) BotOption {
+	return func(b *Bot) {
+		b.prometheus = u
+	}
+}
+
+// WithTemplates uses Alertmanager template to render messages for Telegram
+// instead of the built-in DefaultTemplate, see LoadTemplates
+func WithTemplates(t *Templates) BotOption {
+	return func(b *Bot) {
+		b.templates = t
+	}
+}
+
+// SetTemplates atomically swaps the templates used to render messages, e.g. after reloading them
+func (b *Bot) SetTemplates(t *Templates) {
+	b.templatesMu.Lock()
+	defer b.templatesMu.Unlock()
+
+	b.templates = t
+}
+
+// currentTemplates returns the templates used to render messages
+func (b *Bot) currentTemplates() *Templates {
+	b.templatesMu.RLock()
+	defer b.templatesMu.RUnlock()
+
+	return b.templates
+}
+
+// WithRevision is setting the Bot's revision for status commands
+func WithRevision(r string) BotOption {
+	return func(b *Bot) {
+		b.revision = r
+	}
+}
+
+// WithAdminStore allows the admins added with /addadmin to issue admin commands to the bot.
+func WithAdminStore(admins BotUserRefStore) BotOption {
+	return func(b *Bot) {
+		b.adminStore = admins
+	}
+}
+
+// WithBans ignores all messages of the users banned with /ban.
+func WithBans(bans BotUserRefStore) BotOption {
+	return func(b *Bot) {
+		b.bans = bans
+	}
+}
+
+// WithForbiddenNotices forwards a summary of messages dropped from forbidden senders to the admins.
+func WithForbiddenNotices(notify bool) BotOption {
+	return func(b *Bot) {
+		b.forbiddenNotices = nil
+		if notify {
+			b.forbiddenNotices = newDeduplicator(forbiddenNoticeWindow)
+		}
+	}
+}
+
+// WithAdminChats allows every member of the specified chats to issue admin
+// commands to the bot in these chats.
+func WithAdminChats(ids ...int64) BotOption {
+	return func(b *Bot) {
+		b.adminChats = append(b.adminChats, ids...)
+	}
+}
+
+// WithAllowedChats restricts the bot to the specified chats, the admin chats
+// and the private chats of the admins. It refuses commands from other chats
+// and leaves the groups it is added to that aren't allowed.
+func WithAllowedChats(ids ...int64) BotOption {
+	return func(b *Bot) {
+		b.allowedChats = append(b.allowedChats, ids...)
+	}
+}
+
+// WithChatAdmins lets the administrators of a group manage the members and nodes of their group,
+// while the bot's admins keep managing all of them.
+func WithChatAdmins(allow bool) BotOption {
+	return func(b *Bot) {
+		b.chatAdmins = allow
+	}
+}
+
+// WithReadOnlyCommands permits the commands that don't change anything,
+// like /alerts and /status, from any sender. All other commands stay admin-only.
+func WithReadOnlyCommands(allow bool) BotOption {
+	return func(b *Bot) {
+		b.allowReadOnly = allow
+	}
+}
+
+// SetAdmins atomically swaps the admin IDs, e.g. after reloading the configuration
+func (b *Bot) SetAdmins(ids []int) {
+	admins := append([]int(nil), ids...)
+	sort.Ints(admins)
+
+	b.adminsMu.Lock()
+	defer b.adminsMu.Unlock()
+
+	b.admins = admins
+}
+
+// currentAdmins returns the sorted admin IDs
+func (b *Bot) currentAdmins() []int {
+	b.adminsMu.RLock()
+	defer b.adminsMu.RUnlock()
+
+	return b.admins
+}
+
+// WithStartTime is setting the Bot's start time for status commands
+func WithStartTime(st time.Time) BotOption {
+	return func(b *Bot) {
+		b.startTime = st
+	}
+}
+
+// WithExtraAdmins allows the specified additional user IDs to issue admin
+// commands to the bot.
+func WithExtraAdmins(ids ...int) BotOption {
+	return func(b *Bot) {
+		b.admins = append(b.admins, ids...)
+		sort.Ints(b.admins)
+	}
+}
+
+// WithQuietOverrides sets the severities of alerts that are delivered
+// even during a chat's quiet hours or maintenance windows.
+func WithQuietOverrides(severities ...string) BotOption {
+	return func(b *Bot) {
+		b.quietOverrides = severities
+	}
+}
+
+// WithRouter routes alerts to chats by a routing configuration
+// instead of sending all alerts to all subscribed chats.
+func WithRouter(r *Router) BotOption {
+	return func(b *Bot) {
+		b.router = r
+	}
+}
+
+// WithTeams enables teams that routes can reference instead of chats
+func WithTeams(teams BotTeamStore) BotOption {
+	return func(b *Bot) {
+		b.teams = teams
+	}
+}
+
+// WithAudit records every command sent to the bot in the audit log
+func WithAudit(audit BotAuditStore) BotOption {
+	return func(b *Bot) {
+		b.audit = audit
+	}
+}
+
+// WithHistory records the alerts delivered to the chats in the alert history
+func WithHistory(history BotHistoryStore) BotOption {
+	return func(b *Bot) {
+		b.history = history
+	}
+}
+
+// WithFallbackChat sets the chat receiving the alerts that match no chat
+// because of the routing configuration or the chats' filters.
+func WithFallbackChat(id int64) BotOption {
+	return func(b *Bot) {
+		b.fallbackChat = id
+	}
+}
+
+// WithErrorsChat sets the chat the bot posts its own failures to that may hide alerts,
+// like store failures, template errors and failed sends to Telegram.
+func WithErrorsChat(id int64) BotOption {
+	return func(b *Bot) {
+		b.errorsChat = id
+	}
+}
+
+// WithSuppressResolved sets whether chats without an own choice are sent resolved notifications
+func WithSuppressResolved(suppress bool) BotOption {
+	return func(b *Bot) {
+		b.suppressResolved = suppress
+	}
+}
+
+// WithResolvedNotifications sets whether resolved notifications are sent at all. Without them
+// resolved alerts only stop escalating and lose their buttons, whatever the chats chose.
+func WithResolvedNotifications(notify bool) BotOption {
+	return func(b *Bot) {
+		b.noResolved = !notify
+	}
+}
+
+// WithDedupWindow drops deliveries of a group identical to one delivered
+// to the same chat within the window, e.g. when it arrives through multiple receivers.
+func WithDedupWindow(d time.Duration) BotOption {
+	return func(b *Bot) {
+		b.dedup = newDeduplicator(d)
+	}
+}
+
+// WithReceiverTemplates maps the receivers of webhooks to the template used for their alerts.
+// Templates set by the routing configuration take precedence.
+func WithReceiverTemplates(templates map[string]string) BotOption {
+	return func(b *Bot) {
+		b.receiverTemplates = templates
+	}
+}
+
+// WithTracer traces the delivery of webhooks with the tracer
+func WithTracer(t *tracing.Tracer) BotOption {
+	return func(b *Bot) {
+		b.tracer = t
+	}
+}
+
+// WithSentry reports the failures that may hide alerts and panics to Sentry
+func WithSentry(c *sentry.Client) BotOption {
+	return func(b *Bot) {
+		b.sentry = c
+	}
+}
+
+// WithGrafana annotates the dashboards of the Grafana when alerts are acknowledged or resolved
+func WithGrafana(c *grafana.Client) BotOption {
+	return func(b *Bot) {
+		b.grafana = c
+	}
+}
+
+// WithTicketTracker adds a Create ticket button to the alerts creating an issue for the alert in the tracker
+func WithTicketTracker(t ticket.Tracker) BotOption {
+	return func(b *Bot) {
+		b.tracker = t
+	}
+}
+
+// WithPager triggers an incident for alerts whose escalation was exhausted without acknowledgement
+func WithPager(c *pager.Client) BotOption {
+	return func(b *Bot) {
+		b.pager = c
+	}
+}
+
+// WithMessageSender sends, edits and answers the bot's messages with another transport than Telegram
+func WithMessageSender(s MessageSender) BotOption {
+	return func(b *Bot) {
+		b.sender = s
+	}
+}
+
+// WithKarma links the alert groups in the Karma dashboard at the URL instead of the Alertmanager UI
+func WithKarma(u *url.URL) BotOption {
+	return func(b *Bot) {
+		b.karma = u
+	}
+}
+
+// WithStatusPage opens an incident on the status page when an alert of the severities is acknowledged,
+// posts its public link to the chat and resolves it with the alert
+func WithStatusPage(c *statuspage.Client, severities ...string) BotOption {
+	return func(b *Bot) {
+		b.statusPage = c
+		b.statusPageSeverities = make(map[string]bool, len(severities))
+		for _, s := range severities {
+			b.statusPageSeverities[s] = true
+		}
+	}
+}
+
+// WithOutbox records every message in the store before it is sent and retries those Telegram didn't accept
+// with backoff, also after a restart, until they are older than the maxAge. 0 retries them forever.
+func WithOutbox(s BotOutboxStore, maxAge time.Duration) BotOption {
+	return func(b *Bot) {
+		b.outboxStore = s
+		b.outboxMaxAge = maxAge
+	}
+}
+
+// WithMaxOpenAlerts limits the alerts the bot keeps to resolve, update and escalate them,
+// evicting the oldest above the limit. 0 keeps all of them until they are resolved.
+func WithMaxOpenAlerts(n int) BotOption {
+	return func(b *Bot) {
+		b.alerts.max = n
+	}
+}
+
+// WithAlertStore stores the alerts sent to the chats, so that the escalations still open are resumed after a restart.
+func WithAlertStore(s BotAlertStore) BotOption {
+	return func(b *Bot) {
+		b.alertStore = s
+	}
+}
+
+// WithCallbackStore stores the payloads of the buttons of alerts, so that they work on every replica and after a restart.
+func WithCallbackStore(s BotCallbackStore) BotOption {
+	return func(b *Bot) {
+		b.callbackStore = s
+	}
+}
+
+// WithDeliveryWorkers sets how many chats the alerts of a webhook are delivered to at once.
+func WithDeliveryWorkers(n int) BotOption {
+	return func(b *Bot) {
+		b.deliveryWorkers = n
+	}
+}
+
+// WithSendRate limits the messages sent and edited by the bot to the number per second,
+// for all chats together to stay below the limits of Telegram.
+func WithSendRate(perSecond float64) BotOption {
+	return func(b *Bot) {
+		b.sendRate = perSecond
+	}
+}
+
+// WithChatSendRate limits the messages sent and edited in each chat to the number per minute,
+// bursts to a chat are delayed without holding up the messages to other chats.
+func WithChatSendRate(perMinute float64) BotOption {
+	return func(b *Bot) {
+		b.chatSendRate = perMinute
+	}
+}
+
+// WithRunbook lets admins and members request the actions of the runbook with /run,
+// which run once another admin approved them
+func WithRunbook(c *runbook.Config) BotOption {
+	return func(b *Bot) {
+		b.runbook = c
+	}
+}
+
+// WithOutboundWebhooks sends the events of the types, by default when alerts are acknowledged, forwarded,
+// auto-forwarded or resolved, to the webhooks of the client
+func WithOutboundWebhooks(c *outbound.Client, types ...string) BotOption {
+	return func(b *Bot) {
+		if len(types) == 0 {
+			types = outboundEventTypes
+		}
+		b.outbound = c
+		b.outboundTypes = make(map[string]bool, len(types))
+		for _, t := range types {
+			b.outboundTypes[t] = true
+		}
+	}
+}
+
+// WithDeployments appends the latest deployment within the window of the repository of an alert's service,
+// found by the label's value in the repositories, to its message, e.g. "Deployed 12m ago by @dev".
+// Only deployments to the environment are considered, any if empty.
+func WithDeployments(source deploy.Source, label string, repositories map[string]string, environment string, window time.Duration) BotOption {
+	return func(b *Bot) {
+		b.deploys = source
+		b.deployLabel = label
+		b.deployRepositories = repositories
+		b.deployEnvironment = environment
+		b.deployWindow = window
+	}
+}
+
+// WithRemediations adds a Remediate button to the alerts with an action, which runs it once an admin confirmed
+func WithRemediations(c *remediate.Config) BotOption {
+	return func(b *Bot) {
+		b.remediations = c
+	}
+}
+
+// WithMirror sends copies of the alert and escalation messages to a secondary platform like Matrix or Slack
+func WithMirror(c *mirror.Client) BotOption {
+	return func(b *Bot) {
+		b.mirror = c
+	}
+}
+
+// WithLoki appends the last lines logged within the window by the streams of firing alerts to their messages.
+// The streams are matched by the alerts' labels mapped to the labels of Loki, e.g. instance to host.
+func WithLoki(c *loki.Client, labels map[string]string, lines int, window time.Duration) BotOption {
+	return func(b *Bot) {
+		b.loki = c
+		b.lokiLabels = labels
+		b.lokiLines = lines
+		b.lokiWindow = window
+	}
+}
+
+// WithAlertImages sends the images and Grafana panels referenced in the annotations of firing alerts as album
+// in reply to their message. Images larger than maxSize bytes or not fetched within the timeout are left out,
+// panels are only rendered WithGrafana.
+func WithAlertImages(maxSize int64, timeout time.Duration) BotOption {
+	return func(b *Bot) {
+		b.imagesMaxSize = maxSize
+		b.imagesTimeout = timeout
+	}
+}
+
+// WithOnCallCalendar reads who is on call from the iCalendar every interval. Alerts are assigned to the members
+// of the level attending a current event of the calendar, found by the usernames of the attendees' emails.
+func WithOnCallCalendar(calendarURL string, interval time.Duration, usernames map[string]string) BotOption {
+	return func(b *Bot) {
+		b.onCall = newOnCallSchedule(usernames)
+		b.onCallURL = calendarURL
+		b.onCallInterval = interval
+	}
+}
+
+// WithCooldown sets the duration in which an alert firing again only
+// updates its existing message instead of notifying and escalating again.
+func WithCooldown(d time.Duration) BotOption {
+	return func(b *Bot) {
+		b.cooldown = d
+	}
+}
+
+// WithWatchdog expects the alert with the alertname to keep firing at least once within the timeout.
+// The chats, or the admins if there are none, are warned once it stops arriving.
+func WithWatchdog(alertname string, timeout time.Duration, chats ...int64) BotOption {
+	return func(b *Bot) {
+		b.watchdog = newWatchdog(alertname, timeout, chats, time.Now())
+	}
+}
+
+// SendAdminMessage to the admin's ID with a message
+func (b *Bot) SendAdminMessage(adminID int, message string) {
+	b.sender.SendMessage(telebot.User{ID: adminID}, message, nil)
+}
+
+// isAdminID returns whether id is one of the configured admin IDs.
+func (b *Bot) isAdminID(id int) bool {
+	admins := b.currentAdmins()
+	i := sort.SearchInts(admins, id)
+	return i < len(admins) && admins[i] == id
+}
+
+// isAdminChat returns whether id is one of the configured admin chat IDs.
+func (b *Bot) isAdminChat(id int64) bool {
+	for _, chat := range b.adminChats {
+		if chat == id {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowedChat returns whether the bot may be used in the chat.
+func (b *Bot) isAllowedChat(chat telebot.Chat) bool {
+	if len(b.allowedChats) == 0 || b.isAdminChat(chat.ID) {
+		return true
+	}
+	if chat.Type == telebot.ChatPrivate && b.isAdminID(int(chat.ID)) {
+		return true
+	}
+	for _, id := range b.allowedChats {
+		if id == chat.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// isAdmin returns whether the message was sent by an admin or in an admin chat.
+func (b *Bot) isAdmin(message telebot.Message) bool {
+	return b.isAdminID(message.Sender.ID) || b.isAdminChat(message.Chat.ID) || b.isDynamicAdmin(message.Sender)
+}
+
+// permission returns whether the sender of the message may use a command.
+// The administrators of a group are only looked up once, when needed.
+func (b *Bot) permission(message telebot.Message) func(command string) bool {
+	admin := b.isAdmin(message)
+	var chatAdmin *bool
+	return func(command string) bool {
+		if admin || selfServiceCommands[command] || (b.allowReadOnly && readOnlyCommands[command]) {
+			return true
+		}
+		if !chatAdminCommands[command] {
+			return false
+		}
+		if chatAdmin == nil {
+			isChatAdmin := b.isChatAdmin(message)
+			chatAdmin = &isChatAdmin
+		}
+		return *chatAdmin
+	}
+}
+
+// Run the telegram and listen to messages send to the telegram
+func (b *Bot) Run(ctx context.Context, webhooks <-chan alertmanager.Webhook) error {
+	commandSuffix := fmt.Sprintf("@%s", b.telegram.Identity.Username)
+
+	commands := make(map[string]func(message telebot.Message))
+	for _, c := range b.registeredCommands() {
+		commands[c.name] = c.handler
+	}
+
+	// init counters with 0
+	for command := range commands {
+		b.commandsCounter.WithLabelValues(command).Add(0)
+	}
+
+	process := func(message telebot.Message) error {
+		// The duration is observed by the command, or why it wasn't executed
+		var label string
+		start := time.Now()
+		defer func() {
+			if label != "" {
+				b.commandDuration.WithLabelValues(label).Observe(time.Since(start).Seconds())
+			}
+		}()
+
+		if !b.isAllowedChat(message.Chat) {
+			label = "refused"
+			b.commandsCounter.WithLabelValues(label).Inc()
+			if message.Chat.Type == telebot.ChatGroup || message.Chat.Type == telebot.ChatSuperGroup {
+				if err := b.telegram.LeaveChat(message.Chat); err != nil {
+					return fmt.Errorf("failed to leave chat that isn't allowed: %v", err)
+				}
+				return fmt.Errorf("left chat that isn't allowed")
+			}
+			if !message.IsService() {
+				b.reply(message, "Sorry, this chat isn't allowed to use me.", nil)
+			}
+			return fmt.Errorf("refused message from chat that isn't allowed")
+		}
+
+		if message.IsService() {
+			return nil
+		}
+
+		// Files are sent with the command as caption
+		command := message.Text
+		if command == "" {
+			command = message.Caption
+		}
+
+		// Remove the command suffix from the text, /help@BotName => /help
+		text := strings.Replace(command, commandSuffix, "", -1)
+		// Only take the first part into account, /help foo => /help
+		text = strings.Split(text, " ")[0]
+
+		// Banned users are ignored, even if they are admins of an admin chat.
+		// The admins of --telegram.admin can't be banned.
+		if b.isBanned(message.Sender) {
+			label = "banned"
+			b.commandsCounter.WithLabelValues(label).Inc()
+			return fmt.Errorf("dropped message from banned sender")
+		}
+
+		permitted := b.permission(message)
+		if !permitted(text) {
+			b.notifyForbidden(message, command)
+			label = "dropped"
+			b.commandsCounter.WithLabelValues(label).Inc()
+			return fmt.Errorf("dropped message from forbidden sender")
+		}
+
+		if err := b.telegram.SendChatAction(message.Chat, telebot.Typing); err != nil {
+			return err
+		}
+
+		level.Debug(b.logger).Log("msg", "message received", "chat_id", message.Chat.ID, "text", text)
+
+		// Get the corresponding handler from the map by the commands text
+		handler, ok := commands[text]
+
+		if !ok {
+			b.auditCommand(message, text, command, auditUnknown)
+			label = "incomprehensible"
+			b.commandsCounter.WithLabelValues(label).Inc()
+			level.Info(b.logger).Log("msg", "unknown command", "text", truncate(maxAuditText, text), "count", b.unknownCommands.Inc(text))
+
+			response := "Sorry, I don't understand..."
+			if suggestion, ok := suggestCommand(text, b.registeredCommands(), permitted); ok {
+				response = fmt.Sprintf("Sorry, I don't understand... Did you mean %s?", suggestion)
+			}
+			b.reply(message, response, nil)
+			return nil
+		}
+
+		label = text
+		b.commandsCounter.WithLabelValues(label).Inc()
+		b.auditCommand(message, text, command, auditExecuted)
+
+		defer b.recoverPanic("command", text, "chat_id", message.Chat.ID)
+		handler(message)
+
+		return nil
+	}
+
+	messages := make(chan telebot.Message, 100)
+	callbacks := make(chan telebot.Callback, 500)
+	b.stats.setQueues(
+		queueDepth{name: "webhooks", length: func() int { return len(webhooks) }, capacity: cap(webhooks)},
+		queueDepth{name: "messages", length: func() int { return len(messages) }, capacity: cap(messages)},
+		queueDepth{name: "callbacks", length: func() int { return len(callbacks) }, capacity: cap(callbacks)},
+	)
+
+	// The actors stop once the context is canceled, the first of them returning stops the others
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// A restart mustn't silently stop the escalations of the alerts still firing
+	if b.alertStore != nil {
+		b.recoverAlerts()
+	}
+
+	var gr run.Group
+	{
+		gr.Add(func() error {
+			if b.pollStart != nil {
+				select {
+				case <-b.pollStart:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			return b.poll(ctx, messages, callbacks)
+		}, func(err error) {
+			cancel()
+		})
+	}
+	{
+		gr.Add(func() error {
+			return b.sendWebhook(ctx, webhooks)
+		}, func(err error) {
+			cancel()
+		})
+	}
+	if b.shard != nil {
+		gr.Add(func() error {
+			return b.takeHandovers(ctx)
+		}, func(err error) {
+			cancel()
+		})
+	}
+	if b.outbox != nil {
+		gr.Add(func() error {
+			return b.outbox.Run(ctx)
+		}, func(err error) {
+			cancel()
+		})
+	}
+	{
+		gr.Add(func() error {
+			return b.pruneAlerts(ctx)
+		}, func(err error) {
+			cancel()
+		})
+	}
+	{
+		gr.Add(func() error {
+			return b.escalations.Run(ctx, b.autoForward)
+		}, func(err error) {
+			cancel()
+		})
+	}
+	{
+		gr.Add(func() error {
+			return b.flushHeldAlerts(ctx)
+		}, func(err error) {
+			cancel()
+		})
+	}
+	{
+		gr.Add(func() error {
+			return b.flushDigests(ctx)
+		}, func(err error) {
+			cancel()
+		})
+	}
+	if b.history != nil {
+		gr.Add(func() error {
+			return b.recordHistory(ctx)
+		}, func(err error) {
+			cancel()
+		})
+	}
+	if b.statusPage != nil {
+		gr.Add(func() error {
+			return b.updateStatusPage(ctx)
+		}, func(err error) {
+			cancel()
+		})
+	}
+	if b.onCall != nil {
+		gr.Add(func() error {
+			return b.syncOnCall(ctx)
+		}, func(err error) {
+			cancel()
+		})
+	}
+	if b.watchdog != nil {
+		gr.Add(func() error {
+			return b.runWatchdog(ctx)
+		}, func(err error) {
+			cancel()
+		})
+	}
+	{
+		gr.Add(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case message := <-messages:
+					if err := process(message); err != nil {
+						level.Info(b.logger).Log(
+							"msg", "failed to process message",
+							"err", err,
+							"sender_id", message.Sender.ID,
+							"sender_username", message.Sender.Username,
+						)
+					}
+				case callback := <-callbacks:
+					if !b.handOver(callback) {
+						b.handleCallback(callback)
+					}
+				}
+
+			}
+		}, func(err error) {
+			cancel()
+		})
+	}
+
+	return gr.Run()
+}
+
+// handleCallback handles the pressed buttons of the bot's messages
+func (b *Bot) handleCallback(callback telebot.Callback) {
+	level.Debug(b.logger).Log(
+		"msg", "received callback",
+		"data", callback.Data,
+		"sender_id", callback.Sender.ID,
+		"sender_username", callback.Sender.Username,
+		"message_id", callback.Message.ID,
+	)
+
+	if b.isBanned(callback.Sender) {
+		level.Info(b.logger).Log("msg", "dropped callback from banned sender", "sender_id", callback.Sender.ID)
+		b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{})
+		return
+	}
+
+	cd, err := b.callbacks.resolve(callback.Data, time.Now())
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to resolve callback data", "data", callback.Data, "err", err)
+		b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{Text: "This button expired."})
+		return
+	}
+	b.events.Publish(Event{
+		Type:    eventCallback,
+		ChatID:  callback.Message.Chat.ID,
+		AlertID: cd.AlertID,
+		Detail:  fmt.Sprintf("%s by @%s", cd.Button, callback.Sender.Username),
+	})
+
+	// Handle if member press the "Acknowledge" button
+	if cd.Onboarding != "" {
+		b.handleOnboarding(callback, cd)
+	} else if cd.Button == strPageData {
+		b.handlePageCallback(callback, cd)
+	} else if cd.Button == strSettingData {
+		b.handleSettingCallback(callback, cd)
+	} else if cd.Button == strConfirmData || cd.Button == strCancelData {
+		b.handleConfirmation(callback, cd)
+	} else if cd.Button == strAcknowledgeData {
+		for _, h := range b.alerts.Get(cd.AlertID) {
+			level.Debug(b.logger).Log(
+				"msg", "acknowledging alert",
+				"alert_id", h.ID,
+			)
+			err := h.Acknowledge(b.sender, callback)
+			if err != nil {
+				level.Error(b.logger).Log(
+					"msg", "failed to acknowledge",
+					"err", err,
+				)
+			}
+			b.saveAlert(h)
+		}
+	} else if cd.Button == strTicketData {
+		b.handleTicketCallback(callback, cd, b.alerts.Get(cd.AlertID))
+	} else if cd.Button == strRemediateData {
+		b.handleRemediateCallback(callback, cd, b.alerts.Get(cd.AlertID))
+	} else if cd.Button == strForwardData {
+		// Handle if member press the "Forward" button
+		for _, h := range b.alerts.Get(cd.AlertID) {
+			level.Debug(b.logger).Log(
+				"msg", "forwarding alert",
+				"alert_id", h.ID,
+			)
+			ackData, err := NewCallbackData(strAcknowledgeData, h.Group)
+			if err != nil {
+				break
+			}
+			jsonAckStr, err := json.Marshal(ackData)
+			if err != nil {
+				level.Error(b.logger).Log("msg", "failed to create acknowledge button", "err", err)
+				break
+			}
+			err = h.Forward(b.sender, callback, string(jsonAckStr))
+			if err != nil {
+				level.Error(b.logger).Log(
+					"msg", "failed to forward",
+					"err", err,
+				)
+			}
+			b.saveAlert(h)
+		}
+
+	}
+}
+
+// sendWebhook sends messages received via webhook to all subscribed chats
+func (b *Bot) sendWebhook(ctx context.Context, webhooks <-chan alertmanager.Webhook) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case w := <-webhooks:
+			b.deliverWebhook(w)
+		}
+	}
+}
+
+// deliverWebhook sends the alerts of the webhook to the chats they are routed to
+func (b *Bot) deliverWebhook(w alertmanager.Webhook) {
+	ctx := tracing.ContextWithSpanContext(context.Background(), w.Trace)
+	ctx, span := b.tracer.Start(ctx, "webhook deliver", tracing.KindInternal,
+		tracing.String("receiver", w.Receiver),
+		tracing.String("status", w.Status),
+	)
+	defer span.End()
+	defer b.recoverPanic("receiver", w.Receiver)
+
+	b.stats.Webhook(time.Now())
+	b.events.Publish(Event{
+		Type:   eventWebhook,
+		Detail: fmt.Sprintf("receiver %s, status %s, %d alerts", w.Receiver, w.Status, len(w.Alerts)),
+	})
+
+	// The heartbeat of the watchdog only proves the pipeline is alive and isn't delivered
+	if b.watchdog != nil {
+		alerts, heartbeat := b.watchdog.Filter(w.Alerts)
+		if heartbeat {
+			b.heartbeat(time.Now())
+		}
+		if len(alerts) == 0 {
+			return
+		}
+		w.Alerts = alerts
+	}
 
-// WithAddr sets the internal listening addr of the bot's web server receiving webhooks
-func WithAddr(addr string) BotOption {
-	return func(b *Bot) {
-		b.addr = addr
+	_, storeSpan := b.tracer.Start(ctx, "store list chats", tracing.KindClient)
+	chats, err := b.chats.List()
+	storeSpan.SetError(err)
+	storeSpan.End()
+	if err != nil {
+		b.reportError("failed to get chat list from store", "err", err)
+		span.SetError(err)
+		return
 	}
-}
 
-// WithAlertmanager sets the connection url for the Alertmanager
-func WithAlertmanager(u *url.URL) BotOption {
-	return func(b *Bot) {
-		b.alertmanager = u
+	data := &template.Data{
+		Receiver:          w.Receiver,
+		Status:            w.Status,
+		Alerts:            w.Alerts,
+		GroupLabels:       w.GroupLabels,
+		CommonLabels:      w.CommonLabels,
+		CommonAnnotations: w.CommonAnnotations,
+		ExternalURL:       w.ExternalURL,
 	}
-}
 
-// WithTemplates uses Alertmanager template to render messages for Telegram
-func WithTemplates(t *template.Template) BotOption {
-	return func(b *Bot) {
-		b.templates = t
+	b.setLastWebhook(data)
+
+	// Without a routing configuration every subscribed chat receives every alert
+	targets := broadcast(chats, data.Alerts)
+	if b.router != nil {
+		var teams []Team
+		if b.teams != nil {
+			_, storeSpan := b.tracer.Start(ctx, "store list teams", tracing.KindClient)
+			if teams, err = b.teams.List(); err != nil {
+				b.reportError("failed to list teams from team store", "err", err)
+				storeSpan.SetError(err)
+			}
+			storeSpan.End()
+		}
+		targets = b.router.Route(chats, teams, data.Alerts)
 	}
-}
 
-// WithRevision is setting the Bot's revision for status commands
-func WithRevision(r string) BotOption {
-	return func(b *Bot) {
-		b.revision = r
+	targets = b.filterTargets(ctx, targets)
+
+	// Receivers can have their own layout unless the routing configuration chose one
+	if t, ok := b.receiverTemplates[w.Receiver]; ok {
+		for _, target := range targets {
+			if target.template == defaultTemplate {
+				target.template = t
+			}
+		}
 	}
-}
 
-// WithStartTime is setting the Bot's start time for status commands
-func WithStartTime(st time.Time) BotOption {
-	return func(b *Bot) {
-		b.startTime = st
+	// Alerts matching no chat go to the fallback chat instead of being dropped silently
+	if unrouted := unroutedAlerts(data.Alerts, targets); len(unrouted) > 0 {
+		b.unroutedCounter.Add(float64(len(unrouted)))
+		if b.fallbackChat != 0 {
+			level.Debug(b.logger).Log("msg", "sending unrouted alerts to fallback chat", "alerts", len(unrouted))
+			targets = append(targets, b.fallbackTarget(chats, unrouted))
+		} else {
+			b.reportError("dropping alerts that match no chat", "alerts", len(unrouted))
+		}
 	}
-}
 
-// WithExtraAdmins allows the specified additional user IDs to issue admin
-// commands to the bot.
-func WithExtraAdmins(ids ...int) BotOption {
-	return func(b *Bot) {
-		b.admins = append(b.admins, ids...)
-		sort.Ints(b.admins)
+	// Other replicas deliver the alerts of their chats
+	targets = b.ownTargets(targets)
+
+	// The images of the alerts are only fetched once for all chats
+	var photos *webhookPhotos
+	if w.Status == string(model.AlertFiring) {
+		photos = b.newWebhookPhotos(data.Alerts)
 	}
-}
 
-// SendAdminMessage to the admin's ID with a message
-func (b *Bot) SendAdminMessage(adminID int, message string) {
-	b.telegram.SendMessage(telebot.User{ID: adminID}, message, nil)
+	fanOut(targets, b.deliveryWorkers, func(target *routedAlerts) {
+		b.deliverTarget(ctx, w, data, target, photos)
+	})
 }
 
-// isAdminID returns whether id is one of the configured admin IDs.
-func (b *Bot) isAdminID(id int) bool {
-	i := sort.SearchInts(b.admins, id)
-	return i < len(b.admins) && b.admins[i] == id
-}
+// deliverTarget sends, updates or resolves the messages of the routed alerts in their chat.
+// New messages get the photos of their alerts, if not nil.
+func (b *Bot) deliverTarget(ctx context.Context, w alertmanager.Webhook, data *template.Data, target *routedAlerts, photos *webhookPhotos) {
+	if b.deliveryLatency != nil {
+		defer prometheus.NewTimer(b.deliveryLatency.WithLabelValues(w.Status)).ObserveDuration()
+	}
+	defer b.recoverPanic("receiver", w.Receiver, "chat_id", target.chat.ID)
 
-// Run the telegram and listen to messages send to the telegram
-func (b *Bot) Run(ctx context.Context, webhooks <-chan notify.WebhookMessage) error {
-	commandSuffix := fmt.Sprintf("@%s", b.telegram.Identity.Username)
+	chat := target.chat
+	settings := target.settings
 
-	commands := map[string]func(message telebot.Message){
-		commandStart:        b.handleStart,
-		commandStop:         b.handleStop,
-		commandHelp:         b.handleHelp,
-		commandChats:        b.handleChats,
-		commandStatus:       b.handleStatus,
-		commandAlerts:       b.handleAlerts,
-		commandSilences:     b.handleSilences,
-		commandAddMember:    b.handleAddMember,
-		commandRemoveMember: b.handleRemoveMember,
-		commandMembers:      b.handleMembers,
-		commandNodes:        b.handleNodes,
+	// The same group can arrive through multiple receivers of the Alertmanager
+	if b.dedup.Duplicate(chat.ID, groupFingerprint(w.Status, target.alerts), time.Now()) {
+		level.Debug(b.logger).Log("msg", "dropping duplicate alerts", "chat_id", chat.ID, "receiver", w.Receiver)
+		return
 	}
 
-	// init counters with 0
-	for command := range commands {
-		b.commandsCounter.WithLabelValues(command).Add(0)
+	// The alertname is shared by many groups, the buttons and webhooks find the message by its group
+	group := groupID(w.Receiver, data.GroupLabels)
+	chatData := *data
+	chatData.Alerts = target.alerts
+
+	// Tracked alerts resolve even if the chat muted, digests or holds back their alertname,
+	// those filters only keep firing alerts from being sent
+	if w.Status == string(model.AlertResolved) {
+		// Handler resolved signal via webhook, chats without resolved notifications only get the buttons removed
+		tracked := b.alerts.InChat(group, chat)
+		if len(tracked) == 0 {
+			return
+		}
+		chatData.Alerts = sortAlerts(chatData.Alerts)
+		out, mode := b.renderTarget(ctx, target, &chatData)
+		b.resolveAlerts(ctx, chat, tracked, b.notifyResolved(settings), out, mode)
+		return
+	}
+	if w.Status != string(model.AlertFiring) {
+		return
 	}
 
-	process := func(message telebot.Message) error {
-		if message.IsService() {
-			return nil
+	// The messages of the group whose alerts all resolved meanwhile get their buttons removed,
+	// the firing message shows them as resolved
+	resolved := make(map[model.Fingerprint]bool)
+	for _, a := range target.alerts {
+		if a.Status == string(model.AlertResolved) {
+			resolved[alertLabelSet(a).Fingerprint()] = true
 		}
-
-		if !b.isAdminID(message.Sender.ID) {
-			b.commandsCounter.WithLabelValues("dropped").Inc()
-			return fmt.Errorf("dropped message from forbidden sender")
+	}
+	var done []*HandleAlert
+	for _, h := range b.alerts.InChat(group, chat) {
+		if atomic.LoadInt32(&h.resolved) == 0 && h.resolvedIn(resolved) {
+			done = append(done, h)
 		}
+	}
+	b.resolveAlerts(ctx, chat, done, false, "", telebot.ModeDefault)
 
-		if err := b.telegram.SendChatAction(message.Chat, telebot.Typing); err != nil {
-			return err
-		}
+	chatData.Alerts = filterMuted(settings.Mutes, chatData.Alerts, time.Now())
+	if len(chatData.Alerts.Firing()) == 0 {
+		return
+	}
 
-		// Remove the command suffix from the text, /help@BotName => /help
-		text := strings.Replace(message.Text, commandSuffix, "", -1)
-		// Only take the first part into account, /help foo => /help
-		text = strings.Split(text, " ")[0]
+	// Collect digest-only alerts for the next digest
+	deliver, digest := splitDigest(settings.Digest, chatData.Alerts)
+	if len(digest) > 0 {
+		b.digests.Add(chat, digest)
+	}
+	chatData.Alerts = deliver
+	if len(chatData.Alerts.Firing()) == 0 {
+		return
+	}
 
-		level.Debug(b.logger).Log("msg", "message received", "text", text)
+	// Hold back alerts during quiet hours and maintenance windows
+	if settings.Quiet(time.Now()) {
+		deliver, held := splitQuietOverrides(b.quietOverrides, chatData.Alerts)
+		if settings.QuietDigest && len(held) > 0 {
+			b.held.Add(chat, held)
+		}
+		chatData.Alerts = deliver
+		if len(chatData.Alerts.Firing()) == 0 {
+			return
+		}
+	}
 
-		// Get the corresponding handler from the map by the commands text
-		handler, ok := commands[text]
+	// Show the worst problem first
+	chatData.Alerts = sortAlerts(chatData.Alerts)
+	out, mode := b.renderTarget(ctx, target, &chatData)
 
-		if !ok {
-			b.commandsCounter.WithLabelValues("incomprehensible").Inc()
-			b.telegram.SendMessage(
-				message.Chat,
-				"Sorry, I don't understand...",
-				nil,
-			)
-			return nil
-		}
+	id := chatData.Alerts[0].Labels["alertname"]
+	if id == "" {
+		b.reportError("dropping alerts without alertname", "chat_id", chat.ID)
+		return
+	}
 
-		b.commandsCounter.WithLabelValues(text).Inc()
-		handler(message)
+	// Flapping alerts firing again within the cooldown only update their message
+	fingerprints := alertFingerprints(chatData.Alerts)
+	if h := b.alerts.Recent(group, chat, alertLabelSet(chatData.Alerts[0]).Fingerprint(), b.cooldown); h != nil {
+		var next time.Time
+		err := b.traceTelegram(ctx, "refire", chat, func() (err error) {
+			next, err = h.Refire(b.sender, fingerprints, out, mode)
+			return err
+		})
+		if err != nil {
+			b.reportError("failed to update message of alert firing again", "chat_id", chat.ID, "alertname", id, "err", err)
+		}
+		// The escalation of an alert resolved meanwhile starts over
+		if !next.IsZero() {
+			b.escalations.Schedule(h, next)
+		}
+		b.saveAlert(h)
+		return
+	}
 
-		return nil
+	// If receive the firing signal via webhook, create the inline message with 2 buttons,
+	// And create new HandleAlert object and register it
+	out += b.alertDeployment(chatData.Alerts[0], mode)
+	out += b.alertLogs(chatData.Alerts[0], mode, maxMessageLength-len(out))
+	var alert *HandleAlert
+	err := b.traceTelegram(ctx, "send", chat, func() (err error) {
+		alert, err = NewAlert(id, group, chat, chatData.Alerts, b, out, mode, target.timeout, b.groupLink(&chatData))
+		return err
+	})
+	if err != nil {
+		b.reportError("failed to send alert", "chat_id", chat.ID, "alertname", id, "err", err)
+		return
 	}
 
-	messages := make(chan telebot.Message, 100)
-	queries := make(chan telebot.Query, 500)
-	callbacks := make(chan telebot.Callback, 500)
-	b.telegram.Messages = messages
-	b.telegram.Queries = queries
-	b.telegram.Callbacks = callbacks
-	// b.telegram.Listen(messages, time.Second)
-	go b.telegram.Start(1 * time.Second)
-	alertchan := make(chan *HandleAlert, 100)
+	// Save it to process whenever receive resolved signal or a button is pressed
+	for _, evicted := range b.alerts.Add(alert) {
+		settled := evicted.settled()
+		evicted.stopEscalation()
+		b.forgetAlert(evicted)
+		if settled {
+			level.Warn(b.logger).Log("msg", "evicted oldest settled alert above the limit of tracked alerts", "chat_id", evicted.Chat.ID, "alertname", evicted.ID)
+		} else {
+			b.reportError("evicted unacknowledged alert above the limit of tracked alerts, it isn't escalated anymore", "chat_id", evicted.Chat.ID, "alertname", evicted.ID)
+		}
+	}
+	b.saveAlert(alert)
 
-	var gr run.Group
-	{
-		gr.Add(func() error {
-			return b.sendWebhook(ctx, webhooks, alertchan)
-		}, func(err error) {
-		})
+	// Fetching and uploading the images mustn't hold up the delivery to other chats
+	if photos != nil {
+		go func(alerts template.Alerts) {
+			b.sendAlertImages(ctx, alert, photos.get(ctx, alerts))
+		}(chatData.Alerts)
 	}
-	{
-		gr.Add(func() error {
-			// var HandleAlerts []HandleAlert
-			HandleAlerts := make(map[string][]*HandleAlert)
-			for {
-				select {
-				case <-ctx.Done():
-					return nil
-				case message := <-messages:
-					if err := process(message); err != nil {
-						level.Info(b.logger).Log(
-							"msg", "failed to process message",
-							"err", err,
-							"sender_id", message.Sender.ID,
-							"sender_username", message.Sender.Username,
-						)
-					}
-				case callback := <-callbacks:
-					level.Debug(b.logger).Log(
-						"msg", "received callback",
-						"data", callback.Data,
-						"sender_id", callback.Sender.ID,
-						"sender_username", callback.Sender.Username,
-						"message_id", callback.Message.ID,
-					)
-
-					var cd CallbackData
-					dec := json.NewDecoder(strings.NewReader(callback.Data))
-					dec.Decode(&cd)
-					if err := dec.Decode(&cd); err == io.EOF {
-						// TODO: Handle this case
-					} else if err != nil {
-						level.Error(b.logger).Log("err", err)
-					}
+}
 
-					// Handle if member press the "Acknowledge" button
-					if cd.Button == strAcknowledgeData {
-						for _, h := range HandleAlerts[cd.AlertID] {
-							level.Debug(b.logger).Log(
-								"msg", "run Acknowledge at",
-								"data", h.ID,
-							)
-							// h.MessageID = callback.Message.ID
-							err := h.Acknowledge(b.telegram, callback)
-							if err != nil {
-								level.Error(b.logger).Log(
-									"msg", "failed to acknowledge",
-									"err", err,
-								)
-							}
-						}
-					} else if cd.Button == strForwardData {
-						// Handle if member press the "Forward" button
-						for _, h := range HandleAlerts[cd.AlertID] {
-							level.Debug(b.logger).Log(
-								"msg", "run Forward at",
-								"data", h.ID,
-							)
-							// h.MessageID = callback.Message.ID
-							ackData, err := NewCallbackData(strAcknowledgeData, h.ID)
-							if err != nil {
-								break
-							}
-							jsonAckStr, err := json.Marshal(ackData)
-							err = h.Forward(b.telegram, callback, string(jsonAckStr))
-							if err != nil {
-								level.Error(b.logger).Log(
-									"msg", "failed to forward",
-									"err", err,
-								)
-							}
-						}
+// renderTarget renders the alerts with the template and settings of the target's chat
+func (b *Bot) renderTarget(ctx context.Context, target *routedAlerts, data *template.Data) (string, telebot.ParseMode) {
+	_, span := b.tracer.Start(ctx, "template execute", tracing.KindInternal,
+		tracing.String("template", target.template),
+		tracing.Int("chat_id", target.chat.ID),
+	)
+	defer span.End()
 
-					}
-				case a := <-alertchan:
-					// Get the HandleAlert in channel and save
-					// HandleAlerts = append(HandleAlerts, a)
-					if HandleAlerts[a.ID] == nil {
-						HandleAlerts[a.ID] = append(HandleAlerts[a.ID], a)
-						level.Debug(b.logger).Log(
-							"msg", "received alert",
-							"data", a.ID,
-						)
-					}
-				}
+	out, mode := b.renderAlertsOrFallback(target.chat, target.settings, target.template, data)
+	return alertsHeader(data.Alerts, mode) + out, mode
+}
 
+// resolveAlerts resolves the messages of the chat, without notify they only get their buttons removed
+func (b *Bot) resolveAlerts(ctx context.Context, chat telebot.Chat, alerts []*HandleAlert, notify bool, out string, mode telebot.ParseMode) {
+	for _, h := range alerts {
+		err := b.traceTelegram(ctx, "resolve", chat, func() error {
+			if notify {
+				return h.Resolved(b.sender, out, mode)
 			}
-		}, func(err error) {
+			return h.Clear(b.sender)
 		})
+		if err != nil {
+			b.reportError("failed to resolve alert", "chat_id", chat.ID, "alertname", h.ID, "err", err)
+		}
+		b.saveAlert(h)
 	}
-
-	return gr.Run()
 }
 
-// sendWebhook sends messages received via webhook to all subscribed chats
-func (b *Bot) sendWebhook(ctx context.Context, webhooks <-chan notify.WebhookMessage, alerts chan<- *HandleAlert) error {
-	HandleAlerts := make(map[string][]*HandleAlert)
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case w := <-webhooks:
-			chats, err := b.chats.List()
-			if err != nil {
-				level.Error(b.logger).Log("msg", "failed to get chat list from store", "err", err)
-				continue
-			}
+// autoForward forwards the alert to the next level if it is due and returns when it is due next
+func (b *Bot) autoForward(a *HandleAlert, now time.Time) time.Time {
+	next, err := a.AutoForward(b.sender, now)
+	if err != nil {
+		b.reportError("failed to auto forward alert", "chat_id", a.Chat.ID, "alertname", a.ID, "err", err)
+	}
+	b.saveAlert(a)
+	return next
+}
 
-			data := &template.Data{
-				Receiver:          w.Receiver,
-				Status:            w.Status,
-				Alerts:            w.Alerts,
-				GroupLabels:       w.GroupLabels,
-				CommonLabels:      w.CommonLabels,
-				CommonAnnotations: w.CommonAnnotations,
-				ExternalURL:       w.ExternalURL,
-			}
+// traceTelegram runs the Telegram API calls in a span
+func (b *Bot) traceTelegram(ctx context.Context, name string, chat telebot.Chat, call func() error) error {
+	_, span := b.tracer.Start(ctx, "telegram "+name, tracing.KindClient, tracing.Int("chat_id", chat.ID))
+	defer span.End()
 
-			out, err := b.templates.ExecuteHTMLString(`{{ template "telegram.default" . }}`, data)
-			if err != nil {
-				level.Warn(b.logger).Log("msg", "failed to template alerts", "err", err)
-				continue
-			}
+	err := call()
+	span.SetError(err)
+	b.stats.TelegramSend(err)
+	return err
+}
 
-			id := data.Alerts[0].Labels["alertname"]
-			if id == "" {
-				level.Warn(b.logger).Log("msg", "missing alertname")
-				continue
-			}
+// filterTargets loads the settings of the targets' chats and only keeps
+// the alerts matching the chat's filters and subscribed nodes
+func (b *Bot) filterTargets(ctx context.Context, targets []*routedAlerts) []*routedAlerts {
+	filtered := make([]*routedAlerts, 0, len(targets))
+	for _, target := range targets {
+		_, span := b.tracer.Start(ctx, "store get settings", tracing.KindClient, tracing.Int("chat_id", target.chat.ID))
+		settings, err := b.settings.Get(target.chat)
+		span.SetError(err)
+		span.End()
+		if err != nil {
+			b.reportError("failed to get chat settings from store", "chat_id", target.chat.ID, "err", err)
+			continue
+		}
 
-			// id += string(time.Stamp)
-			for _, chat := range chats {
-				// If receive the resolved signal via webhook, Resolve() all of HandlerAlert in the map list
-				if w.Status == string(model.AlertResolved) {
-					// Handler resolved signal via webhook
-					if len(HandleAlerts[id]) > 0 {
-						for _, h := range HandleAlerts[id] {
-							h.Resolved(b.telegram, out)
-						}
-					}
-				} else if w.Status == string(model.AlertFiring) {
-					// If receive the firing signal via webhook, create the inline message with 2 buttons,
-
-					// And create new HandleAlert object and put it to channel
-					alert, err := NewAlert(id, chat, data.Alerts[0], b, out)
-					if err != nil {
-						level.Error(b.logger).Log("msg", "failed to create new handle alert", "err", err)
-						break
-					}
-					alerts <- alert
+		target.settings = settings
+		target.alerts = filterAlerts(settings.Matchers, target.alerts)
+		target.alerts = filterNodes(settings.Nodes, target.alerts)
+		if len(target.alerts) > 0 {
+			filtered = append(filtered, target)
+		}
+	}
+	return filtered
+}
 
-					// Save it to process whenever receive resolved signal
-					HandleAlerts[alert.ID] = append(HandleAlerts[alert.ID], alert)
-				}
-			}
+// fallbackTarget delivers alerts to the fallback chat, ignoring its filters
+func (b *Bot) fallbackTarget(chats []telebot.Chat, alerts template.Alerts) *routedAlerts {
+	chat := telebot.Chat{ID: b.fallbackChat}
+	for _, c := range chats {
+		if c.ID == b.fallbackChat {
+			chat = c
 		}
+	}
+
+	settings, err := b.settings.Get(chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "chat_id", chat.ID, "err", err)
+	}
 
+	return &routedAlerts{
+		chat:     chat,
+		alerts:   alerts,
+		template: defaultTemplate,
+		timeout:  AutoForwardTimeout,
+		settings: settings,
 	}
 }
 
 func (b *Bot) handleStart(message telebot.Message) {
 	if err := b.chats.Add(message.Chat); err != nil {
 		level.Warn(b.logger).Log("msg", "failed to add chat to chat store", "err", err)
-		b.telegram.SendMessage(message.Chat, "I can't add this chat to the subscribers list.", nil)
+		b.reply(message, "I can't add this chat to the subscribers list.", nil)
 		return
 	}
 
-	b.telegram.SendMessage(message.Chat, fmt.Sprintf(responseStart, message.Sender.FirstName), nil)
+	b.reply(message, fmt.Sprintf(responseStart, message.Sender.FirstName), nil)
 	level.Info(b.logger).Log(
 		"msg", "user subscribed",
-		"username", message.Sender.Username,
-		"user_id", message.Sender.ID,
+		"chat_id", message.Chat.ID,
+		"sender_id", message.Sender.ID,
+		"sender_username", message.Sender.Username,
 	)
 }
 
 func (b *Bot) handleStop(message telebot.Message) {
+	b.confirm(message, "Do you really want to unsubscribe this chat from alerts?", func() {
+		b.stop(message)
+	})
+}
+
+func (b *Bot) stop(message telebot.Message) {
 	if err := b.chats.Remove(message.Chat); err != nil {
 		level.Warn(b.logger).Log("msg", "failed to remove chat from chat store", "err", err)
-		b.telegram.SendMessage(message.Chat, "I can't remove this chat from the subscribers list.", nil)
+		b.reply(message, "I can't remove this chat from the subscribers list.", nil)
 		return
 	}
 
-	b.telegram.SendMessage(message.Chat, fmt.Sprintf(responseStop, message.Sender.FirstName), nil)
+	b.reply(message, fmt.Sprintf(responseStop, message.Sender.FirstName), nil)
 	level.Info(b.logger).Log(
 		"msg", "user unsubscribed",
-		"username", message.Sender.Username,
-		"user_id", message.Sender.ID,
+		"chat_id", message.Chat.ID,
+		"sender_id", message.Sender.ID,
+		"sender_username", message.Sender.Username,
 	)
 }
 
 func (b *Bot) handleHelp(message telebot.Message) {
-	b.telegram.SendMessage(message.Chat, responseHelp, nil)
+	b.reply(message, helpMessage(b.registeredCommands(), b.permission(message)), nil)
+}
+
+// helpMessage lists the commands the sender is permitted to use
+func helpMessage(commands []botCommand, permitted func(command string) bool) string {
+	var b strings.Builder
+	b.WriteString(responseHelp)
+	for _, c := range commands {
+		if permitted(c.name) {
+			fmt.Fprintf(&b, "%s - %s\n", c.name, c.description)
+		}
+	}
+	return b.String()
 }
 
 func (b *Bot) handleChats(message telebot.Message) {
 	chats, err := b.chats.List()
 	if err != nil {
 		level.Warn(b.logger).Log("msg", "failed to list chats from chat store", "err", err)
-		b.telegram.SendMessage(message.Chat, "I can't list the subscribed chats.", nil)
+		b.reply(message, "I can't list the subscribed chats.", nil)
 		return
 	}
 
-	list := ""
+	var list []string
 	for _, chat := range chats {
 		if chat.IsGroupChat() {
-			list = list + fmt.Sprintf("@%s\n", chat.Title)
+			list = append(list, fmt.Sprintf("@%s", chat.Title))
 		} else {
-			list = list + fmt.Sprintf("@%s\n", chat.Username)
+			list = append(list, fmt.Sprintf("@%s", chat.Username))
 		}
 	}
 
-	b.telegram.SendMessage(message.Chat, "Currently these chat have subscribed:\n"+list, nil)
+	b.sendPages(message, paginate("Currently these chat have subscribed:\n", list, itemsPerPage), "")
 }
 
 func (b *Bot) handleStatus(message telebot.Message) {
 	s, err := alertmanager.Status(b.logger, b.alertmanager.String())
 	if err != nil {
 		level.Warn(b.logger).Log("msg", "failed to get status", "err", err)
-		b.telegram.SendMessage(message.Chat, fmt.Sprintf("failed to get status... %v", err), nil)
+		b.reply(message, fmt.Sprintf("failed to get status... %v", err), nil)
 		return
 	}
 
 	uptime := durafmt.Parse(time.Since(s.Data.Uptime))
 	uptimeBot := durafmt.Parse(time.Since(b.startTime))
 
-	b.telegram.SendMessage(
-		message.Chat,
+	b.reply(
+		message,
 		fmt.Sprintf(
 			"*AlertManager*\nVersion: %s\nUptime: %s\n*AlertManager Bot*\nVersion: %s\nUptime: %s",
 			s.Data.VersionInfo.Version,
@@ -528,159 +1774,245 @@ func (b *Bot) handleStatus(message telebot.Message) {
 func (b *Bot) handleAlerts(message telebot.Message) {
 	alerts, err := alertmanager.ListAlerts(b.logger, b.alertmanager.String())
 	if err != nil {
-		b.telegram.SendMessage(message.Chat, fmt.Sprintf("failed to list alerts... %v", err), nil)
+		b.reply(message, fmt.Sprintf("failed to list alerts... %v", err), nil)
 		return
 	}
 
 	if len(alerts) == 0 {
-		b.telegram.SendMessage(message.Chat, "No alerts right now! 🎉", nil)
+		b.reply(message, "No alerts right now! 🎉", nil)
 		return
 	}
 
-	out, err := b.tmplAlerts(alerts...)
-	if err != nil {
-		return
+	// Every page is rendered with the template on its own
+	var (
+		pages []string
+		mode  telebot.ParseMode
+	)
+	for start := 0; start < len(alerts); start += alertsPerPage {
+		out, m, err := b.tmplAlerts(alerts[start:min(len(alerts), start+alertsPerPage)]...)
+		if err != nil {
+			return
+		}
+		pages, mode = append(pages, out), m
 	}
 
-	_, err = b.telegram.SendMessage(message.Chat, out, &telebot.SendOptions{
-		ParseMode: telebot.ModeHTML,
-	})
-	if err != nil {
-		level.Warn(b.logger).Log("msg", "failed to send message", "err", err)
-	}
+	b.sendPages(message, pages, mode)
 }
 
 func (b *Bot) handleSilences(message telebot.Message) {
 	silences, err := alertmanager.ListSilences(b.logger, b.alertmanager.String())
 	if err != nil {
-		b.telegram.SendMessage(message.Chat, fmt.Sprintf("failed to list silences... %v", err), nil)
+		b.reply(message, fmt.Sprintf("failed to list silences... %v", err), nil)
 		return
 	}
 
 	if len(silences) == 0 {
-		b.telegram.SendMessage(message.Chat, "No silences right now.", nil)
+		b.reply(message, "No silences right now.", nil)
 		return
 	}
 
-	var out string
+	var list []string
 	for _, silence := range silences {
-		out = out + alertmanager.SilenceMessage(silence) + "\n"
+		list = append(list, alertmanager.SilenceMessage(silence))
 	}
 
-	b.telegram.SendMessage(message.Chat, out, &telebot.SendOptions{ParseMode: telebot.ModeMarkdown})
+	b.sendPages(message, paginate("", list, itemsPerPage), telebot.ModeMarkdown)
 }
 
-func (b *Bot) tmplAlerts(alerts ...*types.Alert) (string, error) {
-	data := b.templates.Data("default", nil, alerts...)
-
-	out, err := b.templates.ExecuteHTMLString(`{{ template "telegram.default" . }}`, data)
-	if err != nil {
-		return "", err
-	}
+func (b *Bot) tmplAlerts(alerts ...*types.Alert) (string, telebot.ParseMode, error) {
+	data := b.currentTemplates().Data("default", nil, alerts...)
 
-	return out, nil
+	return b.renderAlerts(ChatSettings{}, defaultTemplate, data)
 }
 
 func (b *Bot) handleAddMember(message telebot.Message) {
-	// Right format: '/addmember username level (node if level = 1)'.
+	// Right format: '/addmember username level (node if level = 1)', or '/addmember username'
+	// and '/addmember' replying to a message of the user to select the level and node with buttons.
+	// The level and node can also be named, nodes with spaces quoted.
 	// Ex: /addmember vu_long 1 httpd
-	params := strings.Split(message.Text, " ")
-	if len(params) < 3 || len(params) > 4 {
+	// Ex: /addmember vu_long level=1 node="web server"
+	const usage = "Please send right format: '/addmember username level (node if level = 1)'. Ex: /addmember vu_long 1 httpd"
+	args, err := parseArgs(message.Text)
+	if err == nil {
+		err = args.Unknown("level", "node")
+	}
+	if err != nil {
+		b.reply(message, fmt.Sprintf("%s (%v)", usage, err), nil)
+		return
+	}
+
+	params := args.Positional
+	if len(params) == 0 && len(args.Named) == 0 && message.ReplyTo != nil && message.ReplyTo.Sender.Username != "" {
+		b.startOnboarding(message, message.ReplyTo.Sender.Username)
+		return
+	}
+	if len(params) == 1 && len(args.Named) == 0 {
+		b.startOnboarding(message, strings.TrimPrefix(params[0], "@"))
+		return
+	}
+	for _, name := range []string{"level", "node"} {
+		if v, ok := args.Named[name]; ok {
+			params = append(params, v)
+		}
+	}
+	if len(params) < 2 || len(params) > 3 {
 		level.Warn(b.logger).Log("msg", "need 2-3 parameters")
-		b.telegram.SendMessage(message.Chat, "Please send right format: '/addmember username level (node if level = 1)'. Ex: /addmember vu_long 1 httpd", nil)
+		b.reply(message, usage, nil)
 		return
 	}
 
-	if HandleLevel(params[2]) != levelOne && HandleLevel(params[2]) != levelTwo && HandleLevel(params[2]) != levelThree {
+	if HandleLevel(params[1]) != levelOne && HandleLevel(params[1]) != levelTwo && HandleLevel(params[1]) != levelThree {
 		level.Warn(b.logger).Log("msg", "level need to be 1-3")
-		b.telegram.SendMessage(message.Chat, "Level need to be \"[1-3]\"", nil)
+		b.reply(message, "Level need to be \"[1-3]\"", nil)
+		return
+	}
+	if HandleLevel(params[1]) == levelOne && len(params) != 3 {
+		b.reply(message, usage, nil)
 		return
 	}
 
 	member := Member{
-		Username: params[1],
-		Level:    HandleLevel(params[2]),
+		Username: params[0],
+		Level:    HandleLevel(params[1]),
 		Chat:     message.Chat,
 	}
+	var node string
+	if member.Level == levelOne {
+		node = params[2]
+	}
+
+	b.addMember(message, member, node)
+}
+
+// addMember adds the member and, for level 1, the node owned by it as requested by the message
+func (b *Bot) addMember(message telebot.Message, member Member, node string) {
+	if err := b.checkMemberScope(message, member.Username); err != nil {
+		b.reply(message, fmt.Sprintf("I can't add this member. %v", err), nil)
+		return
+	}
+	if member.Level == levelOne {
+		if err := b.checkNodeScope(message, node); err != nil {
+			b.reply(message, fmt.Sprintf("I can't add this member. %v", err), nil)
+			return
+		}
+	}
 
 	if err := b.members.Add(member); err != nil {
 		level.Warn(b.logger).Log("msg", "failed to add chat to chat store", "err", err)
-		b.telegram.SendMessage(message.Chat, "I can't add this member to the subscribers list.", nil)
+		b.reply(message, "I can't add this member to the subscribers list.", nil)
 		return
 	}
+	if err := b.moveNodes([]Member{member}); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to move nodes of member to its chat", "username", member.Username, "err", err)
+	}
 
 	if member.Level == levelOne {
 		node := NodeExported{
-			Name:  params[3],
+			Name:  node,
 			Owner: member.Username,
+			Chat:  member.Chat,
 		}
 
 		if err := b.nodes.Add(node); err != nil {
 			level.Warn(b.logger).Log("msg", "failed to add node exported to node store", "err", err)
-			b.telegram.SendMessage(message.Chat, "I can't add this node to the subscribers list.", nil)
+			b.reply(message, "I can't add this node to the subscribers list.", nil)
 			return
 		}
 	}
 
-	b.telegram.SendMessage(message.Chat, responseMember, nil)
+	b.reply(message, responseMember, nil)
 	level.Info(b.logger).Log(
-		"msg", "Member added",
+		"msg", "member added",
 		"username", member.Username,
-		"level", member.Level,
+		"member_level", member.Level,
 	)
 }
 
+// moveNodes keys the nodes owned by the members by their new chat
+func (b *Bot) moveNodes(members []Member) error {
+	chats := make(map[string]telebot.Chat, len(members))
+	for _, m := range members {
+		chats[m.Username] = m.Chat
+	}
+
+	nodes, err := b.nodes.List()
+	if err != nil && err != store.ErrKeyNotFound {
+		return err
+	}
+	var moved []NodeExported
+	for _, n := range nodes {
+		if chat, ok := chats[n.Owner]; ok && chat.ID != n.Chat.ID {
+			n.Chat = chat
+			moved = append(moved, n)
+		}
+	}
+	if len(moved) == 0 {
+		return nil
+	}
+	return b.nodes.AddAll(moved)
+}
+
 func (b *Bot) handleRemoveMember(message telebot.Message) {
-	// Right format: '/rmmember username level (node if level = 1)'.
-	// Ex: /rmmember vu_long 1 httpd
-	params := strings.Split(message.Text, " ")
-	level.Debug(b.logger).Log("len", len(params))
-	if len(params) != 2 {
+	// Right format: '/rmmember username'.
+	// Ex: /rmmember vu_long
+	args, err := parseArgs(message.Text)
+	if err != nil || len(args.Positional) != 1 || len(args.Named) != 0 {
 		level.Warn(b.logger).Log("msg", "need only 1 parameter")
-		b.telegram.SendMessage(message.Chat, "Please send right format: '/rmmember username'. Ex: /rmmember vu_long", nil)
+		b.reply(message, "Please send right format: '/rmmember username'. Ex: /rmmember vu_long", nil)
 		return
 	}
 
 	member := Member{
-		Username: params[1],
+		Username: args.Positional[0],
+	}
+
+	if err := b.checkMemberScope(message, member.Username); err != nil {
+		b.reply(message, fmt.Sprintf("I can't remove this member. %v", err), nil)
+		return
 	}
 
+	b.confirm(message, fmt.Sprintf("Do you really want to remove the member @%s?", member.Username), func() {
+		b.removeMember(message, member)
+	})
+}
+
+func (b *Bot) removeMember(message telebot.Message, member Member) {
 	if err := b.members.Remove(member); err != nil {
-		level.Warn(b.logger).Log("msg", "failed to remove chat to chat store", "err", err)
-		b.telegram.SendMessage(message.Chat, "I can't remove this member to the subscribers list.", nil)
+		level.Warn(b.logger).Log("msg", "failed to remove member from member store", "err", err)
+		b.reply(message, "I can't remove this member to the subscribers list.", nil)
 		return
 	}
 
-	b.telegram.SendMessage(message.Chat, responseMember, nil)
+	b.reply(message, responseMember, nil)
 	level.Info(b.logger).Log(
-		"msg", "Member is removed",
+		"msg", "member removed",
 		"username", member.Username,
 	)
 }
 
 func (b *Bot) handleMembers(message telebot.Message) {
-	members, err := b.members.List()
+	members, err := b.scopedMembers(message)
 	if err != nil {
 		level.Warn(b.logger).Log("msg", "failed to list members from member store", "err", err)
-		b.telegram.SendMessage(message.Chat, "I can't list the added members.", nil)
+		b.reply(message, "I can't list the added members.", nil)
 		return
 	}
 
-	list := ""
+	var list []string
 	for _, member := range members {
-		list = list + fmt.Sprintf("@%s level: %s\n", member.Username, member.Level)
+		list = append(list, fmt.Sprintf("@%s level: %s", member.Username, member.Level))
 	}
 
-	level.Debug(b.logger).Log("list", list)
+	level.Debug(b.logger).Log("msg", "listed members", "chat_id", message.Chat.ID, "members", len(members))
 
-	b.telegram.SendMessage(message.Chat, "Currently these members have added:\n"+list, nil)
+	b.sendPages(message, paginate("Currently these members have added:\n", list, itemsPerPage), "")
 }
 
 func (b *Bot) handleNodes(message telebot.Message) {
-	nodes, err := b.nodes.List()
+	nodes, err := b.scopedNodes(message)
 	if err != nil {
-		level.Warn(b.logger).Log("msg", "failed to list members from nodes store", "err", err)
-		b.telegram.SendMessage(message.Chat, "I can't list the added nodes.", nil)
+		level.Warn(b.logger).Log("msg", "failed to list nodes from node store", "err", err)
+		b.reply(message, "I can't list the added nodes.", nil)
 		return
 	}
 
@@ -689,7 +2021,54 @@ func (b *Bot) handleNodes(message telebot.Message) {
 		list = list + fmt.Sprintf("@%s level: %s\n", node.Name, node.Owner)
 	}
 
-	level.Debug(b.logger).Log("list", list)
+	level.Debug(b.logger).Log("msg", "listed nodes", "chat_id", message.Chat.ID, "nodes", len(nodes))
+
+	b.reply(message, "Currently these nodes have added:\n"+list, nil)
+}
+
+func (b *Bot) handleFilter(message telebot.Message) {
+	// Right format: '/filter {label=value, label!=value, label=~regex, label!~regex, annotations.name=~regex} | clear'.
+	// Ex: /filter team=db severity=~"critical|warning" instance!~"test-.*"
+	params := strings.Fields(message.Text)[1:]
+
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't get the filters of this chat.", nil)
+		return
+	}
+
+	if len(params) == 0 {
+		if len(settings.Matchers) == 0 {
+			b.reply(message, "This chat receives all alerts.", nil)
+			return
+		}
+		b.reply(message, "This chat only receives alerts matching:\n"+settings.Matchers.String(), nil)
+		return
+	}
+
+	if len(params) == 1 && params[0] == "clear" {
+		settings.Matchers = nil
+	} else {
+		matchers, err := parseMatchers(strings.TrimPrefix(message.Text, strings.Fields(message.Text)[0]))
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to parse matchers", "err", err)
+			b.reply(message, fmt.Sprintf("Please send right format: '/filter label=value label!=value label=~regex label!~regex' or '/filter clear'. %v", err), nil)
+			return
+		}
+		settings.Matchers = matchers
+	}
+
+	if err := b.settings.Set(message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.reply(message, "I can't save the filters of this chat.", nil)
+		return
+	}
 
-	b.telegram.SendMessage(message.Chat, "Currently these nodes have added:\n"+list, nil)
+	b.reply(message, responseMember, nil)
+	level.Info(b.logger).Log(
+		"msg", "chat filter changed",
+		"chat_id", message.Chat.ID,
+		"matchers", settings.Matchers.String(),
+	)
 }
@@ -0,0 +1,220 @@
+package telegram
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/alertmanager"
+)
+
+func TestIsAdmin(t *testing.T) {
+	b := &Bot{admins: []int{1}}
+	WithExtraAdmins(3, 2)(b)
+	WithAdminChats(-100)(b)
+
+	assert.True(t, b.isAdmin(telebot.Message{Sender: telebot.User{ID: 2}, Chat: telebot.Chat{ID: 2}}))
+	assert.True(t, b.isAdmin(telebot.Message{Sender: telebot.User{ID: 42}, Chat: telebot.Chat{ID: -100}}))
+	assert.False(t, b.isAdmin(telebot.Message{Sender: telebot.User{ID: 42}, Chat: telebot.Chat{ID: 42}}))
+}
+
+func TestParseUserRef(t *testing.T) {
+	u, err := parseUserRef(telebot.Message{}, []string{"@Vu_Long"})
+	assert.NoError(t, err)
+	assert.True(t, u.Matches(telebot.User{ID: 7, Username: "vu_long"}))
+	assert.Equal(t, "@vu_long", u.key())
+
+	u, err = parseUserRef(telebot.Message{}, []string{"42"})
+	assert.NoError(t, err)
+	assert.Equal(t, UserRef{ID: 42}, u)
+	assert.False(t, u.Matches(telebot.User{ID: 7, Username: "vu_long"}))
+
+	reply := &telebot.Message{Sender: telebot.User{ID: 7, Username: "vu_long"}}
+	u, err = parseUserRef(telebot.Message{ReplyTo: reply}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "@vu_long (7)", u.String())
+
+	_, err = parseUserRef(telebot.Message{}, nil)
+	assert.Error(t, err)
+}
+
+// fakeUserRefStore keeps the users in memory
+type fakeUserRefStore struct{ users []UserRef }
+
+func (s *fakeUserRefStore) List() ([]UserRef, error) { return s.users, nil }
+func (s *fakeUserRefStore) Add(u UserRef) error      { s.users = append(s.users, u); return nil }
+func (s *fakeUserRefStore) Remove(UserRef) error     { return nil }
+func (s *fakeUserRefStore) Contains(user telebot.User) (bool, error) {
+	for _, u := range s.users {
+		if u.Matches(user) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestBans(t *testing.T) {
+	bans := &fakeUserRefStore{}
+	s := newFakeSender()
+	b := &Bot{admins: []int{1}, adminNames: map[int]string{1: "RootAdmin"}, bans: bans, sender: s, logger: log.NewNopLogger()}
+	sender := telebot.User{ID: 2, Username: "admin"}
+
+	b.handleBan(telebot.Message{Sender: sender, Text: "/ban @rootadmin"})
+	b.handleBan(telebot.Message{Sender: sender, Text: "/ban 1"})
+	b.handleBan(telebot.Message{Sender: sender, Text: "/ban @Admin"})
+	assert.Empty(t, bans.users, "configured admins and the sender can't be banned")
+
+	b.handleBan(telebot.Message{Sender: sender, Text: "/ban @spammer"})
+	assert.Equal(t, []UserRef{{Username: "spammer"}}, bans.users)
+	assert.True(t, b.isBanned(telebot.User{ID: 5, Username: "Spammer"}))
+	assert.False(t, b.isBanned(telebot.User{ID: 1, Username: "spammer"}), "configured admins are never banned")
+
+	// Banned users can't press buttons
+	b.handleCallback(telebot.Callback{Sender: telebot.User{ID: 5, Username: "spammer"}, Data: "ack"})
+	assert.Equal(t, []string{""}, s.answers)
+}
+
+func TestConfirmations(t *testing.T) {
+	now := time.Now()
+	c := newConfirmations()
+
+	id := c.Add(confirmation{userID: 1, expires: now.Add(time.Minute)}, now)
+	_, ok := c.Take(id, telebot.User{ID: 2}, now)
+	assert.False(t, ok, "only the sender can confirm")
+	_, ok = c.Take(id, telebot.User{ID: 1}, now)
+	assert.True(t, ok)
+	_, ok = c.Take(id, telebot.User{ID: 1}, now)
+	assert.False(t, ok, "confirmations are only taken once")
+
+	id = c.Add(confirmation{userID: 1, expires: now.Add(time.Minute)}, now)
+	_, ok = c.Take(id, telebot.User{ID: 1}, now.Add(2*time.Minute))
+	assert.False(t, ok, "expired confirmations can't be taken")
+
+	admin := func(u telebot.User) bool { return u.ID == 3 }
+	id = c.Add(confirmation{userID: 1, expires: now.Add(time.Minute), approve: admin}, now)
+	_, ok = c.Take(id, telebot.User{ID: 1}, now)
+	assert.False(t, ok, "requests are approved by others than the sender")
+	_, ok = c.Take(id, telebot.User{ID: 3}, now)
+	assert.True(t, ok)
+}
+
+func TestIsAllowedChat(t *testing.T) {
+	b := &Bot{admins: []int{1}}
+	assert.True(t, b.isAllowedChat(telebot.Chat{ID: -200, Type: telebot.ChatGroup}), "all chats are allowed without allow-list")
+
+	WithAllowedChats(-100)(b)
+	WithAdminChats(-300)(b)
+	assert.True(t, b.isAllowedChat(telebot.Chat{ID: -100, Type: telebot.ChatGroup}))
+	assert.True(t, b.isAllowedChat(telebot.Chat{ID: -300, Type: telebot.ChatSuperGroup}))
+	assert.True(t, b.isAllowedChat(telebot.Chat{ID: 1, Type: telebot.ChatPrivate}))
+	assert.False(t, b.isAllowedChat(telebot.Chat{ID: -200, Type: telebot.ChatGroup}))
+	assert.False(t, b.isAllowedChat(telebot.Chat{ID: 2, Type: telebot.ChatPrivate}))
+}
+
+func TestForbiddenNotices(t *testing.T) {
+	b := &Bot{}
+	WithForbiddenNotices(true)(b)
+
+	now := time.Now()
+	assert.False(t, b.forbiddenNotices.Duplicate(-100, 42, now))
+	assert.True(t, b.forbiddenNotices.Duplicate(-100, 42, now.Add(time.Minute)), "one notice per sender and chat in the window")
+	assert.False(t, b.forbiddenNotices.Duplicate(-100, 43, now))
+	assert.False(t, b.forbiddenNotices.Duplicate(-100, 42, now.Add(forbiddenNoticeWindow)))
+
+	WithForbiddenNotices(false)(b)
+	assert.Nil(t, b.forbiddenNotices)
+}
+
+func TestHelpMessage(t *testing.T) {
+	b := &Bot{admins: []int{1}}
+	WithReadOnlyCommands(true)(b)
+
+	admin := helpMessage(b.registeredCommands(), b.permission(telebot.Message{Sender: telebot.User{ID: 1}}))
+	for _, c := range b.registeredCommands() {
+		assert.Contains(t, admin, c.name+" - "+c.description+"\n")
+	}
+
+	user := helpMessage(b.registeredCommands(), b.permission(telebot.Message{Sender: telebot.User{ID: 42}}))
+	assert.Contains(t, user, commandAlerts+" - List all alerts.\n")
+	assert.Contains(t, user, commandHelp+" - ")
+	assert.NotContains(t, user, commandAddMember+" - ", "admin-only commands are hidden")
+	assert.NotContains(t, user, commandAudit+" - ")
+}
+
+func TestOnboardings(t *testing.T) {
+	now := time.Now()
+	o := newOnboardings()
+
+	sender := telebot.Message{Sender: telebot.User{ID: 1}}
+	id := o.Add(&onboarding{message: sender, username: "vu_long", expires: now.Add(time.Minute)}, now)
+	_, ok := o.Get(id, 2, now)
+	assert.False(t, ok, "only the sender can continue")
+	ob, ok := o.Get(id, 1, now)
+	assert.True(t, ok)
+	assert.Equal(t, "vu_long", ob.username)
+	_, ok = o.Get(id, 1, now.Add(2*time.Minute))
+	assert.False(t, ok, "expired onboardings can't be continued")
+
+	o.Remove(id)
+	_, ok = o.Get(id, 1, now)
+	assert.False(t, ok)
+}
+
+func TestOnboardingKeyboards(t *testing.T) {
+	nodes := onboardingNodes([]NodeExported{{Name: "nginx"}, {Name: "httpd"}, {Name: "nginx"}, {Name: "mysql"}, {Name: "redis"}})
+	assert.Equal(t, []string{"httpd", "mysql", "nginx", "redis"}, nodes)
+
+	keyboard, err := nodeKeyboard("1000", nodes)
+	assert.NoError(t, err)
+	assert.Len(t, keyboard, 2)
+	assert.Len(t, keyboard[0], onboardingNodesPerRow)
+
+	levels, err := levelKeyboard("1000")
+	assert.NoError(t, err)
+	for _, row := range append(keyboard, levels...) {
+		for _, button := range row {
+			assert.True(t, len(button.Data) <= 64, "telegram limits the callback data to 64 bytes: %s", button.Data)
+		}
+	}
+}
+
+func TestDeliverResolvedToMutedChat(t *testing.T) {
+	tmpl, err := LoadTemplates()
+	assert.NoError(t, err)
+
+	s := newFakeSender()
+	b := &Bot{logger: log.NewNopLogger(), sender: s, templates: tmpl, dedup: newDeduplicator(0), alerts: NewAlertRegistry()}
+
+	chat := telebot.Chat{ID: -100}
+	resolved := template.Alert{Status: "resolved", Labels: template.KV{"alertname": "HighCPU"}}
+	data := &template.Data{Receiver: "ops", Status: "resolved", GroupLabels: template.KV{"alertname": "HighCPU"}, Alerts: template.Alerts{resolved}}
+	tracked := &HandleAlert{
+		ID:              "HighCPU",
+		Group:           groupID("ops", data.GroupLabels),
+		Chat:            chat,
+		MessageID:       42,
+		Alert:           resolved,
+		Fingerprints:    alertFingerprints(data.Alerts),
+		AutoForwardFlag: true,
+	}
+	b.alerts.Add(tracked)
+
+	// The chat muted HighCPU after its message was sent
+	settings := ChatSettings{Mutes: map[string]time.Time{"HighCPU": time.Now().Add(time.Hour)}}
+	assert.Equal(t, data.Alerts, filterMuted(settings.Mutes, data.Alerts, time.Now()), "resolved alerts aren't muted")
+
+	w := alertmanager.Webhook{WebhookMessage: notify.WebhookMessage{Data: data}}
+	b.deliverTarget(context.Background(), w, data, &routedAlerts{chat: chat, alerts: data.Alerts, template: defaultTemplate, settings: settings}, nil)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tracked.resolved))
+	assert.False(t, tracked.escalating())
+	assert.Contains(t, s.markups, 42)
+	assert.Empty(t, s.markups[42].InlineKeyboard, "the buttons are removed")
+	assert.Len(t, s.sent, 1, "the resolved notification is sent")
+}
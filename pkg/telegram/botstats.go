@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hako/durafmt"
+	"github.com/tucnak/telebot"
+)
+
+const commandBotStats = "/botstats"
+
+// queueDepth reports how full one of the bot's channels is
+type queueDepth struct {
+	name     string
+	length   func() int
+	capacity int
+}
+
+// botStats track the health of the bot shown by /botstats to those without access to Prometheus
+type botStats struct {
+	telegramSends  int64 // atomic, alert messages sent, updated or resolved
+	telegramErrors int64 // atomic
+
+	mu            sync.Mutex
+	queues        []queueDepth
+	lastWebhookAt time.Time
+}
+
+func (s *botStats) setQueues(queues ...queueDepth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queues = queues
+}
+
+// Webhook records the arrival of a webhook
+func (s *botStats) Webhook(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastWebhookAt = now
+}
+
+// TelegramSend records the outcome of a Telegram API call delivering alerts
+func (s *botStats) TelegramSend(err error) {
+	atomic.AddInt64(&s.telegramSends, 1)
+	if err != nil {
+		atomic.AddInt64(&s.telegramErrors, 1)
+	}
+}
+
+// botStatsMessage summarizes the health of the bot
+func botStatsMessage(s *botStats, open int64, storeLatency time.Duration, storeErr error, now time.Time) string {
+	s.mu.Lock()
+	queues := s.queues
+	lastWebhookAt := s.lastWebhookAt
+	s.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("Bot health:\n")
+
+	b.WriteString("Queues:")
+	if len(queues) == 0 {
+		b.WriteString(" not running")
+	}
+	for i, q := range queues {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, " %s %d/%d", q.name, q.length(), q.capacity)
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "Open alerts: %d\n", open)
+
+	if lastWebhookAt.IsZero() {
+		b.WriteString("Last webhook: never\n")
+	} else {
+		fmt.Fprintf(&b, "Last webhook: %s ago (%s)\n",
+			durafmt.Parse(now.Sub(lastWebhookAt).Truncate(time.Second)).String(),
+			lastWebhookAt.UTC().Format(time.RFC3339),
+		)
+	}
+
+	if storeErr != nil {
+		fmt.Fprintf(&b, "Store latency: failed after %s: %v\n", storeLatency, storeErr)
+	} else {
+		fmt.Fprintf(&b, "Store latency: %s\n", storeLatency)
+	}
+
+	sends := atomic.LoadInt64(&s.telegramSends)
+	errors := atomic.LoadInt64(&s.telegramErrors)
+	if sends == 0 {
+		b.WriteString("Telegram sends: none yet\n")
+	} else {
+		fmt.Fprintf(&b, "Telegram sends: %d, failed: %d (%.1f%%)\n", sends, errors, float64(errors)/float64(sends)*100)
+	}
+
+	return b.String()
+}
+
+func (b *Bot) handleBotStats(message telebot.Message) {
+	// Probe the store with the lookup every webhook delivery starts with
+	start := time.Now()
+	_, err := b.chats.List()
+	latency := time.Since(start).Round(time.Microsecond)
+
+	b.reply(message, botStatsMessage(&b.stats, b.alertMetrics.Open(), latency, err, time.Now()), nil)
+}
@@ -0,0 +1,39 @@
+package telegram
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotStatsMessage(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var s botStats
+	msg := botStatsMessage(&s, 0, time.Millisecond, nil, now)
+	assert.Contains(t, msg, "Queues: not running\n")
+	assert.Contains(t, msg, "Last webhook: never\n")
+	assert.Contains(t, msg, "Store latency: 1ms\n")
+	assert.Contains(t, msg, "Telegram sends: none yet\n")
+
+	webhooks := make(chan struct{}, 32)
+	webhooks <- struct{}{}
+	s.setQueues(
+		queueDepth{name: "webhooks", length: func() int { return len(webhooks) }, capacity: cap(webhooks)},
+		queueDepth{name: "alerts", length: func() int { return 0 }, capacity: 100},
+	)
+	s.Webhook(now.Add(-90 * time.Second))
+	for i := 0; i < 7; i++ {
+		s.TelegramSend(nil)
+	}
+	s.TelegramSend(errors.New("Too Many Requests"))
+
+	msg = botStatsMessage(&s, 3, time.Second, errors.New("connection refused"), now)
+	assert.Contains(t, msg, "Queues: webhooks 1/32, alerts 0/100\n")
+	assert.Contains(t, msg, "Open alerts: 3\n")
+	assert.Contains(t, msg, "Last webhook: 1 minute 30 seconds ago (2020-01-01T11:58:30Z)\n")
+	assert.Contains(t, msg, "Store latency: failed after 1s: connection refused\n")
+	assert.Contains(t, msg, "Telegram sends: 8, failed: 1 (12.5%)\n")
+}
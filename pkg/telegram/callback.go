@@ -1,9 +1,43 @@
 package telegram
 
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	// callbackIDPrefix marks the data of buttons holding the ID of their payload,
+	// the data of buttons sent before holds the JSON payload itself
+	callbackIDPrefix = "#"
+	// callbackRetention after which the payloads of buttons that weren't sent or pressed again are removed
+	callbackRetention = 30 * 24 * time.Hour
+	// callbackRefresh after which the payload of a button sent or pressed again is saved again, extending its retention
+	callbackRefresh = 24 * time.Hour
+	// callbackPruneInterval in which the expired payloads are removed
+	callbackPruneInterval = time.Hour
+)
+
 // CallbackData save the json struct to communication in inline button data
 type CallbackData struct {
 	Button  string `json:"button"`
-	AlertID string `json:"alert"`
+	AlertID string `json:"alert,omitempty"`
+	// Confirmation is the ID of a pending confirmation of a destructive command
+	Confirmation string `json:"confirmation,omitempty"`
+	// Onboarding is the ID of a pending /addmember selection and Value the selected level or node
+	Onboarding string `json:"onboarding,omitempty"`
+	Value      string `json:"value,omitempty"`
+	// List is the ID of a paginated list and Value the selected page
+	List string `json:"list,omitempty"`
 }
 
 // NewCallbackData create new CallbackData object
@@ -13,3 +47,148 @@ func NewCallbackData(button string, alert string) (*CallbackData, error) {
 		AlertID: alert,
 	}, nil
 }
+
+// StoredCallback is the payload of a button whose data is its ID
+type StoredCallback struct {
+	Data  string    `json:"data"`
+	Saved time.Time `json:"saved"`
+}
+
+// BotCallbackStore is all the Bot needs to store and read the payloads of buttons
+type BotCallbackStore interface {
+	Get(id string) (StoredCallback, error)
+	Put(id string, c StoredCallback) error
+	Prune(before time.Time) (int, error)
+}
+
+// callbacks keep the payloads of the inline buttons, whose data only holds their ID.
+// Telegram drops buttons with more than 64 bytes of data, which the IDs of alert groups and node names can exceed.
+type callbacks struct {
+	store  BotCallbackStore // shares the payloads with other replicas and restarts, only kept in memory if nil
+	logger log.Logger
+
+	mu       sync.Mutex
+	payloads map[string]StoredCallback
+	pruned   time.Time
+}
+
+func newCallbacks(s BotCallbackStore, logger log.Logger) *callbacks {
+	return &callbacks{store: s, logger: logger, payloads: make(map[string]StoredCallback)}
+}
+
+// callbackID derives the ID from the payload, so that a button gets the same ID on every replica and after restarts
+func callbackID(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return callbackIDPrefix + base64.RawURLEncoding.EncodeToString(sum[:9])
+}
+
+// markup returns the inline keyboard with the data of its buttons shortened, every inline keyboard is sent through it
+func (c *callbacks) markup(keyboard [][]telebot.KeyboardButton) telebot.ReplyMarkup {
+	c.shorten(keyboard, time.Now())
+	return telebot.ReplyMarkup{InlineKeyboard: keyboard}
+}
+
+// shorten replaces the data of the keyboard's buttons by the IDs of their payloads
+func (c *callbacks) shorten(keyboard [][]telebot.KeyboardButton, now time.Time) {
+	if c == nil {
+		return
+	}
+	for _, row := range keyboard {
+		for i := range row {
+			if row[i].Data != "" && !strings.HasPrefix(row[i].Data, callbackIDPrefix) {
+				row[i].Data = c.save(row[i].Data, now)
+			}
+		}
+	}
+}
+
+// save keeps the payload and returns its ID. Payloads the store can't save are kept in memory,
+// so that their buttons work until a restart.
+func (c *callbacks) save(data string, now time.Time) string {
+	id := callbackID(data)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh(id, StoredCallback{Data: data, Saved: now}, now)
+	return id
+}
+
+// refresh saves the payload again if it wasn't saved within callbackRefresh, c.mu must be held
+func (c *callbacks) refresh(id string, sc StoredCallback, now time.Time) {
+	if p, ok := c.payloads[id]; ok && now.Sub(p.Saved) < callbackRefresh {
+		return
+	}
+	sc.Saved = now
+	c.payloads[id] = sc
+	if c.store == nil {
+		return
+	}
+	if err := c.store.Put(strings.TrimPrefix(id, callbackIDPrefix), sc); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to store callback data, the button only works until a restart", "id", id, "err", err)
+	}
+}
+
+// resolve returns the payload of the button's data, which is either the ID of a payload or the payload itself
+func (c *callbacks) resolve(data string, now time.Time) (CallbackData, error) {
+	var cd CallbackData
+	if c == nil || !strings.HasPrefix(data, callbackIDPrefix) {
+		err := json.Unmarshal([]byte(data), &cd)
+		return cd, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sc, ok := c.payloads[data]
+	if !ok {
+		if c.store == nil {
+			return cd, fmt.Errorf("unknown callback %s", data)
+		}
+		var err error
+		// The button may have been sent by another replica or before a restart
+		sc, err = c.store.Get(strings.TrimPrefix(data, callbackIDPrefix))
+		if err == store.ErrKeyNotFound {
+			return cd, fmt.Errorf("unknown or expired callback %s", data)
+		}
+		if err != nil {
+			return cd, err
+		}
+		c.payloads[data] = sc
+	}
+	c.refresh(data, sc, now)
+
+	err := json.Unmarshal([]byte(sc.Data), &cd)
+	return cd, err
+}
+
+// prune removes the payloads that weren't saved within callbackRetention, at most once per callbackPruneInterval
+func (c *callbacks) prune(now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.pruned) < callbackPruneInterval {
+		return
+	}
+	c.pruned = now
+
+	before := now.Add(-callbackRetention)
+	for id, p := range c.payloads {
+		if p.Saved.Before(before) {
+			delete(c.payloads, id)
+		}
+	}
+	if c.store == nil {
+		return
+	}
+	n, err := c.store.Prune(before)
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to prune stored callback data", "err", err)
+		return
+	}
+	if n > 0 {
+		level.Debug(c.logger).Log("msg", "pruned expired callback data", "callbacks", n)
+	}
+}
@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestCallbacks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "callbacks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	defer kv.Close()
+	s, err := NewCallbackStore(kv)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	c := newCallbacks(s, log.NewNopLogger())
+
+	// The ID of a group can exceed the 64 bytes Telegram accepts as data of a button
+	ack := CallbackData{Button: strAcknowledgeData, AlertID: strings.Repeat("instance=web01:9100,", 5)}
+	data, err := json.Marshal(ack)
+	assert.NoError(t, err)
+	keyboard := [][]telebot.KeyboardButton{{
+		{Text: strAcknowledgeData, Data: string(data)},
+		{Text: "Graph", URL: "http://prometheus:9090/graph"},
+	}}
+	c.shorten(keyboard, now)
+	id := keyboard[0][0].Data
+	assert.True(t, len(id) <= 64, "telegram limits the callback data to 64 bytes: %s", id)
+	assert.True(t, strings.HasPrefix(id, callbackIDPrefix))
+	assert.Equal(t, "http://prometheus:9090/graph", keyboard[0][1].URL)
+	assert.Empty(t, keyboard[0][1].Data, "link buttons have no data")
+
+	cd, err := c.resolve(id, now)
+	assert.NoError(t, err)
+	assert.Equal(t, ack, cd)
+
+	// Other replicas and the bot after a restart read the payload from the store
+	cd, err = newCallbacks(s, log.NewNopLogger()).resolve(id, now)
+	assert.NoError(t, err)
+	assert.Equal(t, ack, cd)
+
+	// Buttons sent before hold the payload itself
+	cd, err = c.resolve(string(data), now)
+	assert.NoError(t, err)
+	assert.Equal(t, ack, cd)
+
+	_, err = c.resolve("#unknown", now)
+	assert.Error(t, err)
+
+	// Payloads that weren't sent or pressed again expire
+	later := now.Add(callbackRetention + time.Hour)
+	c.prune(later)
+	_, err = c.resolve(id, later)
+	assert.Error(t, err)
+	_, err = s.Get(strings.TrimPrefix(id, callbackIDPrefix))
+	assert.Equal(t, store.ErrKeyNotFound, err)
+}
+
+func TestCallbacksWithoutRegistry(t *testing.T) {
+	var c *callbacks
+	keyboard := [][]telebot.KeyboardButton{{{Text: strAcknowledgeData, Data: `{"button":"Acknowledge","alert":"HighCPU"}`}}}
+	c.shorten(keyboard, time.Now())
+	assert.Equal(t, `{"button":"Acknowledge","alert":"HighCPU"}`, keyboard[0][0].Data)
+
+	cd, err := c.resolve(keyboard[0][0].Data, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, CallbackData{Button: strAcknowledgeData, AlertID: "HighCPU"}, cd)
+}
+
+func TestCallbacksMarkup(t *testing.T) {
+	c := newCallbacks(nil, log.NewNopLogger())
+
+	// The label of the button and its payload exceed the 64 bytes Telegram accepts as data
+	node := strings.Repeat("kube-node-eu-west-1a.", 4)
+	button, err := onboardingButton("1000", node, strNodeData, node)
+	assert.NoError(t, err)
+	assert.True(t, len(button.Text) > 64)
+	assert.True(t, len(button.Data) > 64)
+
+	markup := c.markup([][]telebot.KeyboardButton{{button}})
+	shortened := markup.InlineKeyboard[0][0]
+	assert.Equal(t, node, shortened.Text)
+	assert.True(t, len(shortened.Data) <= 64, "telegram limits the callback data to 64 bytes: %s", shortened.Data)
+
+	cd, err := c.resolve(shortened.Data, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, CallbackData{Button: strNodeData, Onboarding: "1000", Value: node}, cd)
+}
@@ -0,0 +1,69 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/docker/libkv/store"
+)
+
+const telegramCallbacksDirectory = "telegram/callbacks"
+
+// CallbackStore writes the payloads of buttons to a libkv store backend
+type CallbackStore struct {
+	kv store.Store
+}
+
+// NewCallbackStore stores the payloads of buttons in the provided kv backend
+func NewCallbackStore(kv store.Store) (*CallbackStore, error) {
+	return &CallbackStore{kv: kv}, nil
+}
+
+// Get the payload with the ID from the kv backend, store.ErrKeyNotFound if there is none
+func (s *CallbackStore) Get(id string) (StoredCallback, error) {
+	var c StoredCallback
+	kv, err := s.kv.Get(callbackKey(id))
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(kv.Value, &c)
+	return c, err
+}
+
+// Put adds or updates the payload in the kv backend
+func (s *CallbackStore) Put(id string, c StoredCallback) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return s.kv.Put(callbackKey(id), b, nil)
+}
+
+// Prune removes the payloads saved before the time and returns their number
+func (s *CallbackStore) Prune(before time.Time) (int, error) {
+	kvPairs, err := s.kv.List(telegramCallbacksDirectory)
+	if err == store.ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, kv := range kvPairs {
+		var c StoredCallback
+		if err := json.Unmarshal(kv.Value, &c); err == nil && !c.Saved.Before(before) {
+			continue
+		}
+		if err := s.kv.Delete(kv.Key); err != nil && err != store.ErrKeyNotFound {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+func callbackKey(id string) string {
+	return fmt.Sprintf("%s/%s", telegramCallbacksDirectory, id)
+}
@@ -0,0 +1,97 @@
+package telegram
+
+import (
+	"fmt"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+// chatAdminCommands can be used by the administrators of a group for the group's own members and nodes,
+// if enabled with WithChatAdmins
+var chatAdminCommands = map[string]bool{
+	commandAddMember:    true,
+	commandRemoveMember: true,
+	commandMembers:      true,
+	commandNodes:        true,
+}
+
+// isChatAdmin returns whether the sender is an administrator of the group the message was sent in
+func (b *Bot) isChatAdmin(message telebot.Message) bool {
+	if !b.chatAdmins {
+		return false
+	}
+	if message.Chat.Type != telebot.ChatGroup && message.Chat.Type != telebot.ChatSuperGroup {
+		return false
+	}
+
+	admins, err := b.telegram.GetChatAdministrators(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat administrators", "chat_id", message.Chat.ID, "err", err)
+		return false
+	}
+	for _, admin := range admins {
+		if admin.User.ID == message.Sender.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMemberScope returns an error if the sender of the message may not manage the member,
+// chat administrators may only manage the members of their chat
+func (b *Bot) checkMemberScope(message telebot.Message, username string) error {
+	if b.isAdmin(message) {
+		return nil
+	}
+
+	members, err := b.members.List()
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if m.Username == username && m.Chat.ID != message.Chat.ID {
+			return fmt.Errorf("@%s is a member of another chat", username)
+		}
+	}
+	return nil
+}
+
+// checkNodeScope returns an error if the sender of the message may not assign the node,
+// chat administrators may only reassign nodes owned by the members of their chat
+func (b *Bot) checkNodeScope(message telebot.Message, name string) error {
+	if b.isAdmin(message) {
+		return nil
+	}
+
+	nodes, err := b.nodes.List()
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if n.Name != name {
+			continue
+		}
+		if err := b.checkMemberScope(message, n.Owner); err != nil {
+			return fmt.Errorf("the node %s is owned by a member of another chat", name)
+		}
+	}
+	return nil
+}
+
+// scopedMembers returns all members for bot admins and only the chat's members for chat administrators
+func (b *Bot) scopedMembers(message telebot.Message) ([]Member, error) {
+	if b.isAdmin(message) {
+		return b.members.List()
+	}
+	return b.members.GetMembersByChat(message.Chat)
+}
+
+// scopedNodes returns all nodes for bot admins and only the nodes owned by
+// the chat's members for chat administrators
+func (b *Bot) scopedNodes(message telebot.Message) ([]NodeExported, error) {
+	if b.isAdmin(message) {
+		return b.nodes.List()
+	}
+	return b.nodes.GetByChat(message.Chat)
+}
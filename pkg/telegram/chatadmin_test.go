@@ -0,0 +1,76 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+type fakeMemberStore []Member
+
+func (s fakeMemberStore) List() ([]Member, error) { return s, nil }
+func (s fakeMemberStore) Add(Member) error        { return nil }
+func (s fakeMemberStore) AddAll([]Member) error   { return nil }
+func (s fakeMemberStore) Remove(Member) error     { return nil }
+func (s fakeMemberStore) GetMembersByChat(chat telebot.Chat) ([]Member, error) {
+	var members []Member
+	for _, m := range s {
+		if m.Chat.ID == chat.ID {
+			members = append(members, m)
+		}
+	}
+	return members, nil
+}
+func (s fakeMemberStore) GetRandomMemberByChatandLevel(telebot.Chat, string) (Member, error) {
+	return Member{}, nil
+}
+
+type fakeNodeStore []NodeExported
+
+func (s fakeNodeStore) List() ([]NodeExported, error) { return s, nil }
+func (s fakeNodeStore) Add(NodeExported) error        { return nil }
+func (s fakeNodeStore) AddAll([]NodeExported) error   { return nil }
+func (s fakeNodeStore) Remove(NodeExported) error     { return nil }
+func (s fakeNodeStore) GetByChat(chat telebot.Chat) ([]NodeExported, error) {
+	var nodes []NodeExported
+	for _, n := range s {
+		if n.Chat.ID == chat.ID {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+func TestChatAdminScope(t *testing.T) {
+	db := telebot.Chat{ID: -100}
+	web := telebot.Chat{ID: -200}
+	b := &Bot{
+		admins: []int{1},
+		members: fakeMemberStore{
+			{Username: "alice", Level: levelOne, Chat: db},
+			{Username: "bob", Level: levelOne, Chat: web},
+		},
+		nodes: fakeNodeStore{{Name: "db01", Owner: "alice", Chat: db}, {Name: "web01", Owner: "bob", Chat: web}},
+	}
+
+	chatAdmin := telebot.Message{Sender: telebot.User{ID: 2}, Chat: db}
+	assert.NoError(t, b.checkMemberScope(chatAdmin, "alice"))
+	assert.NoError(t, b.checkMemberScope(chatAdmin, "carol"))
+	assert.Error(t, b.checkMemberScope(chatAdmin, "bob"))
+	assert.NoError(t, b.checkNodeScope(chatAdmin, "db01"))
+	assert.Error(t, b.checkNodeScope(chatAdmin, "web01"))
+
+	members, err := b.scopedMembers(chatAdmin)
+	assert.NoError(t, err)
+	assert.Len(t, members, 1)
+	nodes, err := b.scopedNodes(chatAdmin)
+	assert.NoError(t, err)
+	assert.Equal(t, []NodeExported{{Name: "db01", Owner: "alice", Chat: db}}, nodes)
+
+	admin := telebot.Message{Sender: telebot.User{ID: 1}, Chat: db}
+	assert.NoError(t, b.checkMemberScope(admin, "bob"))
+	nodes, err = b.scopedNodes(admin)
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 2)
+}
@@ -0,0 +1,141 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tucnak/telebot"
+)
+
+// watchRetryInterval after which a failed watch of the chats is started again
+var watchRetryInterval = 10 * time.Second
+
+// CachedChatStore keeps the list of subscribed chats in memory, so that webhooks don't list them from the store.
+// The list is reloaded after /start and /stop, when the store reports a change and once it is older than the TTL.
+type CachedChatStore struct {
+	BotChatStore
+	ttl    time.Duration
+	logger log.Logger
+
+	mu       sync.Mutex
+	chats    []telebot.Chat
+	loadedAt time.Time
+}
+
+// NewCachedChatStore caches the chats of the store for up to the TTL
+func NewCachedChatStore(s BotChatStore, ttl time.Duration, logger log.Logger) *CachedChatStore {
+	return &CachedChatStore{BotChatStore: s, ttl: ttl, logger: logger}
+}
+
+// List the cached chats, loading them from the store if they are stale
+func (s *CachedChatStore) List() ([]telebot.Chat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.loadedAt.IsZero() || time.Since(s.loadedAt) >= s.ttl {
+		chats, err := s.BotChatStore.List()
+		if err != nil {
+			return nil, err
+		}
+		s.chats = chats
+		s.loadedAt = time.Now()
+	}
+	return append([]telebot.Chat(nil), s.chats...), nil
+}
+
+// Add the chat to the store and reload the list on its next use
+func (s *CachedChatStore) Add(c telebot.Chat) error {
+	defer s.invalidate()
+	return s.BotChatStore.Add(c)
+}
+
+// Remove the chat from the store and reload the list on its next use
+func (s *CachedChatStore) Remove(c telebot.Chat) error {
+	defer s.invalidate()
+	return s.BotChatStore.Remove(c)
+}
+
+func (s *CachedChatStore) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedAt = time.Time{}
+}
+
+// set replaces the cached chats with those the store reported
+func (s *CachedChatStore) set(chats []telebot.Chat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats = chats
+	s.loadedAt = time.Now()
+}
+
+// age returns how long ago the chats were loaded, 0 if they weren't yet
+func (s *CachedChatStore) age() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loadedAt.IsZero() {
+		return 0
+	}
+	return time.Since(s.loadedAt)
+}
+
+// Collector returns the gauge of the age of the cached chats to be registered
+func (s *CachedChatStore) Collector() prometheus.Collector {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "alertmanagerbot",
+		Name:      "chats_cache_age_seconds",
+		Help:      "Seconds since the cached list of subscribed chats was loaded from the store",
+	}, func() float64 {
+		return s.age().Seconds()
+	})
+}
+
+// Watch updates the cached chats whenever the chats of the kv backend change, e.g. by /start
+// of another replica, until the context is canceled. Stores that can't be watched only expire the cache.
+func (s *CachedChatStore) Watch(ctx context.Context, kv store.Store) error {
+	for {
+		events, err := kv.WatchTree(telegramChatsDirectory, ctx.Done())
+		if err == store.ErrCallNotSupported {
+			level.Debug(s.logger).Log("msg", "the store can't be watched, the chats are reloaded after the cache TTL")
+			<-ctx.Done()
+			return nil
+		}
+		if err == nil {
+			s.watch(events)
+		} else {
+			level.Warn(s.logger).Log("msg", "failed to watch chats", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
+
+// watch applies the changes of the chats until the events are closed
+func (s *CachedChatStore) watch(events <-chan []*store.KVPair) {
+	for pairs := range events {
+		chats := make([]telebot.Chat, 0, len(pairs))
+		for _, kv := range pairs {
+			var c telebot.Chat
+			if err := json.Unmarshal(kv.Value, &c); err != nil {
+				level.Warn(s.logger).Log("msg", "failed to decode watched chat", "key", kv.Key, "err", err)
+				s.invalidate()
+				chats = nil
+				break
+			}
+			chats = append(chats, c)
+		}
+		if chats != nil {
+			s.set(chats)
+		}
+	}
+}
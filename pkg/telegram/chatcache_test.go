@@ -0,0 +1,63 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestCachedChatStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chatcache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	defer kv.Close()
+	chats, err := NewChatStore(kv)
+	assert.NoError(t, err)
+	assert.NoError(t, chats.Add(telebot.Chat{ID: -100}))
+
+	c := NewCachedChatStore(chats, time.Hour, log.NewNopLogger())
+	assert.Zero(t, c.age())
+	list, err := c.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	// Changes bypassing the cache are only seen once it is reloaded
+	assert.NoError(t, chats.Add(telebot.Chat{ID: -200}))
+	list, err = c.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	assert.NoError(t, c.Remove(telebot.Chat{ID: -100}))
+	list, err = c.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []telebot.Chat{{ID: -200}}, list)
+
+	// Changes reported by the store replace the cached chats
+	events := make(chan []*store.KVPair, 1)
+	value, err := json.Marshal(telebot.Chat{ID: -300})
+	assert.NoError(t, err)
+	events <- []*store.KVPair{{Key: telegramChatsDirectory + "/-300", Value: value}}
+	close(events)
+	c.watch(events)
+	list, err = c.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []telebot.Chat{{ID: -300}}, list)
+
+	// bolt can't be watched, Watch waits for the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, c.Watch(ctx, kv))
+}
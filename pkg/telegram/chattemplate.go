@@ -0,0 +1,300 @@
+package telegram
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandSetTemplate  = "/settemplate"
+	commandTemplateTest = "/tmpltest"
+
+	templateClear = "clear"
+	formatPrefix  = "format="
+	templateLast  = "last"
+
+	// maxTemplateSize limits the size of uploaded chat templates
+	maxTemplateSize = 64 << 10
+)
+
+// renderAlerts renders the sorted alerts for a chat with its custom template if it has one,
+// otherwise with the template of its mode or the named template.
+// It returns the parse mode of the template's output format.
+func (b *Bot) renderAlerts(settings ChatSettings, name string, data *template.Data) (string, telebot.ParseMode, error) {
+	return renderTemplates(b.currentTemplates(), settings, name, data)
+}
+
+// RenderSample renders the named template with an example alert, to check templates without a bot
+func RenderSample(t *Templates, name string) (string, telebot.ParseMode, error) {
+	return renderTemplates(t, ChatSettings{}, name, sampleData())
+}
+
+func renderTemplates(t *Templates, settings ChatSettings, name string, data *template.Data) (string, telebot.ParseMode, error) {
+	if settings.Template != "" {
+		mode, err := parseFormat(settings.TemplateFormat)
+		if err != nil {
+			return "", mode, err
+		}
+		out, err := executeTemplate(t, settings.Template, mode, data)
+		return out, mode, err
+	}
+
+	name = settings.modeTemplate(name, data.Alerts)
+
+	// Templates without a .format template render HTML
+	var format string
+	if t.defined(name + ".format") {
+		f, err := t.execute(fmt.Sprintf(`{{ template "%s.format" . }}`, name), false, data)
+		if err != nil {
+			return "", telebot.ModeHTML, fmt.Errorf("failed to execute %s.format: %v", name, err)
+		}
+		format = f
+	}
+	mode, err := parseFormat(format)
+	if err != nil {
+		return "", mode, err
+	}
+
+	out, err := executeTemplate(t, fmt.Sprintf(`{{ template %q . }}`, name), mode, data)
+	return out, mode, err
+}
+
+// fallbackMessage renders alerts without templates, used when rendering the template failed
+func fallbackMessage(alerts template.Alerts) string {
+	var b strings.Builder
+	b.WriteString("<b>The template failed, showing the raw alerts:</b>\n")
+	for _, a := range alerts {
+		fmt.Fprintf(&b, "\n<b>%s</b> %s\n", html.EscapeString(strings.ToUpper(a.Status)), html.EscapeString(a.Labels["alertname"]))
+		for _, kv := range a.Labels.SortedPairs() {
+			fmt.Fprintf(&b, "%s = %s\n", html.EscapeString(kv.Name), html.EscapeString(kv.Value))
+		}
+		for _, kv := range a.Annotations.SortedPairs() {
+			fmt.Fprintf(&b, "%s: %s\n", html.EscapeString(kv.Name), html.EscapeString(kv.Value))
+		}
+	}
+	return b.String()
+}
+
+// renderAlertsOrFallback renders the alerts like renderAlerts. If the template fails,
+// the admins are told about it and the alerts are rendered without templates,
+// so that broken templates don't hide incidents.
+func (b *Bot) renderAlertsOrFallback(chat telebot.Chat, settings ChatSettings, name string, data *template.Data) (string, telebot.ParseMode) {
+	out, mode, err := b.renderAlerts(settings, name, data)
+	if err == nil {
+		return out, mode
+	}
+
+	b.reportError("failed to template alerts, sent them without the template", "chat_id", chat.ID, "template", name, "err", err)
+	if b.errorsChat == 0 {
+		// Without errors chat each admin is told once per failure in the errors window, not for every chat and webhook
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%s\n%v", name, err)
+		now := time.Now()
+		for _, admin := range b.currentAdmins() {
+			if !b.errorNotices.Duplicate(int64(admin), h.Sum64(), now) {
+				b.SendAdminMessage(admin, fmt.Sprintf("Rendering the template %s for chat %d failed, the alerts were sent without it: %v", name, chat.ID, err))
+			}
+		}
+	}
+	return fallbackMessage(data.Alerts), telebot.ModeHTML
+}
+
+// sampleData is an example webhook to render templates with
+func sampleData() *template.Data {
+	now := time.Now()
+	return &template.Data{
+		Receiver: "telegram",
+		Status:   "firing",
+		Alerts: template.Alerts{{
+			Status:      "firing",
+			Labels:      template.KV{"alertname": "Example", "severity": "warning", "instance": "web01:9100"},
+			Annotations: template.KV{"message": "This is an example alert."},
+			StartsAt:    now.Add(-time.Minute),
+			EndsAt:      now,
+		}},
+		GroupLabels:       template.KV{"alertname": "Example"},
+		CommonLabels:      template.KV{"alertname": "Example", "severity": "warning", "instance": "web01:9100"},
+		CommonAnnotations: template.KV{"message": "This is an example alert."},
+	}
+}
+
+// validateTemplate parses the template and renders it with an example alert
+func (b *Bot) validateTemplate(tmpl string, format string) error {
+	mode, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+	_, err = executeTemplate(b.currentTemplates(), tmpl, mode, sampleData())
+	return err
+}
+
+// setLastWebhook remembers the last received webhook for /tmpltest
+func (b *Bot) setLastWebhook(data *template.Data) {
+	b.lastWebhookMu.Lock()
+	defer b.lastWebhookMu.Unlock()
+
+	b.lastWebhook = data
+}
+
+func (b *Bot) getLastWebhook() *template.Data {
+	b.lastWebhookMu.Lock()
+	defer b.lastWebhookMu.Unlock()
+
+	return b.lastWebhook
+}
+
+// downloadTemplate fetches the template uploaded as a document
+func (b *Bot) downloadTemplate(doc telebot.Document) (string, error) {
+	if doc.FileSize > maxTemplateSize {
+		return "", fmt.Errorf("the file is bigger than %d bytes", maxTemplateSize)
+	}
+
+	url, err := b.telegram.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("downloading the file failed with status %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, maxTemplateSize))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+func (b *Bot) handleSetTemplate(message telebot.Message) {
+	// Right format: '/settemplate [format=html|markdown|markdownv2|plain] template', '/settemplate clear'
+	// or a file with the caption '/settemplate [format=...]'.
+	// Ex: /settemplate {{ range .Alerts }}<b>{{ .Labels.alertname }}</b> {{ .Annotations.message }}{{ end }}
+	command := message.Text
+	if command == "" {
+		command = message.Caption
+	}
+	fields := strings.Fields(command)
+	tmpl := strings.TrimSpace(strings.TrimPrefix(command, fields[0]))
+
+	format := ""
+	if len(fields) > 1 && strings.HasPrefix(fields[1], formatPrefix) {
+		format = strings.TrimPrefix(fields[1], formatPrefix)
+		tmpl = strings.TrimSpace(strings.TrimPrefix(tmpl, fields[1]))
+	}
+
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't get the template of this chat.", nil)
+		return
+	}
+
+	switch {
+	case message.Document.FileID != "":
+		tmpl, err = b.downloadTemplate(message.Document)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to download template", "err", err)
+			b.reply(message, fmt.Sprintf("I can't download the template. %v", err), nil)
+			return
+		}
+	case tmpl == "" && format == "":
+		if settings.Template == "" {
+			b.reply(message, "This chat uses the global template.", nil)
+			return
+		}
+		b.reply(message, "This chat uses the template:\n"+settings.Template, nil)
+		return
+	case tmpl == templateClear:
+		tmpl = ""
+	}
+
+	if tmpl != "" {
+		if err := b.validateTemplate(tmpl, format); err != nil {
+			b.reply(message, fmt.Sprintf("Please send a valid template. %v", err), nil)
+			return
+		}
+	} else {
+		format = ""
+	}
+	settings.Template = tmpl
+	settings.TemplateFormat = format
+
+	if err := b.settings.Set(message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.reply(message, "I can't save the template of this chat.", nil)
+		return
+	}
+
+	b.reply(message, responseMember, nil)
+	level.Info(b.logger).Log(
+		"msg", "chat template changed",
+		"chat_id", message.Chat.ID,
+		"custom", tmpl != "",
+	)
+}
+
+func (b *Bot) handleTemplateTest(message telebot.Message) {
+	// Right format: '/tmpltest [last] [template]'.
+	// Ex: /tmpltest last telegram.compact
+	params := strings.Fields(message.Text)[1:]
+
+	data := sampleData()
+	if len(params) > 0 && params[0] == templateLast {
+		last := b.getLastWebhook()
+		if last == nil {
+			b.reply(message, "I haven't received any webhook yet.", nil)
+			return
+		}
+		lastData := *last
+		data = &lastData
+		params = params[1:]
+	}
+	if len(params) > 1 {
+		b.reply(message, "Please send right format: '/tmpltest [last] [template]'. Ex: /tmpltest last telegram.compact", nil)
+		return
+	}
+
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't get the template of this chat.", nil)
+		return
+	}
+
+	// An explicitly named template is rendered instead of the chat's one
+	name := defaultTemplate
+	if len(params) == 1 {
+		name = params[0]
+		settings.Template = ""
+		settings.Modes = nil
+	}
+
+	data.Alerts = sortAlerts(data.Alerts)
+	out, mode, err := b.renderAlerts(settings, name, data)
+	if err != nil {
+		b.reply(message, fmt.Sprintf("Rendering the template failed. %v", err), nil)
+		return
+	}
+	if len(data.Alerts) > 0 {
+		out = alertsHeader(data.Alerts, mode) + out
+	}
+
+	if _, err := b.reply(message, out, &telebot.SendOptions{ParseMode: mode}); err != nil {
+		b.reply(message, fmt.Sprintf("Telegram rejected the rendered template. %v", err), nil)
+	}
+}
@@ -0,0 +1,159 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	strConfirmData = "Confirm"
+	strCancelData  = "Cancel"
+
+	// confirmationTimeout after which a destructive command can't be confirmed anymore
+	confirmationTimeout = 5 * time.Minute
+)
+
+// confirmation is a destructive command waiting for its sender to confirm it
+type confirmation struct {
+	chat    telebot.Chat
+	userID  int
+	expires time.Time
+	action  func()
+	// approve returns whether the user may confirm or cancel instead of the sender, for requests approved by others
+	approve func(telebot.User) bool
+	// resolved, if set, is told who confirmed or cancelled, before the action runs
+	resolved func(user telebot.User, confirmed bool)
+}
+
+// may returns whether the user may confirm or cancel the confirmation
+func (c confirmation) may(user telebot.User) bool {
+	if c.approve != nil {
+		return c.approve(user)
+	}
+	return c.userID == user.ID
+}
+
+// confirmations are the pending confirmations by their ID
+type confirmations struct {
+	mu      sync.Mutex
+	next    int
+	pending map[string]confirmation
+	tag     string // appended to the IDs, tells the confirmations of replicas apart
+}
+
+func newConfirmations() *confirmations {
+	return &confirmations{pending: make(map[string]confirmation)}
+}
+
+// Add a pending confirmation and return its ID, expired ones are removed
+func (c *confirmations) Add(conf confirmation, now time.Time) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, p := range c.pending {
+		if now.After(p.expires) {
+			delete(c.pending, id)
+		}
+	}
+
+	c.next++
+	id := strconv.Itoa(c.next) + c.tag
+	c.pending[id] = conf
+	return id
+}
+
+// Take removes the confirmation if the user may resolve it and returns it,
+// ok is false if it doesn't exist or expired
+func (c *confirmations) Take(id string, user telebot.User, now time.Time) (conf confirmation, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conf, ok = c.pending[id]
+	if !ok || !conf.may(user) {
+		return confirmation{}, false
+	}
+	delete(c.pending, id)
+
+	if now.After(conf.expires) {
+		return confirmation{}, false
+	}
+	return conf, true
+}
+
+// confirmKeyboard creates the Confirm and Cancel buttons of a confirmation
+func confirmKeyboard(id string) ([]telebot.KeyboardButton, error) {
+	confirmData, err := json.Marshal(CallbackData{Button: strConfirmData, Confirmation: id})
+	if err != nil {
+		return nil, err
+	}
+	cancelData, err := json.Marshal(CallbackData{Button: strCancelData, Confirmation: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return []telebot.KeyboardButton{
+		{Text: strConfirmData, Data: string(confirmData)},
+		{Text: strCancelData, Data: string(cancelData)},
+	}, nil
+}
+
+// confirm asks the sender of the message to confirm the question before the action is run
+func (b *Bot) confirm(message telebot.Message, question string, action func()) {
+	id := b.confirmations.Add(confirmation{
+		chat:    message.Chat,
+		userID:  message.Sender.ID,
+		expires: time.Now().Add(confirmationTimeout),
+		action:  action,
+	}, time.Now())
+
+	keyboard, err := confirmKeyboard(id)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to create confirmation keyboard", "err", err)
+		return
+	}
+
+	_, err = b.reply(message, question, &telebot.SendOptions{
+		ReplyMarkup: b.callbacks.markup([][]telebot.KeyboardButton{keyboard}),
+	})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send confirmation", "err", err)
+	}
+}
+
+// handleConfirmation runs or cancels the pending command of a Confirm or Cancel button
+func (b *Bot) handleConfirmation(callback telebot.Callback, cd CallbackData) {
+	conf, ok := b.confirmations.Take(cd.Confirmation, callback.Sender, time.Now())
+	if !ok {
+		b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{
+			Text: "This confirmation expired or belongs to someone else.",
+		})
+		return
+	}
+
+	text := "Cancelled."
+	if cd.Button == strConfirmData {
+		text = "Confirmed."
+	}
+	// Requests show who approved or declined them
+	if conf.approve != nil {
+		text = fmt.Sprintf("%s by @%s.", strings.TrimSuffix(text, "."), callback.Sender.Username)
+	}
+	if err := b.sender.EditMessageText(conf.chat, callback.Message.ID, callback.Message.Text+"\n"+text, nil); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to update confirmation", "err", err)
+	}
+	b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{Text: text})
+
+	if conf.resolved != nil {
+		conf.resolved(callback.Sender, cd.Button == strConfirmData)
+	}
+	if cd.Button == strConfirmData && conf.action != nil {
+		conf.action()
+	}
+}
@@ -0,0 +1,73 @@
+package telegram
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// groupFingerprint identifies the content of a webhook delivered to a chat,
+// independent of the receiver it was sent to
+func groupFingerprint(status string, alerts template.Alerts) uint64 {
+	keys := make([]string, 0, len(alerts))
+	for _, a := range alerts {
+		keys = append(keys, fmt.Sprintf("%s:%s:%d", alertLabelSet(a).Fingerprint(), a.Status, a.StartsAt.UnixNano()))
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	h.Write([]byte(status))
+	for _, k := range keys {
+		h.Write([]byte{0})
+		h.Write([]byte(k))
+	}
+	return h.Sum64()
+}
+
+// deduplicator remembers the groups delivered to chats to drop identical deliveries within a window
+type deduplicator struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[deliveryKey]time.Time
+}
+
+type deliveryKey struct {
+	chat  int64
+	group uint64
+}
+
+func newDeduplicator(window time.Duration) *deduplicator {
+	return &deduplicator{
+		window: window,
+		seen:   make(map[deliveryKey]time.Time),
+	}
+}
+
+// Duplicate returns whether the group was already delivered to the chat within the window
+// and records the delivery otherwise
+func (d *deduplicator) Duplicate(chat int64, group uint64, now time.Time) bool {
+	if d.window <= 0 {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for k, t := range d.seen {
+		if now.Sub(t) >= d.window {
+			delete(d.seen, k)
+		}
+	}
+
+	k := deliveryKey{chat: chat, group: group}
+	if _, ok := d.seen[k]; ok {
+		return true
+	}
+	d.seen[k] = now
+	return false
+}
@@ -0,0 +1,32 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeduplicator(t *testing.T) {
+	alerts := template.Alerts{
+		{Labels: template.KV{"alertname": "HighCPU"}, Status: "firing"},
+		{Labels: template.KV{"alertname": "DiskFull"}, Status: "firing"},
+	}
+	reversed := template.Alerts{alerts[1], alerts[0]}
+
+	group := groupFingerprint("firing", alerts)
+	assert.Equal(t, group, groupFingerprint("firing", reversed))
+	assert.NotEqual(t, group, groupFingerprint("resolved", alerts))
+
+	now := time.Now()
+	d := newDeduplicator(time.Minute)
+	assert.False(t, d.Duplicate(1, group, now))
+	assert.True(t, d.Duplicate(1, group, now.Add(30*time.Second)))
+	assert.False(t, d.Duplicate(2, group, now.Add(30*time.Second)))
+	assert.False(t, d.Duplicate(1, group, now.Add(time.Minute)))
+
+	disabled := newDeduplicator(0)
+	assert.False(t, disabled.Duplicate(1, group, now))
+	assert.False(t, disabled.Duplicate(1, group, now))
+}
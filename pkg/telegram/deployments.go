@@ -0,0 +1,51 @@
+package telegram
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/common/model"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/deploy"
+)
+
+// deploymentNote describes the deployment as line appended to an alert's message of the parse mode,
+// e.g. "🚀 Deployed 12m ago by @dev: v1.2.0"
+func deploymentNote(d deploy.Deployment, mode telebot.ParseMode, now time.Time) string {
+	text := fmt.Sprintf("Deployed %s ago", model.Duration(now.Sub(d.CreatedAt).Round(time.Minute)))
+	if d.CreatedAt.After(now.Add(-time.Minute)) {
+		text = "Deployed just now"
+	}
+	if d.Creator != "" {
+		text += " by @" + d.Creator
+	}
+	if d.Ref != "" {
+		text += ": " + d.Ref
+	}
+	return "\n\n🚀 " + escapeText(text, mode)
+}
+
+// alertDeployment returns the note of the latest deployment of the alert's service within the deployment window,
+// empty without deployments, a repository of the service or a recent deployment
+func (b *Bot) alertDeployment(alert template.Alert, mode telebot.ParseMode) string {
+	if b.deploys == nil {
+		return ""
+	}
+	repository, ok := b.deployRepositories[alert.Labels[b.deployLabel]]
+	if !ok {
+		return ""
+	}
+
+	d, err := b.deploys.Latest(repository, b.deployEnvironment)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to look up deployments of alert", "repository", repository, "err", err)
+		return ""
+	}
+	now := time.Now()
+	if d == nil || now.Sub(d.CreatedAt) > b.deployWindow {
+		return ""
+	}
+	return deploymentNote(*d, mode, now)
+}
@@ -0,0 +1,42 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/deploy"
+)
+
+type fakeDeploySource map[string]*deploy.Deployment
+
+func (s fakeDeploySource) Latest(repository, environment string) (*deploy.Deployment, error) {
+	return s[repository], nil
+}
+
+func TestDeploymentNote(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	d := deploy.Deployment{Ref: "v1.2.0", Creator: "dev_ops", CreatedAt: now.Add(-12*time.Minute - 10*time.Second)}
+
+	assert.Equal(t, "\n\n🚀 Deployed 12m ago by @dev_ops: v1.2.0", deploymentNote(d, telebot.ModeHTML, now))
+	assert.Equal(t, "\n\n🚀 Deployed 12m ago by @dev\\_ops: v1.2.0", deploymentNote(d, telebot.ModeMarkdown, now))
+	assert.Equal(t, "\n\n🚀 Deployed just now", deploymentNote(deploy.Deployment{CreatedAt: now}, "", now))
+}
+
+func TestAlertDeployment(t *testing.T) {
+	b := &Bot{logger: log.NewNopLogger()}
+	alert := template.Alert{Labels: template.KV{"alertname": "HighErrorRate", "service": "shop"}}
+	assert.Equal(t, "", b.alertDeployment(alert, ""), "disabled without a source")
+
+	WithDeployments(fakeDeploySource{
+		"acme/shop": {Ref: "v1.2.0", Creator: "dev", CreatedAt: time.Now().Add(-12 * time.Minute)},
+		"acme/cart": {Ref: "v0.9.0", Creator: "dev", CreatedAt: time.Now().Add(-3 * time.Hour)},
+	}, "service", map[string]string{"shop": "acme/shop", "cart": "acme/cart"}, "", time.Hour)(b)
+
+	assert.Equal(t, "\n\n🚀 Deployed 12m ago by @dev: v1.2.0", b.alertDeployment(alert, ""))
+	assert.Equal(t, "", b.alertDeployment(template.Alert{Labels: template.KV{"service": "cart"}}, ""), "deployments before the window are ignored")
+	assert.Equal(t, "", b.alertDeployment(template.Alert{Labels: template.KV{"service": "billing"}}, ""), "services without repository are ignored")
+}
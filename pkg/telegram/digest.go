@@ -0,0 +1,179 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/hako/durafmt"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/common/model"
+	"github.com/tucnak/telebot"
+)
+
+const commandDigest = "/digest"
+
+// DigestSettings configure which alerts of a chat are only delivered in a periodic digest
+type DigestSettings struct {
+	// Matchers of the alerts that are digest-only
+	Matchers Matchers `json:"matchers"`
+	// Interval in which the digest is posted
+	Interval time.Duration `json:"interval"`
+}
+
+// splitDigest separates the firing alerts matching the digest matchers from the ones delivered immediately,
+// resolved alerts are always delivered to resolve their messages
+func splitDigest(d *DigestSettings, alerts template.Alerts) (deliver template.Alerts, digest template.Alerts) {
+	if d == nil {
+		return alerts, nil
+	}
+
+	for _, a := range alerts {
+		if a.Status != string(model.AlertResolved) && d.Matchers.Match(a) {
+			digest = append(digest, a)
+		} else {
+			deliver = append(deliver, a)
+		}
+	}
+	return deliver, digest
+}
+
+// digestMessage summarizes alerts by alertname and status
+func digestMessage(alerts template.Alerts, since time.Time) string {
+	type summary struct {
+		alertname string
+		firing    int
+		resolved  int
+	}
+
+	summaries := make(map[string]*summary)
+	for _, a := range alerts {
+		name := a.Labels["alertname"]
+		s, ok := summaries[name]
+		if !ok {
+			s = &summary{alertname: name}
+			summaries[name] = s
+		}
+		if a.Status == string(model.AlertResolved) {
+			s.resolved++
+		} else {
+			s.firing++
+		}
+	}
+
+	names := make([]string, 0, len(summaries))
+	for name := range summaries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>Digest of %d alerts in the last %s</b>\n", len(alerts), durafmt.Parse(time.Since(since).Round(time.Minute)))
+	for _, name := range names {
+		s := summaries[name]
+		fmt.Fprintf(&b, "<b>%s</b>", html.EscapeString(s.alertname))
+		if s.firing > 0 {
+			fmt.Fprintf(&b, " 🔥 %d firing", s.firing)
+		}
+		if s.resolved > 0 {
+			fmt.Fprintf(&b, " ✅ %d resolved", s.resolved)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// flushDigests periodically posts the digest of chats whose digest interval passed
+func (b *Bot) flushDigests(ctx context.Context) error {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			for _, chat := range b.digests.Chats() {
+				settings, err := b.settings.Get(chat)
+				if err != nil {
+					b.reportError("failed to get chat settings from store", "chat_id", chat.ID, "err", err)
+					continue
+				}
+
+				since := b.digests.Since(chat)
+				if settings.Digest != nil && now.Sub(since) < settings.Digest.Interval {
+					continue
+				}
+
+				alerts := b.digests.Take(chat)
+				if len(alerts) == 0 {
+					continue
+				}
+
+				_, err = b.sender.SendMessage(chat, digestMessage(alerts, since), &telebot.SendOptions{
+					ParseMode: telebot.ModeHTML,
+				})
+				if err != nil {
+					b.reportError("failed to send digest", "chat_id", chat.ID, "err", err)
+				}
+			}
+		}
+	}
+}
+
+func (b *Bot) handleDigest(message telebot.Message) {
+	// Right format: '/digest interval matchers...' or '/digest off'.
+	// Ex: /digest 1h severity=~"info|warning"
+	params := strings.Fields(message.Text)[1:]
+
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't get the digest of this chat.", nil)
+		return
+	}
+
+	if len(params) == 0 {
+		if settings.Digest == nil {
+			b.reply(message, "This chat has no digest.", nil)
+			return
+		}
+		b.reply(message, fmt.Sprintf(
+			"Alerts matching %s are sent as digest every %s.",
+			settings.Digest.Matchers, durafmt.Parse(settings.Digest.Interval),
+		), nil)
+		return
+	}
+
+	if params[0] == quietOff {
+		settings.Digest = nil
+	} else {
+		interval, err := time.ParseDuration(params[0])
+		if err != nil || interval < time.Minute || len(params) < 2 {
+			b.reply(message, "Please send right format: '/digest interval matchers' or '/digest off'. Ex: /digest 1h severity=~\"info|warning\"", nil)
+			return
+		}
+
+		args := strings.TrimPrefix(message.Text, strings.Fields(message.Text)[0])
+		matchers, err := parseMatchers(strings.TrimPrefix(strings.TrimSpace(args), params[0]))
+		if err != nil {
+			b.reply(message, fmt.Sprintf("Please send valid matchers. %v", err), nil)
+			return
+		}
+
+		settings.Digest = &DigestSettings{Matchers: matchers, Interval: interval}
+	}
+
+	if err := b.settings.Set(message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.reply(message, "I can't save the digest of this chat.", nil)
+		return
+	}
+
+	b.reply(message, responseMember, nil)
+}
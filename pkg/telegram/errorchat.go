@@ -0,0 +1,78 @@
+package telegram
+
+import (
+	"fmt"
+	"hash/fnv"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+// errorsWindow in which the same failure is posted to the errors chat only once
+const errorsWindow = 10 * time.Minute
+
+// reportError logs an operational failure that may hide alerts and posts it to the errors chat and Sentry,
+// so that it doesn't go unnoticed in the logs. The keyvals are logged like go-kit's.
+func (b *Bot) reportError(msg string, keyvals ...interface{}) {
+	level.Error(b.logger).Log(append([]interface{}{"msg", msg}, keyvals...)...)
+
+	err, tags := sentryContext(keyvals...)
+	b.sentry.CaptureError(msg, err, tags, nil)
+
+	if b.errorsChat == 0 {
+		return
+	}
+
+	text := errorMessage(msg, keyvals...)
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	if b.errorNotices.Duplicate(b.errorsChat, h.Sum64(), time.Now()) {
+		return
+	}
+
+	if _, err := b.sender.SendMessage(telebot.Chat{ID: b.errorsChat}, text, nil); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send error to errors chat", "chat_id", b.errorsChat, "err", err)
+	}
+}
+
+// errorMessage formats a failure for the errors chat
+func errorMessage(msg string, keyvals ...interface{}) string {
+	var b strings.Builder
+	b.WriteString("⚠️ ")
+	b.WriteString(msg)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fmt.Fprintf(&b, "\n%v: %v", keyvals[i], keyvals[i+1])
+	}
+	return b.String()
+}
+
+// recoverPanic reports a panic of a command or webhook delivery instead of crashing the bot,
+// it has to be deferred directly
+func (b *Bot) recoverPanic(keyvals ...interface{}) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	stack := debug.Stack()
+	level.Error(b.logger).Log(append([]interface{}{"msg", "recovered from panic", "panic", r, "stack", string(stack)}, keyvals...)...)
+
+	_, tags := sentryContext(keyvals...)
+	b.sentry.CapturePanic(r, stack, tags)
+}
+
+// sentryContext splits keyvals into the error and the tags of a Sentry event
+func sentryContext(keyvals ...interface{}) (err error, tags map[string]string) {
+	tags = make(map[string]string)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if e, ok := keyvals[i+1].(error); ok && keyvals[i] == "err" {
+			err = e
+			continue
+		}
+		tags[fmt.Sprint(keyvals[i])] = fmt.Sprint(keyvals[i+1])
+	}
+	return err, tags
+}
@@ -0,0 +1,23 @@
+package telegram
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorMessage(t *testing.T) {
+	assert.Equal(t,
+		"⚠️ failed to send alert\nchat_id: -100\nalertname: HighCPU\nerr: Too Many Requests",
+		errorMessage("failed to send alert", "chat_id", int64(-100), "alertname", "HighCPU", "err", errors.New("Too Many Requests")),
+	)
+	assert.Equal(t, "⚠️ dropping alerts without alertname", errorMessage("dropping alerts without alertname"))
+}
+
+func TestSentryContext(t *testing.T) {
+	failure := errors.New("Too Many Requests")
+	err, tags := sentryContext("chat_id", int64(-100), "alertname", "HighCPU", "err", failure)
+	assert.Equal(t, failure, err)
+	assert.Equal(t, map[string]string{"chat_id": "-100", "alertname": "HighCPU"}, tags)
+}
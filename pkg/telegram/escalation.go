@@ -0,0 +1,118 @@
+package telegram
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// minEscalationInterval is the shortest time between two auto forwards of an alert
+const minEscalationInterval = 5 * time.Second
+
+// escalation is the next auto forward of an alert
+type escalation struct {
+	at    time.Time
+	alert *HandleAlert
+}
+
+// escalationQueue is a min-heap of escalations ordered by their time
+type escalationQueue []escalation
+
+func (q escalationQueue) Len() int            { return len(q) }
+func (q escalationQueue) Less(i, j int) bool  { return q[i].at.Before(q[j].at) }
+func (q escalationQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *escalationQueue) Push(x interface{}) { *q = append(*q, x.(escalation)) }
+func (q *escalationQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// escalations schedule the auto forwards of all alerts in a single goroutine,
+// instead of one goroutine per alert polling until it is acknowledged
+type escalations struct {
+	mu    sync.Mutex
+	queue escalationQueue
+	// wake interrupts the wait of Run for an escalation due earlier
+	wake chan struct{}
+}
+
+func newEscalations() *escalations {
+	return &escalations{wake: make(chan struct{}, 1)}
+}
+
+// Schedule the next auto forward of the alert
+func (e *escalations) Schedule(a *HandleAlert, at time.Time) {
+	e.mu.Lock()
+	heap.Push(&e.queue, escalation{at: at, alert: a})
+	e.mu.Unlock()
+
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Len returns the number of scheduled escalations
+func (e *escalations) Len() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.queue.Len()
+}
+
+// due removes the alerts due at the time and returns them with the time the next one is due,
+// which is zero if none is scheduled
+func (e *escalations) due(now time.Time) ([]*HandleAlert, time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var alerts []*HandleAlert
+	for e.queue.Len() > 0 && !e.queue[0].at.After(now) {
+		alerts = append(alerts, heap.Pop(&e.queue).(escalation).alert)
+	}
+	if e.queue.Len() == 0 {
+		return alerts, time.Time{}
+	}
+	return alerts, e.queue[0].at
+}
+
+// Run calls forward for the alerts when they are due until the context is canceled,
+// forward returns when the alert is due next, zero once its escalation stopped
+func (e *escalations) Run(ctx context.Context, forward func(a *HandleAlert, now time.Time) time.Time) error {
+	for {
+		now := time.Now()
+		alerts, next := e.due(now)
+		for _, a := range alerts {
+			at := forward(a, now)
+			if at.IsZero() {
+				continue
+			}
+			if min := now.Add(minEscalationInterval); at.Before(min) {
+				at = min
+			}
+			e.Schedule(a, at)
+		}
+		if len(alerts) > 0 {
+			continue
+		}
+
+		// Without scheduled escalations only a new one wakes Run
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(next.Sub(now))
+			fire = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-e.wake:
+		case <-fire:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
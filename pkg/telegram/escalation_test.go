@@ -0,0 +1,65 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscalations(t *testing.T) {
+	e := newEscalations()
+	now := time.Now()
+	late := &HandleAlert{ID: "late"}
+	early := &HandleAlert{ID: "early"}
+	later := &HandleAlert{ID: "later"}
+	e.Schedule(late, now.Add(time.Hour))
+	e.Schedule(early, now.Add(-time.Minute))
+	e.Schedule(later, now.Add(2*time.Hour))
+
+	alerts, next := e.due(now)
+	assert.Equal(t, []*HandleAlert{early}, alerts)
+	assert.Equal(t, now.Add(time.Hour), next)
+	assert.Equal(t, 2, e.Len())
+
+	alerts, next = e.due(now.Add(3 * time.Hour))
+	assert.Equal(t, []*HandleAlert{late, later}, alerts)
+	assert.True(t, next.IsZero())
+}
+
+func TestEscalationsRun(t *testing.T) {
+	e := newEscalations()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var forwarded []string
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx, func(a *HandleAlert, now time.Time) time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			forwarded = append(forwarded, a.ID)
+			if len(forwarded) == 2 {
+				close(done)
+			}
+			// Stopped escalations aren't scheduled again
+			return time.Time{}
+		})
+	}()
+
+	e.Schedule(&HandleAlert{ID: "second"}, time.Now().Add(50*time.Millisecond))
+	e.Schedule(&HandleAlert{ID: "first"}, time.Now())
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the escalations weren't run")
+	}
+	mu.Lock()
+	assert.Equal(t, []string{"first", "second"}, forwarded)
+	mu.Unlock()
+	assert.Equal(t, 0, e.Len())
+}
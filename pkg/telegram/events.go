@@ -0,0 +1,195 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandDebug = "/debug"
+
+	debugOn  = "on"
+	debugOff = "off"
+
+	// debugDuration after which a debug stream ends by itself
+	debugDuration = 15 * time.Minute
+	// debugBuffer is the most events waiting to be streamed, more are dropped
+	debugBuffer = 100
+)
+
+// Types of the events of the webhook → escalation → callback flow
+const (
+	eventWebhook       = "webhook"
+	eventFired         = "fired"
+	eventRefired       = "refired"
+	eventAcknowledged  = "acknowledged"
+	eventForwarded     = "forwarded"
+	eventAutoForwarded = "autoforwarded"
+	eventExhausted     = "exhausted"
+	eventResolved      = "resolved"
+	eventTicket        = "ticket"
+	eventRemediation   = "remediation"
+	eventCallback      = "callback"
+)
+
+// Event happened while delivering and escalating alerts
+type Event struct {
+	Time    time.Time
+	Type    string
+	ChatID  int64
+	AlertID string
+	// User is the username of the member acting on the alert, if any
+	User string
+	// Labels of the alert, if any
+	Labels map[string]string
+	Detail string
+}
+
+func (e Event) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s", e.Time.UTC().Format("15:04:05"), e.Type)
+	if e.AlertID != "" {
+		fmt.Fprintf(&b, " %s", e.AlertID)
+	}
+	if e.ChatID != 0 {
+		fmt.Fprintf(&b, " in %d", e.ChatID)
+	}
+	if e.Detail != "" {
+		fmt.Fprintf(&b, ": %s", e.Detail)
+	}
+	return b.String()
+}
+
+// eventBus passes the published events to all subscribers
+type eventBus struct {
+	mu          sync.RWMutex
+	next        int
+	subscribers map[int]func(Event)
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[int]func(Event))}
+}
+
+// Subscribe calls fn with every published event until unsubscribed.
+// fn is called by the publisher and mustn't block.
+func (b *eventBus) Subscribe(fn func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	b.next++
+	b.subscribers[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// Publish the event to all subscribers, a nil bus drops it
+func (b *eventBus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, fn := range b.subscribers {
+		fn(e)
+	}
+}
+
+// debugTap streams the events to an admin's private chat
+type debugTap struct {
+	unsubscribe func()
+	stop        chan struct{}
+}
+
+// startDebug streams the events to the admin until stopped or the debugDuration passed
+func (b *Bot) startDebug(admin int) {
+	b.debugMu.Lock()
+	defer b.debugMu.Unlock()
+
+	if _, ok := b.debugTaps[admin]; ok {
+		return
+	}
+
+	events := make(chan Event, debugBuffer)
+	tap := &debugTap{stop: make(chan struct{})}
+	tap.unsubscribe = b.events.Subscribe(func(e Event) {
+		select {
+		case events <- e:
+		default:
+		}
+	})
+	b.debugTaps[admin] = tap
+
+	go func() {
+		timeout := time.NewTimer(debugDuration)
+		defer timeout.Stop()
+
+		for {
+			select {
+			case e := <-events:
+				if _, err := b.sender.SendMessage(telebot.User{ID: admin}, e.String(), nil); err != nil {
+					level.Warn(b.logger).Log("msg", "failed to send debug event", "user_id", admin, "err", err)
+				}
+			case <-timeout.C:
+				b.stopDebug(admin, tap)
+				b.SendAdminMessage(admin, fmt.Sprintf("The debug stream ended after %s.", debugDuration))
+				return
+			case <-tap.stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopDebug ends the admin's debug stream, if given only the tap, and returns whether there was one
+func (b *Bot) stopDebug(admin int, only *debugTap) bool {
+	b.debugMu.Lock()
+	defer b.debugMu.Unlock()
+
+	tap, ok := b.debugTaps[admin]
+	if !ok || (only != nil && tap != only) {
+		return false
+	}
+	tap.unsubscribe()
+	close(tap.stop)
+	delete(b.debugTaps, admin)
+	return true
+}
+
+func (b *Bot) handleDebug(message telebot.Message) {
+	// Right format: '/debug on|off'.
+	// Ex: /debug on
+	params := strings.Fields(message.Text)[1:]
+	if len(params) != 1 || (params[0] != debugOn && params[0] != debugOff) {
+		b.reply(message, "Please send right format: '/debug on|off'. Ex: /debug on", nil)
+		return
+	}
+
+	if params[0] == debugOff {
+		if !b.stopDebug(message.Sender.ID, nil) {
+			b.reply(message, "There is no debug stream to stop.", nil)
+			return
+		}
+		b.reply(message, responseMember, nil)
+		return
+	}
+
+	b.startDebug(message.Sender.ID)
+	b.SendAdminMessage(message.Sender.ID, fmt.Sprintf("Streaming the webhook, escalation and callback events here for %s, stop with '/debug off'.", debugDuration))
+	level.Info(b.logger).Log("msg", "debug stream started", "sender_id", message.Sender.ID, "sender_username", message.Sender.Username)
+}
@@ -0,0 +1,39 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := newEventBus()
+
+	var first, second []Event
+	unsubscribe := bus.Subscribe(func(e Event) { first = append(first, e) })
+	bus.Subscribe(func(e Event) { second = append(second, e) })
+
+	bus.Publish(Event{Type: eventFired, ChatID: -100, AlertID: "HighCPU"})
+	unsubscribe()
+	bus.Publish(Event{Type: eventAcknowledged, ChatID: -100, AlertID: "HighCPU"})
+
+	assert.Len(t, first, 1)
+	assert.Len(t, second, 2)
+	assert.False(t, second[0].Time.IsZero(), "the publish time is set")
+
+	var disabled *eventBus
+	disabled.Publish(Event{Type: eventFired})
+}
+
+func TestEventString(t *testing.T) {
+	at := time.Date(2020, 1, 1, 9, 30, 0, 0, time.UTC)
+	assert.Equal(t,
+		"09:30:00 forwarded HighCPU in -100: by @vu_long to level 2",
+		Event{Time: at, Type: eventForwarded, ChatID: -100, AlertID: "HighCPU", Detail: "by @vu_long to level 2"}.String(),
+	)
+	assert.Equal(t,
+		"09:30:00 webhook: receiver telegram, status firing, 2 alerts",
+		Event{Time: at, Type: eventWebhook, Detail: "receiver telegram, status firing, 2 alerts"}.String(),
+	)
+}
@@ -0,0 +1,47 @@
+package telegram
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultDeliveryWorkers deliver the alerts of a webhook to this many chats at once
+const defaultDeliveryWorkers = 8
+
+// newDeliveryLatency returns the histogram of the time delivering a webhook to a chat takes
+func newDeliveryLatency() *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "alertmanagerbot",
+		Name:      "chat_delivery_duration_seconds",
+		Help:      "Latency of delivering the alerts of a webhook to a chat by status, including the wait for the send rate limit",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"status"})
+}
+
+// fanOut calls deliver for every target with at most workers of them running at once
+func fanOut(targets []*routedAlerts, workers int, deliver func(*routedAlerts)) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(targets) {
+		workers = len(targets)
+	}
+
+	queue := make(chan *routedAlerts)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for target := range queue {
+				deliver(target)
+			}
+		}()
+	}
+	for _, target := range targets {
+		queue <- target
+	}
+	close(queue)
+	wg.Wait()
+}
@@ -0,0 +1,42 @@
+package telegram
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestFanOut(t *testing.T) {
+	var targets []*routedAlerts
+	for i := 0; i < 20; i++ {
+		targets = append(targets, &routedAlerts{chat: telebot.Chat{ID: int64(i)}})
+	}
+
+	var mu sync.Mutex
+	delivered := make(map[int64]bool)
+	running, maxRunning := 0, 0
+	fanOut(targets, 4, func(target *routedAlerts) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		delivered[target.chat.ID] = true
+		mu.Unlock()
+	})
+
+	assert.Len(t, delivered, 20)
+	assert.True(t, maxRunning <= 4, "at most 4 chats are delivered to at once, got %d", maxRunning)
+
+	// No targets don't block
+	fanOut(nil, 4, func(*routedAlerts) { t.Fatal("no target to deliver") })
+}
@@ -0,0 +1,242 @@
+package telegram
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/common/model"
+)
+
+// MatchType is the operator of a matcher, the same as in PromQL label matchers
+type MatchType string
+
+// The supported match types
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// annotationPrefix makes a matcher match an annotation instead of a label,
+// like annotations.summary=~".*disk.*"
+const annotationPrefix = "annotations."
+
+// Matcher matches a label or annotation of an alert
+type Matcher struct {
+	Type  MatchType `json:"type"`
+	Name  string    `json:"name"`
+	Value string    `json:"value"`
+
+	// IsRegex is only read to support matchers stored before match types existed
+	IsRegex bool `json:"isRegex,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Init validates the matcher and compiles its regular expression. Must be called before Match.
+func (m *Matcher) Init() error {
+	if m.Type == "" {
+		m.Type = MatchEqual
+		if m.IsRegex {
+			m.Type = MatchRegexp
+		}
+		m.IsRegex = false
+	}
+
+	name := strings.TrimPrefix(m.Name, annotationPrefix)
+	if !model.LabelName(name).IsValid() {
+		return fmt.Errorf("invalid name %q", m.Name)
+	}
+
+	switch m.Type {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + m.Value + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regular expression %q", m.Value)
+		}
+		m.re = re
+	default:
+		return fmt.Errorf("invalid match type %q", m.Type)
+	}
+
+	return nil
+}
+
+// Match returns whether the alert matches. Unset labels and annotations match the empty string.
+func (m *Matcher) Match(a template.Alert) bool {
+	var v string
+	if strings.HasPrefix(m.Name, annotationPrefix) {
+		v = a.Annotations[strings.TrimPrefix(m.Name, annotationPrefix)]
+	} else {
+		v = a.Labels[m.Name]
+	}
+
+	switch m.Type {
+	case MatchEqual:
+		return v == m.Value
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(v)
+	case MatchNotRegexp:
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+func (m *Matcher) String() string {
+	return fmt.Sprintf("%s%s%q", m.Name, m.Type, m.Value)
+}
+
+// Matchers is a list of matchers that all have to match
+type Matchers []*Matcher
+
+// Match returns whether all matchers match the alert
+func (ms Matchers) Match(a template.Alert) bool {
+	for _, m := range ms {
+		if !m.Match(a) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ms Matchers) String() string {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for i, m := range ms {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(m.String())
+	}
+	buf.WriteByte('}')
+
+	return buf.String()
+}
+
+// parseMatchers parses PromQL style matchers like {team="db", severity=~"critical|page", instance!~"test-.*"}.
+// The braces, commas and quotes around values without whitespace or commas are optional.
+func parseMatchers(s string) (Matchers, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "{") {
+		if !strings.HasSuffix(s, "}") {
+			return nil, fmt.Errorf("missing closing brace")
+		}
+		s = s[1 : len(s)-1]
+	}
+
+	var matchers Matchers
+	for {
+		s = strings.TrimLeftFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == ',' })
+		if s == "" {
+			break
+		}
+
+		m, rest, err := parseMatcher(s)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+		s = rest
+	}
+
+	sort.Slice(matchers, func(i, j int) bool { return matchers[i].Name < matchers[j].Name })
+
+	return matchers, nil
+}
+
+// parseMatcher parses the first matcher of s and returns the remaining input
+func parseMatcher(s string) (*Matcher, string, error) {
+	i := strings.IndexAny(s, "=!")
+	if i <= 0 {
+		return nil, "", fmt.Errorf("invalid matcher %q, expected label=value, label!=value, label=~regex or label!~regex", s)
+	}
+
+	m := &Matcher{Name: strings.TrimSpace(s[:i])}
+	s = s[i:]
+
+	switch {
+	case strings.HasPrefix(s, string(MatchRegexp)):
+		m.Type = MatchRegexp
+	case strings.HasPrefix(s, string(MatchNotRegexp)):
+		m.Type = MatchNotRegexp
+	case strings.HasPrefix(s, string(MatchNotEqual)):
+		m.Type = MatchNotEqual
+	case strings.HasPrefix(s, string(MatchEqual)):
+		m.Type = MatchEqual
+	default:
+		return nil, "", fmt.Errorf("invalid operator in matcher for %q", m.Name)
+	}
+	s = s[len(m.Type):]
+
+	var err error
+	if strings.HasPrefix(s, `"`) {
+		m.Value, s, err = parseQuoted(s)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid quoted value for %q: %v", m.Name, err)
+		}
+	} else {
+		end := strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == ',' })
+		if end < 0 {
+			end = len(s)
+		}
+		m.Value, s = s[:end], s[end:]
+	}
+
+	if err := m.Init(); err != nil {
+		return nil, "", err
+	}
+
+	return m, s, nil
+}
+
+// parseQuoted reads a double quoted string with Go escapes from the start of s
+func parseQuoted(s string) (string, string, error) {
+	escaped := false
+	for i := 1; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			v, err := strconv.Unquote(s[:i+1])
+			return v, s[i+1:], err
+		}
+	}
+	return "", "", fmt.Errorf("missing closing quote")
+}
+
+// filterAlerts returns the alerts matching all of the matchers
+func filterAlerts(matchers Matchers, alerts template.Alerts) template.Alerts {
+	if len(matchers) == 0 {
+		return alerts
+	}
+
+	var filtered template.Alerts
+	for _, a := range alerts {
+		if matchers.Match(a) {
+			filtered = append(filtered, a)
+		}
+	}
+
+	return filtered
+}
+
+func alertLabelSet(a template.Alert) model.LabelSet {
+	lset := make(model.LabelSet, len(a.Labels))
+	for k, v := range a.Labels {
+		lset[model.LabelName(k)] = model.LabelValue(v)
+	}
+	return lset
+}
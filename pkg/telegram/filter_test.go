@@ -0,0 +1,55 @@
+package telegram
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMatchers(t *testing.T) {
+	matchers, err := parseMatchers(`team=db severity=~"critical|warning" instance!~"test-.*"`)
+	assert.NoError(t, err)
+	assert.Len(t, matchers, 3)
+	assert.Equal(t, `{instance!~"test-.*", severity=~"critical|warning", team="db"}`, matchers.String())
+
+	matchers, err = parseMatchers(`{env!="staging", annotations.summary=~".*disk, full.*"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `{annotations.summary=~".*disk, full.*", env!="staging"}`, matchers.String())
+
+	for _, invalid := range []string{`team`, `severity=~(`, `team="db`, `{team=db`, `1team=db`} {
+		_, err = parseMatchers(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestMatcherLegacyJSON(t *testing.T) {
+	var matchers Matchers
+	assert.NoError(t, json.Unmarshal([]byte(`[{"name":"severity","value":"critical|page","isRegex":true}]`), &matchers))
+	assert.NoError(t, matchers[0].Init())
+	assert.Equal(t, MatchRegexp, matchers[0].Type)
+	assert.True(t, matchers.Match(template.Alert{Labels: template.KV{"severity": "page"}}))
+}
+
+func TestFilterAlerts(t *testing.T) {
+	alerts := template.Alerts{
+		{Labels: template.KV{"alertname": "HighCPU", "team": "db", "severity": "critical"}},
+		{Labels: template.KV{"alertname": "DiskFull", "team": "db", "severity": "info"}, Annotations: template.KV{"summary": "disk is full"}},
+		{Labels: template.KV{"alertname": "NodeDown", "team": "web", "severity": "warning", "instance": "test-1"}},
+	}
+
+	assert.Equal(t, alerts, filterAlerts(nil, alerts))
+
+	matchers, err := parseMatchers(`team=db severity=~"critical|warning"`)
+	assert.NoError(t, err)
+	filtered := filterAlerts(matchers, alerts)
+	assert.Len(t, filtered, 1)
+	assert.Equal(t, "HighCPU", filtered[0].Labels["alertname"])
+
+	matchers, err = parseMatchers(`instance!~"test-.*" annotations.summary!=""`)
+	assert.NoError(t, err)
+	filtered = filterAlerts(matchers, alerts)
+	assert.Len(t, filtered, 1)
+	assert.Equal(t, "DiskFull", filtered[0].Labels["alertname"])
+}
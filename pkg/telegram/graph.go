@@ -0,0 +1,118 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/prometheus"
+)
+
+const (
+	commandGraph = "/graph"
+
+	// defaultGraphRange is graphed by /graph without a range
+	defaultGraphRange = time.Hour
+	// maxCaptionLength is the longest photo caption Telegram accepts
+	maxCaptionLength = 1024
+)
+
+// graphQuery parses the params of /graph into the range, expression and Prometheus URL to query.
+// The expression can be an alert's generatorURL, which is queried if no Prometheus is configured.
+func graphQuery(params []string, prometheusURL string) (rng time.Duration, expr, queryURL string, err error) {
+	rng = defaultGraphRange
+	if len(params) > 1 {
+		if d, err := time.ParseDuration(params[0]); err == nil && d > 0 {
+			rng = d
+			params = params[1:]
+		}
+	}
+	expr = strings.Join(params, " ")
+	if expr == "" {
+		return 0, "", "", fmt.Errorf("missing expression")
+	}
+
+	queryURL = prometheusURL
+	if strings.HasPrefix(expr, "http://") || strings.HasPrefix(expr, "https://") {
+		var generatorURL string
+		expr, generatorURL, err = prometheus.ExprFromGeneratorURL(expr)
+		if err != nil {
+			return 0, "", "", err
+		}
+		if queryURL == "" {
+			queryURL = generatorURL
+		}
+	}
+	if queryURL == "" {
+		return 0, "", "", fmt.Errorf("no Prometheus is configured to query")
+	}
+	return rng, expr, queryURL, nil
+}
+
+// graphCaption describes the graphed expression with the bounds and a legend of the series
+func graphCaption(expr string, rng time.Duration, matrix model.Matrix) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\nLast %s", expr, rng)
+	if min, max, ok := prometheus.Bounds(matrix); ok {
+		fmt.Fprintf(&b, ", min %g, max %g", min, max)
+	}
+	for i, s := range matrix {
+		if i >= prometheus.MaxChartSeries {
+			fmt.Fprintf(&b, "\n%d more series not shown", len(matrix)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\n%s %s", prometheus.Markers[i], s.Metric)
+	}
+	return truncate(maxCaptionLength, b.String())
+}
+
+func (b *Bot) handleGraph(message telebot.Message) {
+	// Right format: '/graph [range] expression|generatorURL'.
+	// Ex: /graph 6h rate(http_requests_total{job="nginx"}[5m])
+	var prometheusURL string
+	if b.prometheus != nil {
+		prometheusURL = b.prometheus.String()
+	}
+	rng, expr, queryURL, err := graphQuery(strings.Fields(message.Text)[1:], prometheusURL)
+	if err != nil {
+		b.reply(message, fmt.Sprintf("Please send right format: '/graph [range] expression|generatorURL'. Ex: /graph 6h rate(http_requests_total[5m]) (%v)", err), nil)
+		return
+	}
+
+	end := time.Now()
+	start := end.Add(-rng)
+	// One point per two pixels is as detailed as the chart gets
+	step := rng / (prometheus.ChartWidth / 2)
+	if step < time.Second {
+		step = time.Second
+	}
+
+	matrix, err := prometheus.QueryRange(queryURL, expr, start, end, step)
+	if err != nil {
+		b.reply(message, fmt.Sprintf("failed to query Prometheus... %v", err), nil)
+		return
+	}
+	if len(matrix) == 0 {
+		b.reply(message, fmt.Sprintf("%s returned no data in the last %s.", expr, rng), nil)
+		return
+	}
+
+	chart, err := prometheus.RenderChart(matrix, model.TimeFromUnixNano(start.UnixNano()), model.TimeFromUnixNano(end.UnixNano()))
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to render chart", "err", err)
+		b.reply(message, "I can't render the chart.", nil)
+		return
+	}
+
+	replyTo := 0
+	if message.IsReply() {
+		replyTo = message.ID
+	}
+	photo := AlbumPhoto{Data: chart, Caption: graphCaption(expr, rng, matrix)}
+	if err := b.sender.SendAlbum(message.Chat, []AlbumPhoto{photo}, replyTo); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send chart", "chat_id", message.Chat.ID, "err", err)
+	}
+}
@@ -0,0 +1,35 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphQuery(t *testing.T) {
+	rng, expr, u, err := graphQuery([]string{"6h", "sum", "by", "(job)", "(up)"}, "http://prometheus:9090")
+	assert.NoError(t, err)
+	assert.Equal(t, 6*time.Hour, rng)
+	assert.Equal(t, "sum by (job) (up)", expr)
+	assert.Equal(t, "http://prometheus:9090", u)
+
+	rng, expr, u, err = graphQuery([]string{"http://prom:9090/graph?g0.expr=up+%3D%3D+0"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultGraphRange, rng)
+	assert.Equal(t, "up == 0", expr)
+	assert.Equal(t, "http://prom:9090", u, "the generatorURL's Prometheus is queried without a configured one")
+
+	_, _, _, err = graphQuery([]string{"up"}, "")
+	assert.Error(t, err)
+	_, _, _, err = graphQuery(nil, "http://prometheus:9090")
+	assert.Error(t, err)
+}
+
+func TestGraphCaption(t *testing.T) {
+	matrix := model.Matrix{
+		{Metric: model.Metric{"job": "nginx"}, Values: []model.SamplePair{{Value: 0.5}, {Value: 2}}},
+	}
+	assert.Equal(t, "up\nLast 1h0m0s, min 0.5, max 2\n🟥 {job=\"nginx\"}", graphCaption("up", time.Hour, matrix))
+}
@@ -0,0 +1,49 @@
+package telegram
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/tucnak/telebot"
+)
+
+// sortedMatchers formats the labels as equality matchers in the order of their names
+func sortedMatchers(labels template.KV) []string {
+	var matchers []string
+	for _, pair := range labels.SortedPairs() {
+		matchers = append(matchers, fmt.Sprintf("%s=%q", pair.Name, pair.Value))
+	}
+	return matchers
+}
+
+// alertmanagerFilterURL links the active alerts matching the labels in the UI of the Alertmanager at the external URL
+func alertmanagerFilterURL(externalURL string, labels template.KV) string {
+	filter := "{" + strings.Join(sortedMatchers(labels), ",") + "}"
+	return strings.TrimSuffix(externalURL, "/") + "/#/alerts?silenced=false&inhibited=false&active=true&filter=" + url.QueryEscape(filter)
+}
+
+// karmaFilterURL links the alerts matching the labels in the Karma dashboard at the URL
+func karmaFilterURL(karmaURL string, labels template.KV) string {
+	q := url.Values{"q": sortedMatchers(labels)}
+	return strings.TrimSuffix(karmaURL, "/") + "/?" + q.Encode()
+}
+
+// groupLink is the button linking the alert group of the webhook in Karma if configured, or else the Alertmanager UI.
+// Groups without labels are linked by the alertname of the first alert. It is nil if the link isn't a valid URL.
+func (b *Bot) groupLink(data *template.Data) *telebot.KeyboardButton {
+	labels := data.GroupLabels
+	if len(labels) == 0 && len(data.Alerts) > 0 {
+		labels = template.KV{"alertname": data.Alerts[0].Labels["alertname"]}
+	}
+
+	button := telebot.KeyboardButton{Text: "Alertmanager", URL: alertmanagerFilterURL(data.ExternalURL, labels)}
+	if b.karma != nil {
+		button = telebot.KeyboardButton{Text: "Karma", URL: karmaFilterURL(b.karma.String(), labels)}
+	}
+	if !validButtonURL(button.URL) {
+		return nil
+	}
+	return &button
+}
@@ -0,0 +1,49 @@
+package telegram
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestGroupLink(t *testing.T) {
+	labels := template.KV{"alertname": "HighCPU", "job": "node"}
+
+	assert.Equal(t,
+		"http://alertmanager:9093/#/alerts?silenced=false&inhibited=false&active=true&filter=%7Balertname%3D%22HighCPU%22%2Cjob%3D%22node%22%7D",
+		alertmanagerFilterURL("http://alertmanager:9093/", labels),
+	)
+	assert.Equal(t,
+		"https://karma.example.com/?q=alertname%3D%22HighCPU%22&q=job%3D%22node%22",
+		karmaFilterURL("https://karma.example.com", labels),
+	)
+
+	data := &template.Data{
+		ExternalURL: "http://alertmanager:9093",
+		Alerts:      template.Alerts{{Labels: template.KV{"alertname": "DiskFull", "instance": "web-1"}}},
+	}
+	b := &Bot{}
+	assert.Equal(t, &telebot.KeyboardButton{Text: "Alertmanager", URL: alertmanagerFilterURL(data.ExternalURL, template.KV{"alertname": "DiskFull"})}, b.groupLink(data),
+		"groups without labels are linked by the alertname")
+
+	data.GroupLabels = labels
+	b.karma, _ = url.Parse("https://karma.example.com")
+	assert.Equal(t, &telebot.KeyboardButton{Text: "Karma", URL: karmaFilterURL("https://karma.example.com", labels)}, b.groupLink(data))
+
+	assert.Nil(t, (&Bot{}).groupLink(&template.Data{}), "webhooks without external URL get no button")
+}
+
+func TestGroupLinkTemplateFuncs(t *testing.T) {
+	tmpl, err := LoadTemplates()
+	assert.NoError(t, err)
+
+	out, err := tmpl.execute(`{{ alertmanagerURL .ExternalURL .GroupLabels }} {{ karmaURL "https://karma" .GroupLabels }}`, false, &template.Data{
+		ExternalURL: "http://alertmanager:9093",
+		GroupLabels: template.KV{"alertname": "HighCPU"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://alertmanager:9093/#/alerts?silenced=false&inhibited=false&active=true&filter=%7Balertname%3D%22HighCPU%22%7D https://karma/?q=alertname%3D%22HighCPU%22", out)
+}
@@ -0,0 +1,221 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandHistory = "/history"
+
+	telegramHistoryDirectory = "telegram/history"
+
+	// Final statuses of delivered alerts
+	historyFiring   = "firing"
+	historyResolved = "resolved"
+
+	// defaultHistoryWindow is listed by /history without a window
+	defaultHistoryWindow = 24 * time.Hour
+	// historyBuffer is the most events waiting to be recorded, more are dropped
+	historyBuffer = 100
+)
+
+// HistoryEntry records an alert delivered to a chat
+type HistoryEntry struct {
+	AlertID        string    `json:"alertID"`
+	ChatID         int64     `json:"chatID"`
+	FiredAt        time.Time `json:"firedAt"`
+	Status         string    `json:"status"`
+	ResolvedAt     time.Time `json:"resolvedAt,omitempty"`
+	AcknowledgedBy string    `json:"acknowledgedBy,omitempty"`
+	AcknowledgedAt time.Time `json:"acknowledgedAt,omitempty"`
+}
+
+// String formats the entry as a line of /history
+func (e HistoryEntry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s [%s]", e.FiredAt.Format("2006-01-02 15:04:05"), e.AlertID, e.Status)
+	if e.AcknowledgedBy != "" {
+		fmt.Fprintf(&b, " acknowledged by @%s after %s", e.AcknowledgedBy, e.AcknowledgedAt.Sub(e.FiredAt).Round(time.Second))
+	}
+	return b.String()
+}
+
+// HistoryStore writes the history of delivered alerts to a libkv store backend
+type HistoryStore struct {
+	kv store.Store
+	// retention after which entries are removed, 0 keeps them
+	retention time.Duration
+}
+
+// NewHistoryStore stores the alert history in the provided kv backend, keeping the entries for the retention
+func NewHistoryStore(kv store.Store, retention time.Duration) (*HistoryStore, error) {
+	return &HistoryStore{kv: kv, retention: retention}, nil
+}
+
+// List the alerts delivered to the chat since the time from the kv backend, newest first
+func (s *HistoryStore) List(chatID int64, since time.Time) ([]HistoryEntry, error) {
+	entries, err := s.list(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	var recent []HistoryEntry
+	for _, e := range entries {
+		if !e.FiredAt.Before(since) {
+			recent = append(recent, e)
+		}
+	}
+	return recent, nil
+}
+
+func (s *HistoryStore) list(chatID int64) ([]HistoryEntry, error) {
+	kvPairs, err := s.kv.List(fmt.Sprintf("%s/%d", telegramHistoryDirectory, chatID))
+	if err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+
+	var entries []HistoryEntry
+	for _, kv := range kvPairs {
+		var e HistoryEntry
+		if err := json.Unmarshal(kv.Value, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].FiredAt.After(entries[j].FiredAt)
+	})
+
+	return entries, nil
+}
+
+// Record an escalation event in the history of its chat.
+// Fired alerts add an entry, acknowledging and resolving update the newest entry of the alert.
+func (s *HistoryStore) Record(e Event) error {
+	switch e.Type {
+	case eventFired:
+		if err := s.put(HistoryEntry{AlertID: e.AlertID, ChatID: e.ChatID, FiredAt: e.Time, Status: historyFiring}); err != nil {
+			return err
+		}
+		return s.prune(e.ChatID, e.Time)
+	case eventAcknowledged, eventResolved:
+	default:
+		return nil
+	}
+
+	entries, err := s.list(e.ChatID)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.AlertID != e.AlertID {
+			continue
+		}
+		if e.Type == eventResolved {
+			entry.Status = historyResolved
+			entry.ResolvedAt = e.Time
+		} else if entry.AcknowledgedBy == "" {
+			entry.AcknowledgedBy = e.User
+			entry.AcknowledgedAt = e.Time
+		}
+		return s.put(entry)
+	}
+	return nil
+}
+
+func (s *HistoryStore) put(e HistoryEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.kv.Put(historyKey(e), b, nil)
+}
+
+// prune removes the entries of the chat older than the retention
+func (s *HistoryStore) prune(chatID int64, now time.Time) error {
+	if s.retention <= 0 {
+		return nil
+	}
+
+	entries, err := s.list(chatID)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if now.Sub(e.FiredAt) <= s.retention {
+			continue
+		}
+		if err := s.kv.Delete(historyKey(e)); err != nil && err != store.ErrKeyNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// historyKey orders the entries of a chat by the time they fired
+func historyKey(e HistoryEntry) string {
+	return fmt.Sprintf("%s/%d/%020d-%s", telegramHistoryDirectory, e.ChatID, e.FiredAt.UnixNano(), e.AlertID)
+}
+
+// recordHistory writes the escalation events to the history store until the context is done
+func (b *Bot) recordHistory(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-b.historyEvents:
+			if err := b.history.Record(e); err != nil {
+				level.Warn(b.logger).Log("msg", "failed to record alert history", "chat_id", e.ChatID, "alert_id", e.AlertID, "err", err)
+			}
+		}
+	}
+}
+
+func (b *Bot) handleHistory(message telebot.Message) {
+	// Right format: '/history [window]'.
+	// Ex: /history 24h
+	params := strings.Fields(message.Text)[1:]
+
+	if b.history == nil {
+		b.reply(message, "The alert history is not enabled.", nil)
+		return
+	}
+
+	window := defaultHistoryWindow
+	if len(params) > 0 {
+		d, err := time.ParseDuration(params[0])
+		if err != nil || d <= 0 || len(params) > 1 {
+			b.reply(message, "Please send right format: '/history [window]'. Ex: /history 24h", nil)
+			return
+		}
+		window = d
+	}
+
+	entries, err := b.history.List(message.Chat.ID, time.Now().Add(-window))
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to list alert history from store", "err", err)
+		b.reply(message, "I can't get the alert history.", nil)
+		return
+	}
+	if len(entries) == 0 {
+		b.reply(message, fmt.Sprintf("No alerts were delivered to this chat in the last %s.", window), nil)
+		return
+	}
+
+	var list []string
+	for _, e := range entries {
+		list = append(list, e.String())
+	}
+
+	b.sendPages(message, paginate(fmt.Sprintf("Alerts delivered in the last %s:\n", window), list, itemsPerPage), "")
+}
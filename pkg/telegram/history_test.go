@@ -0,0 +1,63 @@
+package telegram
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryEntry(t *testing.T) {
+	at := time.Date(2026, 10, 15, 9, 30, 12, 0, time.UTC)
+	e := HistoryEntry{AlertID: "HighCPU", ChatID: -100, FiredAt: at, Status: historyFiring}
+	assert.Equal(t, "2026-10-15 09:30:12 HighCPU [firing]", e.String())
+
+	e.Status = historyResolved
+	e.AcknowledgedBy = "vu_long"
+	e.AcknowledgedAt = at.Add(161 * time.Second)
+	assert.Equal(t, "2026-10-15 09:30:12 HighCPU [resolved] acknowledged by @vu_long after 2m41s", e.String())
+}
+
+func TestHistoryStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	s, err := NewHistoryStore(kv, 48*time.Hour)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	entries, err := s.List(-100, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	record := func(e Event) { assert.NoError(t, s.Record(e)) }
+	record(Event{Time: now.Add(-72 * time.Hour), Type: eventFired, ChatID: -100, AlertID: "Expired"})
+	record(Event{Time: now.Add(-30 * time.Hour), Type: eventFired, ChatID: -100, AlertID: "HighCPU"})
+	record(Event{Time: now.Add(-time.Hour), Type: eventFired, ChatID: -100, AlertID: "HighCPU"})
+	record(Event{Time: now.Add(-50 * time.Minute), Type: eventAcknowledged, ChatID: -100, AlertID: "HighCPU", User: "vu_long"})
+	record(Event{Time: now.Add(-45 * time.Minute), Type: eventAcknowledged, ChatID: -100, AlertID: "HighCPU", User: "techleader"})
+	record(Event{Time: now.Add(-40 * time.Minute), Type: eventResolved, ChatID: -100, AlertID: "HighCPU"})
+	record(Event{Time: now.Add(-time.Minute), Type: eventFired, ChatID: -200, AlertID: "DiskFull"})
+	record(Event{Time: now, Type: eventWebhook})
+
+	entries, err = s.List(-100, now.Add(-24*time.Hour))
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, historyResolved, entries[0].Status)
+		assert.Equal(t, "vu_long", entries[0].AcknowledgedBy, "the first acknowledgement is kept")
+	}
+
+	entries, err = s.List(-100, time.Time{})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2, "entries older than the retention are removed") {
+		assert.Equal(t, historyFiring, entries[1].Status, "only the newest entry of an alert is updated")
+	}
+}
@@ -0,0 +1,116 @@
+package telegram
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandIAm = "/iam"
+
+	// approvalTimeout after which a request of /iam can't be approved anymore
+	approvalTimeout = 24 * time.Hour
+)
+
+// selfServiceCommands can be used by everyone, what they request needs the approval of an admin
+var selfServiceCommands = map[string]bool{
+	commandIAm: true,
+	commandRun: true,
+}
+
+// memberRequest validates the arguments of /iam and returns the requested member and, for level 1, its node
+func memberRequest(message telebot.Message) (Member, string, error) {
+	args, err := parseArgs(message.Text)
+	if err == nil {
+		err = args.Unknown("level", "node")
+	}
+	if err != nil {
+		return Member{}, "", err
+	}
+
+	params := args.Positional
+	for _, name := range []string{"level", "node"} {
+		if v, ok := args.Named[name]; ok {
+			params = append(params, v)
+		}
+	}
+	if len(params) < 1 || len(params) > 2 {
+		return Member{}, "", fmt.Errorf("need the level and, for level 1, the node")
+	}
+
+	member := Member{
+		Username: message.Sender.Username,
+		Level:    HandleLevel(params[0]),
+		Chat:     message.Chat,
+	}
+	switch member.Level {
+	case levelOne:
+		if len(params) != 2 {
+			return Member{}, "", fmt.Errorf("level 1 needs the node")
+		}
+		return member, params[1], nil
+	case levelTwo, levelThree:
+		if len(params) != 1 {
+			return Member{}, "", fmt.Errorf("only level 1 has a node")
+		}
+		return member, "", nil
+	default:
+		return Member{}, "", fmt.Errorf("level need to be 1-3")
+	}
+}
+
+func (b *Bot) handleIAm(message telebot.Message) {
+	// Right format: '/iam level (node if level = 1)'.
+	// Ex: /iam 1 httpd
+	// Ex: /iam 2
+	const usage = "Please send right format: '/iam level (node if level = 1)'. Ex: /iam 1 httpd"
+	if message.Sender.Username == "" {
+		b.reply(message, "You need a Telegram username to become a member.", nil)
+		return
+	}
+
+	member, node, err := memberRequest(message)
+	if err != nil {
+		b.reply(message, fmt.Sprintf("%s (%v)", usage, err), nil)
+		return
+	}
+
+	request := fmt.Sprintf("@%s asks to become a level %s member of this chat", member.Username, member.Level)
+	if node != "" {
+		request += fmt.Sprintf(" owning the node %s", node)
+	}
+	request += ". Can an admin approve?"
+
+	id := b.confirmations.Add(confirmation{
+		chat:    message.Chat,
+		userID:  message.Sender.ID,
+		expires: time.Now().Add(approvalTimeout),
+		// The request is checked against the scope of its sender, the approval only grants it
+		action: func() { b.addMember(message, member, node) },
+		approve: func(user telebot.User) bool {
+			return b.permission(telebot.Message{Sender: user, Chat: message.Chat})(commandAddMember)
+		},
+	}, time.Now())
+
+	keyboard, err := confirmKeyboard(id)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to create confirmation keyboard", "err", err)
+		return
+	}
+	_, err = b.reply(message, request, &telebot.SendOptions{
+		ReplyMarkup: b.callbacks.markup([][]telebot.KeyboardButton{keyboard}),
+	})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send member request", "err", err)
+		return
+	}
+	level.Info(b.logger).Log(
+		"msg", "member requested",
+		"chat_id", message.Chat.ID,
+		"username", member.Username,
+		"member_level", member.Level,
+	)
+}
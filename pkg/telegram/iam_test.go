@@ -0,0 +1,41 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestMemberRequest(t *testing.T) {
+	message := func(text string) telebot.Message {
+		return telebot.Message{Text: text, Sender: telebot.User{ID: 1, Username: "vu_long"}, Chat: telebot.Chat{ID: -100}}
+	}
+
+	member, node, err := memberRequest(message("/iam 1 httpd"))
+	assert.NoError(t, err)
+	assert.Equal(t, Member{Username: "vu_long", Level: levelOne, Chat: telebot.Chat{ID: -100}}, member)
+	assert.Equal(t, "httpd", node)
+
+	member, node, err = memberRequest(message(`/iam level=1 node="web server"`))
+	assert.NoError(t, err)
+	assert.Equal(t, levelOne, member.Level)
+	assert.Equal(t, "web server", node)
+
+	member, node, err = memberRequest(message("/iam 2"))
+	assert.NoError(t, err)
+	assert.Equal(t, levelTwo, member.Level)
+	assert.Equal(t, "", node)
+
+	for _, text := range []string{"/iam", "/iam 1", "/iam 2 httpd", "/iam 4", "/iam 3 for=2h"} {
+		_, _, err := memberRequest(message(text))
+		assert.Error(t, err, text)
+	}
+}
+
+func TestSelfServicePermission(t *testing.T) {
+	b := &Bot{admins: []int{1}}
+	permitted := b.permission(telebot.Message{Sender: telebot.User{ID: 2}, Chat: telebot.Chat{ID: -100}})
+	assert.True(t, permitted(commandIAm))
+	assert.False(t, permitted(commandAddMember))
+}
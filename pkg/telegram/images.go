@@ -0,0 +1,321 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	// maxAlbumPhotos is the most photos Telegram accepts in an album
+	maxAlbumPhotos = 10
+	// panelRange is how long before an alert fired its Grafana panel is rendered
+	panelRange = time.Hour
+	// albumUploadTimeout is how long uploading an album to Telegram may take
+	albumUploadTimeout = time.Minute
+)
+
+var (
+	// imageExtensions of the URLs in annotations that are attached as images
+	imageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true}
+	// imageAnnotations hold URLs of images without extension, e.g. of a renderer
+	imageAnnotations = []string{"image_url", "imageURL"}
+	// dashboardAnnotations and panelAnnotations hold the Grafana panel of an alert, Grafana sets the first
+	dashboardAnnotations = []string{"__dashboardUid__", "dashboardUID", "dashboard_uid"}
+	panelAnnotations     = []string{"__panelId__", "panelId", "panel_id"}
+)
+
+// AlbumPhoto is an image sent as photo with its caption
+type AlbumPhoto struct {
+	Data    []byte
+	Caption string
+}
+
+// alertImage is an image referenced in the annotations of an alert, by its URL or a Grafana panel
+type alertImage struct {
+	URL          string
+	DashboardUID string
+	PanelID      string
+	Caption      string
+	From         time.Time
+}
+
+// firstAnnotation returns the value of the first of the annotations the alert has
+func firstAnnotation(alert template.Alert, names []string) string {
+	for _, name := range names {
+		if v := alert.Annotations[name]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// isImageURL returns whether the annotation is the URL of an image
+func isImageURL(name, value string) bool {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	for _, n := range imageAnnotations {
+		if n == name {
+			return true
+		}
+	}
+	return imageExtensions[strings.ToLower(path.Ext(u.Path))]
+}
+
+// alertImages returns the images referenced in the annotations of the alerts, each image once
+func alertImages(alerts template.Alerts) []alertImage {
+	var images []alertImage
+	seen := make(map[string]bool)
+	for _, a := range alerts {
+		caption := a.Labels["alertname"]
+
+		names := make([]string, 0, len(a.Annotations))
+		for name := range a.Annotations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value := a.Annotations[name]
+			if !seen[value] && isImageURL(name, value) {
+				seen[value] = true
+				images = append(images, alertImage{URL: value, Caption: caption})
+			}
+		}
+
+		dashboard, panel := firstAnnotation(a, dashboardAnnotations), firstAnnotation(a, panelAnnotations)
+		if key := dashboard + "/" + panel; dashboard != "" && panel != "" && !seen[key] {
+			seen[key] = true
+			images = append(images, alertImage{DashboardUID: dashboard, PanelID: panel, Caption: caption, From: a.StartsAt.Add(-panelRange)})
+		}
+	}
+	return images
+}
+
+// downloadImage returns the image at the URL, images larger than maxSize bytes fail
+func downloadImage(ctx context.Context, u string, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("image returned %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/") {
+		return nil, fmt.Errorf("%s isn't an image", ct)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("image has %d bytes, more than %d", resp.ContentLength, maxSize)
+	}
+	img, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(img)) > maxSize {
+		return nil, fmt.Errorf("image has more than %d bytes", maxSize)
+	}
+	return img, nil
+}
+
+// key identifies the image among those of a webhook
+func (img alertImage) key() string {
+	if img.URL != "" {
+		return img.URL
+	}
+	return img.DashboardUID + "/" + img.PanelID
+}
+
+// webhookPhotos downloads the images and renders the Grafana panels of a webhook's alerts once,
+// when the first message of the webhook needs them. The chats get the photos of their alerts.
+type webhookPhotos struct {
+	bot    *Bot
+	alerts template.Alerts
+	once   sync.Once
+	done   chan struct{}
+	data   map[string][]byte // the fetched images by their key
+}
+
+// newWebhookPhotos returns the photos of the alerts, nil if images aren't sent
+func (b *Bot) newWebhookPhotos(alerts template.Alerts) *webhookPhotos {
+	if b.imagesMaxSize <= 0 {
+		return nil
+	}
+	return &webhookPhotos{bot: b, alerts: alerts, done: make(chan struct{})}
+}
+
+// get returns the photos of the alerts, the images are fetched at the first call and the others wait for them
+func (p *webhookPhotos) get(ctx context.Context, alerts template.Alerts) []AlbumPhoto {
+	p.once.Do(func() {
+		defer close(p.done)
+		p.data = p.bot.fetchImages(ctx, alertImages(p.alerts))
+	})
+	<-p.done
+
+	var photos []AlbumPhoto
+	for _, img := range alertImages(alerts) {
+		if data, ok := p.data[img.key()]; ok {
+			photos = append(photos, AlbumPhoto{Data: data, Caption: img.Caption})
+		}
+	}
+	return photos
+}
+
+// fetchImages downloads the images and renders the Grafana panels at once within the timeout.
+// Images that fail or are too large are left out, panels without Grafana too.
+func (b *Bot) fetchImages(ctx context.Context, images []alertImage) map[string][]byte {
+	ctx, cancel := context.WithTimeout(ctx, b.imagesTimeout)
+	defer cancel()
+
+	var (
+		mu   sync.Mutex
+		data = make(map[string][]byte, len(images))
+		wg   sync.WaitGroup
+	)
+	for _, img := range images {
+		if img.URL == "" && b.grafana == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(img alertImage) {
+			defer wg.Done()
+			var d []byte
+			var err error
+			if img.URL != "" {
+				d, err = downloadImage(ctx, img.URL, b.imagesMaxSize)
+			} else {
+				d, err = b.grafana.Render(ctx, img.DashboardUID, img.PanelID, img.From, time.Now(), b.imagesMaxSize)
+			}
+			if err != nil {
+				level.Warn(b.logger).Log("msg", "failed to fetch image of alert", "alertname", img.Caption, "url", img.URL, "dashboard", img.DashboardUID, "panel", img.PanelID, "err", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			data[img.key()] = d
+		}(img)
+	}
+	wg.Wait()
+	return data
+}
+
+// sendAlertImages sends the photos as albums in reply to the message of the alert
+func (b *Bot) sendAlertImages(ctx context.Context, a *HandleAlert, photos []AlbumPhoto) {
+	for len(photos) > 0 {
+		n := len(photos)
+		if n > maxAlbumPhotos {
+			n = maxAlbumPhotos
+		}
+		err := b.traceTelegram(ctx, "send album", a.Chat, func() error {
+			return b.sender.SendAlbum(a.Chat, photos[:n], a.messageID())
+		})
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to send images of alert", "chat_id", a.Chat.ID, "alertname", a.ID, "err", err)
+			return
+		}
+		photos = photos[n:]
+	}
+}
+
+// telegramAlbums uploads photos with the Bot API, telebot only sends single photos from files
+type telegramAlbums struct {
+	token  string
+	client *http.Client
+}
+
+func newTelegramAlbums(token string) *telegramAlbums {
+	return &telegramAlbums{token: token, client: &http.Client{Timeout: albumUploadTimeout}}
+}
+
+// inputMediaPhoto is a photo of an album uploaded as the attachment named in Media
+type inputMediaPhoto struct {
+	Type    string `json:"type"`
+	Media   string `json:"media"`
+	Caption string `json:"caption,omitempty"`
+}
+
+// SendAlbum sends a single photo as such and more as album, Telegram accepts up to maxAlbumPhotos
+func (t *telegramAlbums) SendAlbum(recipient telebot.Recipient, photos []AlbumPhoto, replyTo int) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("chat_id", recipient.Destination())
+	if replyTo != 0 {
+		w.WriteField("reply_to_message_id", strconv.Itoa(replyTo))
+	}
+
+	method := "sendMediaGroup"
+	if len(photos) == 1 {
+		method = "sendPhoto"
+		w.WriteField("caption", truncate(maxCaptionLength, photos[0].Caption))
+	}
+	media := make([]inputMediaPhoto, 0, len(photos))
+	for i, p := range photos {
+		name := fmt.Sprintf("photo%d", i)
+		if len(photos) == 1 {
+			name = "photo"
+		}
+		part, err := w.CreateFormFile(name, name)
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(p.Data); err != nil {
+			return err
+		}
+		media = append(media, inputMediaPhoto{Type: "photo", Media: "attach://" + name, Caption: truncate(maxCaptionLength, p.Caption)})
+	}
+	if len(photos) > 1 {
+		m, err := json.Marshal(media)
+		if err != nil {
+			return err
+		}
+		w.WriteField("media", string(m))
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("%s/bot%s/%s", telegramAPI, t.token, method)
+	resp, err := t.client.Post(u, w.FormDataContentType(), &body)
+	if err != nil {
+		// The error contains the URL with the token
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	if !result.Ok {
+		return fmt.Errorf("telegram: %s", result.Description)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestAlertImages(t *testing.T) {
+	startsAt := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	cpu := template.Alert{
+		Labels: template.KV{"alertname": "HighCPU"},
+		Annotations: template.KV{
+			"summary":          "CPU of web01 is above 90%",
+			"runbook_url":      "https://wiki.example.com/runbooks/cpu",
+			"graph":            "https://images.example.com/cpu.PNG",
+			"image_url":        "https://renderer.example.com/render?id=1",
+			"__dashboardUid__": "node-exporter",
+			"__panelId__":      "3",
+		},
+		StartsAt: startsAt,
+	}
+	// Alerts of the group share the dashboard and image
+	other := template.Alert{
+		Labels:      template.KV{"alertname": "HighCPU"},
+		Annotations: template.KV{"graph": "https://images.example.com/cpu.PNG", "dashboardUID": "node-exporter", "panelId": "3"},
+		StartsAt:    startsAt,
+	}
+
+	assert.Equal(t, []alertImage{
+		{URL: "https://images.example.com/cpu.PNG", Caption: "HighCPU"},
+		{URL: "https://renderer.example.com/render?id=1", Caption: "HighCPU"},
+		{DashboardUID: "node-exporter", PanelID: "3", Caption: "HighCPU", From: startsAt.Add(-panelRange)},
+	}, alertImages(template.Alerts{cpu, other}))
+
+	assert.Empty(t, alertImages(template.Alerts{{Annotations: template.KV{"__panelId__": "3", "image_url": "file:///etc/passwd"}}}))
+}
+
+func TestDownloadImage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cpu.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png"))
+		case "/slow.png":
+			<-r.Context().Done()
+		default:
+			fmt.Fprint(w, "<html></html>")
+		}
+	}))
+	defer srv.Close()
+
+	img, err := downloadImage(context.Background(), srv.URL+"/cpu.png", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("png"), img)
+
+	_, err = downloadImage(context.Background(), srv.URL+"/cpu.png", 2)
+	assert.Error(t, err, "the image is too large")
+
+	_, err = downloadImage(context.Background(), srv.URL+"/dashboard", 10)
+	assert.Error(t, err, "the page isn't an image")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = downloadImage(ctx, srv.URL+"/slow.png", 10)
+	assert.Error(t, err, "the download timed out")
+}
+
+// upload is a request of the Bot API with its form values and files
+type upload struct {
+	path   string
+	values map[string]string
+	files  map[string]string
+}
+
+func TestSendAlbum(t *testing.T) {
+	uploads := make(chan upload, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		u := upload{path: r.URL.Path, values: map[string]string{}, files: map[string]string{}}
+		for name := range r.MultipartForm.Value {
+			u.values[name] = r.FormValue(name)
+		}
+		for name := range r.MultipartForm.File {
+			f, _, err := r.FormFile(name)
+			assert.NoError(t, err)
+			data, _ := ioutil.ReadAll(f)
+			u.files[name] = string(data)
+		}
+		uploads <- u
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	defer srv.Close()
+
+	api := telegramAPI
+	telegramAPI = srv.URL
+	defer func() { telegramAPI = api }()
+
+	chat := telebot.Chat{ID: -100}
+	albums := newTelegramAlbums("token")
+
+	assert.NoError(t, albums.SendAlbum(chat, []AlbumPhoto{{Data: []byte("cpu"), Caption: "HighCPU"}}, 42))
+	assert.Equal(t, upload{
+		path:   "/bottoken/sendPhoto",
+		values: map[string]string{"chat_id": "-100", "reply_to_message_id": "42", "caption": "HighCPU"},
+		files:  map[string]string{"photo": "cpu"},
+	}, <-uploads)
+
+	assert.NoError(t, albums.SendAlbum(chat, []AlbumPhoto{{Data: []byte("cpu"), Caption: "HighCPU"}, {Data: []byte("disk")}}, 42))
+	u := <-uploads
+	assert.Equal(t, "/bottoken/sendMediaGroup", u.path)
+	assert.Equal(t, map[string]string{"photo0": "cpu", "photo1": "disk"}, u.files)
+	var media []inputMediaPhoto
+	assert.NoError(t, json.Unmarshal([]byte(u.values["media"]), &media))
+	assert.Equal(t, []inputMediaPhoto{
+		{Type: "photo", Media: "attach://photo0", Caption: "HighCPU"},
+		{Type: "photo", Media: "attach://photo1"},
+	}, media)
+
+	telegramAPI = "http://127.0.0.1:0"
+	assert.Error(t, albums.SendAlbum(chat, []AlbumPhoto{{Data: []byte("cpu")}}, 42))
+}
+
+func TestSendAlertImages(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "image/png")
+		fmt.Fprint(w, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	var alerts template.Alerts
+	for i := 0; i < maxAlbumPhotos+1; i++ {
+		alerts = append(alerts, template.Alert{
+			Labels:      template.KV{"alertname": "HighCPU"},
+			Annotations: template.KV{"graph": fmt.Sprintf("%s/%d.png", srv.URL, i)},
+		})
+	}
+	// Panels aren't rendered without Grafana
+	alerts[0].Annotations["__dashboardUid__"] = "node-exporter"
+	alerts[0].Annotations["__panelId__"] = "3"
+
+	sent := newFakeSender()
+	b := &Bot{logger: log.NewNopLogger(), sender: sent, imagesMaxSize: 1024, imagesTimeout: time.Second}
+	photos := b.newWebhookPhotos(alerts)
+	b.sendAlertImages(context.Background(), &HandleAlert{ID: "HighCPU", MessageID: 42}, photos.get(context.Background(), alerts))
+
+	assert.Len(t, sent.albums, 2, "albums hold at most 10 photos")
+	assert.Len(t, sent.albums[0], maxAlbumPhotos)
+	assert.Equal(t, "/0.png", string(sent.albums[0][0].Data))
+	assert.Equal(t, "/10.png", string(sent.albums[1][0].Data))
+	assert.Equal(t, []int{42, 42}, sent.replyTo)
+
+	// Other chats of the webhook get the photos of their alerts without downloading them again
+	assert.Equal(t, []AlbumPhoto{{Data: []byte("/3.png"), Caption: "HighCPU"}}, photos.get(context.Background(), alerts[3:4]))
+	assert.Equal(t, int32(maxAlbumPhotos+1), atomic.LoadInt32(&requests))
+
+	b.imagesMaxSize = 0
+	assert.Nil(t, b.newWebhookPhotos(alerts), "images are disabled without a max size")
+}
@@ -0,0 +1,79 @@
+package telegram
+
+import (
+	"html"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/loki"
+)
+
+// maxLogLineLength is the longest log line shown below an alert, longer lines are truncated
+const maxLogLineLength = 300
+
+// logSelector matches the log streams of the alert by its labels mapped to the labels of Loki,
+// empty if the alert has none of the mapped labels
+func logSelector(alert template.Alert, labels map[string]string) string {
+	matchers := make(map[string]string)
+	for alertLabel, lokiLabel := range labels {
+		if value := alert.Labels[alertLabel]; value != "" {
+			matchers[lokiLabel] = value
+		}
+	}
+	if len(matchers) == 0 {
+		return ""
+	}
+	return loki.Selector(matchers)
+}
+
+// logsSection formats the log lines as preformatted block in the parse mode,
+// dropping the oldest lines until the section is at most room long
+func logsSection(lines []loki.Line, mode telebot.ParseMode, room int) string {
+	var texts []string
+	for _, l := range lines {
+		texts = append(texts, truncate(maxLogLineLength, strings.TrimRight(l.Text, "\n")))
+	}
+
+	header := "\n\n" + bold("Recent logs:", mode) + "\n"
+	for len(texts) > 0 {
+		text := strings.Join(texts, "\n")
+		var section string
+		switch mode {
+		case telebot.ModeHTML:
+			section = header + "<pre>" + html.EscapeString(text) + "</pre>"
+		case telebot.ModeMarkdown:
+			section = header + "```\n" + strings.Replace(text, "`", "'", -1) + "\n```"
+		case telebot.ModeMarkdownV2:
+			section = header + "```\n" + strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(text) + "\n```"
+		default:
+			section = header + text
+		}
+		if len(section) <= room {
+			return section
+		}
+		texts = texts[1:]
+	}
+	return ""
+}
+
+// alertLogs returns the recent log lines of the alert's streams as a section to append to its message of the
+// parse mode, which has room left. It is empty without Loki, matching streams or logs.
+func (b *Bot) alertLogs(alert template.Alert, mode telebot.ParseMode, room int) string {
+	if b.loki == nil {
+		return ""
+	}
+	selector := logSelector(alert, b.lokiLabels)
+	if selector == "" {
+		return ""
+	}
+
+	lines, err := b.loki.Tail(selector, time.Now(), b.lokiWindow, b.lokiLines)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to query logs of alert", "selector", selector, "err", err)
+		return ""
+	}
+	return logsSection(lines, mode, room)
+}
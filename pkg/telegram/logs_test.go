@@ -0,0 +1,37 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/loki"
+)
+
+func TestLogSelector(t *testing.T) {
+	labels := map[string]string{"instance": "host", "namespace": "namespace"}
+
+	assert.Equal(t, `{host="web-1:9100"}`, logSelector(template.Alert{Labels: template.KV{"instance": "web-1:9100", "job": "node"}}, labels))
+	assert.Equal(t, "", logSelector(template.Alert{Labels: template.KV{"job": "node"}}, labels))
+}
+
+func TestLogsSection(t *testing.T) {
+	lines := []loki.Line{
+		{Time: time.Unix(1, 0), Text: "starting"},
+		{Time: time.Unix(2, 0), Text: "panic: <nil> map\n"},
+	}
+
+	assert.Equal(t, "\n\n<b>Recent logs:</b>\n<pre>starting\npanic: &lt;nil&gt; map</pre>", logsSection(lines, telebot.ModeHTML, maxMessageLength))
+	assert.Equal(t, "\n\nRecent logs:\nstarting\npanic: <nil> map", logsSection(lines, "", maxMessageLength))
+	assert.Equal(t, "\n\n*Recent logs:*\n```\nstarting\npanic: <nil> map\n```", logsSection(lines, telebot.ModeMarkdown, maxMessageLength))
+
+	// The oldest lines are dropped to fit into the message
+	assert.Equal(t, "\n\nRecent logs:\npanic: <nil> map", logsSection(lines, "", 35))
+	assert.Equal(t, "", logsSection(lines, "", 10))
+
+	long := logsSection([]loki.Line{{Text: strings.Repeat("x", 1000)}}, "", maxMessageLength)
+	assert.True(t, len(long) < 400, "long lines are truncated")
+}
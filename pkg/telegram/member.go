@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/docker/libkv/store"
@@ -22,16 +23,119 @@ type MemberStore struct {
 	kv store.Store
 }
 
-// NewMemberStore stores telegram chats in the provided kv backend
+// NewMemberStore stores telegram chats in the provided kv backend,
+// the members stored before they were keyed by their chat are moved under it once
 func NewMemberStore(kv store.Store) (*MemberStore, error) {
-	return &MemberStore{kv: kv}, nil
+	s := &MemberStore{kv: kv}
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+const (
+	// The members are keyed by their chat, so that the members of a chat are listed without the others
+	telegramMembersDirectory = "telegram/members"
+	// The keys of the members are indexed by their username, so that a member is found without knowing its chat
+	telegramMemberKeysDirectory = "telegram/member-keys"
+	// The version of the layout of the members, so that they are only migrated once.
+	// It is kept outside of the members directory, which is listed by prefix.
+	telegramMembersLayoutKey = "telegram/layout/members"
+	// membersLayout is the current version, the members keyed by chat and indexed by username
+	membersLayout = "2"
+)
+
+func memberKey(m Member) string {
+	return fmt.Sprintf("%s/%d/%s", telegramMembersDirectory, m.Chat.ID, m.Username)
+}
+
+func memberIndexKey(username string) string {
+	return fmt.Sprintf("%s/%s", telegramMemberKeysDirectory, username)
 }
 
-const telegramMembersDirectory = "telegram/members"
+// migrate moves the members keyed by their username only under their chat and indexes their keys,
+// unless the layout version shows they were migrated already
+func (s *MemberStore) migrate() error {
+	version, err := s.kv.Get(telegramMembersLayoutKey)
+	if err != nil && err != store.ErrKeyNotFound {
+		return err
+	}
+	if version != nil && string(version.Value) == membersLayout {
+		return nil
+	}
+
+	kvPairs, err := s.kv.List(telegramMembersDirectory)
+	if err != nil && err != store.ErrKeyNotFound {
+		return err
+	}
+
+	for _, kv := range kvPairs {
+		var m Member
+		if err := json.Unmarshal(kv.Value, &m); err != nil {
+			return err
+		}
+		if flatKey(telegramMembersDirectory, kv.Key) {
+			if err := s.kv.Put(memberKey(m), kv.Value, nil); err != nil {
+				return err
+			}
+			if err := s.kv.Delete(kv.Key); err != nil {
+				return err
+			}
+		}
+		if err := s.kv.Put(memberIndexKey(m.Username), []byte(memberKey(m)), nil); err != nil {
+			return err
+		}
+	}
+	return s.kv.Put(telegramMembersLayoutKey, []byte(membersLayout), nil)
+}
+
+// putIndexed stores the value at the key and indexes it,
+// the value is removed from the key indexed before if that differs, e.g. of another chat
+func putIndexed(kv store.Store, indexKey, key string, value []byte) error {
+	old, err := kv.Get(indexKey)
+	if err != nil && err != store.ErrKeyNotFound {
+		return err
+	}
+	if err := kv.Put(key, value, nil); err != nil {
+		return err
+	}
+	if err := kv.Put(indexKey, []byte(key), nil); err != nil {
+		return err
+	}
+	if old != nil && string(old.Value) != key {
+		if err := kv.Delete(string(old.Value)); err != nil && err != store.ErrKeyNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteIndexed removes the value at the indexed key and the index, store.ErrKeyNotFound if there is none
+func deleteIndexed(kv store.Store, indexKey string) error {
+	key, err := kv.Get(indexKey)
+	if err != nil {
+		return err
+	}
+	if err := kv.Delete(string(key.Value)); err != nil && err != store.ErrKeyNotFound {
+		return err
+	}
+	return kv.Delete(indexKey)
+}
+
+// flatKey returns whether the key is right below the directory instead of the directory of a chat
+func flatKey(directory, key string) bool {
+	name := strings.TrimPrefix(strings.TrimPrefix(key, "/"), directory+"/")
+	return !strings.Contains(name, "/")
+}
 
 // List all members saved in the kv backend
 func (s *MemberStore) List() ([]Member, error) {
-	kvPairs, err := s.kv.List(telegramMembersDirectory)
+	return s.list(telegramMembersDirectory)
+}
+
+// list the members saved below the directory
+func (s *MemberStore) list(directory string) ([]Member, error) {
+	kvPairs, err := s.kv.List(directory)
 	if err != nil {
 		return nil, err
 	}
@@ -50,36 +154,36 @@ func (s *MemberStore) List() ([]Member, error) {
 
 // Add a telegram member to the kv backend
 func (s *MemberStore) Add(m Member) error {
-	b, err := json.Marshal(m)
-	if err != nil {
-		return err
-	}
-
-	key := fmt.Sprintf("%s/%s", telegramMembersDirectory, m.Username)
+	return s.AddAll([]Member{m})
+}
 
-	return s.kv.Put(key, b, nil)
+// AddAll adds the telegram members to the kv backend. A member belongs to a single chat,
+// so the members are removed from the chats they belonged to before.
+func (s *MemberStore) AddAll(members []Member) error {
+	for _, m := range members {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if err := putIndexed(s.kv, memberIndexKey(m.Username), memberKey(m), b); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// Remove a telegram members from the kv backend
+// Remove a telegram members from the kv backend, whichever chat it belongs to
 func (s *MemberStore) Remove(m Member) error {
-	key := fmt.Sprintf("%s/%s", telegramMembersDirectory, m.Username)
-	return s.kv.Delete(key)
+	return deleteIndexed(s.kv, memberIndexKey(m.Username))
 }
 
-// GetMembersByChat helps getting members by chat ID
+// GetMembersByChat helps getting members by chat ID, only the chat's members are listed from the kv backend
 func (s *MemberStore) GetMembersByChat(chat telebot.Chat) ([]Member, error) {
-	var ret []Member
-	members, err := s.List()
-	if err != nil {
-		return ret, err
-	}
-
-	for _, ms := range members {
-		if ms.Chat.ID == chat.ID {
-			ret = append(ret, ms)
-		}
+	members, err := s.list(fmt.Sprintf("%s/%d/", telegramMembersDirectory, chat.ID))
+	if err == store.ErrKeyNotFound {
+		return nil, nil
 	}
-	return ret, err
+	return members, err
 }
 
 // GetRandomMemberByChatandLevel get random member by level
@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestMemberStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "members")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	defer kv.Close()
+
+	db := telebot.Chat{ID: -100}
+	web := telebot.Chat{ID: -1001}
+
+	// Members and nodes stored before they were keyed by chat are moved under it
+	alice, _ := json.Marshal(Member{Username: "alice", Level: levelOne, Chat: db})
+	assert.NoError(t, kv.Put("telegram/members/alice", alice, nil))
+	db01, _ := json.Marshal(NodeExported{Name: "db01", Owner: "alice"})
+	assert.NoError(t, kv.Put("telegram/nodes/db01", db01, nil))
+	// The owner of web01 isn't a member anymore
+	web01, _ := json.Marshal(NodeExported{Name: "web01", Owner: "dave"})
+	assert.NoError(t, kv.Put("telegram/nodes/web01", web01, nil))
+
+	members, err := NewMemberStore(kv)
+	assert.NoError(t, err)
+	nodes, err := NewNodeStore(kv)
+	assert.NoError(t, err)
+	_, err = kv.Get("telegram/members/alice")
+	assert.Equal(t, store.ErrKeyNotFound, err)
+
+	// Once migrated, the members aren't migrated again on the next start
+	assert.NoError(t, kv.Put("telegram/members/erin", alice, nil))
+	_, err = NewMemberStore(kv)
+	assert.NoError(t, err)
+	_, err = kv.Get("telegram/members/erin")
+	assert.NoError(t, err)
+	assert.NoError(t, kv.Delete("telegram/members/erin"))
+
+	byChat, err := members.GetMembersByChat(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []Member{{Username: "alice", Level: levelOne, Chat: db}}, byChat)
+	owned, err := nodes.GetByChat(db)
+	assert.NoError(t, err)
+	assert.Equal(t, []NodeExported{{Name: "db01", Owner: "alice", Chat: db}}, owned)
+
+	// Orphaned nodes keep their key instead of being hidden in chat 0
+	_, err = kv.Get("telegram/nodes/web01")
+	assert.NoError(t, err)
+	owned, err = nodes.GetByChat(telebot.Chat{})
+	assert.NoError(t, err)
+	assert.Empty(t, owned)
+	allNodes, err := nodes.List()
+	assert.NoError(t, err)
+	assert.Len(t, allNodes, 2)
+
+	// The chat -100 is a prefix of -1001, its members are still listed alone
+	assert.NoError(t, members.AddAll([]Member{
+		{Username: "bob", Level: levelOne, Chat: web},
+		{Username: "carol", Level: levelTwo, Chat: web},
+	}))
+	byChat, err = members.GetMembersByChat(db)
+	assert.NoError(t, err)
+	assert.Len(t, byChat, 1)
+
+	// A member moved to another chat isn't a member of the former chat anymore
+	assert.NoError(t, members.Add(Member{Username: "alice", Level: levelTwo, Chat: web}))
+	byChat, err = members.GetMembersByChat(db)
+	assert.NoError(t, err)
+	assert.Empty(t, byChat)
+	byChat, err = members.GetMembersByChat(web)
+	assert.NoError(t, err)
+	assert.Len(t, byChat, 3)
+
+	assert.NoError(t, members.Remove(Member{Username: "carol"}))
+	assert.Equal(t, store.ErrKeyNotFound, members.Remove(Member{Username: "carol"}))
+	all, err := members.List()
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	// Nodes are unique, a new owner takes the node to its chat
+	assert.NoError(t, nodes.Add(NodeExported{Name: "db01", Owner: "bob", Chat: web}))
+	owned, err = nodes.GetByChat(db)
+	assert.NoError(t, err)
+	assert.Empty(t, owned)
+	owned, err = nodes.GetByChat(web)
+	assert.NoError(t, err)
+	assert.Equal(t, []NodeExported{{Name: "db01", Owner: "bob", Chat: web}}, owned)
+
+	// Adding the orphaned node files it under the chat of its owner
+	assert.NoError(t, nodes.Add(NodeExported{Name: "web01", Owner: "bob", Chat: web}))
+	_, err = kv.Get("telegram/nodes/web01")
+	assert.Equal(t, store.ErrKeyNotFound, err)
+
+	assert.NoError(t, nodes.Remove(NodeExported{Name: "db01"}))
+	assert.Equal(t, store.ErrKeyNotFound, nodes.Remove(NodeExported{Name: "db01"}))
+	allNodes, err = nodes.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []NodeExported{{Name: "web01", Owner: "bob", Chat: web}}, allNodes)
+}
@@ -0,0 +1,73 @@
+package telegram
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// escalationEvents are the events counted by alertMetrics
+var escalationEvents = map[string]bool{
+	eventFired:         true,
+	eventAcknowledged:  true,
+	eventForwarded:     true,
+	eventAutoForwarded: true,
+	eventExhausted:     true,
+	eventResolved:      true,
+}
+
+// alertMetrics track the flow of alerts through the escalation
+type alertMetrics struct {
+	events     *prometheus.CounterVec
+	open       prometheus.Gauge
+	openAlerts int64 // atomic, mirrors the open gauge for /botstats
+}
+
+func newAlertMetrics() *alertMetrics {
+	return &alertMetrics{
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "alertmanagerbot",
+			Name:      "alert_events_total",
+			Help:      "Number of alerts fired, acknowledged, forwarded, auto-forwarded and resolved by chat",
+		}, []string{"chat", "event"}),
+		open: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "alertmanagerbot",
+			Name:      "alerts_open",
+			Help:      "Number of alerts sent to chats that aren't resolved yet",
+		}),
+	}
+}
+
+// register the metrics with the prometheus client
+func (m *alertMetrics) register(reg prometheus.Registerer) error {
+	if err := reg.Register(m.events); err != nil {
+		return err
+	}
+	return reg.Register(m.open)
+}
+
+// Observe counts the escalation events of alerts, it subscribes to the event bus
+func (m *alertMetrics) Observe(e Event) {
+	if m == nil || !escalationEvents[e.Type] {
+		return
+	}
+	m.events.WithLabelValues(strconv.FormatInt(e.ChatID, 10), e.Type).Inc()
+
+	switch e.Type {
+	case eventFired:
+		m.open.Inc()
+		atomic.AddInt64(&m.openAlerts, 1)
+	case eventResolved:
+		m.open.Dec()
+		atomic.AddInt64(&m.openAlerts, -1)
+	}
+}
+
+// Open returns the number of alerts sent to chats that aren't resolved yet
+func (m *alertMetrics) Open() int64 {
+	if m == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&m.openAlerts)
+}
@@ -0,0 +1,29 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertMetrics(t *testing.T) {
+	m := newAlertMetrics()
+	bus := newEventBus()
+	bus.Subscribe(m.Observe)
+
+	bus.Publish(Event{Type: eventFired, ChatID: -100})
+	bus.Publish(Event{Type: eventFired, ChatID: -100})
+	bus.Publish(Event{Type: eventAcknowledged, ChatID: -100})
+	bus.Publish(Event{Type: eventWebhook})
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.open))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.events.WithLabelValues("-100", eventFired)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.events.WithLabelValues("0", eventWebhook)), "only escalation events are counted")
+
+	bus.Publish(Event{Type: eventResolved, ChatID: -100})
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.open))
+	assert.Equal(t, int64(1), m.Open())
+
+	var nilMetrics *alertMetrics
+	nilMetrics.Observe(Event{Type: eventFired, ChatID: -100})
+}
@@ -0,0 +1,20 @@
+package telegram
+
+import (
+	"fmt"
+
+	"github.com/docker/libkv/store"
+)
+
+// Migrate moves the data written by older versions in the store to its current layout.
+// The stores migrate their data when they are created too, this migrates ahead of a rollout.
+func Migrate(kv store.Store) error {
+	// The nodes are migrated under the chats of their owners, which are looked up in the migrated members
+	if _, err := NewMemberStore(kv); err != nil {
+		return fmt.Errorf("failed to migrate members: %v", err)
+	}
+	if _, err := NewNodeStore(kv); err != nil {
+		return fmt.Errorf("failed to migrate nodes: %v", err)
+	}
+	return nil
+}
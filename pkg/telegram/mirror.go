@@ -0,0 +1,28 @@
+package telegram
+
+import (
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/mirror"
+)
+
+// mirror sends a copy of a message of the alert, sent to its chat in the parse mode, to the mirror
+func (a *HandleAlert) mirror(text string, mode telebot.ParseMode) {
+	if a.Mirror == nil {
+		return
+	}
+
+	// Markdown is mirrored as it is, the platforms' own flavors render most of it
+	a.Mirror.Notify(mirror.Message{
+		Chat: chatTitle(a.Chat),
+		Text: text,
+		HTML: mode == telebot.ModeHTML,
+	})
+}
+
+// chatTitle names the chat in mirrored messages, groups by their title and private chats by the username
+func chatTitle(chat telebot.Chat) string {
+	if chat.IsGroupChat() {
+		return chat.Title
+	}
+	return "@" + chat.Username
+}
@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandMode = "/mode"
+
+	modeDefault = "default"
+)
+
+// modeTemplates are the built-in rendering modes a chat can switch to
+var modeTemplates = map[string]string{
+	"compact": "telegram.compact",
+	"verbose": "telegram.verbose",
+}
+
+// modeTemplate returns the template of the chat's rendering mode for the alerts,
+// a mode for the severity of the most severe alert takes precedence over the chat's mode.
+// Without a matching mode the given template is returned.
+func (s ChatSettings) modeTemplate(name string, sorted template.Alerts) string {
+	if len(sorted) > 0 {
+		if mode, ok := s.Modes[strings.ToLower(sorted[0].Labels["severity"])]; ok {
+			return modeTemplates[mode]
+		}
+	}
+	if mode, ok := s.Modes[""]; ok {
+		return modeTemplates[mode]
+	}
+	return name
+}
+
+func (b *Bot) handleMode(message telebot.Message) {
+	// Right format: '/mode compact|verbose|default [severity]'.
+	// Ex: /mode compact warning
+	params := strings.Fields(message.Text)[1:]
+
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't get the mode of this chat.", nil)
+		return
+	}
+
+	if len(params) == 0 {
+		if len(settings.Modes) == 0 {
+			b.reply(message, "This chat uses the default mode.", nil)
+			return
+		}
+
+		severities := make([]string, 0, len(settings.Modes))
+		for severity := range settings.Modes {
+			severities = append(severities, severity)
+		}
+		sort.Strings(severities)
+
+		list := ""
+		for _, severity := range severities {
+			if severity == "" {
+				list = list + fmt.Sprintf("all alerts: %s\n", settings.Modes[severity])
+			} else {
+				list = list + fmt.Sprintf("%s alerts: %s\n", severity, settings.Modes[severity])
+			}
+		}
+		b.reply(message, "This chat uses these modes:\n"+list, nil)
+		return
+	}
+
+	_, ok := modeTemplates[params[0]]
+	if len(params) > 2 || (!ok && params[0] != modeDefault) {
+		b.reply(message, "Please send right format: '/mode compact|verbose|default [severity]'. Ex: /mode compact warning", nil)
+		return
+	}
+
+	severity := ""
+	if len(params) == 2 {
+		severity = strings.ToLower(params[1])
+	}
+
+	if params[0] == modeDefault {
+		delete(settings.Modes, severity)
+	} else {
+		if settings.Modes == nil {
+			settings.Modes = make(map[string]string)
+		}
+		settings.Modes[severity] = params[0]
+	}
+
+	if err := b.settings.Set(message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.reply(message, "I can't save the mode of this chat.", nil)
+		return
+	}
+
+	b.reply(message, responseMember, nil)
+}
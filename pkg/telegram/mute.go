@@ -0,0 +1,155 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/hako/durafmt"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/common/model"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandMute   = "/mute"
+	commandUnmute = "/unmute"
+	commandMutes  = "/mutes"
+)
+
+// activeMutes returns the mutes that haven't expired yet at the given time
+func activeMutes(mutes map[string]time.Time, now time.Time) map[string]time.Time {
+	active := make(map[string]time.Time, len(mutes))
+	for alertname, until := range mutes {
+		if until.After(now) {
+			active[alertname] = until
+		}
+	}
+	return active
+}
+
+// filterMuted returns the alerts whose alertname isn't muted at the given time, resolved alerts are never muted
+func filterMuted(mutes map[string]time.Time, alerts template.Alerts, now time.Time) template.Alerts {
+	if len(mutes) == 0 {
+		return alerts
+	}
+
+	var filtered template.Alerts
+	for _, a := range alerts {
+		if until, ok := mutes[a.Labels["alertname"]]; ok && until.After(now) && a.Status != string(model.AlertResolved) {
+			continue
+		}
+		filtered = append(filtered, a)
+	}
+	return filtered
+}
+
+func (b *Bot) handleMute(message telebot.Message) {
+	// Right format: '/mute alertname duration', alertnames with spaces quoted.
+	// Ex: /mute HighCPU 2h
+	args, err := parseArgs(message.Text)
+	if err != nil || len(args.Positional) != 2 || len(args.Named) != 0 {
+		b.reply(message, "Please send right format: '/mute alertname duration'. Ex: /mute HighCPU 2h", nil)
+		return
+	}
+	alertname := args.Positional[0]
+
+	d, err := time.ParseDuration(args.Positional[1])
+	if err != nil || d <= 0 {
+		b.reply(message, "Please send a valid duration like 30m or 2h.", nil)
+		return
+	}
+
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't get the mutes of this chat.", nil)
+		return
+	}
+
+	now := time.Now()
+	settings.Mutes = activeMutes(settings.Mutes, now)
+	settings.Mutes[alertname] = now.Add(d)
+
+	if err := b.settings.Set(message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.reply(message, "I can't mute this alert.", nil)
+		return
+	}
+
+	b.reply(message, fmt.Sprintf("%s is muted for %s.", alertname, durafmt.Parse(d)), nil)
+	level.Info(b.logger).Log(
+		"msg", "alert muted",
+		"chat_id", message.Chat.ID,
+		"alertname", alertname,
+		"duration", d,
+	)
+}
+
+func (b *Bot) handleUnmute(message telebot.Message) {
+	// Right format: '/unmute alertname'.
+	// Ex: /unmute HighCPU
+	args, err := parseArgs(message.Text)
+	if err != nil || len(args.Positional) != 1 || len(args.Named) != 0 {
+		b.reply(message, "Please send right format: '/unmute alertname'. Ex: /unmute HighCPU", nil)
+		return
+	}
+	alertname := args.Positional[0]
+
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't get the mutes of this chat.", nil)
+		return
+	}
+
+	settings.Mutes = activeMutes(settings.Mutes, time.Now())
+	if _, ok := settings.Mutes[alertname]; !ok {
+		b.reply(message, fmt.Sprintf("%s isn't muted.", alertname), nil)
+		return
+	}
+	delete(settings.Mutes, alertname)
+
+	if err := b.settings.Set(message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.reply(message, "I can't unmute this alert.", nil)
+		return
+	}
+
+	b.reply(message, responseMember, nil)
+	level.Info(b.logger).Log(
+		"msg", "alert unmuted",
+		"chat_id", message.Chat.ID,
+		"alertname", alertname,
+	)
+}
+
+func (b *Bot) handleMutes(message telebot.Message) {
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't list the mutes of this chat.", nil)
+		return
+	}
+
+	now := time.Now()
+	mutes := activeMutes(settings.Mutes, now)
+	if len(mutes) == 0 {
+		b.reply(message, "No alerts are muted right now.", nil)
+		return
+	}
+
+	alertnames := make([]string, 0, len(mutes))
+	for alertname := range mutes {
+		alertnames = append(alertnames, alertname)
+	}
+	sort.Strings(alertnames)
+
+	list := ""
+	for _, alertname := range alertnames {
+		list = list + fmt.Sprintf("%s for %s\n", alertname, durafmt.Parse(mutes[alertname].Sub(now)))
+	}
+
+	b.reply(message, "Currently these alerts are muted:\n"+list, nil)
+}
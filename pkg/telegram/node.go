@@ -5,12 +5,15 @@ import (
 	"fmt"
 
 	"github.com/docker/libkv/store"
+	"github.com/tucnak/telebot"
 )
 
 // NodeExported saves the exported node
 type NodeExported struct {
 	Name  string `json:"name"`
 	Owner string `json:"owner_id"`
+	// Chat of the owner, the node is keyed by it
+	Chat telebot.Chat `json:"chat"`
 }
 
 // NodeStore writes the users to a libkv store backend
@@ -18,16 +21,111 @@ type NodeStore struct {
 	kv store.Store
 }
 
-// NewNodeStore stores telegram chats in the provided kv backend
+// NewNodeStore stores telegram chats in the provided kv backend,
+// the nodes stored before they were keyed by the chat of their owner are moved under it.
+// Nodes whose owner isn't a member keep their key until the owner is added, List returns them.
 func NewNodeStore(kv store.Store) (*NodeStore, error) {
-	return &NodeStore{kv: kv}, nil
+	s := &NodeStore{kv: kv}
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
-const telegramNodesDirectory = "telegram/nodes"
+const (
+	// The nodes are keyed by the chat of their owner, so that the nodes of a chat are listed without the others
+	telegramNodesDirectory = "telegram/nodes"
+	// The keys of the nodes are indexed by their name, so that a node is found without knowing the chat of its owner
+	telegramNodeKeysDirectory = "telegram/node-keys"
+)
+
+func nodeKey(n NodeExported) string {
+	return fmt.Sprintf("%s/%d/%s", telegramNodesDirectory, n.Chat.ID, n.Name)
+}
+
+// legacyNodeKey is the key of the node before the nodes were keyed by chat
+func legacyNodeKey(n NodeExported) string {
+	return fmt.Sprintf("%s/%s", telegramNodesDirectory, n.Name)
+}
+
+func nodeIndexKey(name string) string {
+	return fmt.Sprintf("%s/%s", telegramNodeKeysDirectory, name)
+}
+
+// migrate moves the nodes keyed by their name only under the chat of their owner and indexes their keys
+func (s *NodeStore) migrate() error {
+	kvPairs, err := s.kv.List(telegramNodesDirectory)
+	if err != nil && err != store.ErrKeyNotFound {
+		return err
+	}
+	var legacy []*store.KVPair
+	for _, kv := range kvPairs {
+		if flatKey(telegramNodesDirectory, kv.Key) {
+			legacy = append(legacy, kv)
+			continue
+		}
+		var n NodeExported
+		if err := json.Unmarshal(kv.Value, &n); err != nil {
+			return err
+		}
+		if err := s.kv.Put(nodeIndexKey(n.Name), []byte(nodeKey(n)), nil); err != nil {
+			return err
+		}
+	}
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	// The members are read whether they were moved under their chat already or not
+	memberPairs, err := s.kv.List(telegramMembersDirectory)
+	if err != nil && err != store.ErrKeyNotFound {
+		return err
+	}
+	chats := make(map[string]telebot.Chat, len(memberPairs))
+	for _, kv := range memberPairs {
+		var m Member
+		if err := json.Unmarshal(kv.Value, &m); err != nil {
+			return err
+		}
+		chats[m.Username] = m.Chat
+	}
+
+	for _, kv := range legacy {
+		var n NodeExported
+		if err := json.Unmarshal(kv.Value, &n); err != nil {
+			return err
+		}
+		chat, ok := chats[n.Owner]
+		if !ok {
+			// Without the chat of the owner the node would be hidden in a chat of its own
+			if err := s.kv.Put(nodeIndexKey(n.Name), []byte(legacyNodeKey(n)), nil); err != nil {
+				return err
+			}
+			continue
+		}
+		n.Chat = chat
+		b, err := json.Marshal(n)
+		if err != nil {
+			return err
+		}
+		if err := putIndexed(s.kv, nodeIndexKey(n.Name), nodeKey(n), b); err != nil {
+			return err
+		}
+		if err := s.kv.Delete(kv.Key); err != nil && err != store.ErrKeyNotFound {
+			return err
+		}
+	}
+	return nil
+}
 
 // List all nodes saved in the kv backend
 func (s *NodeStore) List() ([]NodeExported, error) {
-	kvPairs, err := s.kv.List(telegramNodesDirectory)
+	return s.list(telegramNodesDirectory)
+}
+
+// list the nodes saved below the directory
+func (s *NodeStore) list(directory string) ([]NodeExported, error) {
+	kvPairs, err := s.kv.List(directory)
 	if err != nil {
 		return nil, err
 	}
@@ -44,20 +142,36 @@ func (s *NodeStore) List() ([]NodeExported, error) {
 	return nodes, nil
 }
 
-// Add a telegram node to the kv backend
-func (s *NodeStore) Add(n NodeExported) error {
-	b, err := json.Marshal(n)
-	if err != nil {
-		return err
+// GetByChat returns the nodes owned by the members of the chat, only they are listed from the kv backend
+func (s *NodeStore) GetByChat(chat telebot.Chat) ([]NodeExported, error) {
+	nodes, err := s.list(fmt.Sprintf("%s/%d/", telegramNodesDirectory, chat.ID))
+	if err == store.ErrKeyNotFound {
+		return nil, nil
 	}
+	return nodes, err
+}
 
-	key := fmt.Sprintf("%s/%s", telegramNodesDirectory, n.Name)
+// Add a telegram node to the kv backend
+func (s *NodeStore) Add(n NodeExported) error {
+	return s.AddAll([]NodeExported{n})
+}
 
-	return s.kv.Put(key, b, nil)
+// AddAll adds the telegram nodes to the kv backend. The names of nodes are unique,
+// so the nodes are removed from the chats of their former owners.
+func (s *NodeStore) AddAll(nodes []NodeExported) error {
+	for _, n := range nodes {
+		b, err := json.Marshal(n)
+		if err != nil {
+			return err
+		}
+		if err := putIndexed(s.kv, nodeIndexKey(n.Name), nodeKey(n), b); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// Remove a telegram nodes from the kv backend
+// Remove a telegram nodes from the kv backend, whichever chat its owner belongs to
 func (s *NodeStore) Remove(n NodeExported) error {
-	key := fmt.Sprintf("%s/%s", telegramNodesDirectory, n.Name)
-	return s.kv.Delete(key)
+	return deleteIndexed(s.kv, nodeIndexKey(n.Name))
 }
@@ -0,0 +1,256 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	strLevelData = "Level"
+	strNodeData  = "Node"
+
+	// maxOnboardingNodes is the most nodes offered as buttons, the others have to be typed
+	maxOnboardingNodes = 30
+	// onboardingNodesPerRow of the node keyboard
+	onboardingNodesPerRow = 3
+)
+
+// onboarding is an /addmember waiting for its sender to select the level, node and confirm
+type onboarding struct {
+	message  telebot.Message // the /addmember command
+	username string
+	level    HandleLevel
+	nodes    []string // offered for level 1, selected by index to fit the callback data
+	node     string
+	expires  time.Time
+}
+
+// onboardings are the pending onboardings by their ID
+type onboardings struct {
+	mu      sync.Mutex
+	next    int
+	pending map[string]*onboarding
+}
+
+func newOnboardings() *onboardings {
+	return &onboardings{pending: make(map[string]*onboarding)}
+}
+
+// Add a pending onboarding and return its ID, expired ones are removed
+func (o *onboardings) Add(ob *onboarding, now time.Time) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for id, p := range o.pending {
+		if now.After(p.expires) {
+			delete(o.pending, id)
+		}
+	}
+
+	o.next++
+	id := strconv.Itoa(o.next)
+	o.pending[id] = ob
+	return id
+}
+
+// Get returns the onboarding if the user may continue it,
+// ok is false if it doesn't exist, expired or belongs to someone else
+func (o *onboardings) Get(id string, userID int, now time.Time) (ob *onboarding, ok bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	ob, ok = o.pending[id]
+	if !ok || ob.message.Sender.ID != userID || now.After(ob.expires) {
+		return nil, false
+	}
+	return ob, true
+}
+
+// Remove the finished onboarding
+func (o *onboardings) Remove(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.pending, id)
+}
+
+// onboardingButton creates a button of the onboarding
+func onboardingButton(id, text, button, value string) (telebot.KeyboardButton, error) {
+	data, err := json.Marshal(CallbackData{Button: button, Onboarding: id, Value: value})
+	if err != nil {
+		return telebot.KeyboardButton{}, err
+	}
+	return telebot.KeyboardButton{Text: text, Data: string(data)}, nil
+}
+
+// levelKeyboard offers the levels of members
+func levelKeyboard(id string) ([][]telebot.KeyboardButton, error) {
+	var row []telebot.KeyboardButton
+	for _, l := range []HandleLevel{levelOne, levelTwo, levelThree} {
+		button, err := onboardingButton(id, "Level "+string(l), strLevelData, string(l))
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, button)
+	}
+	return [][]telebot.KeyboardButton{row}, nil
+}
+
+// nodeKeyboard offers the nodes to own, the buttons refer to them by index
+func nodeKeyboard(id string, nodes []string) ([][]telebot.KeyboardButton, error) {
+	var keyboard [][]telebot.KeyboardButton
+	for i, n := range nodes {
+		button, err := onboardingButton(id, n, strNodeData, strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		if i%onboardingNodesPerRow == 0 {
+			keyboard = append(keyboard, nil)
+		}
+		keyboard[len(keyboard)-1] = append(keyboard[len(keyboard)-1], button)
+	}
+	return keyboard, nil
+}
+
+// onboardingNodes returns the distinct names of the nodes the sender may assign, sorted
+func onboardingNodes(nodes []NodeExported) []string {
+	seen := make(map[string]bool, len(nodes))
+	var names []string
+	for _, n := range nodes {
+		if !seen[n.Name] {
+			seen[n.Name] = true
+			names = append(names, n.Name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) > maxOnboardingNodes {
+		names = names[:maxOnboardingNodes]
+	}
+	return names
+}
+
+// startOnboarding asks the sender of /addmember for the level and node of the user with buttons
+func (b *Bot) startOnboarding(message telebot.Message, username string) {
+	if err := b.checkMemberScope(message, username); err != nil {
+		b.reply(message, fmt.Sprintf("I can't add this member. %v", err), nil)
+		return
+	}
+
+	id := b.onboardings.Add(&onboarding{
+		message:  message,
+		username: username,
+		expires:  time.Now().Add(confirmationTimeout),
+	}, time.Now())
+
+	keyboard, err := levelKeyboard(id)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to create level keyboard", "err", err)
+		return
+	}
+	_, err = b.reply(message, fmt.Sprintf("Which level should @%s have?", username), &telebot.SendOptions{
+		ReplyMarkup: b.callbacks.markup(keyboard),
+	})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send level selection", "err", err)
+	}
+}
+
+// handleOnboarding continues an /addmember with the selected level or node, or its confirmation
+func (b *Bot) handleOnboarding(callback telebot.Callback, cd CallbackData) {
+	ob, ok := b.onboardings.Get(cd.Onboarding, callback.Sender.ID, time.Now())
+	if !ok {
+		b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{
+			Text: "This selection expired or belongs to someone else.",
+		})
+		return
+	}
+	b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{})
+
+	var (
+		text     string
+		keyboard [][]telebot.KeyboardButton
+		err      error
+	)
+	switch cd.Button {
+	case strLevelData:
+		ob.level = HandleLevel(cd.Value)
+		if ob.level == levelOne {
+			text, keyboard, err = b.onboardingNodeStep(cd.Onboarding, ob)
+			break
+		}
+		text, keyboard, err = onboardingConfirmStep(cd.Onboarding, ob)
+	case strNodeData:
+		i, convErr := strconv.Atoi(cd.Value)
+		if convErr != nil || i < 0 || i >= len(ob.nodes) {
+			return
+		}
+		ob.node = ob.nodes[i]
+		text, keyboard, err = onboardingConfirmStep(cd.Onboarding, ob)
+	case strConfirmData:
+		b.onboardings.Remove(cd.Onboarding)
+		text = onboardingSummary(ob) + "\nConfirmed."
+		defer b.addMember(ob.message, Member{Username: ob.username, Level: ob.level, Chat: ob.message.Chat}, ob.node)
+	default:
+		b.onboardings.Remove(cd.Onboarding)
+		text = onboardingSummary(ob) + "\nCancelled."
+	}
+	if err != nil {
+		b.onboardings.Remove(cd.Onboarding)
+		level.Warn(b.logger).Log("msg", "failed to continue member onboarding", "err", err)
+		text = fmt.Sprintf("I can't add this member. %v", err)
+		keyboard = nil
+	}
+
+	err = b.sender.EditMessageText(callback.Message.Chat, callback.Message.ID, text, &telebot.SendOptions{
+		ReplyMarkup: b.callbacks.markup(keyboard),
+	})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to update member onboarding", "err", err)
+	}
+}
+
+// onboardingNodeStep offers the nodes a member of level 1 can own
+func (b *Bot) onboardingNodeStep(id string, ob *onboarding) (string, [][]telebot.KeyboardButton, error) {
+	nodes, err := b.scopedNodes(ob.message)
+	if err != nil {
+		return "", nil, err
+	}
+	ob.nodes = onboardingNodes(nodes)
+	if len(ob.nodes) == 0 {
+		return "", nil, fmt.Errorf("there are no nodes yet, add the first with '/addmember %s 1 node'", ob.username)
+	}
+
+	keyboard, err := nodeKeyboard(id, ob.nodes)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("Which node should @%s own?", ob.username), keyboard, nil
+}
+
+// onboardingConfirmStep asks to confirm the selection
+func onboardingConfirmStep(id string, ob *onboarding) (string, [][]telebot.KeyboardButton, error) {
+	var row []telebot.KeyboardButton
+	for _, button := range []string{strConfirmData, strCancelData} {
+		b, err := onboardingButton(id, button, button, "")
+		if err != nil {
+			return "", nil, err
+		}
+		row = append(row, b)
+	}
+	return onboardingSummary(ob) + "?", [][]telebot.KeyboardButton{row}, nil
+}
+
+// onboardingSummary describes the member to add
+func onboardingSummary(ob *onboarding) string {
+	if ob.node != "" {
+		return fmt.Sprintf("Add @%s with level %s owning the node %s", ob.username, ob.level, ob.node)
+	}
+	return fmt.Sprintf("Add @%s with level %s", ob.username, ob.level)
+}
@@ -0,0 +1,438 @@
+package telegram
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// Shift is an event of an on-call calendar, the attendees are on call from Start to End
+type Shift struct {
+	Start  time.Time
+	End    time.Time
+	Emails []string
+}
+
+// onCallHorizon is how far ahead recurring shifts are expanded, the calendar is read again long before
+const onCallHorizon = 31 * 24 * time.Hour
+
+// icalEvent is a VEVENT of the calendar, recurring ones are expanded into their shifts
+type icalEvent struct {
+	Shift
+	uid          string
+	rule         string
+	exdates      []time.Time
+	recurrenceID time.Time // start of the occurrence of a recurring event this event replaces
+	cancelled    bool
+}
+
+// parseICal reads the shifts overlapping from until to from the VEVENTs of an iCalendar, like the secret address of a Google Calendar.
+// Recurring events are expanded by their RRULE, DAILY and WEEKLY rules are supported, others fail the calendar.
+func parseICal(r io.Reader, from, to time.Time) ([]Shift, error) {
+	// Long lines are folded into lines starting with a space or tab
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		events []icalEvent
+		event  *icalEvent
+		date   bool // whether the event starts at a date without time
+	)
+	for i, line := range lines {
+		name, params, value := icalProperty(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event, date = &icalEvent{}, false
+		case event == nil:
+			continue
+		case name == "END" && value == "VEVENT":
+			if event.End.IsZero() && date {
+				event.End = event.Start.AddDate(0, 0, 1)
+			}
+			if !event.Start.IsZero() && event.End.After(event.Start) {
+				events = append(events, *event)
+			}
+			event = nil
+		case name == "DTSTART" || name == "DTEND" || name == "RECURRENCE-ID":
+			t, err := icalTime(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			switch name {
+			case "DTSTART":
+				event.Start, date = t, params["VALUE"] == "DATE"
+			case "DTEND":
+				event.End = t
+			default:
+				event.recurrenceID = t
+			}
+		case name == "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				t, err := icalTime(params, v)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", i+1, err)
+				}
+				event.exdates = append(event.exdates, t)
+			}
+		case name == "RRULE":
+			event.rule = value
+		case name == "RDATE":
+			return nil, fmt.Errorf("line %d: RDATE isn't supported", i+1)
+		case name == "UID":
+			event.uid = value
+		case name == "STATUS":
+			event.cancelled = strings.ToUpper(value) == "CANCELLED"
+		case name == "ATTENDEE":
+			if strings.HasPrefix(strings.ToLower(value), "mailto:") {
+				event.Emails = append(event.Emails, strings.ToLower(value[len("mailto:"):]))
+			}
+		}
+	}
+
+	// Occurrences of recurring events that were moved or changed are events of their own
+	moved := make(map[string][]time.Time)
+	for _, e := range events {
+		if !e.recurrenceID.IsZero() {
+			moved[e.uid] = append(moved[e.uid], e.recurrenceID)
+		}
+	}
+
+	var shifts []Shift
+	for _, e := range events {
+		if e.cancelled {
+			continue
+		}
+		s, err := e.shifts(from, to, moved[e.uid])
+		if err != nil {
+			return nil, fmt.Errorf("event %s: %v", e.uid, err)
+		}
+		shifts = append(shifts, s...)
+	}
+	return shifts, nil
+}
+
+// shifts returns the occurrences of the event overlapping from until to,
+// without those of its EXDATEs and the skipped ones
+func (e icalEvent) shifts(from, to time.Time, skipped []time.Time) ([]Shift, error) {
+	overlaps := func(s Shift) bool { return s.End.After(from) && s.Start.Before(to) }
+	if e.rule == "" {
+		if overlaps(e.Shift) {
+			return []Shift{e.Shift}, nil
+		}
+		return nil, nil
+	}
+
+	rule, err := parseRRule(e.rule, e.Start.Location())
+	if err != nil {
+		return nil, err
+	}
+	skipped = append(skipped, e.exdates...)
+	length := e.End.Sub(e.Start)
+
+	var shifts []Shift
+	rule.each(e.Start, func(start time.Time) bool {
+		if !start.Before(to) {
+			return false
+		}
+		s := Shift{Start: start, End: start.Add(length), Emails: e.Emails}
+		if overlaps(s) && !containsTime(skipped, start) {
+			shifts = append(shifts, s)
+		}
+		return true
+	})
+	return shifts, nil
+}
+
+func containsTime(times []time.Time, t time.Time) bool {
+	for _, x := range times {
+		if x.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// icalWeekdays are the days of BYDAY and WKST
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// rrule is the part of an RRULE on-call rotations use: every interval days or weeks, optionally on some days of the week
+type rrule struct {
+	weekly    bool
+	interval  int
+	count     int       // 0 without limit
+	until     time.Time // zero without limit
+	byDay     []time.Weekday
+	weekStart time.Weekday
+}
+
+// parseRRule parses a DAILY or WEEKLY RRULE, UNTIL dates are read in the location of the event
+func parseRRule(value string, loc *time.Location) (rrule, error) {
+	r := rrule{interval: 1, weekStart: time.Monday}
+	freq := ""
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return r, fmt.Errorf("invalid RRULE %q", value)
+		}
+		name, v := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		switch name {
+		case "FREQ":
+			freq = v
+		case "INTERVAL", "COUNT":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return r, fmt.Errorf("invalid %s of RRULE %q", name, value)
+			}
+			if name == "INTERVAL" {
+				r.interval = n
+			} else {
+				r.count = n
+			}
+		case "UNTIL":
+			params := map[string]string{}
+			if len(v) == len("20060102") {
+				params["VALUE"] = "DATE"
+			}
+			t, err := icalTime(params, v)
+			if err != nil {
+				return r, fmt.Errorf("invalid UNTIL of RRULE %q", value)
+			}
+			if params["VALUE"] == "DATE" {
+				// The whole day is included
+				t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, -1, 0, loc)
+			}
+			r.until = t
+		case "BYDAY", "WKST":
+			for _, d := range strings.Split(v, ",") {
+				day, ok := icalWeekdays[d]
+				if !ok {
+					return r, fmt.Errorf("%s=%s of RRULE isn't supported", name, v)
+				}
+				if name == "WKST" {
+					r.weekStart = day
+				} else {
+					r.byDay = append(r.byDay, day)
+				}
+			}
+		default:
+			return r, fmt.Errorf("%s of RRULE isn't supported", name)
+		}
+	}
+
+	switch {
+	case freq == "WEEKLY":
+		r.weekly = true
+	case freq != "DAILY":
+		return r, fmt.Errorf("RRULE with FREQ=%s isn't supported, only DAILY and WEEKLY", freq)
+	case len(r.byDay) > 0:
+		return r, fmt.Errorf("BYDAY of a DAILY RRULE isn't supported")
+	}
+	return r, nil
+}
+
+// each calls fn with the starts of the occurrences from start on, until the rule ends or fn returns false
+func (r rrule) each(start time.Time, fn func(time.Time) bool) {
+	n := 0
+	next := func(t time.Time) bool {
+		if (r.count > 0 && n >= r.count) || (!r.until.IsZero() && t.After(r.until)) {
+			return false
+		}
+		n++
+		return fn(t)
+	}
+
+	if !r.weekly || len(r.byDay) == 0 {
+		days := r.interval
+		if r.weekly {
+			days *= 7
+		}
+		for i := 0; ; i++ {
+			if !next(start.AddDate(0, 0, i*days)) {
+				return
+			}
+		}
+	}
+
+	// The days of every interval-th week, counted from the week of start
+	var offsets []int
+	for _, day := range r.byDay {
+		offsets = append(offsets, (int(day)-int(r.weekStart)+7)%7)
+	}
+	sort.Ints(offsets)
+	week := start.AddDate(0, 0, -((int(start.Weekday()) - int(r.weekStart) + 7) % 7))
+	for i := 0; ; i += r.interval {
+		for _, offset := range offsets {
+			t := week.AddDate(0, 0, i*7+offset)
+			if t.Before(start) {
+				continue
+			}
+			if !next(t) {
+				return
+			}
+		}
+	}
+}
+
+// icalProperty splits a line like ATTENDEE;CN="Doe: John":mailto:john@example.com into its name, params and value
+func icalProperty(line string) (name string, params map[string]string, value string) {
+	params = make(map[string]string)
+	quoted := false
+	end := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return "", params, ""
+	}
+
+	parts := strings.Split(line[:end], ";")
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[end+1:]
+}
+
+// icalTime parses UTC times, times of the TZID timezone, otherwise local times, and dates
+func icalTime(params map[string]string, value string) (time.Time, error) {
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+
+	switch {
+	case params["VALUE"] == "DATE":
+		return time.ParseInLocation("20060102", value, loc)
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse("20060102T150405Z", value)
+	default:
+		return time.ParseInLocation("20060102T150405", value, loc)
+	}
+}
+
+// onCallSchedule knows who is on call by the shifts of a calendar
+type onCallSchedule struct {
+	mu     sync.RWMutex
+	shifts []Shift
+	// usernames of the members by their lowercase email
+	usernames map[string]string
+}
+
+func newOnCallSchedule(usernames map[string]string) *onCallSchedule {
+	s := &onCallSchedule{usernames: make(map[string]string, len(usernames))}
+	for email, username := range usernames {
+		s.usernames[strings.ToLower(email)] = strings.TrimPrefix(username, "@")
+	}
+	return s
+}
+
+// Set replaces the shifts
+func (s *onCallSchedule) Set(shifts []Shift) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shifts = shifts
+}
+
+// OnCall returns whether the member is attending a shift at the time
+func (s *onCallSchedule) OnCall(username string, at time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, shift := range s.shifts {
+		if at.Before(shift.Start) || !at.Before(shift.End) {
+			continue
+		}
+		for _, email := range shift.Emails {
+			if s.usernames[email] == username {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// onCallCheck returns whether a member is on call now, nil without a calendar
+func (b *Bot) onCallCheck() func(username string) bool {
+	if b.onCall == nil {
+		return nil
+	}
+	return func(username string) bool {
+		return b.onCall.OnCall(username, time.Now())
+	}
+}
+
+// syncOnCall reads the shifts from the calendar now and then every interval until the context is done
+func (b *Bot) syncOnCall(ctx context.Context) error {
+	ticker := time.NewTicker(b.onCallInterval)
+	defer ticker.Stop()
+
+	for {
+		shifts, err := fetchShifts(ctx, b.onCallURL)
+		if err != nil {
+			b.reportError("failed to read on-call calendar, the previous shifts are kept", "err", err)
+		} else {
+			b.onCall.Set(shifts)
+			level.Debug(b.logger).Log("msg", "read on-call calendar", "shifts", len(shifts))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchShifts downloads the iCalendar and parses the shifts from now until the horizon
+func fetchShifts(ctx context.Context, calendarURL string) ([]Shift, error) {
+	req, err := http.NewRequest(http.MethodGet, calendarURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calendar returned %s", resp.Status)
+	}
+	now := time.Now()
+	return parseICal(resp.Body, now, now.Add(onCallHorizon))
+}
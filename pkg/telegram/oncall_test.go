@@ -0,0 +1,133 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+const onCallCalendar = "BEGIN:VCALENDAR\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART:20261015T090000Z\r\n" +
+	"DTEND:20261015T170000Z\r\n" +
+	"SUMMARY:On call\r\n" +
+	"ATTENDEE;CN=\"Long: Vu\";ROLE=REQ-PARTICIPANT:mailto:Vu.Long@example.c\r\n" +
+	" om\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20261015T190000\r\n" +
+	"DTEND;TZID=Europe/Berlin:20261016T090000\r\n" +
+	"ATTENDEE:mailto:sre@example.com\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20261017\r\n" +
+	"ATTENDEE:mailto:techleader@example.com\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+// onCallFrom and onCallTo are the window the shifts of onCallCalendar are read in
+var onCallFrom, onCallTo = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+func TestParseICal(t *testing.T) {
+	shifts, err := parseICal(strings.NewReader(onCallCalendar), onCallFrom, onCallTo)
+	assert.NoError(t, err)
+	assert.Len(t, shifts, 3)
+
+	assert.Equal(t, time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC), shifts[0].Start)
+	assert.Equal(t, []string{"vu.long@example.com"}, shifts[0].Emails, "folded lines are unfolded and quoted params skipped")
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err == nil {
+		assert.True(t, time.Date(2026, 10, 15, 19, 0, 0, 0, berlin).Equal(shifts[1].Start))
+	}
+
+	assert.Equal(t, 24*time.Hour, shifts[2].End.Sub(shifts[2].Start), "all-day events last the day")
+
+	shifts, err = parseICal(strings.NewReader(onCallCalendar), onCallTo, onCallTo.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, shifts, "shifts outside the window are left out")
+
+	_, err = parseICal(strings.NewReader("BEGIN:VEVENT\nDTSTART:tomorrow\nEND:VEVENT\n"), onCallFrom, onCallTo)
+	assert.Error(t, err)
+
+	shifts, err = parseICal(strings.NewReader("BEGIN:VEVENT\nDTSTART:20261015T090000Z\nDTEND:20261015T170000Z\n"+
+		"ATTENDEE;CN=Vu Long:MAILTO:Vu.Long@example.com\nATTENDEE:urn:uuid:SRE\nEND:VEVENT\n"), onCallFrom, onCallTo)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vu.long@example.com"}, shifts[0].Emails, "only mailto attendees have an email")
+}
+
+func TestParseICalRecurring(t *testing.T) {
+	event := func(lines ...string) string {
+		return "BEGIN:VEVENT\n" + strings.Join(lines, "\n") + "\nATTENDEE:mailto:sre@example.com\nEND:VEVENT\n"
+	}
+	starts := func(shifts []Shift) []string {
+		var s []string
+		for _, shift := range shifts {
+			s = append(s, shift.Start.Format("Mon 01-02 15:04"))
+		}
+		return s
+	}
+
+	// A weekly rotation, the occurrence of 10-19 was moved to the afternoon and the one of 10-26 cancelled
+	calendar := event("UID:rotation", "DTSTART:20261005T090000Z", "DTEND:20261005T170000Z", "RRULE:FREQ=WEEKLY;BYDAY=MO,TH", "EXDATE:20261008T090000Z") +
+		event("UID:rotation", "RECURRENCE-ID:20261019T090000Z", "DTSTART:20261019T130000Z", "DTEND:20261019T170000Z") +
+		event("UID:rotation", "RECURRENCE-ID:20261026T090000Z", "DTSTART:20261026T090000Z", "DTEND:20261026T170000Z", "STATUS:CANCELLED")
+	shifts, err := parseICal(strings.NewReader(calendar), time.Date(2026, 10, 6, 0, 0, 0, 0, time.UTC), onCallTo)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Mon 10-12 09:00", "Thu 10-15 09:00", "Thu 10-22 09:00", "Thu 10-29 09:00", "Mon 10-19 13:00"}, starts(shifts))
+	assert.Equal(t, 8*time.Hour, shifts[0].End.Sub(shifts[0].Start))
+	assert.Equal(t, []string{"sre@example.com"}, shifts[0].Emails)
+
+	shifts, err = parseICal(strings.NewReader(event("DTSTART:20261005T090000Z", "DTEND:20261006T090000Z", "RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=2")), onCallFrom, onCallTo)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Mon 10-05 09:00", "Mon 10-19 09:00"}, starts(shifts))
+
+	shifts, err = parseICal(strings.NewReader(event("DTSTART;VALUE=DATE:20261028", "RRULE:FREQ=DAILY;UNTIL=20261030")), onCallFrom, onCallTo)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Wed 10-28 00:00", "Thu 10-29 00:00", "Fri 10-30 00:00"}, starts(shifts))
+
+	// A rotation that started long ago is still read
+	shifts, err = parseICal(strings.NewReader(event("DTSTART:20200101T000000Z", "DTEND:20200102T000000Z", "RRULE:FREQ=DAILY")), onCallFrom, onCallFrom.Add(48*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Thu 10-01 00:00", "Fri 10-02 00:00"}, starts(shifts))
+
+	for _, rule := range []string{"RRULE:FREQ=MONTHLY", "RRULE:FREQ=WEEKLY;BYDAY=1MO", "RRULE:FREQ=DAILY;BYHOUR=9", "RDATE:20261010T090000Z"} {
+		_, err := parseICal(strings.NewReader(event("DTSTART:20261005T090000Z", "DTEND:20261005T170000Z", rule)), onCallFrom, onCallTo)
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestOnCallAssignee(t *testing.T) {
+	shifts, err := parseICal(strings.NewReader(onCallCalendar), onCallFrom, onCallTo)
+	assert.NoError(t, err)
+	s := newOnCallSchedule(map[string]string{"vu.long@example.com": "@vu_long", "SRE@example.com": "sre"})
+	s.Set(shifts)
+
+	at := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	assert.True(t, s.OnCall("vu_long", at))
+	assert.False(t, s.OnCall("sre", at))
+	assert.False(t, s.OnCall("vu_long", at.Add(5*time.Hour)), "shifts end at DTEND")
+
+	chat := telebot.Chat{ID: -100}
+	a := &HandleAlert{
+		Chat:  chat,
+		Level: levelOne,
+		MemberStore: fakeMemberStore{
+			{Username: "alice", Level: levelOne, Chat: chat},
+			{Username: "vu_long", Level: levelOne, Chat: chat},
+			{Username: "sre", Level: levelTwo, Chat: chat},
+		},
+		OnCall: func(username string) bool { return s.OnCall(username, at) },
+	}
+	m, err := a.assignee()
+	assert.NoError(t, err)
+	assert.Equal(t, "vu_long", m.Username)
+
+	a.Level = levelTwo
+	m, err = a.assignee()
+	assert.NoError(t, err)
+	assert.Equal(t, Member{}, m, "falls back to a random member if nobody of the level is on call")
+}
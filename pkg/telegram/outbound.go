@@ -0,0 +1,23 @@
+package telegram
+
+import "github.com/vu-long/alertmanager-bot/pkg/outbound"
+
+// outboundEventTypes are sent to the outbound webhooks by default
+var outboundEventTypes = []string{eventAcknowledged, eventForwarded, eventAutoForwarded, eventResolved}
+
+// notifyOutbound sends the events of the types configured with WithOutboundWebhooks to the webhooks
+func (b *Bot) notifyOutbound(e Event) {
+	if !b.outboundTypes[e.Type] {
+		return
+	}
+
+	b.outbound.Notify(outbound.Event{
+		Type:    e.Type,
+		Time:    e.Time,
+		ChatID:  e.ChatID,
+		AlertID: e.AlertID,
+		User:    e.User,
+		Labels:  e.Labels,
+		Detail:  e.Detail,
+	})
+}
@@ -0,0 +1,39 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/vu-long/alertmanager-bot/pkg/outbound"
+)
+
+func TestNotifyOutbound(t *testing.T) {
+	var types []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e outbound.Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		types = append(types, e.Type)
+	}))
+	defer srv.Close()
+
+	c := outbound.New([]string{srv.URL}, "", log.NewNopLogger())
+	b := &Bot{}
+	WithOutboundWebhooks(c)(b)
+
+	for _, typ := range []string{eventFired, eventAcknowledged, eventCallback, eventForwarded, eventAutoForwarded, eventResolved} {
+		b.notifyOutbound(Event{Type: typ, ChatID: -100, AlertID: "HighCPU"})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Run(ctx)
+	assert.Equal(t, []string{eventAcknowledged, eventForwarded, eventAutoForwarded, eventResolved}, types)
+
+	WithOutboundWebhooks(c, eventResolved)(b)
+	assert.Equal(t, map[string]bool{eventResolved: true}, b.outboundTypes)
+}
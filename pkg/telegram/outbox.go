@@ -0,0 +1,272 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	telegramOutboxDirectory = "telegram/outbox"
+
+	// outboxInterval in which the outbox is checked for messages due to be retried
+	outboxInterval = 10 * time.Second
+	// outboxBackoff is the wait before the first retry, doubled after every failed attempt
+	outboxBackoff = 30 * time.Second
+	// maxOutboxBackoff limits the wait between two attempts
+	maxOutboxBackoff = time.Hour
+)
+
+// OutboxEntry is a message recorded before it is sent and removed once Telegram accepted it
+type OutboxEntry struct {
+	ID          string            `json:"id"`
+	Destination string            `json:"destination"`
+	Text        string            `json:"text"`
+	ParseMode   telebot.ParseMode `json:"parseMode,omitempty"`
+	ReplyTo     int               `json:"replyTo,omitempty"`
+	// Buttons are the URL buttons of the message, messages with callback buttons aren't recorded
+	Buttons     [][]telebot.KeyboardButton `json:"buttons,omitempty"`
+	Created     time.Time                  `json:"created"`
+	Attempts    int                        `json:"attempts"`
+	NextAttempt time.Time                  `json:"nextAttempt"`
+	LastError   string                     `json:"lastError,omitempty"`
+}
+
+// destination is the recipient of a message of the outbox
+type destination string
+
+// Destination is the chat ID of the recipient
+func (d destination) Destination() string {
+	return string(d)
+}
+
+// backoff returns the wait after the failed attempts
+func backoff(attempts int) time.Duration {
+	wait := outboxBackoff
+	for i := 1; i < attempts && wait < maxOutboxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxOutboxBackoff {
+		return maxOutboxBackoff
+	}
+	return wait
+}
+
+// OutboxStore writes the messages not yet delivered to a libkv store backend
+type OutboxStore struct {
+	kv store.Store
+}
+
+// NewOutboxStore stores the outbox in the provided kv backend
+func NewOutboxStore(kv store.Store) (*OutboxStore, error) {
+	return &OutboxStore{kv: kv}, nil
+}
+
+// List the undelivered messages from the kv backend, oldest first
+func (s *OutboxStore) List() ([]OutboxEntry, error) {
+	kvPairs, err := s.kv.List(telegramOutboxDirectory)
+	if err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+
+	var entries []OutboxEntry
+	for _, kv := range kvPairs {
+		var e OutboxEntry
+		if err := json.Unmarshal(kv.Value, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Created.Before(entries[j].Created)
+	})
+	return entries, nil
+}
+
+// Put adds or updates the message in the kv backend
+func (s *OutboxStore) Put(e OutboxEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.kv.Put(outboxKey(e.ID), b, nil)
+}
+
+// Remove the delivered message from the kv backend
+func (s *OutboxStore) Remove(e OutboxEntry) error {
+	err := s.kv.Delete(outboxKey(e.ID))
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+func outboxKey(id string) string {
+	return fmt.Sprintf("%s/%s", telegramOutboxDirectory, id)
+}
+
+// permanentError returns whether Telegram refused the message for good, e.g. because the chat
+// doesn't exist or the bot was blocked, retrying it won't help
+func permanentError(err error) bool {
+	return strings.Contains(err.Error(), "Bad Request:") || strings.Contains(err.Error(), "Forbidden:")
+}
+
+// outbox records every message before sending it and retries those Telegram didn't accept with backoff,
+// also after a restart. Edits and answers of callbacks are sent as they are, and so are the messages of alerts:
+// their callback buttons only work for the HandleAlert their first delivery creates.
+type outbox struct {
+	MessageSender
+	store  BotOutboxStore
+	logger log.Logger
+	// maxAge after which undelivered messages are given up
+	maxAge time.Duration
+	// started is when the bot started, earlier messages were left over by a previous run
+	started time.Time
+	next    uint64 // atomic, makes the IDs of messages created at the same time unique
+	// owns returns whether the destination's messages are retried by this replica, all if nil
+	owns func(destination string) bool
+}
+
+func newOutbox(sender MessageSender, store BotOutboxStore, maxAge time.Duration, logger log.Logger) *outbox {
+	return &outbox{MessageSender: sender, store: store, maxAge: maxAge, logger: logger, started: time.Now()}
+}
+
+// SendMessage records the message, sends it and removes it once delivered.
+// Failed messages are retried by Run unless Telegram refused them for good, the error is still returned to the caller.
+func (o *outbox) SendMessage(recipient telebot.Recipient, text string, options *telebot.SendOptions) (*telebot.Message, error) {
+	if options != nil && hasCallbackButtons(options.ReplyMarkup.InlineKeyboard) {
+		return o.MessageSender.SendMessage(recipient, text, options)
+	}
+
+	now := time.Now()
+	e := OutboxEntry{
+		ID:          fmt.Sprintf("%020d-%d", now.UnixNano(), atomic.AddUint64(&o.next, 1)),
+		Destination: recipient.Destination(),
+		Text:        text,
+		Created:     now,
+		Attempts:    1,
+		// Messages being sent aren't retried unless their attempt takes longer than the backoff
+		NextAttempt: now.Add(backoff(1)),
+	}
+	if options != nil {
+		e.ParseMode = options.ParseMode
+		e.ReplyTo = options.ReplyTo.ID
+		e.Buttons = options.ReplyMarkup.InlineKeyboard
+	}
+	if err := o.store.Put(e); err != nil {
+		level.Warn(o.logger).Log("msg", "failed to record message in outbox", "err", err)
+	}
+
+	message, err := o.MessageSender.SendMessage(recipient, text, options)
+	if err != nil && permanentError(err) {
+		if err := o.store.Remove(e); err != nil {
+			level.Warn(o.logger).Log("msg", "failed to remove refused message from outbox", "err", err)
+		}
+		return nil, err
+	}
+	if err != nil {
+		e.LastError = err.Error()
+		if err := o.store.Put(e); err != nil {
+			level.Warn(o.logger).Log("msg", "failed to update message in outbox", "err", err)
+		}
+		return nil, err
+	}
+	if err := o.store.Remove(e); err != nil {
+		level.Warn(o.logger).Log("msg", "failed to remove delivered message from outbox", "err", err)
+	}
+	return message, nil
+}
+
+// hasCallbackButtons returns whether the keyboard has buttons other than URL buttons
+func hasCallbackButtons(keyboard [][]telebot.KeyboardButton) bool {
+	for _, row := range keyboard {
+		for _, button := range row {
+			if button.URL == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Run retries the undelivered messages, starting with those left over by a previous run, until the context is canceled
+func (o *outbox) Run(ctx context.Context) error {
+	ticker := time.NewTicker(outboxInterval)
+	defer ticker.Stop()
+
+	o.retry(time.Now(), true)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			o.retry(now, false)
+		}
+	}
+}
+
+// retry sends the messages due at the time, or all of them at startup
+func (o *outbox) retry(now time.Time, startup bool) {
+	entries, err := o.store.List()
+	if err != nil {
+		level.Warn(o.logger).Log("msg", "failed to list outbox", "err", err)
+		return
+	}
+
+	for _, e := range entries {
+		if o.owns != nil && !o.owns(e.Destination) {
+			continue
+		}
+		leftOver := startup && e.Created.Before(o.started)
+		if !leftOver && now.Before(e.NextAttempt) {
+			continue
+		}
+		if o.maxAge > 0 && now.Sub(e.Created) > o.maxAge {
+			level.Error(o.logger).Log("msg", "giving up undelivered message", "destination", e.Destination, "attempts", e.Attempts, "err", e.LastError)
+			if err := o.store.Remove(e); err != nil {
+				level.Warn(o.logger).Log("msg", "failed to remove message from outbox", "err", err)
+			}
+			continue
+		}
+
+		options := &telebot.SendOptions{
+			ParseMode:   e.ParseMode,
+			ReplyTo:     telebot.Message{ID: e.ReplyTo},
+			ReplyMarkup: telebot.ReplyMarkup{InlineKeyboard: e.Buttons},
+		}
+		_, err := o.MessageSender.SendMessage(destination(e.Destination), e.Text, options)
+		if err == nil {
+			level.Info(o.logger).Log("msg", "delivered message from outbox", "destination", e.Destination, "attempts", e.Attempts+1)
+			if err := o.store.Remove(e); err != nil {
+				level.Warn(o.logger).Log("msg", "failed to remove delivered message from outbox", "err", err)
+			}
+			continue
+		}
+
+		if permanentError(err) {
+			level.Error(o.logger).Log("msg", "giving up refused message", "destination", e.Destination, "attempts", e.Attempts+1, "err", err)
+			if err := o.store.Remove(e); err != nil {
+				level.Warn(o.logger).Log("msg", "failed to remove message from outbox", "err", err)
+			}
+			continue
+		}
+
+		e.Attempts++
+		e.NextAttempt = now.Add(backoff(e.Attempts))
+		e.LastError = err.Error()
+		level.Warn(o.logger).Log("msg", "failed to deliver message from outbox", "destination", e.Destination, "attempts", e.Attempts, "err", err)
+		if err := o.store.Put(e); err != nil {
+			level.Warn(o.logger).Log("msg", "failed to update message in outbox", "err", err)
+		}
+	}
+}
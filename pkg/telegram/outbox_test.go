@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+// failingSender fails to send until it is fixed, a blocked bot fails for good
+type failingSender struct {
+	*fakeSender
+	fail    bool
+	blocked bool
+}
+
+func (s *failingSender) SendMessage(recipient telebot.Recipient, text string, options *telebot.SendOptions) (*telebot.Message, error) {
+	if s.blocked {
+		return nil, errors.New("api error: Forbidden: bot was blocked by the user")
+	}
+	if s.fail {
+		return nil, errors.New("telegram: Too Many Requests")
+	}
+	return s.fakeSender.SendMessage(recipient, text, options)
+}
+
+func TestOutbox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	defer kv.Close()
+	s, err := NewOutboxStore(kv)
+	assert.NoError(t, err)
+
+	sender := &failingSender{fakeSender: newFakeSender()}
+	o := newOutbox(sender, s, time.Hour, log.NewNopLogger())
+	chat := telebot.Chat{ID: -100}
+
+	// Delivered messages don't stay in the outbox
+	_, err = o.SendMessage(chat, "HighCPU is firing", nil)
+	assert.NoError(t, err)
+	entries, err := s.List()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	sender.fail = true
+	// Alerts with callback buttons aren't recorded, resent they'd have no HandleAlert
+	_, err = o.SendMessage(chat, "HighLoad is firing", &telebot.SendOptions{
+		ReplyMarkup: telebot.ReplyMarkup{InlineKeyboard: [][]telebot.KeyboardButton{{{Text: strAcknowledgeData, Data: "{}"}}}},
+	})
+	assert.Error(t, err)
+	entries, err = s.List()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = o.SendMessage(chat, "DiskFull is firing", &telebot.SendOptions{
+		ParseMode:   telebot.ModeHTML,
+		ReplyMarkup: telebot.ReplyMarkup{InlineKeyboard: [][]telebot.KeyboardButton{{{Text: "Runbook", URL: "https://wiki/disk"}}}},
+	})
+	assert.Error(t, err)
+	entries, err = s.List()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "-100", entries[0].Destination)
+	assert.Equal(t, [][]telebot.KeyboardButton{{{Text: "Runbook", URL: "https://wiki/disk"}}}, entries[0].Buttons)
+	assert.Equal(t, "telegram: Too Many Requests", entries[0].LastError)
+
+	// Not retried before the backoff
+	now := time.Now()
+	o.retry(now, false)
+	entries, _ = s.List()
+	assert.Equal(t, 1, entries[0].Attempts)
+
+	o.retry(now.Add(backoff(1)), false)
+	entries, _ = s.List()
+	assert.Equal(t, 2, entries[0].Attempts)
+
+	sender.fail = false
+	o.retry(now.Add(backoff(1)+backoff(2)), false)
+	entries, _ = s.List()
+	assert.Empty(t, entries)
+	assert.Equal(t, []string{"HighCPU is firing", "DiskFull is firing"}, sender.sent)
+
+	// Messages Telegram refuses for good aren't retried
+	sender.blocked = true
+	_, err = o.SendMessage(chat, "HighLoad is firing", nil)
+	assert.Error(t, err)
+	entries, _ = s.List()
+	assert.Empty(t, entries)
+
+	sender.blocked = false
+	sender.fail = true
+	_, err = o.SendMessage(chat, "HighLoad is firing", nil)
+	assert.Error(t, err)
+	sender.blocked = true
+	o.retry(time.Now().Add(backoff(1)), false)
+	entries, _ = s.List()
+	assert.Empty(t, entries)
+}
+
+func TestOutboxLeftOver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	defer kv.Close()
+	s, _ := NewOutboxStore(kv)
+
+	// A message recorded before a crash is sent right after the restart, an old one is given up
+	now := time.Now()
+	assert.NoError(t, s.Put(OutboxEntry{ID: "1", Destination: "-100", Text: "HighCPU is firing", Created: now.Add(-time.Minute), Attempts: 1, NextAttempt: now.Add(time.Minute)}))
+	assert.NoError(t, s.Put(OutboxEntry{ID: "2", Destination: "-100", Text: "Stale", Created: now.Add(-2 * time.Hour), Attempts: 9, NextAttempt: now}))
+
+	sender := newFakeSender()
+	o := newOutbox(sender, s, time.Hour, log.NewNopLogger())
+	o.retry(now, true)
+
+	entries, _ := s.List()
+	assert.Empty(t, entries)
+	assert.Equal(t, []string{"HighCPU is firing"}, sender.sent)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, outboxBackoff, backoff(1))
+	assert.Equal(t, 4*outboxBackoff, backoff(3))
+	assert.Equal(t, maxOutboxBackoff, backoff(20))
+}
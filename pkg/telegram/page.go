@@ -0,0 +1,53 @@
+package telegram
+
+import (
+	"fmt"
+
+	"github.com/vu-long/alertmanager-bot/pkg/pager"
+)
+
+// pagerSeverities map the severity label of alerts to the severities of incidents
+var pagerSeverities = map[string]string{
+	"critical": pager.SeverityCritical,
+	"page":     pager.SeverityCritical,
+	"error":    pager.SeverityError,
+	"warning":  pager.SeverityWarning,
+	"info":     pager.SeverityInfo,
+}
+
+// incidentKey identifies the incident of an alert in a chat
+func incidentKey(e Event) string {
+	return fmt.Sprintf("alertmanager-bot/%d/%s", e.ChatID, e.AlertID)
+}
+
+// incident of an alert whose escalation was exhausted
+func incident(e Event) pager.Incident {
+	severity, ok := pagerSeverities[e.Labels["severity"]]
+	if !ok {
+		severity = pager.SeverityCritical
+	}
+
+	details := make(map[string]string, len(e.Labels)+1)
+	for name, value := range e.Labels {
+		details[name] = value
+	}
+	details["telegram_chat_id"] = fmt.Sprint(e.ChatID)
+
+	return pager.Incident{
+		Key:      incidentKey(e),
+		Summary:  fmt.Sprintf("%s wasn't acknowledged in Telegram after escalating to all levels", e.AlertID),
+		Source:   "alertmanager-bot",
+		Severity: severity,
+		Details:  details,
+	}
+}
+
+// page triggers an incident once the escalation of an alert is exhausted and resolves it with the alert
+func (b *Bot) page(e Event) {
+	switch e.Type {
+	case eventExhausted:
+		b.pager.Trigger(incident(e))
+	case eventResolved:
+		b.pager.Resolve(incidentKey(e))
+	}
+}
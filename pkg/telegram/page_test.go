@@ -0,0 +1,26 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vu-long/alertmanager-bot/pkg/pager"
+)
+
+func TestIncident(t *testing.T) {
+	i := incident(Event{
+		Type:    eventExhausted,
+		ChatID:  -100,
+		AlertID: "HighCPU",
+		Labels:  map[string]string{"alertname": "HighCPU", "severity": "warning"},
+	})
+	assert.Equal(t, pager.Incident{
+		Key:      "alertmanager-bot/-100/HighCPU",
+		Summary:  "HighCPU wasn't acknowledged in Telegram after escalating to all levels",
+		Source:   "alertmanager-bot",
+		Severity: pager.SeverityWarning,
+		Details:  map[string]string{"alertname": "HighCPU", "severity": "warning", "telegram_chat_id": "-100"},
+	}, i)
+
+	assert.Equal(t, pager.SeverityCritical, incident(Event{ChatID: -100, AlertID: "HighCPU"}).Severity, "unknown severities page as critical")
+}
@@ -0,0 +1,169 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	strPageData = "Page"
+
+	// pagesTTL after which the pages of a list are dropped and its buttons stop working
+	pagesTTL = 30 * time.Minute
+	// itemsPerPage of the lists paginated by item
+	itemsPerPage = 20
+	// alertsPerPage of /alerts, rendered alerts are longer than the other items
+	alertsPerPage = 5
+)
+
+// pagedList is a result set sent page by page
+type pagedList struct {
+	pages   []string
+	mode    telebot.ParseMode
+	expires time.Time
+}
+
+// paginator caches the pages of the lists sent by their ID
+type paginator struct {
+	mu    sync.Mutex
+	next  int
+	lists map[string]*pagedList
+}
+
+func newPaginator() *paginator {
+	return &paginator{lists: make(map[string]*pagedList)}
+}
+
+// Add the pages of a list and return its ID, expired lists are removed
+func (p *paginator) Add(pages []string, mode telebot.ParseMode, now time.Time) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id, l := range p.lists {
+		if now.After(l.expires) {
+			delete(p.lists, id)
+		}
+	}
+
+	p.next++
+	id := strconv.Itoa(p.next)
+	p.lists[id] = &pagedList{pages: pages, mode: mode, expires: now.Add(pagesTTL)}
+	return id
+}
+
+// Get a page of the list, ok is false if the list or page doesn't exist or expired
+func (p *paginator) Get(id string, page int, now time.Time) (text string, mode telebot.ParseMode, pages int, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l, ok := p.lists[id]
+	if !ok || now.After(l.expires) || page < 0 || page >= len(l.pages) {
+		return "", "", 0, false
+	}
+	return l.pages[page], l.mode, len(l.pages), true
+}
+
+// paginate groups the items into pages starting with the header,
+// each of at most perPage items and at most maxMessageLength long
+func paginate(header string, items []string, perPage int) []string {
+	var pages []string
+	page, count := header, 0
+	for _, item := range items {
+		item = truncate(maxMessageLength-len(header)-1, item) + "\n"
+		if count > 0 && (count == perPage || len(page)+len(item) > maxMessageLength) {
+			pages = append(pages, page)
+			page, count = header, 0
+		}
+		page += item
+		count++
+	}
+	return append(pages, page)
+}
+
+// pageKeyboard navigates between the pages of a list
+func pageKeyboard(id string, page, pages int) ([][]telebot.KeyboardButton, error) {
+	button := func(text string, to int) (telebot.KeyboardButton, error) {
+		data, err := json.Marshal(CallbackData{Button: strPageData, List: id, Value: strconv.Itoa(to)})
+		if err != nil {
+			return telebot.KeyboardButton{}, err
+		}
+		return telebot.KeyboardButton{Text: text, Data: string(data)}, nil
+	}
+
+	var row []telebot.KeyboardButton
+	if page > 0 {
+		prev, err := button("« Prev", page-1)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, prev)
+	}
+	current, err := button(fmt.Sprintf("%d/%d", page+1, pages), page)
+	if err != nil {
+		return nil, err
+	}
+	row = append(row, current)
+	if page < pages-1 {
+		next, err := button("Next »", page+1)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, next)
+	}
+	return [][]telebot.KeyboardButton{row}, nil
+}
+
+// sendPages replies to the message with the first page and buttons to navigate to the others, a single page without
+func (b *Bot) sendPages(message telebot.Message, pages []string, mode telebot.ParseMode) {
+	options := &telebot.SendOptions{ParseMode: mode}
+	if len(pages) > 1 {
+		id := b.pages.Add(pages, mode, time.Now())
+		keyboard, err := pageKeyboard(id, 0, len(pages))
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to create page keyboard", "err", err)
+			return
+		}
+		options.ReplyMarkup = b.callbacks.markup(keyboard)
+	}
+
+	if _, err := b.reply(message, pages[0], options); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send message", "chat_id", message.Chat.ID, "err", err)
+	}
+}
+
+// handlePageCallback shows the selected page of a list
+func (b *Bot) handlePageCallback(callback telebot.Callback, cd CallbackData) {
+	page, err := strconv.Atoi(cd.Value)
+	if err != nil {
+		return
+	}
+	text, mode, pages, ok := b.pages.Get(cd.List, page, time.Now())
+	if !ok {
+		b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{
+			Text: "This list expired, please send the command again.",
+		})
+		return
+	}
+	b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{})
+
+	keyboard, err := pageKeyboard(cd.List, page, pages)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to create page keyboard", "err", err)
+		return
+	}
+	err = b.sender.EditMessageText(callback.Message.Chat, callback.Message.ID, text, &telebot.SendOptions{
+		ParseMode:   mode,
+		ReplyMarkup: b.callbacks.markup(keyboard),
+	})
+	// Pressing the current page doesn't change the message, which Telegram refuses
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		level.Warn(b.logger).Log("msg", "failed to show page", "err", err)
+	}
+}
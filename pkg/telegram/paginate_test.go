@@ -0,0 +1,69 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginate(t *testing.T) {
+	assert.Equal(t, []string{"Chats:\n"}, paginate("Chats:\n", nil, 2))
+	assert.Equal(t,
+		[]string{"Chats:\na\nb\n", "Chats:\nc\n"},
+		paginate("Chats:\n", []string{"a", "b", "c"}, 2),
+	)
+
+	long := strings.Repeat("x", 3000)
+	pages := paginate("Chats:\n", []string{long, long, "a"}, itemsPerPage)
+	assert.Len(t, pages, 2)
+	for _, page := range pages {
+		assert.True(t, len(page) <= maxMessageLength)
+		assert.True(t, strings.HasPrefix(page, "Chats:\n"))
+	}
+
+	pages = paginate("Chats:\n", []string{strings.Repeat("x", 2*maxMessageLength)}, itemsPerPage)
+	assert.Len(t, pages, 1)
+	assert.True(t, utf8.RuneCountInString(pages[0]) <= maxMessageLength, "items longer than a message are truncated")
+}
+
+func TestPaginator(t *testing.T) {
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	p := newPaginator()
+
+	id := p.Add([]string{"first", "second"}, "Markdown", now)
+	text, mode, pages, ok := p.Get(id, 1, now.Add(time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, "second", text)
+	assert.Equal(t, "Markdown", string(mode))
+	assert.Equal(t, 2, pages)
+
+	_, _, _, ok = p.Get(id, 2, now)
+	assert.False(t, ok, "page out of range")
+	_, _, _, ok = p.Get("unknown", 0, now)
+	assert.False(t, ok)
+	_, _, _, ok = p.Get(id, 0, now.Add(pagesTTL+time.Second))
+	assert.False(t, ok, "expired list")
+
+	other := p.Add([]string{"other"}, "", now.Add(pagesTTL+time.Second))
+	assert.NotEqual(t, id, other)
+	assert.Len(t, p.lists, 1, "expired lists are removed")
+}
+
+func TestPageKeyboard(t *testing.T) {
+	keyboard, err := pageKeyboard("1", 0, 3)
+	assert.NoError(t, err)
+	assert.Len(t, keyboard[0], 2, "the first page has no previous button")
+	assert.Equal(t, "1/3", keyboard[0][0].Text)
+	assert.Equal(t, "Next »", keyboard[0][1].Text)
+
+	keyboard, err = pageKeyboard("123456789", 998, 999)
+	assert.NoError(t, err)
+	assert.Len(t, keyboard[0], 2, "the last page has no next button")
+	assert.Equal(t, "« Prev", keyboard[0][0].Text)
+	for _, button := range keyboard[0] {
+		assert.True(t, len(button.Data) <= 64, "telegram limits the callback data to 64 bytes: %s", button.Data)
+	}
+}
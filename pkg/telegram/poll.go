@@ -0,0 +1,118 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	// pollTimeout is how long Telegram holds a request for updates open when there are none
+	pollTimeout = 10 * time.Second
+	// pollRetryInterval after which failed requests for updates are retried
+	pollRetryInterval = time.Second
+	// pollStallTimeout after which a poll loop that didn't get updates, successfully or not, is stalled
+	pollStallTimeout = 3 * pollTimeout
+)
+
+// telegramAPI is the URL of the Telegram Bot API, changed by tests
+var telegramAPI = "https://api.telegram.org"
+
+// poll passes the messages and callbacks sent to the bot to the channels until the context is canceled.
+// It replaces telebot's Start which polls forever.
+func (b *Bot) poll(ctx context.Context, messages chan<- telebot.Message, callbacks chan<- telebot.Callback) error {
+	var offset int64
+	for {
+		updates, err := b.getUpdates(ctx, offset)
+		atomic.StoreInt64(&b.polled, time.Now().UnixNano())
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			level.Warn(b.logger).Log("msg", "failed to get updates from telegram", "err", err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(pollRetryInterval):
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			switch {
+			case update.Payload != nil:
+				select {
+				case messages <- *update.Payload:
+				case <-ctx.Done():
+					return nil
+				}
+			case update.Callback != nil:
+				select {
+				case callbacks <- *update.Callback:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			offset = update.ID + 1
+		}
+	}
+}
+
+// Alive returns an error if polling Telegram stalled, e.g. because the processing of the messages hangs.
+// Failing requests don't stall it, a bot that doesn't poll yet, e.g. a standby, is alive.
+func (b *Bot) Alive(now time.Time) error {
+	polled := atomic.LoadInt64(&b.polled)
+	if polled == 0 {
+		return nil
+	}
+	if since := now.Sub(time.Unix(0, polled)); since > pollStallTimeout {
+		return fmt.Errorf("polling telegram stalled for %s", since)
+	}
+	return nil
+}
+
+// getUpdates long polls Telegram for the updates starting at the offset
+func (b *Bot) getUpdates(ctx context.Context, offset int64) ([]telebot.Update, error) {
+	params := url.Values{}
+	params.Set("offset", strconv.FormatInt(offset, 10))
+	params.Set("timeout", strconv.Itoa(int(pollTimeout/time.Second)))
+	u := fmt.Sprintf("%s/bot%s/getUpdates?%s", telegramAPI, b.telegram.Token, params.Encode())
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*pollTimeout)
+	defer cancel()
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		// The error contains the URL with the token
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Ok          bool             `json:"ok"`
+		Result      []telebot.Update `json:"result"`
+		Description string           `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode updates: %v", err)
+	}
+	if !result.Ok {
+		return nil, fmt.Errorf("telegram: %s", result.Description)
+	}
+	return result.Result, nil
+}
@@ -0,0 +1,64 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestPoll(t *testing.T) {
+	offsets := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bottoken/getUpdates", r.URL.Path)
+		offset := r.URL.Query().Get("offset")
+		offsets <- offset
+		if offset == "0" {
+			fmt.Fprint(w, `{"ok":true,"result":[{"update_id":7,"message":{"message_id":1,"text":"/status"}}]}`)
+			return
+		}
+		// Hold the long poll open until the bot stops
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	api := telegramAPI
+	telegramAPI = srv.URL
+	defer func() { telegramAPI = api }()
+
+	b := &Bot{logger: log.NewNopLogger(), telegram: &telebot.Bot{Token: "token"}}
+	messages := make(chan telebot.Message, 1)
+	callbacks := make(chan telebot.Callback, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- b.poll(ctx, messages, callbacks) }()
+
+	assert.Equal(t, "/status", (<-messages).Text)
+	assert.Equal(t, "0", <-offsets)
+	assert.Equal(t, "8", <-offsets, "the next poll starts after the received update")
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("poll didn't return after the context was canceled")
+	}
+}
+
+func TestAlive(t *testing.T) {
+	b := &Bot{}
+	now := time.Now()
+	assert.NoError(t, b.Alive(now), "a bot that doesn't poll yet is alive")
+
+	b.polled = now.UnixNano()
+	assert.NoError(t, b.Alive(now.Add(pollStallTimeout)))
+	assert.Error(t, b.Alive(now.Add(pollStallTimeout+time.Second)))
+}
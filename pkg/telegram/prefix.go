@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"strings"
+
+	"github.com/docker/libkv/store"
+)
+
+// prefixedStore keeps all keys of a libkv backend under a prefix, so that several bots
+// sharing the backend have their own chats, alerts and outbox next to the shared data.
+type prefixedStore struct {
+	store.Store
+
+	prefix string
+}
+
+// NewPrefixedStore wraps the kv backend so that all keys are kept under the prefix
+func NewPrefixedStore(kv store.Store, prefix string) store.Store {
+	return &prefixedStore{Store: kv, prefix: strings.Trim(prefix, "/") + "/"}
+}
+
+func (s *prefixedStore) key(key string) string {
+	return s.prefix + strings.TrimPrefix(key, "/")
+}
+
+// pair returns the pair of the backend with the key without prefix
+func (s *prefixedStore) pair(pair *store.KVPair) *store.KVPair {
+	if pair == nil {
+		return nil
+	}
+	p := *pair
+	p.Key = strings.TrimPrefix(strings.TrimPrefix(p.Key, "/"), s.prefix)
+	return &p
+}
+
+func (s *prefixedStore) pairs(pairs []*store.KVPair) []*store.KVPair {
+	stripped := make([]*store.KVPair, 0, len(pairs))
+	for _, p := range pairs {
+		stripped = append(stripped, s.pair(p))
+	}
+	return stripped
+}
+
+// previous returns the pair passed to the backend for an atomic operation with the prefixed key
+func (s *prefixedStore) previous(pair *store.KVPair) *store.KVPair {
+	if pair == nil {
+		return nil
+	}
+	p := *pair
+	p.Key = s.key(p.Key)
+	return &p
+}
+
+// Put a value at the specified key
+func (s *prefixedStore) Put(key string, value []byte, options *store.WriteOptions) error {
+	return s.Store.Put(s.key(key), value, options)
+}
+
+// Get a value given its key
+func (s *prefixedStore) Get(key string) (*store.KVPair, error) {
+	pair, err := s.Store.Get(s.key(key))
+	return s.pair(pair), err
+}
+
+// Delete the value at the specified key
+func (s *prefixedStore) Delete(key string) error {
+	return s.Store.Delete(s.key(key))
+}
+
+// Exists verifies if a key exists in the store
+func (s *prefixedStore) Exists(key string) (bool, error) {
+	return s.Store.Exists(s.key(key))
+}
+
+// Watch for changes on a key
+func (s *prefixedStore) Watch(key string, stopCh <-chan struct{}) (<-chan *store.KVPair, error) {
+	events, err := s.Store.Watch(s.key(key), stopCh)
+	if err != nil {
+		return nil, err
+	}
+	stripped := make(chan *store.KVPair)
+	go func() {
+		defer close(stripped)
+		for pair := range events {
+			select {
+			case stripped <- s.pair(pair):
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return stripped, nil
+}
+
+// WatchTree watches for changes on child nodes under a given directory
+func (s *prefixedStore) WatchTree(directory string, stopCh <-chan struct{}) (<-chan []*store.KVPair, error) {
+	events, err := s.Store.WatchTree(s.key(directory), stopCh)
+	if err != nil {
+		return nil, err
+	}
+	stripped := make(chan []*store.KVPair)
+	go func() {
+		defer close(stripped)
+		for pairs := range events {
+			select {
+			case stripped <- s.pairs(pairs):
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return stripped, nil
+}
+
+// NewLock creates a lock for the given key
+func (s *prefixedStore) NewLock(key string, options *store.LockOptions) (store.Locker, error) {
+	return s.Store.NewLock(s.key(key), options)
+}
+
+// List the content of a given prefix
+func (s *prefixedStore) List(directory string) ([]*store.KVPair, error) {
+	pairs, err := s.Store.List(s.key(directory))
+	return s.pairs(pairs), err
+}
+
+// DeleteTree deletes a range of keys under a given directory
+func (s *prefixedStore) DeleteTree(directory string) error {
+	return s.Store.DeleteTree(s.key(directory))
+}
+
+// AtomicPut puts a value at the key if it hasn't been modified since previous
+func (s *prefixedStore) AtomicPut(key string, value []byte, previous *store.KVPair, options *store.WriteOptions) (bool, *store.KVPair, error) {
+	ok, pair, err := s.Store.AtomicPut(s.key(key), value, s.previous(previous), options)
+	return ok, s.pair(pair), err
+}
+
+// AtomicDelete deletes the key if it hasn't been modified since previous
+func (s *prefixedStore) AtomicDelete(key string, previous *store.KVPair) (bool, error) {
+	return s.Store.AtomicDelete(s.key(key), s.previous(previous))
+}
@@ -0,0 +1,57 @@
+package telegram
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestPrefixedStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prefix")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	defer kv.Close()
+
+	// Every bot has its own chats in the shared backend
+	noisy, err := NewChatStore(kv)
+	assert.NoError(t, err)
+	paging, err := NewChatStore(NewPrefixedStore(kv, "bots/paging"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, noisy.Add(telebot.Chat{ID: -100}))
+	assert.NoError(t, paging.Add(telebot.Chat{ID: -200}))
+
+	chats, err := noisy.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []telebot.Chat{{ID: -100}}, chats)
+	chats, err = paging.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []telebot.Chat{{ID: -200}}, chats)
+
+	prefixed := NewPrefixedStore(kv, "bots/paging/")
+	pairs, err := prefixed.List(telegramChatsDirectory)
+	assert.NoError(t, err)
+	assert.Equal(t, telegramChatsDirectory+"/-200", pairs[0].Key)
+	_, err = kv.Get("bots/paging/" + telegramChatsDirectory + "/-200")
+	assert.NoError(t, err)
+
+	pair, err := prefixed.Get(telegramChatsDirectory + "/-200")
+	assert.NoError(t, err)
+	ok, _, err := prefixed.AtomicPut(pair.Key, []byte("{}"), pair, nil)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	assert.NoError(t, paging.Remove(telebot.Chat{ID: -200}))
+	chats, err = noisy.List()
+	assert.NoError(t, err)
+	assert.Len(t, chats, 1)
+}
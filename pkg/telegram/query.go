@@ -0,0 +1,80 @@
+package telegram
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/prometheus"
+)
+
+const (
+	commandQuery = "/query"
+
+	// maxQueryRows of a vector are shown by /query, the others are counted
+	maxQueryRows = 50
+)
+
+// queryMessage formats the result of an instant query as HTML, vectors as a table of series and values
+func queryMessage(expr string, v model.Value) string {
+	var out string
+	switch v := v.(type) {
+	case model.Vector:
+		if len(v) == 0 {
+			return fmt.Sprintf("<code>%s</code> returned no data.", html.EscapeString(expr))
+		}
+		var buf bytes.Buffer
+		w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		for i, s := range v {
+			if i >= maxQueryRows {
+				fmt.Fprintf(w, "%d more series\t\n", len(v)-i)
+				break
+			}
+			fmt.Fprintf(w, "%s\t%s\n", s.Metric, s.Value)
+		}
+		w.Flush()
+		out = buf.String()
+	case *model.Scalar:
+		out = v.Value.String()
+	case *model.String:
+		out = v.Value
+	default:
+		out = v.String()
+	}
+
+	msg := fmt.Sprintf("<code>%s</code>\n<pre>%s</pre>", html.EscapeString(expr), html.EscapeString(strings.TrimRight(out, "\n")))
+	if len(msg) > maxMessageLength {
+		return fmt.Sprintf("The result of <code>%s</code> is too long, narrow the expression down.", html.EscapeString(expr))
+	}
+	return msg
+}
+
+// handleQuery executes ad-hoc PromQL, it is never one of the readOnlyCommands
+func (b *Bot) handleQuery(message telebot.Message) {
+	// Right format: '/query expression'.
+	// Ex: /query sum by (job) (up)
+	expr := strings.Join(strings.Fields(message.Text)[1:], " ")
+	if expr == "" {
+		b.reply(message, "Please send right format: '/query expression'. Ex: /query sum by (job) (up)", nil)
+		return
+	}
+	if b.prometheus == nil {
+		b.reply(message, "No Prometheus is configured to query.", nil)
+		return
+	}
+
+	v, err := prometheus.Query(b.prometheus.String(), expr, time.Now())
+	if err != nil {
+		b.reply(message, fmt.Sprintf("failed to query Prometheus... %v", err), nil)
+		return
+	}
+
+	b.reply(message, queryMessage(expr, v), &telebot.SendOptions{
+		ParseMode: telebot.ModeHTML,
+	})
+}
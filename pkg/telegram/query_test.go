@@ -0,0 +1,27 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryMessage(t *testing.T) {
+	vector := model.Vector{
+		{Metric: model.Metric{"job": "nginx"}, Value: 2},
+		{Metric: model.Metric{"job": "node"}, Value: 5},
+	}
+	assert.Equal(t,
+		"<code>sum by (job) (up)</code>\n<pre>{job=&#34;nginx&#34;}  2\n{job=&#34;node&#34;}   5</pre>",
+		queryMessage("sum by (job) (up)", vector),
+	)
+	assert.Equal(t, "<code>up &gt; 1</code> returned no data.", queryMessage("up > 1", model.Vector{}))
+	assert.Equal(t, "<code>1+1</code>\n<pre>2</pre>", queryMessage("1+1", &model.Scalar{Value: 2}))
+
+	long := make(model.Vector, maxQueryRows+1)
+	for i := range long {
+		long[i] = &model.Sample{Metric: model.Metric{"job": "nginx"}}
+	}
+	assert.Contains(t, queryMessage("up", long), "1 more series")
+}
@@ -0,0 +1,364 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/hako/durafmt"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/common/model"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandQuiet       = "/quiet"
+	commandMaintenance = "/maintenance"
+
+	quietDigest = "digest"
+	quietOff    = "off"
+
+	responseQuietDigest = "Alerts held back while this chat was quiet:"
+)
+
+// QuietHours is a daily recurring time window like 22:00-07:00
+// during which alerts are held back for a chat.
+type QuietHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Location string `json:"location,omitempty"`
+}
+
+// Contains returns whether t falls into the daily window
+func (q QuietHours) Contains(t time.Time) bool {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return false
+	}
+
+	loc := time.Local
+	if q.Location != "" {
+		if l, err := time.LoadLocation(q.Location); err == nil {
+			loc = l
+		}
+	}
+
+	t = t.In(loc)
+	now := t.Hour()*60 + t.Minute()
+
+	if start <= end {
+		return start <= now && now < end
+	}
+	// The window wraps around midnight
+	return now >= start || now < end
+}
+
+func (q QuietHours) String() string {
+	if q.Location == "" {
+		return fmt.Sprintf("%s-%s", q.Start, q.End)
+	}
+	return fmt.Sprintf("%s-%s %s", q.Start, q.End, q.Location)
+}
+
+// MaintenanceWindow is a one-off time window during which alerts are held back for a chat.
+type MaintenanceWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Contains returns whether t falls into the window
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// parseClock parses HH:MM into minutes since midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseQuietHours parses a window like 22:00-07:00 and an optional location
+func parseQuietHours(window string, location string) (QuietHours, error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return QuietHours{}, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", window)
+	}
+
+	q := QuietHours{Start: parts[0], End: parts[1], Location: location}
+	if _, err := parseClock(q.Start); err != nil {
+		return q, err
+	}
+	if _, err := parseClock(q.End); err != nil {
+		return q, err
+	}
+	if location != "" {
+		if _, err := time.LoadLocation(location); err != nil {
+			return q, fmt.Errorf("unknown location %q", location)
+		}
+	}
+
+	return q, nil
+}
+
+// Quiet returns whether alerts for the chat are held back at the given time
+func (s ChatSettings) Quiet(t time.Time) bool {
+	if s.QuietHours != nil && s.QuietHours.Contains(t) {
+		return true
+	}
+	for _, w := range s.Maintenance {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitQuietOverrides separates the alerts with a severity that is delivered
+// even during quiet hours from the ones that are held back.
+// Resolved alerts aren't held back, their messages resolve during quiet hours too.
+func splitQuietOverrides(severities []string, alerts template.Alerts) (deliver template.Alerts, held template.Alerts) {
+	for _, a := range alerts {
+		override := a.Status == string(model.AlertResolved)
+		for _, s := range severities {
+			if a.Labels["severity"] == s {
+				override = true
+				break
+			}
+		}
+
+		if override {
+			deliver = append(deliver, a)
+		} else {
+			held = append(held, a)
+		}
+	}
+	return deliver, held
+}
+
+// heldAlerts buffers alerts per chat to deliver them later as a digest
+type heldAlerts struct {
+	mu     sync.Mutex
+	chats  map[int64]telebot.Chat
+	alerts map[int64]template.Alerts
+	since  map[int64]time.Time
+}
+
+func newHeldAlerts() *heldAlerts {
+	return &heldAlerts{
+		chats:  make(map[int64]telebot.Chat),
+		alerts: make(map[int64]template.Alerts),
+		since:  make(map[int64]time.Time),
+	}
+}
+
+// Add alerts to the buffer of a chat
+func (h *heldAlerts) Add(chat telebot.Chat, alerts template.Alerts) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.chats[chat.ID]; !ok {
+		h.since[chat.ID] = time.Now()
+	}
+	h.chats[chat.ID] = chat
+	h.alerts[chat.ID] = append(h.alerts[chat.ID], alerts...)
+}
+
+// Chats returns all chats with held alerts
+func (h *heldAlerts) Chats() []telebot.Chat {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	chats := make([]telebot.Chat, 0, len(h.chats))
+	for _, c := range h.chats {
+		chats = append(chats, c)
+	}
+	return chats
+}
+
+// Since returns when the first of the currently held alerts of a chat was added
+func (h *heldAlerts) Since(chat telebot.Chat) time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.since[chat.ID]
+}
+
+// Take removes and returns all held alerts of a chat
+func (h *heldAlerts) Take(chat telebot.Chat) template.Alerts {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	alerts := h.alerts[chat.ID]
+	delete(h.alerts, chat.ID)
+	delete(h.chats, chat.ID)
+	delete(h.since, chat.ID)
+	return alerts
+}
+
+// flushHeldAlerts periodically sends the held alerts of chats that aren't quiet anymore as a digest
+func (b *Bot) flushHeldAlerts(ctx context.Context) error {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			for _, chat := range b.held.Chats() {
+				settings, err := b.settings.Get(chat)
+				if err != nil {
+					b.reportError("failed to get chat settings from store", "chat_id", chat.ID, "err", err)
+					continue
+				}
+				if settings.Quiet(now) {
+					continue
+				}
+
+				alerts := b.held.Take(chat)
+				if len(alerts) == 0 {
+					continue
+				}
+
+				out, mode := b.renderAlertsOrFallback(chat, ChatSettings{}, defaultTemplate, &template.Data{Alerts: alerts})
+
+				_, err = b.sender.SendMessage(chat, bold(responseQuietDigest, mode)+"\n"+out, &telebot.SendOptions{
+					ParseMode: mode,
+				})
+				if err != nil {
+					b.reportError("failed to send held alerts", "chat_id", chat.ID, "err", err)
+				}
+			}
+		}
+	}
+}
+
+func (b *Bot) handleQuiet(message telebot.Message) {
+	// Right format: '/quiet HH:MM-HH:MM [location] [digest]' or '/quiet off'.
+	// Ex: /quiet 22:00-07:00 Europe/Berlin digest
+	params := strings.Split(message.Text, " ")[1:]
+
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't get the quiet hours of this chat.", nil)
+		return
+	}
+
+	if len(params) == 0 {
+		if settings.QuietHours == nil {
+			b.reply(message, "This chat has no quiet hours.", nil)
+			return
+		}
+		b.reply(message, fmt.Sprintf("This chat is quiet from %s.", settings.QuietHours), nil)
+		return
+	}
+
+	if params[0] == quietOff {
+		settings.QuietHours = nil
+	} else {
+		var location string
+		digest := false
+		for _, p := range params[1:] {
+			if p == quietDigest {
+				digest = true
+			} else {
+				location = p
+			}
+		}
+
+		quiet, err := parseQuietHours(params[0], location)
+		if err != nil {
+			b.reply(message, fmt.Sprintf("Please send right format: '/quiet HH:MM-HH:MM [location] [digest]' or '/quiet off'. %v", err), nil)
+			return
+		}
+		settings.QuietHours = &quiet
+		settings.QuietDigest = digest
+	}
+
+	if err := b.settings.Set(message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.reply(message, "I can't save the quiet hours of this chat.", nil)
+		return
+	}
+
+	b.reply(message, responseMember, nil)
+}
+
+func (b *Bot) handleMaintenance(message telebot.Message) {
+	// Right format: '/maintenance duration [digest]' or '/maintenance off'.
+	// Ex: /maintenance 2h digest
+	params := strings.Split(message.Text, " ")[1:]
+
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't get the maintenance windows of this chat.", nil)
+		return
+	}
+
+	now := time.Now()
+
+	// Drop the windows that are already over
+	var windows []MaintenanceWindow
+	for _, w := range settings.Maintenance {
+		if w.End.After(now) {
+			windows = append(windows, w)
+		}
+	}
+	settings.Maintenance = windows
+
+	if len(params) == 0 {
+		if len(settings.Maintenance) == 0 {
+			b.reply(message, "This chat has no maintenance windows.", nil)
+			return
+		}
+
+		list := ""
+		for _, w := range settings.Maintenance {
+			list = list + fmt.Sprintf("%s - %s (ends in %s)\n",
+				w.Start.Format(time.RFC822),
+				w.End.Format(time.RFC822),
+				durafmt.Parse(w.End.Sub(now)),
+			)
+		}
+		b.reply(message, "Currently these maintenance windows are planned:\n"+list, nil)
+		return
+	}
+
+	if params[0] == quietOff {
+		settings.Maintenance = nil
+	} else {
+		d, err := time.ParseDuration(params[0])
+		if err != nil || d <= 0 {
+			b.reply(message, "Please send right format: '/maintenance duration [digest]' or '/maintenance off'. Ex: /maintenance 2h digest", nil)
+			return
+		}
+
+		settings.Maintenance = append(settings.Maintenance, MaintenanceWindow{Start: now, End: now.Add(d)})
+		settings.QuietDigest = len(params) > 1 && params[1] == quietDigest
+	}
+
+	if err := b.settings.Set(message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.reply(message, "I can't save the maintenance windows of this chat.", nil)
+		return
+	}
+
+	b.reply(message, responseMember, nil)
+	level.Info(b.logger).Log(
+		"msg", "chat maintenance changed",
+		"chat_id", message.Chat.ID,
+		"windows", len(settings.Maintenance),
+	)
+}
@@ -0,0 +1,75 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuietHoursContains(t *testing.T) {
+	at := func(clock string) time.Time {
+		t, _ := time.ParseInLocation("15:04", clock, time.UTC)
+		return t
+	}
+
+	night := QuietHours{Start: "22:00", End: "07:00", Location: "UTC"}
+	assert.True(t, night.Contains(at("23:30")))
+	assert.True(t, night.Contains(at("06:59")))
+	assert.False(t, night.Contains(at("07:00")))
+	assert.False(t, night.Contains(at("12:00")))
+
+	lunch := QuietHours{Start: "12:00", End: "13:00", Location: "UTC"}
+	assert.True(t, lunch.Contains(at("12:30")))
+	assert.False(t, lunch.Contains(at("13:30")))
+}
+
+func TestParseQuietHours(t *testing.T) {
+	q, err := parseQuietHours("22:00-07:00", "Europe/Berlin")
+	assert.NoError(t, err)
+	assert.Equal(t, "22:00-07:00 Europe/Berlin", q.String())
+
+	_, err = parseQuietHours("22:00", "")
+	assert.Error(t, err)
+	_, err = parseQuietHours("25:00-07:00", "")
+	assert.Error(t, err)
+}
+
+func TestSplitQuietOverrides(t *testing.T) {
+	alerts := template.Alerts{
+		{Labels: template.KV{"alertname": "NodeDown", "severity": "critical"}},
+		{Labels: template.KV{"alertname": "DiskFull", "severity": "warning"}},
+		{Labels: template.KV{"alertname": "HighLoad", "severity": "warning"}, Status: "resolved"},
+	}
+
+	deliver, held := splitQuietOverrides([]string{"critical"}, alerts)
+	assert.Len(t, deliver, 2)
+	assert.Equal(t, "NodeDown", deliver[0].Labels["alertname"])
+	assert.Equal(t, "HighLoad", deliver[1].Labels["alertname"], "resolved alerts aren't held back")
+	assert.Len(t, held, 1)
+	assert.Equal(t, "DiskFull", held[0].Labels["alertname"])
+}
+
+func TestSplitDigest(t *testing.T) {
+	alerts := template.Alerts{
+		{Labels: template.KV{"alertname": "HighCPU", "severity": "critical"}},
+		{Labels: template.KV{"alertname": "DiskFull", "severity": "info"}, Status: "firing"},
+		{Labels: template.KV{"alertname": "DiskFull", "severity": "info"}, Status: "resolved"},
+	}
+
+	deliver, digest := splitDigest(nil, alerts)
+	assert.Equal(t, alerts, deliver)
+	assert.Empty(t, digest)
+
+	matchers, err := parseMatchers(`severity=~"info|warning"`)
+	assert.NoError(t, err)
+	deliver, digest = splitDigest(&DigestSettings{Matchers: matchers, Interval: time.Hour}, alerts)
+	// The resolved alert resolves its message instead of waiting for the digest
+	assert.Equal(t, template.Alerts{alerts[0], alerts[2]}, deliver)
+	assert.Equal(t, template.Alerts{alerts[1]}, digest)
+
+	msg := digestMessage(alerts[1:], time.Now().Add(-time.Hour))
+	assert.Contains(t, msg, "Digest of 2 alerts")
+	assert.Contains(t, msg, "<b>DiskFull</b> 🔥 1 firing ✅ 1 resolved")
+}
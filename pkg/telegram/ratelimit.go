@@ -0,0 +1,144 @@
+package telegram
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tucnak/telebot"
+)
+
+const (
+	// chatSendBurst is the number of messages a chat can receive at once before its rate limit applies
+	chatSendBurst = 3
+	// maxChatBuckets after which the buckets of chats that didn't receive messages recently are dropped
+	maxChatBuckets = 10000
+)
+
+// rateLimiter spaces calls evenly to stay below a number per second
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Wait blocks until the next call is allowed
+func (l *rateLimiter) Wait() {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	time.Sleep(wait)
+}
+
+// tokenBucket holds the messages a chat may still receive right away, negative if messages wait for it
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// chatLimiter keeps a token bucket per chat, so that bursts of messages to one chat
+// are smoothed without delaying the messages to other chats
+type chatLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+func newChatLimiter(perMinute float64, burst int) *chatLimiter {
+	return &chatLimiter{rate: perMinute / 60, burst: float64(burst), buckets: make(map[string]*tokenBucket)}
+}
+
+// reserve takes a token of the chat's bucket and returns how long to wait until it is available
+func (l *chatLimiter) reserve(chat string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.buckets) >= maxChatBuckets {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[chat]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[chat] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / l.rate * float64(time.Second))
+}
+
+// prune drops the buckets that refilled completely, the lock must be held
+func (l *chatLimiter) prune(now time.Time) {
+	for chat, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, chat)
+		}
+	}
+}
+
+// Wait blocks until the chat may receive the next message
+func (l *chatLimiter) Wait(chat telebot.Recipient) {
+	time.Sleep(l.reserve(chat.Destination(), time.Now()))
+}
+
+// rateLimitedSender keeps the calls to the sender below the limits of Telegram, for every chat
+// and for all chats together, no matter how many chats are delivered to at once.
+// Either limiter can be nil.
+type rateLimitedSender struct {
+	MessageSender
+	limiter *rateLimiter
+	chats   *chatLimiter
+}
+
+// wait for the chat's and then the global limit, so that waiting for a busy chat doesn't hold up others
+func (s *rateLimitedSender) wait(chat telebot.Recipient) {
+	if s.chats != nil && chat != nil {
+		s.chats.Wait(chat)
+	}
+	if s.limiter != nil {
+		s.limiter.Wait()
+	}
+}
+
+func (s *rateLimitedSender) SendMessage(recipient telebot.Recipient, text string, options *telebot.SendOptions) (*telebot.Message, error) {
+	s.wait(recipient)
+	return s.MessageSender.SendMessage(recipient, text, options)
+}
+
+func (s *rateLimitedSender) EditMessageText(recipient telebot.Recipient, messageID int, text string, options *telebot.SendOptions) error {
+	s.wait(recipient)
+	return s.MessageSender.EditMessageText(recipient, messageID, text, options)
+}
+
+func (s *rateLimitedSender) EditMessageReplyMarkup(recipient telebot.Recipient, messageID int, options *telebot.SendOptions) error {
+	s.wait(recipient)
+	return s.MessageSender.EditMessageReplyMarkup(recipient, messageID, options)
+}
+
+func (s *rateLimitedSender) AnswerCallbackQuery(callback *telebot.Callback, response *telebot.CallbackResponse) error {
+	s.wait(nil)
+	return s.MessageSender.AnswerCallbackQuery(callback, response)
+}
+
+func (s *rateLimitedSender) SendAlbum(recipient telebot.Recipient, photos []AlbumPhoto, replyTo int) error {
+	s.wait(recipient)
+	return s.MessageSender.SendAlbum(recipient, photos, replyTo)
+}
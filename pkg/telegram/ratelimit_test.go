@@ -0,0 +1,37 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(100)
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		l.Wait()
+	}
+	assert.True(t, time.Since(start) >= 100*time.Millisecond, "11 calls at 100 per second take at least 100ms")
+}
+
+func TestChatLimiter(t *testing.T) {
+	l := newChatLimiter(20, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		assert.Zero(t, l.reserve("-100", now), "the burst isn't delayed")
+	}
+	assert.Equal(t, 3*time.Second, l.reserve("-100", now))
+	assert.Equal(t, 6*time.Second, l.reserve("-100", now), "waiting messages queue up")
+	assert.Zero(t, l.reserve("-200", now), "other chats aren't delayed")
+
+	// The bucket refills with 20 messages per minute
+	assert.Equal(t, 3*time.Second, l.reserve("-100", now.Add(6*time.Second)))
+
+	l.prune(now.Add(10 * time.Second))
+	assert.Len(t, l.buckets, 1, "the bucket of the chat that refilled is dropped")
+	l.prune(now.Add(time.Hour))
+	assert.Empty(t, l.buckets)
+}
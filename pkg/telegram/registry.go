@@ -0,0 +1,205 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/tucnak/telebot"
+)
+
+// AlertRegistry keeps the alerts sent to the chats by their ID, so that
+// webhooks and callbacks find the alerts they resolve or act on.
+// Resolved alerts are removed once they can't fire again within the cooldown,
+// the oldest settled alerts, then the oldest others are evicted once there are more than the limit.
+// It is safe for concurrent use.
+type AlertRegistry struct {
+	mu     sync.RWMutex
+	alerts map[string][]*HandleAlert
+	max    int   // evict the oldest alerts above this number, unlimited if 0
+	count  int64 // atomic, the number of alerts for the gauge
+}
+
+// NewAlertRegistry creates an empty registry
+func NewAlertRegistry() *AlertRegistry {
+	return &AlertRegistry{alerts: make(map[string][]*HandleAlert)}
+}
+
+// Add the alert sent to a chat and returns the alerts evicted to stay within the limit
+func (r *AlertRegistry) Add(a *HandleAlert) []*HandleAlert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.alerts[a.Group] = append(r.alerts[a.Group], a)
+	count := atomic.AddInt64(&r.count, 1)
+
+	var evicted []*HandleAlert
+	for r.max > 0 && count > int64(r.max) {
+		oldest := r.evictable()
+		r.remove(oldest)
+		evicted = append(evicted, oldest)
+		count = atomic.LoadInt64(&r.count)
+	}
+	return evicted
+}
+
+// evictable returns the resolved or acknowledged alert that fired first,
+// or the alert that fired first if all of them still need attention
+func (r *AlertRegistry) evictable() *HandleAlert {
+	var oldest, oldestSettled *HandleAlert
+	for _, alerts := range r.alerts {
+		for _, a := range alerts {
+			if oldest == nil || a.firedAt().Before(oldest.firedAt()) {
+				oldest = a
+			}
+			if a.settled() && (oldestSettled == nil || a.firedAt().Before(oldestSettled.firedAt())) {
+				oldestSettled = a
+			}
+		}
+	}
+	if oldestSettled != nil {
+		return oldestSettled
+	}
+	return oldest
+}
+
+// remove the alert, the lock must be held
+func (r *AlertRegistry) remove(a *HandleAlert) {
+	alerts := r.alerts[a.Group]
+	for i, h := range alerts {
+		if h != a {
+			continue
+		}
+		alerts = append(alerts[:i:i], alerts[i+1:]...)
+		if len(alerts) == 0 {
+			delete(r.alerts, a.Group)
+		} else {
+			r.alerts[a.Group] = alerts
+		}
+		atomic.AddInt64(&r.count, -1)
+		return
+	}
+}
+
+// Prune removes the resolved alerts that didn't fire within the cooldown, so they can't be updated
+// by firing again anymore, and returns how many were removed
+func (r *AlertRegistry) Prune(now time.Time, cooldown time.Duration) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pruned []*HandleAlert
+	for _, alerts := range r.alerts {
+		for _, a := range alerts {
+			if atomic.LoadInt32(&a.resolved) == 1 && now.Sub(a.firedAt()) >= cooldown {
+				pruned = append(pruned, a)
+			}
+		}
+	}
+	for _, a := range pruned {
+		r.remove(a)
+	}
+	return len(pruned)
+}
+
+// Len returns the number of alerts in the registry
+func (r *AlertRegistry) Len() int {
+	return int(atomic.LoadInt64(&r.count))
+}
+
+// Collector returns the gauge of the number of alerts in the registry to be registered
+func (r *AlertRegistry) Collector() prometheus.Collector {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "alertmanagerbot",
+		Name:      "alerts_tracked",
+		Help:      "Number of alerts sent to chats the bot keeps to resolve, update and escalate them",
+	}, func() float64 {
+		return float64(r.Len())
+	})
+}
+
+// Get returns the alerts of the group in all chats
+func (r *AlertRegistry) Get(group string) []*HandleAlert {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]*HandleAlert(nil), r.alerts[group]...)
+}
+
+// InChat returns the alerts of the group sent to the chat
+func (r *AlertRegistry) InChat(group string, chat telebot.Chat) []*HandleAlert {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var alerts []*HandleAlert
+	for _, a := range r.alerts[group] {
+		if a.Chat.ID == chat.ID {
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts
+}
+
+// Chat returns the alerts of all groups sent to the chat
+func (r *AlertRegistry) Chat(chat telebot.Chat) []*HandleAlert {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var alerts []*HandleAlert
+	for _, group := range r.alerts {
+		for _, a := range group {
+			if a.Chat.ID == chat.ID {
+				alerts = append(alerts, a)
+			}
+		}
+	}
+	return alerts
+}
+
+// Recent returns the alert of the group and fingerprint of the chat that fired within the cooldown
+func (r *AlertRegistry) Recent(group string, chat telebot.Chat, fp model.Fingerprint, cooldown time.Duration) *HandleAlert {
+	return recentAlert(r.InChat(group, chat), chat, fp, cooldown)
+}
+
+// groupID identifies the group of alerts by the sorted labels the Alertmanager grouped them by,
+// independent of the order of the labels and alerts. Without group labels all alerts of the receiver are one group.
+func groupID(receiver string, groupLabels template.KV) string {
+	h := fnv.New64a()
+	if len(groupLabels) == 0 {
+		h.Write([]byte(receiver))
+	}
+	for _, p := range groupLabels.SortedPairs() {
+		h.Write([]byte(p.Name))
+		h.Write([]byte{0})
+		h.Write([]byte(p.Value))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// alertPruneInterval in which the resolved alerts are removed from the registry
+const alertPruneInterval = time.Minute
+
+// pruneAlerts periodically removes the resolved alerts that can't fire again within the cooldown
+func (b *Bot) pruneAlerts(ctx context.Context) error {
+	ticker := time.NewTicker(alertPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			if n := b.alerts.Prune(now, b.cooldown); n > 0 {
+				level.Debug(b.logger).Log("msg", "pruned resolved alerts", "alerts", n, "tracked", b.alerts.Len())
+			}
+			b.callbacks.prune(now)
+		}
+	}
+}
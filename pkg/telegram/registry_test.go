@@ -0,0 +1,125 @@
+package telegram
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestAlertRegistry(t *testing.T) {
+	r := NewAlertRegistry()
+	ops := telebot.Chat{ID: -100}
+	dev := telebot.Chat{ID: -200}
+
+	old := &HandleAlert{ID: "HighCPU", Group: "HighCPU", Chat: ops, Fingerprints: []model.Fingerprint{1}, FiredAt: time.Now().Add(-time.Hour)}
+	recent := &HandleAlert{ID: "HighCPU", Group: "HighCPU", Chat: ops, Fingerprints: []model.Fingerprint{1}, FiredAt: time.Now()}
+	other := &HandleAlert{ID: "HighCPU", Group: "HighCPU", Chat: dev, Fingerprints: []model.Fingerprint{1}, FiredAt: time.Now()}
+	for _, a := range []*HandleAlert{old, recent, other} {
+		r.Add(a)
+	}
+
+	assert.Len(t, r.Get("HighCPU"), 3)
+	assert.Empty(t, r.Get("DiskFull"))
+	assert.Equal(t, []*HandleAlert{old, recent}, r.InChat("HighCPU", ops))
+	assert.Equal(t, recent, r.Recent("HighCPU", ops, 1, 10*time.Minute))
+	assert.Nil(t, r.Recent("HighCPU", ops, model.Fingerprint(2), 10*time.Minute))
+
+	// A message showing many alerts of the group is recent for each of them
+	both := &HandleAlert{ID: "HighCPU", Group: "HighCPU", Chat: dev, Fingerprints: []model.Fingerprint{1, 2}, FiredAt: time.Now()}
+	r.Add(both)
+	assert.Equal(t, both, r.Recent("HighCPU", dev, model.Fingerprint(2), 10*time.Minute))
+
+	// Callers can't change the registered alerts through the returned slice
+	alerts := r.Get("HighCPU")
+	alerts[0] = nil
+	assert.Equal(t, old, r.Get("HighCPU")[0])
+}
+
+func TestHandleAlertTransitions(t *testing.T) {
+	a := &HandleAlert{ID: "HighCPU", Level: levelOne, AutoForwardFlag: true, ForwardTimeout: time.Minute, LastUpdate: time.Now()}
+
+	assert.False(t, a.forwardDue(time.Now()))
+	assert.True(t, a.forwardDue(time.Now().Add(time.Minute)))
+	assert.False(t, a.forwardDue(time.Now().Add(time.Minute)), "the timeout restarts once due")
+
+	// The escalation, webhooks and callbacks change the alert concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			a.IncreaseLevel()
+		}()
+		go func() {
+			defer wg.Done()
+			a.level()
+			a.escalating()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, levelThree, a.level())
+
+	a.stopEscalation()
+	assert.False(t, a.escalating())
+	assert.False(t, a.forwardDue(time.Now().Add(time.Hour)))
+}
+
+func TestAlertRegistryPruneAndEvict(t *testing.T) {
+	r := NewAlertRegistry()
+	r.max = 2
+	ops := telebot.Chat{ID: -100}
+	now := time.Now()
+
+	first := &HandleAlert{ID: "HighCPU", Group: "HighCPU", Chat: ops, FiredAt: now.Add(-time.Hour)}
+	second := &HandleAlert{ID: "DiskFull", Group: "DiskFull", Chat: ops, FiredAt: now.Add(-time.Minute)}
+	third := &HandleAlert{ID: "HighCPU", Group: "HighCPU", Chat: ops, FiredAt: now}
+	assert.Empty(t, r.Add(first))
+	assert.Empty(t, r.Add(second))
+	assert.Equal(t, []*HandleAlert{first}, r.Add(third), "the oldest alert is evicted above the limit")
+	assert.Equal(t, 2, r.Len())
+	assert.Equal(t, []*HandleAlert{third}, r.Get("HighCPU"))
+
+	// Resolved alerts are kept while they can fire again within the cooldown
+	second.resolved = 1
+	third.resolved = 1
+	assert.Equal(t, 1, r.Prune(now.Add(9*time.Minute), 10*time.Minute))
+	assert.Empty(t, r.Get("DiskFull"))
+	assert.Equal(t, 1, r.Prune(now.Add(10*time.Minute), 10*time.Minute))
+	assert.Zero(t, r.Len())
+
+	// Resolved and acknowledged alerts are evicted before older ones still escalating
+	firing := &HandleAlert{ID: "HighCPU", Group: "HighCPU", Chat: ops, FiredAt: now.Add(-time.Hour)}
+	acked := &HandleAlert{ID: "DiskFull", Group: "DiskFull", Chat: ops, FiredAt: now.Add(-time.Minute), AcknowledgedAt: now}
+	assert.Empty(t, r.Add(firing))
+	assert.Empty(t, r.Add(acked))
+	assert.Equal(t, []*HandleAlert{acked}, r.Add(&HandleAlert{ID: "NodeDown", Group: "NodeDown", Chat: ops, FiredAt: now}))
+	assert.Equal(t, []*HandleAlert{firing}, r.Add(&HandleAlert{ID: "Load", Group: "Load", Chat: ops, FiredAt: now}), "the oldest alert is evicted if none is settled")
+}
+
+func TestAlertFingerprints(t *testing.T) {
+	cpu := template.Alert{Status: "firing", Labels: template.KV{"alertname": "HighCPU", "instance": "web01"}}
+	disk := template.Alert{Status: "firing", Labels: template.KV{"alertname": "HighCPU", "instance": "web02"}}
+	fps := alertFingerprints(template.Alerts{disk, cpu})
+	assert.Equal(t, alertFingerprints(template.Alerts{cpu, disk}), fps, "the fingerprints don't depend on the order of alerts")
+	assert.NotEqual(t, fps[0], fps[1], "alerts sharing a label value differ")
+
+	a := &HandleAlert{ID: "HighCPU", Fingerprints: fps}
+	assert.True(t, a.shows(alertLabelSet(disk).Fingerprint()))
+	assert.False(t, a.resolvedIn(map[model.Fingerprint]bool{fps[0]: true}), "the message is resolved once all of its alerts are")
+	assert.True(t, a.resolvedIn(map[model.Fingerprint]bool{fps[0]: true, fps[1]: true}))
+	assert.True(t, a.firingIn(map[model.Fingerprint]bool{fps[1]: true}), "the message fires as long as any of its alerts does")
+	assert.False(t, a.firingIn(map[model.Fingerprint]bool{}))
+}
+
+func TestGroupID(t *testing.T) {
+	web := groupID("ops", template.KV{"alertname": "HighCPU", "instance": "web01"})
+	assert.Len(t, web, 16, "the ID fits the callback data of buttons")
+	assert.Equal(t, web, groupID("dev", template.KV{"instance": "web01", "alertname": "HighCPU"}), "the ID doesn't depend on the order of labels")
+	assert.NotEqual(t, web, groupID("ops", template.KV{"alertname": "HighCPU", "instance": "db01"}), "groups of the same alertname differ")
+	assert.NotEqual(t, groupID("ops", nil), groupID("dev", nil), "without group labels the receiver is the group")
+}
@@ -0,0 +1,36 @@
+package telegram
+
+import (
+	"fmt"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const commandReload = "/reload"
+
+// WithReload lets admins reload the configuration with /reload. The function loads all of it,
+// e.g. the admins, the routing configuration with its escalation policies and the templates,
+// and only applies it if all of it is valid.
+func WithReload(reload func() error) BotOption {
+	return func(b *Bot) {
+		b.reload = reload
+	}
+}
+
+func (b *Bot) handleReload(message telebot.Message) {
+	// Right format: '/reload'.
+	if b.reload == nil {
+		b.reply(message, "Reloading the configuration isn't supported.", nil)
+		return
+	}
+
+	if err := b.reload(); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to reload configuration", "err", err)
+		b.reply(message, fmt.Sprintf("I can't reload the configuration, the current one is kept. %v", err), nil)
+		return
+	}
+
+	level.Info(b.logger).Log("msg", "reloaded configuration", "sender_id", message.Sender.ID)
+	b.reply(message, "Reloaded the admins, routing configuration and templates.", nil)
+}
@@ -0,0 +1,51 @@
+package telegram
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestReload(t *testing.T) {
+	s := newFakeSender()
+	b := &Bot{logger: log.NewNopLogger(), sender: s}
+	message := telebot.Message{Text: "/reload", Sender: telebot.User{ID: 1}, Chat: telebot.Chat{ID: 1}}
+
+	b.handleReload(message)
+	assert.Equal(t, "Reloading the configuration isn't supported.", s.sent[0])
+
+	var err error
+	reloaded := 0
+	WithReload(func() error {
+		reloaded++
+		return err
+	})(b)
+
+	b.handleReload(message)
+	assert.Equal(t, 1, reloaded)
+	assert.Equal(t, "Reloaded the admins, routing configuration and templates.", s.sent[1])
+
+	err = errors.New("invalid routing configuration: unknown escalation policy night")
+	b.handleReload(message)
+	assert.Equal(t, "I can't reload the configuration, the current one is kept. invalid routing configuration: unknown escalation policy night", s.sent[2])
+}
+
+func TestReloadAdmins(t *testing.T) {
+	s := newFakeSender()
+	b := &Bot{logger: log.NewNopLogger(), sender: s, admins: []int{1}}
+	WithReload(func() error {
+		b.SetAdmins([]int{3, 2})
+		return nil
+	})(b)
+
+	admin := telebot.Message{Text: "/reload", Sender: telebot.User{ID: 1}, Chat: telebot.Chat{ID: 1}}
+	assert.True(t, b.isAdmin(admin))
+
+	b.handleReload(admin)
+	assert.False(t, b.isAdmin(admin), "the reloaded configuration removed the admin")
+	assert.True(t, b.isAdmin(telebot.Message{Sender: telebot.User{ID: 2}, Chat: telebot.Chat{ID: 2}}))
+	assert.Equal(t, []int{2, 3}, b.currentAdmins())
+}
@@ -0,0 +1,96 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/remediate"
+)
+
+const (
+	strRemediateData = "Remediate"
+
+	// remediationTimeout after which a running remediation is given up
+	remediationTimeout = 15 * time.Minute
+)
+
+// remediateButton runs the remediation action of the alert, until the alert is resolved
+func (a *HandleAlert) remediateButton() ([]telebot.KeyboardButton, error) {
+	if a.Remediation == nil || atomic.LoadInt32(&a.resolved) == 1 {
+		return nil, nil
+	}
+	data, err := json.Marshal(CallbackData{Button: strRemediateData, AlertID: a.Group})
+	if err != nil {
+		return nil, err
+	}
+	return []telebot.KeyboardButton{{Text: "Remediate: " + a.Remediation.Name, Data: string(data)}}, nil
+}
+
+// handleRemediateCallback asks an admin to confirm the remediation of the alert whose Remediate button was pressed
+func (b *Bot) handleRemediateCallback(callback telebot.Callback, cd CallbackData, alerts []*HandleAlert) {
+	for _, h := range alerts {
+		if h.Chat.ID != callback.Message.Chat.ID || h.messageID() != callback.Message.ID || h.Remediation == nil {
+			continue
+		}
+		chat := h.Chat
+
+		id := b.confirmations.Add(confirmation{
+			chat:    chat,
+			userID:  callback.Sender.ID,
+			expires: time.Now().Add(confirmationTimeout),
+			action:  func() { go b.remediate(h, callback.Sender.Username) },
+			approve: func(user telebot.User) bool {
+				return b.isAdmin(telebot.Message{Sender: user, Chat: chat})
+			},
+		}, time.Now())
+
+		keyboard, err := confirmKeyboard(id)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to create confirmation keyboard", "err", err)
+			return
+		}
+		question := fmt.Sprintf("@%s asks to run the remediation %s for %s. Can an admin confirm?", callback.Sender.Username, h.Remediation.Name, h.ID)
+		_, err = b.sender.SendMessage(chat, question, &telebot.SendOptions{
+			ReplyTo:     callback.Message,
+			ReplyMarkup: b.callbacks.markup([][]telebot.KeyboardButton{keyboard}),
+		})
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to send remediation confirmation", "err", err)
+		}
+		b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{})
+		return
+	}
+
+	b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{Text: "This alert isn't tracked anymore."})
+}
+
+// remediate runs the remediation action of the alert requested by the user and posts the result in reply to the alert
+func (b *Bot) remediate(h *HandleAlert, user string) {
+	level.Info(b.logger).Log("msg", "running remediation", "action", h.Remediation.Name, "alert_id", h.ID, "chat_id", h.Chat.ID, "username", user)
+
+	ctx, cancel := context.WithTimeout(context.Background(), remediationTimeout)
+	defer cancel()
+
+	result, err := h.Remediation.Run(ctx, remediate.Alert{
+		Name:        h.ID,
+		Labels:      h.Alert.Labels,
+		Annotations: h.Alert.Annotations,
+		User:        user,
+	})
+	text := fmt.Sprintf("Remediation %s of %s: %s", h.Remediation.Name, h.ID, result)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "remediation failed", "action", h.Remediation.Name, "alert_id", h.ID, "err", err)
+		text = fmt.Sprintf("Remediation %s of %s failed: %v", h.Remediation.Name, h.ID, err)
+	}
+	h.publishBy(eventRemediation, user, text)
+
+	_, err = b.sender.SendMessage(h.Chat, text, &telebot.SendOptions{ReplyTo: telebot.Message{ID: h.messageID()}})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send remediation result", "chat_id", h.Chat.ID, "err", err)
+	}
+}
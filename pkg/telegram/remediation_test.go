@@ -0,0 +1,59 @@
+package telegram
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/remediate"
+)
+
+func TestRemediation(t *testing.T) {
+	requests := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("restarted"))
+		requests <- struct{}{}
+	}))
+	defer srv.Close()
+
+	s := newFakeSender()
+	b := &Bot{logger: log.NewNopLogger(), sender: s, admins: []int{1}, confirmations: newConfirmations()}
+	h := &HandleAlert{
+		ID:          "NginxDown",
+		MessageID:   42,
+		Chat:        telebot.Chat{ID: -100},
+		Alert:       template.Alert{Labels: template.KV{"alertname": "NginxDown"}},
+		Remediation: &remediate.Action{Name: "restart-nginx", Webhook: &remediate.Webhook{URL: srv.URL}},
+	}
+
+	button, err := h.remediateButton()
+	assert.NoError(t, err)
+	assert.Equal(t, "Remediate: restart-nginx", button[0].Text)
+
+	callback := telebot.Callback{Sender: telebot.User{ID: 2, Username: "bob"}, Message: telebot.Message{ID: 42, Chat: h.Chat}}
+	b.handleRemediateCallback(callback, CallbackData{Button: strRemediateData, AlertID: h.ID}, []*HandleAlert{h})
+	assert.Equal(t, []string{"@bob asks to run the remediation restart-nginx for NginxDown. Can an admin confirm?"}, s.sent)
+
+	// Only admins may confirm, not the operator asking
+	_, ok := b.confirmations.Take("1", telebot.User{ID: 2}, time.Now())
+	assert.False(t, ok)
+	conf, ok := b.confirmations.Take("1", telebot.User{ID: 1}, time.Now())
+	assert.True(t, ok)
+
+	conf.action()
+	select {
+	case <-requests:
+	case <-time.After(time.Second):
+		t.Fatal("the remediation webhook wasn't called")
+	}
+
+	h.Clear(s)
+	button, err = h.remediateButton()
+	assert.NoError(t, err)
+	assert.Empty(t, button, "resolved alerts can't be remediated")
+}
@@ -0,0 +1,24 @@
+package telegram
+
+import "github.com/tucnak/telebot"
+
+// reply sends the text to the chat of the message.
+// Commands sent in a forum topic or as a reply are answered in the same thread,
+// Telegram sets the first message of the topic as reply of the messages in a topic.
+func (b *Bot) reply(message telebot.Message, text string, options *telebot.SendOptions) (*telebot.Message, error) {
+	return b.sender.SendMessage(message.Chat, text, replyOptions(message, options))
+}
+
+// replyOptions threads the options to the message if it is in a topic or a reply, without changing the options passed
+func replyOptions(message telebot.Message, options *telebot.SendOptions) *telebot.SendOptions {
+	if !message.IsReply() {
+		return options
+	}
+
+	threaded := telebot.SendOptions{}
+	if options != nil {
+		threaded = *options
+	}
+	threaded.ReplyTo = message
+	return &threaded
+}
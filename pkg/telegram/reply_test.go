@@ -0,0 +1,23 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestReplyOptions(t *testing.T) {
+	options := &telebot.SendOptions{ParseMode: telebot.ModeMarkdown}
+
+	message := telebot.Message{ID: 42}
+	assert.Equal(t, options, replyOptions(message, options), "top level commands aren't threaded")
+	assert.Nil(t, replyOptions(message, nil))
+
+	message.ReplyTo = &telebot.Message{ID: 7}
+	threaded := replyOptions(message, options)
+	assert.Equal(t, 42, threaded.ReplyTo.ID)
+	assert.Equal(t, telebot.ModeMarkdown, threaded.ParseMode)
+	assert.Equal(t, 0, options.ReplyTo.ID, "the options passed aren't changed")
+	assert.Equal(t, 42, replyOptions(message, nil).ReplyTo.ID)
+}
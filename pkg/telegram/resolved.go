@@ -0,0 +1,78 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandResolved = "/resolved"
+
+	resolvedOn      = "on"
+	resolvedOff     = "off"
+	resolvedDefault = "default"
+)
+
+// notifyResolved returns whether a chat with the settings is sent resolved notifications.
+// Chats without an own choice follow the global default, none are sent if they are disabled.
+func (b *Bot) notifyResolved(settings ChatSettings) bool {
+	if b.noResolved {
+		return false
+	}
+	if settings.NotifyResolved != nil {
+		return *settings.NotifyResolved
+	}
+	return !b.suppressResolved
+}
+
+func (b *Bot) handleResolved(message telebot.Message) {
+	// Right format: '/resolved on|off|default'.
+	// Ex: /resolved off
+	params := strings.Fields(message.Text)[1:]
+
+	if b.noResolved {
+		b.reply(message, "Resolved notifications are disabled for all chats.", nil)
+		return
+	}
+
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't get the settings of this chat.", nil)
+		return
+	}
+
+	if len(params) == 0 {
+		state := resolvedOff
+		if b.notifyResolved(settings) {
+			state = resolvedOn
+		}
+		b.reply(message, fmt.Sprintf("Resolved notifications are %s for this chat.", state), nil)
+		return
+	}
+
+	switch {
+	case len(params) == 1 && params[0] == resolvedOn:
+		notify := true
+		settings.NotifyResolved = &notify
+	case len(params) == 1 && params[0] == resolvedOff:
+		notify := false
+		settings.NotifyResolved = &notify
+	case len(params) == 1 && params[0] == resolvedDefault:
+		settings.NotifyResolved = nil
+	default:
+		b.reply(message, "Please send right format: '/resolved on|off|default'. Ex: /resolved off", nil)
+		return
+	}
+
+	if err := b.settings.Set(message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.reply(message, "I can't save the settings of this chat.", nil)
+		return
+	}
+
+	b.reply(message, responseMember, nil)
+}
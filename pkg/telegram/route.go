@@ -0,0 +1,357 @@
+package telegram
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/tucnak/telebot"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const defaultTemplate = "telegram.default"
+
+// EscalationPolicy configures how alerts are escalated to the next level
+type EscalationPolicy struct {
+	Name string `yaml:"name"`
+	// Timeout after which an unacknowledged alert is forwarded to the next level
+	Timeout model.Duration `yaml:"timeout"`
+}
+
+// Route is a node of the routing tree, similar to the Alertmanager's routes.
+// Children inherit chats, teams, template and escalation of their parent if unset.
+// Teams deliver to the team's chat, restricted to the team's nodes if it has any.
+type Route struct {
+	Match      map[string]string `yaml:"match,omitempty"`
+	MatchRE    map[string]string `yaml:"match_re,omitempty"`
+	Chats      []int64           `yaml:"chats,omitempty"`
+	Teams      []string          `yaml:"teams,omitempty"`
+	Template   string            `yaml:"template,omitempty"`
+	Escalation string            `yaml:"escalation,omitempty"`
+	Continue   bool              `yaml:"continue,omitempty"`
+	Routes     []*Route          `yaml:"routes,omitempty"`
+
+	matchers types.Matchers
+}
+
+// RoutingConfig is the content of the routing configuration file
+type RoutingConfig struct {
+	EscalationPolicies []EscalationPolicy `yaml:"escalation_policies,omitempty"`
+	Route              *Route             `yaml:"route"`
+
+	policies map[string]*EscalationPolicy
+}
+
+// LoadRoutingConfig parses the routing configuration from YAML
+func LoadRoutingConfig(b []byte) (*RoutingConfig, error) {
+	var c RoutingConfig
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return nil, err
+	}
+
+	if c.Route == nil {
+		return nil, fmt.Errorf("missing root route")
+	}
+
+	c.policies = make(map[string]*EscalationPolicy, len(c.EscalationPolicies))
+	for i, p := range c.EscalationPolicies {
+		if p.Name == "" {
+			return nil, fmt.Errorf("escalation policy without name")
+		}
+		if p.Timeout <= 0 {
+			return nil, fmt.Errorf("escalation policy %q needs a positive timeout", p.Name)
+		}
+		if _, ok := c.policies[p.Name]; ok {
+			return nil, fmt.Errorf("duplicate escalation policy %q", p.Name)
+		}
+		c.policies[p.Name] = &c.EscalationPolicies[i]
+	}
+
+	if c.Route.Template == "" {
+		c.Route.Template = defaultTemplate
+	}
+	if err := c.init(c.Route, nil); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Templates returns the names of the templates used by the routes
+func (c *RoutingConfig) Templates() []string {
+	var names []string
+	seen := map[string]bool{}
+	var walk func(r *Route)
+	walk = func(r *Route) {
+		if r.Template != "" && !seen[r.Template] {
+			seen[r.Template] = true
+			names = append(names, r.Template)
+		}
+		for _, child := range r.Routes {
+			walk(child)
+		}
+	}
+	walk(c.Route)
+	return names
+}
+
+// LoadRoutingConfigFile parses the routing configuration from a YAML file
+func LoadRoutingConfigFile(path string) (*RoutingConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadRoutingConfig(b)
+}
+
+// init validates a route, builds its matchers and inherits unset values of the parent
+func (c *RoutingConfig) init(r *Route, parent *Route) error {
+	if parent != nil {
+		if len(r.Chats) == 0 && len(r.Teams) == 0 {
+			r.Chats = parent.Chats
+			r.Teams = parent.Teams
+		}
+		if r.Template == "" {
+			r.Template = parent.Template
+		}
+		if r.Escalation == "" {
+			r.Escalation = parent.Escalation
+		}
+	}
+
+	if r.Escalation != "" {
+		if _, ok := c.policies[r.Escalation]; !ok {
+			return fmt.Errorf("route references unknown escalation policy %q", r.Escalation)
+		}
+	}
+
+	var matchers []*types.Matcher
+	for name, value := range r.Match {
+		m := types.NewMatcher(model.LabelName(name), value)
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		matchers = append(matchers, m)
+	}
+	for name, value := range r.MatchRE {
+		m := &types.Matcher{Name: name, Value: value, IsRegex: true}
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		if err := m.Init(); err != nil {
+			return err
+		}
+		matchers = append(matchers, m)
+	}
+	r.matchers = types.NewMatchers(matchers...)
+
+	for _, child := range r.Routes {
+		if err := c.init(child, r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// match returns the deepest routes matching the labels, like the Alertmanager's dispatcher
+func (r *Route) match(lset model.LabelSet) []*Route {
+	if !r.matchers.Match(lset) {
+		return nil
+	}
+
+	var matches []*Route
+	for _, child := range r.Routes {
+		m := child.match(lset)
+		matches = append(matches, m...)
+
+		if len(m) > 0 && !child.Continue {
+			break
+		}
+	}
+
+	if len(matches) == 0 {
+		matches = append(matches, r)
+	}
+
+	return matches
+}
+
+// routedAlerts are the alerts of a webhook delivered to a single chat
+type routedAlerts struct {
+	chat     telebot.Chat
+	alerts   template.Alerts
+	template string
+	// timeout after which unacknowledged alerts are escalated
+	timeout time.Duration
+	// settings of the chat, loaded before the chat's filters are applied
+	settings ChatSettings
+}
+
+// broadcast delivers all alerts to all subscribed chats
+func broadcast(chats []telebot.Chat, alerts template.Alerts) []*routedAlerts {
+	routed := make([]*routedAlerts, 0, len(chats))
+	for _, chat := range chats {
+		routed = append(routed, &routedAlerts{
+			chat:     chat,
+			alerts:   alerts,
+			template: defaultTemplate,
+			timeout:  AutoForwardTimeout,
+		})
+	}
+	return routed
+}
+
+// unroutedAlerts returns the alerts that none of the targets receives
+func unroutedAlerts(alerts template.Alerts, targets []*routedAlerts) template.Alerts {
+	routed := make(map[model.Fingerprint]bool)
+	for _, t := range targets {
+		for _, a := range t.alerts {
+			routed[alertLabelSet(a).Fingerprint()] = true
+		}
+	}
+
+	var unrouted template.Alerts
+	for _, a := range alerts {
+		if !routed[alertLabelSet(a).Fingerprint()] {
+			unrouted = append(unrouted, a)
+		}
+	}
+	return unrouted
+}
+
+// Router routes alerts to chats by a routing configuration that can be reloaded
+type Router struct {
+	load func() (*RoutingConfig, error)
+
+	mu     sync.RWMutex
+	config *RoutingConfig
+}
+
+// NewRouter loads the routing configuration file at path
+func NewRouter(path string) (*Router, error) {
+	return NewRouterFunc(func() (*RoutingConfig, error) {
+		return LoadRoutingConfigFile(path)
+	})
+}
+
+// NewRouterFunc loads the routing configuration with load, which is called again on every reload
+func NewRouterFunc(load func() (*RoutingConfig, error)) (*Router, error) {
+	r := &Router{load: load}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload the routing configuration file. The current configuration is kept if the new one is invalid.
+func (r *Router) Reload() error {
+	c, err := r.Load()
+	if err != nil {
+		return err
+	}
+	r.Set(c)
+	return nil
+}
+
+// Load the routing configuration without applying it, so that it can be applied with Set
+// together with other configuration
+func (r *Router) Load() (*RoutingConfig, error) {
+	return r.load()
+}
+
+// Set the routing configuration used from now on
+func (r *Router) Set(c *RoutingConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config = c
+}
+
+// HasEscalationPolicy returns whether the current configuration has an escalation policy with the name
+func (r *Router) HasEscalationPolicy(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.config.policies[name]
+	return ok
+}
+
+// Route the alerts to chats. Known chats are taken from the subscribed ones,
+// chats only referenced by the routing configuration are addressed by their ID.
+// Teams referenced by routes are looked up in teams, unknown teams are skipped.
+func (r *Router) Route(chats []telebot.Chat, teams []Team, alerts template.Alerts) []*routedAlerts {
+	r.mu.RLock()
+	c := r.config
+	r.mu.RUnlock()
+
+	known := make(map[int64]telebot.Chat, len(chats))
+	for _, chat := range chats {
+		known[chat.ID] = chat
+	}
+
+	teamsByName := make(map[string]Team, len(teams))
+	for _, t := range teams {
+		teamsByName[t.Name] = t
+	}
+
+	type key struct {
+		chat     int64
+		template string
+	}
+
+	var routed []*routedAlerts
+	index := make(map[key]*routedAlerts)
+
+	for _, a := range alerts {
+		seen := make(map[key]bool)
+		for _, route := range c.Route.match(alertLabelSet(a)) {
+			timeout := AutoForwardTimeout
+			if p, ok := c.policies[route.Escalation]; ok {
+				timeout = time.Duration(p.Timeout)
+			}
+
+			add := func(chat telebot.Chat, timeout time.Duration) {
+				k := key{chat: chat.ID, template: route.Template}
+				if seen[k] {
+					return
+				}
+				seen[k] = true
+
+				ra, ok := index[k]
+				if !ok {
+					ra = &routedAlerts{chat: chat, template: route.Template, timeout: timeout}
+					index[k] = ra
+					routed = append(routed, ra)
+				}
+				ra.alerts = append(ra.alerts, a)
+			}
+
+			for _, id := range route.Chats {
+				chat, ok := known[id]
+				if !ok {
+					chat = telebot.Chat{ID: id}
+				}
+				add(chat, timeout)
+			}
+
+			for _, name := range route.Teams {
+				t, ok := teamsByName[name]
+				if !ok || len(filterNodes(t.Nodes, template.Alerts{a})) == 0 {
+					continue
+				}
+
+				teamTimeout := timeout
+				if p, ok := c.policies[t.Escalation]; ok {
+					teamTimeout = time.Duration(p.Timeout)
+				}
+				add(t.Chat, teamTimeout)
+			}
+		}
+	}
+
+	return routed
+}
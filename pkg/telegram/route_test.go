@@ -0,0 +1,133 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+const testRoutingConfig = `
+escalation_policies:
+- name: fast
+  timeout: 1m
+route:
+  chats: [1]
+  routes:
+  - match:
+      team: db
+    chats: [2]
+    template: telegram.db
+    escalation: fast
+  - match_re:
+      severity: critical|page
+    chats: [3]
+    continue: true
+  - match:
+      env: staging
+    chats: [4]
+`
+
+func TestLoadRoutingConfig(t *testing.T) {
+	_, err := LoadRoutingConfig([]byte(testRoutingConfig))
+	assert.NoError(t, err)
+
+	_, err = LoadRoutingConfig([]byte(`route: {escalation: missing}`))
+	assert.Error(t, err)
+
+	_, err = LoadRoutingConfig([]byte(`escalation_policies: []`))
+	assert.Error(t, err)
+
+	_, err = LoadRoutingConfig([]byte(`route: {unknown: field}`))
+	assert.Error(t, err)
+}
+
+func TestRoutingConfigTemplates(t *testing.T) {
+	c, err := LoadRoutingConfig([]byte(testRoutingConfig))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"telegram.default", "telegram.db"}, c.Templates())
+}
+
+func TestRouterRoute(t *testing.T) {
+	c, err := LoadRoutingConfig([]byte(testRoutingConfig))
+	assert.NoError(t, err)
+	r := &Router{config: c}
+
+	alerts := template.Alerts{
+		{Labels: template.KV{"alertname": "Replication", "team": "db"}},
+		{Labels: template.KV{"alertname": "NodeDown", "severity": "critical", "env": "staging"}},
+		{Labels: template.KV{"alertname": "DiskFull", "severity": "warning"}},
+	}
+
+	routed := r.Route([]telebot.Chat{{ID: 1, Title: "ops"}}, nil, alerts)
+	assert.Len(t, routed, 4)
+
+	assert.Equal(t, int64(2), routed[0].chat.ID)
+	assert.Equal(t, "telegram.db", routed[0].template)
+	assert.Equal(t, time.Minute, routed[0].timeout)
+	assert.Len(t, routed[0].alerts, 1)
+
+	assert.Equal(t, int64(3), routed[1].chat.ID)
+	assert.Equal(t, int64(4), routed[2].chat.ID)
+	assert.Equal(t, "NodeDown", routed[2].alerts[0].Labels["alertname"])
+
+	assert.Equal(t, int64(1), routed[3].chat.ID)
+	assert.Equal(t, "ops", routed[3].chat.Title)
+	assert.Equal(t, defaultTemplate, routed[3].template)
+	assert.Equal(t, AutoForwardTimeout, routed[3].timeout)
+	assert.Equal(t, "DiskFull", routed[3].alerts[0].Labels["alertname"])
+}
+
+func TestRouterRouteTeams(t *testing.T) {
+	c, err := LoadRoutingConfig([]byte(`
+escalation_policies:
+- name: fast
+  timeout: 1m
+route:
+  teams: [web]
+  routes:
+  - match:
+      team: db
+    teams: [db, unknown]
+`))
+	assert.NoError(t, err)
+	r := &Router{config: c}
+
+	teams := []Team{
+		{Name: "db", Chat: telebot.Chat{ID: 2}, Escalation: "fast"},
+		{Name: "web", Chat: telebot.Chat{ID: 3}, Nodes: []string{"web01"}},
+	}
+	alerts := template.Alerts{
+		{Labels: template.KV{"alertname": "Replication", "team": "db"}},
+		{Labels: template.KV{"alertname": "HighCPU", "instance": "web01:9100"}},
+		{Labels: template.KV{"alertname": "DiskFull", "instance": "db01:9100"}},
+	}
+
+	routed := r.Route(nil, teams, alerts)
+	assert.Len(t, routed, 2)
+
+	assert.Equal(t, int64(2), routed[0].chat.ID)
+	assert.Equal(t, time.Minute, routed[0].timeout)
+	assert.Equal(t, "Replication", routed[0].alerts[0].Labels["alertname"])
+
+	assert.Equal(t, int64(3), routed[1].chat.ID)
+	assert.Equal(t, AutoForwardTimeout, routed[1].timeout)
+	assert.Len(t, routed[1].alerts, 1)
+	assert.Equal(t, "HighCPU", routed[1].alerts[0].Labels["alertname"])
+}
+
+func TestUnroutedAlerts(t *testing.T) {
+	alerts := template.Alerts{
+		{Labels: template.KV{"alertname": "HighCPU"}},
+		{Labels: template.KV{"alertname": "DiskFull"}},
+	}
+
+	assert.Equal(t, alerts, unroutedAlerts(alerts, nil))
+
+	targets := []*routedAlerts{{alerts: alerts[1:]}}
+	unrouted := unroutedAlerts(alerts, targets)
+	assert.Len(t, unrouted, 1)
+	assert.Equal(t, "HighCPU", unrouted[0].Labels["alertname"])
+}
@@ -0,0 +1,97 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/prometheus"
+)
+
+const commandRules = "/rules"
+
+// ruleStates orders the rules by how close they are to firing
+var ruleStates = map[string]int{
+	prometheus.RuleFiring:   0,
+	prometheus.RulePending:  1,
+	prometheus.RuleInactive: 2,
+}
+
+// ruleIcons show the state of a rule
+var ruleIcons = map[string]string{
+	prometheus.RuleFiring:   "🔥",
+	prometheus.RulePending:  "⏳",
+	prometheus.RuleInactive: "✅",
+}
+
+// ruleLines lists the alerting rules in the state, all if empty, firing before pending before inactive rules.
+// Every rule shows the expression with its threshold and how long it has to be true to fire.
+func ruleLines(groups []prometheus.RuleGroup, state string) []string {
+	var rules []prometheus.Rule
+	for _, g := range groups {
+		for _, r := range g.Rules {
+			if state == "" || r.State == state {
+				rules = append(rules, r)
+			}
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		if ruleStates[rules[i].State] != ruleStates[rules[j].State] {
+			return ruleStates[rules[i].State] < ruleStates[rules[j].State]
+		}
+		return rules[i].Name < rules[j].Name
+	})
+
+	var lines []string
+	for _, r := range rules {
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s %s: %s", ruleIcons[r.State], r.Name, r.State)
+		if len(r.Alerts) > 0 {
+			fmt.Fprintf(&b, ", %d alerts", len(r.Alerts))
+		}
+		if r.Duration > 0 {
+			fmt.Fprintf(&b, ", for %s", model.Duration(time.Duration(r.Duration*float64(time.Second))))
+		}
+		if r.LastError != "" {
+			fmt.Fprintf(&b, ", ❌ %s", r.LastError)
+		}
+		fmt.Fprintf(&b, "\n    %s", truncate(200, strings.Join(strings.Fields(r.Query), " ")))
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+func (b *Bot) handleRules(message telebot.Message) {
+	// Right format: '/rules [firing|pending|inactive]'.
+	// Ex: /rules pending
+	params := strings.Fields(message.Text)[1:]
+	if len(params) > 1 || len(params) == 1 && ruleIcons[params[0]] == "" {
+		b.reply(message, "Please send right format: '/rules [firing|pending|inactive]'. Ex: /rules pending", nil)
+		return
+	}
+	if b.prometheus == nil {
+		b.reply(message, "No Prometheus is configured to query.", nil)
+		return
+	}
+
+	state := ""
+	if len(params) == 1 {
+		state = params[0]
+	}
+
+	groups, err := prometheus.ListAlertingRules(b.prometheus.String())
+	if err != nil {
+		b.reply(message, fmt.Sprintf("failed to list rules... %v", err), nil)
+		return
+	}
+	lines := ruleLines(groups, state)
+	if len(lines) == 0 {
+		b.reply(message, "No alerting rules found.", nil)
+		return
+	}
+
+	b.sendPages(message, paginate("Alerting rules:\n", lines, itemsPerPage), "")
+}
@@ -0,0 +1,28 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vu-long/alertmanager-bot/pkg/prometheus"
+)
+
+func TestRuleLines(t *testing.T) {
+	groups := []prometheus.RuleGroup{
+		{Name: "node", Rules: []prometheus.Rule{
+			{Name: "HighCPU", Query: "cpu_usage\n  > 90", Duration: 300, State: prometheus.RulePending, Alerts: []prometheus.RuleAlert{{State: prometheus.RulePending}}},
+			{Name: "DiskFull", Query: "disk_free < 0.1", State: prometheus.RuleInactive},
+		}},
+		{Name: "nginx", Rules: []prometheus.Rule{
+			{Name: "NginxDown", Query: "up{job=\"nginx\"} == 0", Duration: 60, State: prometheus.RuleFiring, Alerts: []prometheus.RuleAlert{{}, {}}},
+		}},
+	}
+
+	assert.Equal(t, []string{
+		"🔥 NginxDown: firing, 2 alerts, for 1m\n    up{job=\"nginx\"} == 0",
+		"⏳ HighCPU: pending, 1 alerts, for 5m\n    cpu_usage > 90",
+		"✅ DiskFull: inactive\n    disk_free < 0.1",
+	}, ruleLines(groups, ""))
+
+	assert.Equal(t, []string{"✅ DiskFull: inactive\n    disk_free < 0.1"}, ruleLines(groups, prometheus.RuleInactive))
+}
@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/runbook"
+)
+
+const (
+	commandRun = "/run"
+
+	// Outcomes of the audited runbook actions
+	auditApproved = "approved"
+	auditDeclined = "declined"
+	auditFailed   = "failed"
+)
+
+// isOperator returns whether the sender of the message may request runbook actions, admins and the members of the chat
+func (b *Bot) isOperator(message telebot.Message) bool {
+	if b.isAdmin(message) {
+		return true
+	}
+	members, err := b.members.GetMembersByChat(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get members of chat", "chat_id", message.Chat.ID, "err", err)
+		return false
+	}
+	for _, m := range members {
+		if m.Username != "" && m.Username == message.Sender.Username {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bot) handleRun(message telebot.Message) {
+	// Right format: '/run (action)'.
+	// Ex: /run restart-nginx
+	const usage = "Please send right format: '/run (action)'. Ex: /run restart-nginx"
+	if b.runbook == nil {
+		b.reply(message, "No runbook actions are configured.", nil)
+		return
+	}
+	if !b.isOperator(message) {
+		b.reply(message, "Only admins and members of this chat can run runbook actions.", nil)
+		return
+	}
+
+	args := strings.Fields(message.Text)
+	if len(args) == 1 {
+		var lines []string
+		for _, a := range b.runbook.List() {
+			line := a.Name
+			if a.Description != "" {
+				line += " - " + a.Description
+			}
+			lines = append(lines, line)
+		}
+		b.reply(message, "Runbook actions:\n"+strings.Join(lines, "\n"), nil)
+		return
+	}
+	if len(args) != 2 {
+		b.reply(message, usage, nil)
+		return
+	}
+
+	action := b.runbook.Get(args[1])
+	if action == nil {
+		b.reply(message, fmt.Sprintf("There is no runbook action %s, send /run to list them.", args[1]), nil)
+		return
+	}
+
+	// Another admin approves, so no one runs an action alone
+	id := b.confirmations.Add(confirmation{
+		chat:    message.Chat,
+		userID:  message.Sender.ID,
+		expires: time.Now().Add(confirmationTimeout),
+		approve: func(user telebot.User) bool {
+			return user.ID != message.Sender.ID && b.isAdmin(telebot.Message{Sender: user, Chat: message.Chat})
+		},
+		resolved: func(user telebot.User, confirmed bool) {
+			outcome := auditDeclined
+			if confirmed {
+				outcome = auditApproved
+				go b.runAction(message, action, user)
+			}
+			b.auditCommand(telebot.Message{Sender: user, Chat: message.Chat}, commandRun, message.Text, outcome)
+		},
+	}, time.Now())
+
+	keyboard, err := confirmKeyboard(id)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to create confirmation keyboard", "err", err)
+		return
+	}
+	request := fmt.Sprintf("@%s asks to run the runbook action %s", message.Sender.Username, action.Name)
+	if action.Description != "" {
+		request += fmt.Sprintf(" (%s)", action.Description)
+	}
+	request += ". Can another admin approve?"
+	_, err = b.reply(message, request, &telebot.SendOptions{
+		ReplyMarkup: b.callbacks.markup([][]telebot.KeyboardButton{keyboard}),
+	})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send runbook request", "err", err)
+	}
+}
+
+// runAction runs the approved runbook action and replies to its request with the output
+func (b *Bot) runAction(message telebot.Message, action *runbook.Action, approver telebot.User) {
+	level.Info(b.logger).Log("msg", "running runbook action", "action", action.Name, "chat_id", message.Chat.ID, "username", message.Sender.Username, "approver", approver.Username)
+
+	output, err := action.Run(context.Background(), message.Sender.Username)
+	outcome := auditExecuted
+	text := fmt.Sprintf("Runbook action %s approved by @%s finished", action.Name, approver.Username)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "runbook action failed", "action", action.Name, "err", err)
+		outcome = auditFailed
+		text = fmt.Sprintf("Runbook action %s approved by @%s failed: %v", action.Name, approver.Username, err)
+	}
+	if output != "" {
+		text += ":\n" + output
+	} else {
+		text += "."
+	}
+	b.auditCommand(message, commandRun, message.Text, outcome)
+
+	if _, err := b.reply(message, truncate(maxMessageLength, text), nil); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send runbook output", "chat_id", message.Chat.ID, "err", err)
+	}
+}
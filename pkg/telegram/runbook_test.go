@@ -0,0 +1,45 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/runbook"
+)
+
+func TestRun(t *testing.T) {
+	c, err := runbook.Load([]byte(`
+actions:
+- name: hello
+  description: Say hello
+  command: [sh, -c, 'echo "hello from $RUNBOOK_USER"']
+`))
+	assert.NoError(t, err)
+
+	s := newFakeSender()
+	b := &Bot{logger: log.NewNopLogger(), sender: s, admins: []int{1, 2}, confirmations: newConfirmations(), runbook: c}
+	message := telebot.Message{Text: "/run hello", Sender: telebot.User{ID: 1, Username: "alice"}, Chat: telebot.Chat{ID: -100}}
+
+	b.handleRun(telebot.Message{Text: "/run", Sender: message.Sender, Chat: message.Chat})
+	assert.Equal(t, "Runbook actions:\nhello - Say hello", s.sent[0])
+
+	b.handleRun(message)
+	assert.Equal(t, "@alice asks to run the runbook action hello (Say hello). Can another admin approve?", s.sent[1])
+
+	// The admin asking can't approve alone, nor can non-admins
+	_, ok := b.confirmations.Take("1", telebot.User{ID: 1}, time.Now())
+	assert.False(t, ok)
+	_, ok = b.confirmations.Take("1", telebot.User{ID: 3}, time.Now())
+	assert.False(t, ok)
+	_, ok = b.confirmations.Take("1", telebot.User{ID: 2}, time.Now())
+	assert.True(t, ok)
+
+	b.runAction(message, c.Get("hello"), telebot.User{ID: 2, Username: "bob"})
+	assert.Equal(t, "Runbook action hello approved by @bob finished:\nhello from alice", s.sent[2])
+
+	b.handleRun(telebot.Message{Text: "/run rm", Sender: message.Sender, Chat: message.Chat})
+	assert.Equal(t, "There is no runbook action rm, send /run to list them.", s.sent[3])
+}
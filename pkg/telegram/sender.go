@@ -0,0 +1,33 @@
+package telegram
+
+import "github.com/tucnak/telebot"
+
+// MessageSender sends the messages and photos of the bot and edits their text and buttons.
+// telegramSender implements it with the Bot API, other transports map the recipients and buttons to their own.
+type MessageSender interface {
+	SendMessage(recipient telebot.Recipient, text string, options *telebot.SendOptions) (*telebot.Message, error)
+	EditMessageText(recipient telebot.Recipient, messageID int, text string, options *telebot.SendOptions) error
+	EditMessageReplyMarkup(recipient telebot.Recipient, messageID int, options *telebot.SendOptions) error
+	AnswerCallbackQuery(callback *telebot.Callback, response *telebot.CallbackResponse) error
+	// SendAlbum sends a single photo as such and more as album, in reply to the message replyTo unless it is 0
+	SendAlbum(recipient telebot.Recipient, photos []AlbumPhoto, replyTo int) error
+}
+
+// telegramSender sends with telebot, and the photos with the Bot API as telebot only uploads single photos from files
+type telegramSender struct {
+	*telebot.Bot
+	albums *telegramAlbums
+}
+
+func newTelegramSender(bot *telebot.Bot, token string) *telegramSender {
+	return &telegramSender{Bot: bot, albums: newTelegramAlbums(token)}
+}
+
+// EditMessageReplyMarkup replaces the buttons of the message, telebot misspells the method
+func (s *telegramSender) EditMessageReplyMarkup(recipient telebot.Recipient, messageID int, options *telebot.SendOptions) error {
+	return s.Bot.EditMessageReplyMakeup(recipient, messageID, options)
+}
+
+func (s *telegramSender) SendAlbum(recipient telebot.Recipient, photos []AlbumPhoto, replyTo int) error {
+	return s.albums.SendAlbum(recipient, photos, replyTo)
+}
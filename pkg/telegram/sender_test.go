@@ -0,0 +1,96 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+// fakeSender records the messages instead of sending them to Telegram
+type fakeSender struct {
+	sent    []string
+	edited  map[int]string
+	markups map[int]telebot.ReplyMarkup
+	answers []string
+	albums  [][]AlbumPhoto
+	replyTo []int
+}
+
+func newFakeSender() *fakeSender {
+	return &fakeSender{edited: make(map[int]string), markups: make(map[int]telebot.ReplyMarkup)}
+}
+
+func (s *fakeSender) SendMessage(recipient telebot.Recipient, text string, options *telebot.SendOptions) (*telebot.Message, error) {
+	s.sent = append(s.sent, text)
+	return &telebot.Message{ID: len(s.sent)}, nil
+}
+
+func (s *fakeSender) EditMessageText(recipient telebot.Recipient, messageID int, text string, options *telebot.SendOptions) error {
+	s.edited[messageID] = text
+	if options != nil {
+		s.markups[messageID] = options.ReplyMarkup
+	}
+	return nil
+}
+
+func (s *fakeSender) EditMessageReplyMarkup(recipient telebot.Recipient, messageID int, options *telebot.SendOptions) error {
+	s.markups[messageID] = options.ReplyMarkup
+	return nil
+}
+
+func (s *fakeSender) AnswerCallbackQuery(callback *telebot.Callback, response *telebot.CallbackResponse) error {
+	s.answers = append(s.answers, response.Text)
+	return nil
+}
+
+func (s *fakeSender) SendAlbum(recipient telebot.Recipient, photos []AlbumPhoto, replyTo int) error {
+	s.albums = append(s.albums, photos)
+	s.replyTo = append(s.replyTo, replyTo)
+	return nil
+}
+
+func TestHandleAlertSender(t *testing.T) {
+	tmpl, err := LoadTemplates()
+	assert.NoError(t, err)
+
+	s := newFakeSender()
+	a := &HandleAlert{
+		ID:              "HighCPU",
+		MessageID:       42,
+		Chat:            telebot.Chat{ID: -100},
+		Alert:           template.Alert{Labels: template.KV{"alertname": "HighCPU"}},
+		Level:           levelOne,
+		AutoForwardFlag: true,
+		Templates:       func() *Templates { return tmpl },
+	}
+
+	assert.NoError(t, a.Acknowledge(s, telebot.Callback{Sender: telebot.User{Username: "alice"}}))
+	assert.False(t, a.AutoForwardFlag)
+	assert.Equal(t, []string{"Acknowledge by: @alice"}, s.sent)
+	// The Acknowledge and Forward buttons are hidden
+	assert.Empty(t, s.markups[42].InlineKeyboard)
+
+	next, err := a.Refire(s, a.Fingerprints, "HighCPU fired again", telebot.ModeHTML)
+	assert.NoError(t, err)
+	assert.True(t, next.IsZero(), "the acknowledged alert doesn't escalate again")
+	assert.Equal(t, "HighCPU fired again", s.edited[42])
+	assert.Empty(t, s.markups[42].InlineKeyboard)
+
+	assert.NoError(t, a.Resolved(s, "HighCPU is resolved", telebot.ModeHTML))
+	assert.Equal(t, []string{"Acknowledge by: @alice", "HighCPU is resolved"}, s.sent)
+
+	// Firing again after it was resolved restores the buttons and restarts the escalation
+	a.Level = levelThree
+	next, err = a.Refire(s, a.Fingerprints, "HighCPU fired again", telebot.ModeHTML)
+	assert.NoError(t, err)
+	assert.False(t, next.IsZero())
+	assert.True(t, a.AutoForwardFlag)
+	assert.Equal(t, levelOne, a.Level)
+	assert.Empty(t, a.AcknowledgedBy)
+	assert.False(t, a.settled())
+	if assert.Len(t, s.markups[42].InlineKeyboard, 1) {
+		assert.Len(t, s.markups[42].InlineKeyboard[0], 2)
+	}
+}
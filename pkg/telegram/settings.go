@@ -0,0 +1,100 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/tucnak/telebot"
+)
+
+const telegramSettingsDirectory = "telegram/settings"
+
+// ChatSettings holds the per chat configuration of how alerts are delivered
+type ChatSettings struct {
+	// Matchers an alert's labels and annotations have to match to be delivered to the chat
+	Matchers Matchers `json:"matchers,omitempty"`
+	// Nodes the chat subscribed to, alerts of other nodes aren't delivered. Empty means all nodes.
+	Nodes []string `json:"nodes,omitempty"`
+
+	// QuietHours is a daily window during which alerts are held back
+	QuietHours *QuietHours `json:"quietHours,omitempty"`
+	// Maintenance windows during which alerts are held back
+	Maintenance []MaintenanceWindow `json:"maintenance,omitempty"`
+	// QuietDigest delivers the held back alerts as a digest afterwards
+	QuietDigest bool `json:"quietDigest,omitempty"`
+
+	// Digest configures alerts that are only delivered in a periodic digest
+	Digest *DigestSettings `json:"digest,omitempty"`
+
+	// Template renders the alerts of the chat instead of the global template
+	Template string `json:"template,omitempty"`
+	// TemplateFormat is the output format of Template, see parseFormat
+	TemplateFormat string `json:"templateFormat,omitempty"`
+
+	// Modes are the rendering modes of the chat by severity, the empty severity is the mode of all other alerts
+	Modes map[string]string `json:"modes,omitempty"`
+
+	// NotifyResolved overrides whether resolved notifications are sent to the chat, unset follows the global default
+	NotifyResolved *bool `json:"notifyResolved,omitempty"`
+
+	// Mutes holds alertnames not delivered to the chat until the given time
+	Mutes map[string]time.Time `json:"mutes,omitempty"`
+}
+
+// SettingsStore writes the chat settings to a libkv store backend
+type SettingsStore struct {
+	kv store.Store
+}
+
+// NewSettingsStore stores chat settings in the provided kv backend
+func NewSettingsStore(kv store.Store) (*SettingsStore, error) {
+	return &SettingsStore{kv: kv}, nil
+}
+
+// Get the settings of a chat, chats without any settings get the defaults
+func (s *SettingsStore) Get(c telebot.Chat) (ChatSettings, error) {
+	var settings ChatSettings
+
+	kv, err := s.kv.Get(settingsKey(c))
+	if err == store.ErrKeyNotFound {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+
+	if err := json.Unmarshal(kv.Value, &settings); err != nil {
+		return settings, err
+	}
+
+	for _, m := range settings.Matchers {
+		if err := m.Init(); err != nil {
+			return settings, err
+		}
+	}
+	if settings.Digest != nil {
+		for _, m := range settings.Digest.Matchers {
+			if err := m.Init(); err != nil {
+				return settings, err
+			}
+		}
+	}
+
+	return settings, nil
+}
+
+// Set the settings of a chat in the kv backend
+func (s *SettingsStore) Set(c telebot.Chat, settings ChatSettings) error {
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	return s.kv.Put(settingsKey(c), b, nil)
+}
+
+func settingsKey(c telebot.Chat) string {
+	return fmt.Sprintf("%s/%d", telegramSettingsDirectory, c.ID)
+}
@@ -0,0 +1,267 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandSettings = "/settings"
+
+	strSettingData = "Setting"
+
+	// Options of the /settings menu
+	settingResolved    = "resolved"
+	settingMode        = "mode"
+	settingQuietDigest = "quietdigest"
+	settingQuietOff    = "quietoff"
+	settingTemplate    = "template"
+	settingClose       = "close"
+)
+
+// settingsSummary describes the configuration of a chat
+func (b *Bot) settingsSummary(settings ChatSettings, now time.Time) string {
+	var s strings.Builder
+	s.WriteString("Settings of this chat:\n")
+
+	filter := "all alerts"
+	if len(settings.Matchers) > 0 {
+		filter = settings.Matchers.String()
+	}
+	fmt.Fprintf(&s, "Filter: %s\n", filter)
+
+	nodes := "all"
+	if len(settings.Nodes) > 0 {
+		nodes = strings.Join(settings.Nodes, ", ")
+	}
+	fmt.Fprintf(&s, "Nodes: %s\n", nodes)
+
+	quiet := quietOff
+	if settings.QuietHours != nil {
+		quiet = settings.QuietHours.String()
+		if settings.QuietDigest {
+			quiet += ", then as digest"
+		}
+	}
+	fmt.Fprintf(&s, "Quiet hours: %s\n", quiet)
+
+	maintenance := 0
+	for _, w := range settings.Maintenance {
+		if w.End.After(now) {
+			maintenance++
+		}
+	}
+	fmt.Fprintf(&s, "Maintenance windows: %d\n", maintenance)
+
+	digest := "off"
+	if settings.Digest != nil {
+		digest = fmt.Sprintf("%s every %s", settings.Digest.Matchers, settings.Digest.Interval)
+	}
+	fmt.Fprintf(&s, "Digest: %s\n", digest)
+
+	tmpl := modeDefault
+	if settings.Template != "" {
+		tmpl = settings.Template
+	}
+	fmt.Fprintf(&s, "Template: %s\n", tmpl)
+	fmt.Fprintf(&s, "Mode: %s\n", modesString(settings.Modes))
+
+	resolved := resolvedOff
+	if b.notifyResolved(settings) {
+		resolved = resolvedOn
+	}
+	if b.noResolved {
+		resolved += " (disabled for all chats)"
+	} else if settings.NotifyResolved == nil {
+		resolved += " (default)"
+	}
+	fmt.Fprintf(&s, "Resolved notifications: %s\n", resolved)
+	fmt.Fprintf(&s, "Muted alerts: %d\n", len(activeMutes(settings.Mutes, now)))
+
+	escalation := fmt.Sprintf("forward after %s", AutoForwardTimeout)
+	if b.router != nil {
+		escalation = "by the routing configuration"
+	}
+	fmt.Fprintf(&s, "Escalation: %s", escalation)
+
+	return s.String()
+}
+
+// modesString lists the modes of a chat, the chat-wide one first
+func modesString(modes map[string]string) string {
+	if len(modes) == 0 {
+		return modeDefault
+	}
+	var severities []string
+	for severity := range modes {
+		if severity != "" {
+			severities = append(severities, severity)
+		}
+	}
+	sort.Strings(severities)
+
+	mode := modeDefault
+	if m, ok := modes[""]; ok {
+		mode = m
+	}
+	parts := []string{mode}
+	for _, severity := range severities {
+		parts = append(parts, fmt.Sprintf("%s for %s", modes[severity], severity))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// settingsKeyboard offers the options of the chat's settings that can be toggled
+func settingsKeyboard(settings ChatSettings) ([][]telebot.KeyboardButton, error) {
+	button := func(text, setting string) (telebot.KeyboardButton, error) {
+		data, err := json.Marshal(CallbackData{Button: strSettingData, Value: setting})
+		if err != nil {
+			return telebot.KeyboardButton{}, err
+		}
+		return telebot.KeyboardButton{Text: text, Data: string(data)}, nil
+	}
+
+	options := [][2]string{
+		{"Toggle resolved notifications", settingResolved},
+		{"Switch mode", settingMode},
+	}
+	if settings.QuietHours != nil {
+		options = append(options, [2]string{"Toggle quiet digest", settingQuietDigest}, [2]string{"Disable quiet hours", settingQuietOff})
+	}
+	if settings.Template != "" {
+		options = append(options, [2]string{"Reset template", settingTemplate})
+	}
+	options = append(options, [2]string{"Close", settingClose})
+
+	var keyboard [][]telebot.KeyboardButton
+	for _, o := range options {
+		b, err := button(o[0], o[1])
+		if err != nil {
+			return nil, err
+		}
+		keyboard = append(keyboard, []telebot.KeyboardButton{b})
+	}
+	return keyboard, nil
+}
+
+// toggleSetting changes the option of the settings, the chat-wide mode cycles through the modes
+func (b *Bot) toggleSetting(settings ChatSettings, setting string) (ChatSettings, error) {
+	switch setting {
+	case settingResolved:
+		// Cycles from the default to the opposite, the default set explicitly and back to the default
+		def := !b.suppressResolved
+		switch {
+		case b.noResolved:
+			// Resolved notifications disabled for all chats can't be turned on
+		case settings.NotifyResolved == nil:
+			notify := !def
+			settings.NotifyResolved = &notify
+		case *settings.NotifyResolved != def:
+			settings.NotifyResolved = &def
+		default:
+			settings.NotifyResolved = nil
+		}
+	case settingMode:
+		modes := make(map[string]string, len(settings.Modes)+1)
+		for severity, mode := range settings.Modes {
+			modes[severity] = mode
+		}
+		switch modes[""] {
+		case "":
+			modes[""] = "compact"
+		case "compact":
+			modes[""] = "verbose"
+		default:
+			delete(modes, "")
+		}
+		settings.Modes = modes
+	case settingQuietDigest:
+		settings.QuietDigest = !settings.QuietDigest
+	case settingQuietOff:
+		settings.QuietHours = nil
+		settings.QuietDigest = false
+	case settingTemplate:
+		settings.Template = ""
+		settings.TemplateFormat = ""
+	default:
+		return settings, fmt.Errorf("unknown setting %q", setting)
+	}
+	return settings, nil
+}
+
+func (b *Bot) handleSettings(message telebot.Message) {
+	// Right format: '/settings'.
+	// Ex: /settings
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't get the settings of this chat.", nil)
+		return
+	}
+
+	keyboard, err := settingsKeyboard(settings)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to create settings keyboard", "err", err)
+		return
+	}
+	_, err = b.reply(message, b.settingsSummary(settings, time.Now()), &telebot.SendOptions{
+		ReplyMarkup: b.callbacks.markup(keyboard),
+	})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send settings", "err", err)
+	}
+}
+
+// handleSettingCallback changes the setting selected in the /settings menu, only for those permitted to use /settings
+func (b *Bot) handleSettingCallback(callback telebot.Callback, cd CallbackData) {
+	chat := callback.Message.Chat
+	if !b.permission(telebot.Message{Sender: callback.Sender, Chat: chat})(commandSettings) {
+		b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{Text: "Only admins can change the settings."})
+		return
+	}
+	b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{})
+
+	settings, err := b.settings.Get(chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		return
+	}
+
+	var keyboard [][]telebot.KeyboardButton
+	if cd.Value != settingClose {
+		settings, err = b.toggleSetting(settings, cd.Value)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to change setting", "err", err)
+			return
+		}
+		if err := b.settings.Set(chat, settings); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+			return
+		}
+		level.Info(b.logger).Log(
+			"msg", "setting changed",
+			"chat_id", chat.ID,
+			"setting", cd.Value,
+			"sender_username", callback.Sender.Username,
+		)
+
+		keyboard, err = settingsKeyboard(settings)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to create settings keyboard", "err", err)
+			return
+		}
+	}
+
+	err = b.sender.EditMessageText(chat, callback.Message.ID, b.settingsSummary(settings, time.Now()), &telebot.SendOptions{
+		ReplyMarkup: b.callbacks.markup(keyboard),
+	})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to update settings", "err", err)
+	}
+}
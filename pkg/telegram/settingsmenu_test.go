@@ -0,0 +1,92 @@
+package telegram
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToggleSetting(t *testing.T) {
+	b := &Bot{}
+	var settings ChatSettings
+
+	var resolved []string
+	for i := 0; i < 3; i++ {
+		var err error
+		settings, err = b.toggleSetting(settings, settingResolved)
+		assert.NoError(t, err)
+		if settings.NotifyResolved == nil {
+			resolved = append(resolved, "default")
+		} else if *settings.NotifyResolved {
+			resolved = append(resolved, "on")
+		} else {
+			resolved = append(resolved, "off")
+		}
+	}
+	assert.Equal(t, []string{"off", "on", "default"}, resolved)
+
+	settings.Modes = map[string]string{"critical": "verbose"}
+	for _, want := range []string{"compact", "verbose", ""} {
+		settings, _ = b.toggleSetting(settings, settingMode)
+		assert.Equal(t, want, settings.Modes[""])
+	}
+	assert.Equal(t, "verbose", settings.Modes["critical"], "the modes of severities are kept")
+
+	settings.QuietHours = &QuietHours{Start: "22:00", End: "07:00"}
+	settings.QuietDigest = true
+	settings, _ = b.toggleSetting(settings, settingQuietOff)
+	assert.Nil(t, settings.QuietHours)
+	assert.False(t, settings.QuietDigest)
+
+	_, err := b.toggleSetting(settings, "language")
+	assert.Error(t, err)
+}
+
+func TestNoResolved(t *testing.T) {
+	b := &Bot{}
+	WithResolvedNotifications(false)(b)
+
+	on := true
+	assert.False(t, b.notifyResolved(ChatSettings{NotifyResolved: &on}), "chats can't turn them on")
+
+	settings, err := b.toggleSetting(ChatSettings{}, settingResolved)
+	assert.NoError(t, err)
+	assert.Nil(t, settings.NotifyResolved)
+	assert.Contains(t, b.settingsSummary(settings, time.Now()), "Resolved notifications: off (disabled for all chats)\n")
+}
+
+func TestSettingsSummary(t *testing.T) {
+	b := &Bot{}
+	now := time.Now()
+	settings := ChatSettings{
+		QuietHours:  &QuietHours{Start: "22:00", End: "07:00"},
+		QuietDigest: true,
+		Modes:       map[string]string{"critical": "verbose"},
+		Mutes:       map[string]time.Time{"HighCPU": now.Add(time.Hour), "DiskFull": now.Add(-time.Hour)},
+	}
+	assert.Equal(t, "Settings of this chat:\n"+
+		"Filter: all alerts\n"+
+		"Nodes: all\n"+
+		"Quiet hours: 22:00-07:00, then as digest\n"+
+		"Maintenance windows: 0\n"+
+		"Digest: off\n"+
+		"Template: default\n"+
+		"Mode: default, verbose for critical\n"+
+		"Resolved notifications: on (default)\n"+
+		"Muted alerts: 1\n"+
+		"Escalation: forward after 5m0s",
+		b.settingsSummary(settings, now),
+	)
+
+	keyboard, err := settingsKeyboard(settings)
+	assert.NoError(t, err)
+	assert.Len(t, keyboard, 5)
+	for _, row := range keyboard {
+		var cd CallbackData
+		assert.NoError(t, json.Unmarshal([]byte(row[0].Data), &cd))
+		assert.Equal(t, strSettingData, cd.Button)
+		assert.True(t, len(row[0].Data) <= 64)
+	}
+}
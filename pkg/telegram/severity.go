@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/tucnak/telebot"
+)
+
+// severities in order of priority, alerts with an unknown severity come last
+var severities = []string{"critical", "page", "error", "warning", "info", "none"}
+
+// severityEmojis are shown in the header of a message for the most severe alert
+var severityEmojis = map[string]string{
+	"critical": "🔴",
+	"page":     "🔴",
+	"error":    "🟠",
+	"warning":  "🟡",
+	"info":     "🔵",
+}
+
+const defaultSeverityEmoji = "⚪"
+
+func severityRank(a template.Alert) int {
+	severity := strings.ToLower(a.Labels["severity"])
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(severities)
+}
+
+// sortAlerts returns a copy of the alerts sorted by severity, firing before resolved and the oldest first
+func sortAlerts(alerts template.Alerts) template.Alerts {
+	sorted := make(template.Alerts, len(alerts))
+	copy(sorted, alerts)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ri, rj := severityRank(sorted[i]), severityRank(sorted[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if sorted[i].Status != sorted[j].Status {
+			return sorted[i].Status == "firing"
+		}
+		if !sorted[i].StartsAt.Equal(sorted[j].StartsAt) {
+			return sorted[i].StartsAt.Before(sorted[j].StartsAt)
+		}
+		// The order of the Alertmanager isn't stable, the message and its first alert must be
+		return alertLabelSet(sorted[i]).Fingerprint() < alertLabelSet(sorted[j]).Fingerprint()
+	})
+
+	return sorted
+}
+
+// alertsHeader summarizes the sorted alerts of a message with the emoji of the most severe one
+func alertsHeader(sorted template.Alerts, mode telebot.ParseMode) string {
+	emoji, ok := severityEmojis[strings.ToLower(sorted[0].Labels["severity"])]
+	if !ok {
+		emoji = defaultSeverityEmoji
+	}
+
+	if len(sorted) == 1 {
+		return emoji + "\n"
+	}
+	return fmt.Sprintf("%s %s\n", emoji, bold(fmt.Sprintf("%d alerts", len(sorted)), mode))
+}
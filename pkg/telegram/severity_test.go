@@ -0,0 +1,38 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestSortAlerts(t *testing.T) {
+	now := time.Now()
+	alerts := template.Alerts{
+		{Labels: template.KV{"alertname": "Unknown"}, Status: "firing", StartsAt: now},
+		{Labels: template.KV{"alertname": "DiskFull", "severity": "warning"}, Status: "firing", StartsAt: now},
+		{Labels: template.KV{"alertname": "NodeDown", "severity": "critical"}, Status: "resolved", StartsAt: now.Add(-2 * time.Hour)},
+		{Labels: template.KV{"alertname": "HighCPU", "severity": "critical"}, Status: "firing", StartsAt: now},
+		{Labels: template.KV{"alertname": "HighMemory", "severity": "critical"}, Status: "firing", StartsAt: now.Add(-time.Hour)},
+	}
+
+	sorted := sortAlerts(alerts)
+	var names []string
+	for _, a := range sorted {
+		names = append(names, a.Labels["alertname"])
+	}
+	assert.Equal(t, []string{"HighMemory", "HighCPU", "NodeDown", "DiskFull", "Unknown"}, names)
+	assert.Equal(t, "Unknown", alerts[0].Labels["alertname"], "input must not be modified")
+
+	assert.Equal(t, "🔴 <b>5 alerts</b>\n", alertsHeader(sorted, telebot.ModeHTML))
+	assert.Equal(t, "⚪\n", alertsHeader(sorted[4:], telebot.ModeHTML))
+	assert.Equal(t, "🔴 *5 alerts*\n", alertsHeader(sorted, telebot.ModeMarkdownV2))
+
+	// Alerts alike otherwise are sorted the same whatever order the Alertmanager sent them in
+	web := template.Alert{Labels: template.KV{"alertname": "HighCPU", "instance": "web01"}, Status: "firing", StartsAt: now}
+	db := template.Alert{Labels: template.KV{"alertname": "HighCPU", "instance": "db01"}, Status: "firing", StartsAt: now}
+	assert.Equal(t, sortAlerts(template.Alerts{web, db}), sortAlerts(template.Alerts{db, web}))
+}
@@ -0,0 +1,174 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	telegramShardsDirectory = "telegram/shards"
+
+	// handoverInterval in which a replica takes the callbacks handed over to it
+	handoverInterval = time.Second
+	// watchdogShardKey is the key of the shard that warns about the missing heartbeat of the watchdog
+	watchdogShardKey = "watchdog"
+)
+
+// BotShard is all the Bot needs to only deliver the alerts of the chats assigned to this replica
+type BotShard interface {
+	ID() string
+	Owner(key string) string
+}
+
+// BotHandoverStore is all the Bot needs to hand the callbacks over to the replica owning their chat
+type BotHandoverStore interface {
+	Put(owner string, c telebot.Callback) error
+	Take(owner string) ([]telebot.Callback, error)
+}
+
+// HandoverStore keeps the callbacks the replica polling Telegram received for the chats of other replicas
+type HandoverStore struct {
+	kv store.Store
+}
+
+// NewHandoverStore stores the callbacks handed over in the provided kv backend
+func NewHandoverStore(kv store.Store) (*HandoverStore, error) {
+	return &HandoverStore{kv: kv}, nil
+}
+
+// Put hands the callback over to the owner
+func (s *HandoverStore) Put(owner string, c telebot.Callback) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return s.kv.Put(fmt.Sprintf("%s/%s/%s", telegramShardsDirectory, owner, c.ID), b, nil)
+}
+
+// Take removes the callbacks handed over to the owner and returns them
+func (s *HandoverStore) Take(owner string) ([]telebot.Callback, error) {
+	kvPairs, err := s.kv.List(fmt.Sprintf("%s/%s", telegramShardsDirectory, owner))
+	if err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+
+	var callbacks []telebot.Callback
+	for _, kv := range kvPairs {
+		if err := s.kv.Delete(kv.Key); err != nil && err != store.ErrKeyNotFound {
+			return callbacks, err
+		}
+		var c telebot.Callback
+		if err := json.Unmarshal(kv.Value, &c); err != nil {
+			return callbacks, err
+		}
+		callbacks = append(callbacks, c)
+	}
+	return callbacks, nil
+}
+
+// WithShard only delivers and escalates the alerts of the chats the shard assigns to this replica.
+// The replica polling Telegram hands the callbacks of the alerts of other replicas' chats over to them.
+func WithShard(s BotShard, handover BotHandoverStore) BotOption {
+	return func(b *Bot) {
+		b.shard = s
+		b.handover = handover
+		// The confirmations of different replicas must not be mistaken for each other
+		h := fnv.New32a()
+		h.Write([]byte(s.ID()))
+		b.confirmations.tag = fmt.Sprintf("@%08x", h.Sum32())
+	}
+}
+
+// WithPolling only starts polling Telegram once the channel is closed, e.g. once elected leader
+func WithPolling(start <-chan struct{}) BotOption {
+	return func(b *Bot) {
+		b.pollStart = start
+	}
+}
+
+// owns returns whether the key belongs to the shard of this replica, always without shards
+func (b *Bot) owns(key string) bool {
+	return b.shard == nil || b.shard.Owner(key) == b.shard.ID()
+}
+
+// ownsChat returns whether the chat belongs to the shard of this replica
+func (b *Bot) ownsChat(id int64) bool {
+	return b.owns(strconv.FormatInt(id, 10))
+}
+
+// ownTargets returns the targets of the chats of this replica
+func (b *Bot) ownTargets(targets []*routedAlerts) []*routedAlerts {
+	if b.shard == nil {
+		return targets
+	}
+	owned := make([]*routedAlerts, 0, len(targets))
+	for _, t := range targets {
+		if b.ownsChat(t.chat.ID) {
+			owned = append(owned, t)
+		}
+	}
+	return owned
+}
+
+// handOver hands the callback over to the replica owning it and returns whether it did so.
+// The buttons of alerts and confirmations need the state of the replica that sent them.
+func (b *Bot) handOver(callback telebot.Callback) bool {
+	if b.shard == nil {
+		return false
+	}
+
+	cd, err := b.callbacks.resolve(callback.Data, time.Now())
+	if err != nil {
+		return false
+	}
+	switch cd.Button {
+	case strAcknowledgeData, strForwardData, strTicketData, strRemediateData:
+	case strConfirmData, strCancelData:
+		if strings.HasSuffix(cd.Confirmation, b.confirmations.tag) {
+			return false
+		}
+	default:
+		return false
+	}
+
+	owner := b.shard.Owner(strconv.FormatInt(callback.Message.Chat.ID, 10))
+	if owner == b.shard.ID() {
+		return false
+	}
+	if err := b.handover.Put(owner, callback); err != nil {
+		b.reportError("failed to hand callback over to its replica", "chat_id", callback.Message.Chat.ID, "replica", owner, "err", err)
+		return false
+	}
+	level.Debug(b.logger).Log("msg", "handed callback over", "chat_id", callback.Message.Chat.ID, "replica", owner)
+	return true
+}
+
+// takeHandovers handles the callbacks handed over to this replica until the context is canceled
+func (b *Bot) takeHandovers(ctx context.Context) error {
+	ticker := time.NewTicker(handoverInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			callbacks, err := b.handover.Take(b.shard.ID())
+			if err != nil {
+				level.Warn(b.logger).Log("msg", "failed to take callbacks handed over", "err", err)
+			}
+			for _, c := range callbacks {
+				b.handleCallback(c)
+			}
+		}
+	}
+}
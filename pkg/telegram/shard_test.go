@@ -0,0 +1,69 @@
+package telegram
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+// fakeShard assigns the chats by a fixed table, the rest to the replica itself
+type fakeShard struct {
+	id     string
+	owners map[string]string
+}
+
+func (s fakeShard) ID() string { return s.id }
+
+func (s fakeShard) Owner(key string) string {
+	if owner, ok := s.owners[key]; ok {
+		return owner
+	}
+	return s.id
+}
+
+func TestShard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shard")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	kv, err := boltdb.New([]string{filepath.Join(dir, "bot.db")}, &store.Config{Bucket: "alertmanager"})
+	assert.NoError(t, err)
+	defer kv.Close()
+	handover, err := NewHandoverStore(kv)
+	assert.NoError(t, err)
+
+	b := &Bot{logger: log.NewNopLogger(), confirmations: newConfirmations()}
+	WithShard(fakeShard{id: "bot-0", owners: map[string]string{"-200": "bot-1"}}, handover)(b)
+
+	// Only the alerts of the replica's own chats are delivered
+	targets := b.ownTargets([]*routedAlerts{{chat: telebot.Chat{ID: -100}}, {chat: telebot.Chat{ID: -200}}})
+	assert.Len(t, targets, 1)
+	assert.Equal(t, int64(-100), targets[0].chat.ID)
+
+	callback := func(id string, chat int64, cd CallbackData) telebot.Callback {
+		data, _ := json.Marshal(cd)
+		return telebot.Callback{ID: id, Data: string(data), Message: telebot.Message{Chat: telebot.Chat{ID: chat}}}
+	}
+
+	assert.False(t, b.handOver(callback("1", -100, CallbackData{Button: strAcknowledgeData})), "own chats' buttons are handled")
+	assert.True(t, b.handOver(callback("2", -200, CallbackData{Button: strAcknowledgeData})), "other chats' buttons are handed over")
+	assert.False(t, b.handOver(callback("3", -200, CallbackData{Button: strConfirmData, Confirmation: "1" + b.confirmations.tag})), "own confirmations are handled")
+	assert.True(t, b.handOver(callback("4", -200, CallbackData{Button: strCancelData, Confirmation: "1@00000000"})), "other replicas' confirmations are handed over")
+
+	taken, err := handover.Take("bot-1")
+	assert.NoError(t, err)
+	assert.Len(t, taken, 2)
+	assert.Equal(t, int64(-200), taken[0].Message.Chat.ID)
+
+	taken, err = handover.Take("bot-1")
+	assert.NoError(t, err)
+	assert.Empty(t, taken, "taken callbacks are removed")
+}
@@ -0,0 +1,97 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/statuspage"
+)
+
+// statusPageBuffer is the most events waiting to update the status page, more are dropped
+const statusPageBuffer = 100
+
+// statusIncidentKey identifies the incident of an alert in a chat
+func statusIncidentKey(e Event) string {
+	return fmt.Sprintf("%d/%s", e.ChatID, e.AlertID)
+}
+
+// statusIncidentBody describes the alert by its labels without revealing who works on it
+func statusIncidentBody(e Event) string {
+	var labels []string
+	for name, value := range e.Labels {
+		if name == "alertname" {
+			continue
+		}
+		labels = append(labels, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(labels)
+
+	body := fmt.Sprintf("We are investigating %s.", e.AlertID)
+	if len(labels) > 0 {
+		body += " Affected: " + strings.Join(labels, ", ")
+	}
+	return body
+}
+
+// queueStatusPage queues the acknowledged alerts of the severities and the resolved alerts to update the status page
+func (b *Bot) queueStatusPage(e Event) {
+	if e.Type != eventAcknowledged && e.Type != eventResolved {
+		return
+	}
+	if e.Type == eventAcknowledged && !b.statusPageSeverities[e.Labels["severity"]] {
+		return
+	}
+
+	select {
+	case b.statusPageEvents <- e:
+	default:
+		level.Warn(b.logger).Log("msg", "dropped event of the status page", "chat_id", e.ChatID, "alert_id", e.AlertID)
+	}
+}
+
+// updateStatusPage opens an incident for acknowledged alerts, posts its public link to the chat
+// and resolves it with the alert, until the context is done
+func (b *Bot) updateStatusPage(ctx context.Context) error {
+	// incidents are the IDs of the open incidents by statusIncidentKey
+	incidents := make(map[string]string)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-b.statusPageEvents:
+			key := statusIncidentKey(e)
+
+			switch e.Type {
+			case eventAcknowledged:
+				if _, ok := incidents[key]; ok {
+					continue
+				}
+				incident, err := b.statusPage.Create(e.AlertID, statusIncidentBody(e))
+				if err != nil {
+					level.Warn(b.logger).Log("msg", "failed to open status page incident", "chat_id", e.ChatID, "alert_id", e.AlertID, "err", err)
+					continue
+				}
+				incidents[key] = incident.ID
+
+				text := fmt.Sprintf("Opened the status page incident of %s: %s", e.AlertID, incident.Shortlink)
+				if _, err := b.sender.SendMessage(telebot.Chat{ID: e.ChatID}, text, nil); err != nil {
+					level.Warn(b.logger).Log("msg", "failed to send status page link", "chat_id", e.ChatID, "err", err)
+				}
+			case eventResolved:
+				id, ok := incidents[key]
+				if !ok {
+					continue
+				}
+				delete(incidents, key)
+				if _, err := b.statusPage.Update(id, statuspage.StatusResolved, fmt.Sprintf("%s is resolved.", e.AlertID)); err != nil {
+					level.Warn(b.logger).Log("msg", "failed to resolve status page incident", "chat_id", e.ChatID, "alert_id", e.AlertID, "err", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package telegram
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/vu-long/alertmanager-bot/pkg/statuspage"
+)
+
+func TestStatusIncidentBody(t *testing.T) {
+	e := Event{AlertID: "HighCPU", Labels: map[string]string{"alertname": "HighCPU", "instance": "web-1", "job": "node"}}
+	assert.Equal(t, "We are investigating HighCPU. Affected: instance=web-1, job=node", statusIncidentBody(e))
+	assert.Equal(t, "We are investigating HighCPU.", statusIncidentBody(Event{AlertID: "HighCPU"}))
+}
+
+func TestUpdateStatusPage(t *testing.T) {
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{"id":"inc1","shortlink":"https://stspg.io/abc"}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	s := newFakeSender()
+	b := &Bot{logger: log.NewNopLogger(), sender: s}
+	WithStatusPage(&statuspage.Client{URL: u, PageID: "p1"}, "critical")(b)
+	b.statusPageEvents = make(chan Event, statusPageBuffer)
+
+	b.queueStatusPage(Event{Type: eventAcknowledged, ChatID: -100, AlertID: "DiskFull", Labels: map[string]string{"severity": "warning"}})
+	b.queueStatusPage(Event{Type: eventFired, ChatID: -100, AlertID: "HighCPU", Labels: map[string]string{"severity": "critical"}})
+	b.queueStatusPage(Event{Type: eventAcknowledged, ChatID: -100, AlertID: "HighCPU", Labels: map[string]string{"severity": "critical"}})
+	b.queueStatusPage(Event{Type: eventResolved, ChatID: -100, AlertID: "DiskFull"})
+	b.queueStatusPage(Event{Type: eventResolved, ChatID: -100, AlertID: "HighCPU"})
+	assert.Len(t, b.statusPageEvents, 3, "only acknowledged alerts of the severities and resolved alerts are queued")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.updateStatusPage(ctx)
+		close(done)
+	}()
+	for i := 0; i < 100 && len(b.statusPageEvents) > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The last event is taken off the queue before it is handled
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, []string{"POST /v1/pages/p1/incidents", "PATCH /v1/pages/p1/incidents/inc1"}, methods)
+	assert.Equal(t, []string{"Opened the status page incident of HighCPU: https://stspg.io/abc"}, s.sent)
+}
@@ -0,0 +1,127 @@
+package telegram
+
+import (
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	storeResultSuccess = "success"
+	storeResultError   = "error"
+)
+
+// instrumentedStore wraps a libkv backend and records the number, outcome
+// and latency of every operation going through it.
+type instrumentedStore struct {
+	store.Store
+
+	operations *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+}
+
+// NewInstrumentedStore wraps the kv backend so that all operations of the
+// chat, member and node stores are exported as Prometheus metrics.
+func NewInstrumentedStore(kv store.Store) (store.Store, error) {
+	operations := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "alertmanagerbot",
+		Name:      "store_operations_total",
+		Help:      "Number of store operations by operation and result",
+	}, []string{"operation", "result"})
+	if err := prometheus.Register(operations); err != nil {
+		return nil, err
+	}
+
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "alertmanagerbot",
+		Name:      "store_operation_duration_seconds",
+		Help:      "Latency of store operations by operation",
+		Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation"})
+	if err := prometheus.Register(duration); err != nil {
+		return nil, err
+	}
+
+	return &instrumentedStore{
+		Store:      kv,
+		operations: operations,
+		duration:   duration,
+	}, nil
+}
+
+// observe records a finished operation. A missing key is an expected
+// outcome for lookups and doesn't count as an error.
+func (s *instrumentedStore) observe(operation string, start time.Time, err error) {
+	result := storeResultSuccess
+	if err != nil && err != store.ErrKeyNotFound {
+		result = storeResultError
+	}
+
+	s.operations.WithLabelValues(operation, result).Inc()
+	s.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// Put a value at the specified key
+func (s *instrumentedStore) Put(key string, value []byte, options *store.WriteOptions) error {
+	start := time.Now()
+	err := s.Store.Put(key, value, options)
+	s.observe("put", start, err)
+	return err
+}
+
+// Get a value given its key
+func (s *instrumentedStore) Get(key string) (*store.KVPair, error) {
+	start := time.Now()
+	pair, err := s.Store.Get(key)
+	s.observe("get", start, err)
+	return pair, err
+}
+
+// Delete the value at the specified key
+func (s *instrumentedStore) Delete(key string) error {
+	start := time.Now()
+	err := s.Store.Delete(key)
+	s.observe("delete", start, err)
+	return err
+}
+
+// Exists verifies if a key exists in the store
+func (s *instrumentedStore) Exists(key string) (bool, error) {
+	start := time.Now()
+	ok, err := s.Store.Exists(key)
+	s.observe("exists", start, err)
+	return ok, err
+}
+
+// List the content of a given prefix
+func (s *instrumentedStore) List(directory string) ([]*store.KVPair, error) {
+	start := time.Now()
+	pairs, err := s.Store.List(directory)
+	s.observe("list", start, err)
+	return pairs, err
+}
+
+// DeleteTree deletes a range of keys under a given directory
+func (s *instrumentedStore) DeleteTree(directory string) error {
+	start := time.Now()
+	err := s.Store.DeleteTree(directory)
+	s.observe("delete_tree", start, err)
+	return err
+}
+
+// AtomicPut puts a value at the key if it hasn't been modified since previous
+func (s *instrumentedStore) AtomicPut(key string, value []byte, previous *store.KVPair, options *store.WriteOptions) (bool, *store.KVPair, error) {
+	start := time.Now()
+	ok, pair, err := s.Store.AtomicPut(key, value, previous, options)
+	s.observe("atomic_put", start, err)
+	return ok, pair, err
+}
+
+// AtomicDelete deletes the key if it hasn't been modified since previous
+func (s *instrumentedStore) AtomicDelete(key string, previous *store.KVPair) (bool, error) {
+	start := time.Now()
+	ok, err := s.Store.AtomicDelete(key, previous)
+	s.observe("atomic_delete", start, err)
+	return ok, err
+}
@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandSubscribe = "/subscribe"
+
+	subscribeAll = "all"
+)
+
+// alertNode returns the node an alert belongs to, taken from the node label
+// or the host of the instance label
+func alertNode(a template.Alert) string {
+	if node := a.Labels["node"]; node != "" {
+		return node
+	}
+
+	instance := a.Labels["instance"]
+	if host, _, err := net.SplitHostPort(instance); err == nil {
+		return host
+	}
+	return instance
+}
+
+// filterNodes returns the alerts of the given nodes, all alerts if no nodes are given
+func filterNodes(nodes []string, alerts template.Alerts) template.Alerts {
+	if len(nodes) == 0 {
+		return alerts
+	}
+
+	subscribed := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		subscribed[n] = true
+	}
+
+	var filtered template.Alerts
+	for _, a := range alerts {
+		if subscribed[alertNode(a)] {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered
+}
+
+// parseNodes parses node=name params into the node names
+func parseNodes(params []string) ([]string, error) {
+	nodes := make([]string, 0, len(params))
+	for _, p := range params {
+		name := strings.TrimPrefix(p, "node=")
+		if name == p || name == "" {
+			return nil, fmt.Errorf("invalid node %q", p)
+		}
+		nodes = append(nodes, name)
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+func (b *Bot) handleSubscribe(message telebot.Message) {
+	// Right format: '/subscribe node=name...' or '/subscribe all'.
+	// Ex: /subscribe node=web01 node=web02
+	params := strings.Fields(message.Text)[1:]
+
+	settings, err := b.settings.Get(message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.reply(message, "I can't get the subscriptions of this chat.", nil)
+		return
+	}
+
+	if len(params) == 0 {
+		if len(settings.Nodes) == 0 {
+			b.reply(message, "This chat receives the alerts of all nodes.", nil)
+			return
+		}
+		b.reply(message, "This chat only receives the alerts of these nodes:\n"+strings.Join(settings.Nodes, "\n"), nil)
+		return
+	}
+
+	if len(params) == 1 && params[0] == subscribeAll {
+		settings.Nodes = nil
+	} else {
+		nodes, err := parseNodes(params)
+		if err != nil {
+			b.reply(message, "Please send right format: '/subscribe node=name...' or '/subscribe all'. Ex: /subscribe node=web01 node=web02", nil)
+			return
+		}
+
+		known, err := b.nodes.List()
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to list nodes from nodes store", "err", err)
+			b.reply(message, "I can't list the added nodes.", nil)
+			return
+		}
+		added := make(map[string]bool, len(known))
+		for _, n := range known {
+			added[n.Name] = true
+		}
+		for _, n := range nodes {
+			if !added[n] {
+				b.reply(message, fmt.Sprintf("Node %s isn't added, see %s.", n, commandNodes), nil)
+				return
+			}
+		}
+
+		settings.Nodes = nodes
+	}
+
+	if err := b.chats.Add(message.Chat); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to add chat to chat store", "err", err)
+		b.reply(message, "I can't add this chat to the subscribers list.", nil)
+		return
+	}
+
+	if err := b.settings.Set(message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.reply(message, "I can't save the subscriptions of this chat.", nil)
+		return
+	}
+
+	b.reply(message, responseMember, nil)
+	level.Info(b.logger).Log(
+		"msg", "chat subscribed to nodes",
+		"chat_id", message.Chat.ID,
+		"nodes", strings.Join(settings.Nodes, ","),
+	)
+}
@@ -0,0 +1,34 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterNodes(t *testing.T) {
+	alerts := template.Alerts{
+		{Labels: template.KV{"alertname": "HighCPU", "instance": "web01:9100"}},
+		{Labels: template.KV{"alertname": "DiskFull", "instance": "db01"}},
+		{Labels: template.KV{"alertname": "PodCrash", "node": "web02", "instance": "10.0.0.1:8080"}},
+	}
+
+	assert.Equal(t, alerts, filterNodes(nil, alerts))
+
+	filtered := filterNodes([]string{"web01", "web02"}, alerts)
+	assert.Len(t, filtered, 2)
+	assert.Equal(t, "HighCPU", filtered[0].Labels["alertname"])
+	assert.Equal(t, "PodCrash", filtered[1].Labels["alertname"])
+}
+
+func TestParseNodes(t *testing.T) {
+	nodes, err := parseNodes([]string{"node=web02", "node=web01"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web01", "web02"}, nodes)
+
+	_, err = parseNodes([]string{"web01"})
+	assert.Error(t, err)
+	_, err = parseNodes([]string{"node="})
+	assert.Error(t, err)
+}
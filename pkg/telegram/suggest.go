@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/vu-long/alertmanager-bot/pkg/internal/levenshtein"
+)
+
+const (
+	// maxSuggestionDistance is the most edits an unknown command may be away from a suggested one
+	maxSuggestionDistance = 2
+	// maxUnknownCommands are counted, other unknown commands aren't once that many were seen
+	maxUnknownCommands = 1000
+)
+
+// suggestCommand returns the permitted command closest to the unknown text, ok is false if none is close enough
+func suggestCommand(text string, commands []botCommand, permitted func(command string) bool) (suggestion string, ok bool) {
+	text = strings.ToLower(text)
+	best := maxSuggestionDistance + 1
+	for _, c := range commands {
+		if !permitted(c.name) {
+			continue
+		}
+		if d := levenshtein.Distance(text, c.name); d < best {
+			best, suggestion = d, c.name
+		}
+	}
+	return suggestion, suggestion != ""
+}
+
+// unknownCommands counts how often each unknown command was sent
+type unknownCommands struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newUnknownCommands() *unknownCommands {
+	return &unknownCommands{counts: make(map[string]int)}
+}
+
+// Inc counts the unknown command and returns how often it was sent, 0 if it isn't counted
+func (u *unknownCommands) Inc(text string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, ok := u.counts[text]; !ok && len(u.counts) >= maxUnknownCommands {
+		return 0
+	}
+	u.counts[text]++
+	return u.counts[text]
+}
@@ -0,0 +1,34 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestCommand(t *testing.T) {
+	commands := []botCommand{{name: commandAlerts}, {name: commandSilences}, {name: commandStatus}, {name: commandAudit}}
+	all := func(string) bool { return true }
+
+	suggestion, ok := suggestCommand("/Silence", commands, all)
+	assert.True(t, ok)
+	assert.Equal(t, commandSilences, suggestion)
+
+	_, ok = suggestCommand("/deploy", commands, all)
+	assert.False(t, ok, "nothing is close enough")
+
+	_, ok = suggestCommand("/audits", commands, func(command string) bool { return command != commandAudit })
+	assert.False(t, ok, "forbidden commands aren't suggested")
+}
+
+func TestUnknownCommands(t *testing.T) {
+	u := newUnknownCommands()
+	assert.Equal(t, 1, u.Inc("/deploy"))
+	assert.Equal(t, 2, u.Inc("/deploy"))
+
+	for i := 0; len(u.counts) < maxUnknownCommands; i++ {
+		u.Inc(string(rune('a' + i)))
+	}
+	assert.Equal(t, 0, u.Inc("/rollback"), "new commands aren't counted once full")
+	assert.Equal(t, 3, u.Inc("/deploy"))
+}
@@ -0,0 +1,110 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/prometheus"
+)
+
+const commandTargets = "/targets"
+
+// jobTargets are the health of the targets of a scrape job
+type jobTargets struct {
+	Job  string
+	Up   int
+	Down []prometheus.Target
+	// Total targets, including those of unknown health not scraped yet
+	Total int
+}
+
+// summarizeTargets groups the targets by job, jobs with down targets first
+func summarizeTargets(targets []prometheus.Target) []jobTargets {
+	byJob := make(map[string]*jobTargets)
+	for _, t := range targets {
+		j, ok := byJob[t.Job()]
+		if !ok {
+			j = &jobTargets{Job: t.Job()}
+			byJob[t.Job()] = j
+		}
+		j.Total++
+		switch t.Health {
+		case prometheus.HealthUp:
+			j.Up++
+		case prometheus.HealthDown:
+			j.Down = append(j.Down, t)
+		}
+	}
+
+	var jobs []jobTargets
+	for _, j := range byJob {
+		sort.Slice(j.Down, func(a, b int) bool { return j.Down[a].Instance() < j.Down[b].Instance() })
+		jobs = append(jobs, *j)
+	}
+	sort.Slice(jobs, func(a, b int) bool {
+		if (len(jobs[a].Down) > 0) != (len(jobs[b].Down) > 0) {
+			return len(jobs[a].Down) > 0
+		}
+		return jobs[a].Job < jobs[b].Job
+	})
+	return jobs
+}
+
+// targetsMessage lists the jobs with their share of up targets and the down targets with their last error
+func targetsMessage(jobs []jobTargets) string {
+	var b strings.Builder
+	b.WriteString("Scrape targets:\n")
+	for _, j := range jobs {
+		icon := "✅"
+		if len(j.Down) > 0 {
+			icon = "🔥"
+		}
+		fmt.Fprintf(&b, "%s %s: %d/%d up\n", icon, j.Job, j.Up, j.Total)
+		for _, t := range j.Down {
+			fmt.Fprintf(&b, "    ❌ %s", t.Instance())
+			if t.LastError != "" {
+				fmt.Fprintf(&b, ": %s", t.LastError)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func (b *Bot) handleTargets(message telebot.Message) {
+	// Right format: '/targets [job]'.
+	// Ex: /targets nginx
+	params := strings.Fields(message.Text)[1:]
+	if len(params) > 1 {
+		b.reply(message, "Please send right format: '/targets [job]'. Ex: /targets nginx", nil)
+		return
+	}
+	if b.prometheus == nil {
+		b.reply(message, "No Prometheus is configured to query.", nil)
+		return
+	}
+
+	targets, err := prometheus.ListTargets(b.prometheus.String())
+	if err != nil {
+		b.reply(message, fmt.Sprintf("failed to list targets... %v", err), nil)
+		return
+	}
+	if len(params) == 1 {
+		var matching []prometheus.Target
+		for _, t := range targets {
+			if t.Job() == params[0] {
+				matching = append(matching, t)
+			}
+		}
+		targets = matching
+	}
+	if len(targets) == 0 {
+		b.reply(message, "No scrape targets found.", nil)
+		return
+	}
+
+	lines := strings.Split(targetsMessage(summarizeTargets(targets)), "\n")
+	b.sendPages(message, paginate(lines[0]+"\n", lines[1:], itemsPerPage), "")
+}
@@ -0,0 +1,32 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/vu-long/alertmanager-bot/pkg/prometheus"
+)
+
+func TestTargetsMessage(t *testing.T) {
+	target := func(job, instance, health, lastError string) prometheus.Target {
+		return prometheus.Target{
+			Labels:    model.LabelSet{model.JobLabel: model.LabelValue(job), model.InstanceLabel: model.LabelValue(instance)},
+			Health:    health,
+			LastError: lastError,
+		}
+	}
+
+	jobs := summarizeTargets([]prometheus.Target{
+		target("node", "db-1:9100", prometheus.HealthUp, ""),
+		target("nginx", "web-2:9113", prometheus.HealthDown, "connection refused"),
+		target("nginx", "web-1:9113", prometheus.HealthUp, ""),
+		target("blackbox", "probe:9115", prometheus.HealthUnknown, ""),
+	})
+	assert.Equal(t, []string{"nginx", "blackbox", "node"}, []string{jobs[0].Job, jobs[1].Job, jobs[2].Job}, "jobs with down targets first")
+
+	assert.Equal(t,
+		"Scrape targets:\n🔥 nginx: 1/2 up\n    ❌ web-2:9113: connection refused\n✅ blackbox: 0/1 up\n✅ node: 1/1 up",
+		targetsMessage(jobs),
+	)
+}
@@ -0,0 +1,240 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	commandTeam = "/team"
+
+	telegramTeamsDirectory = "telegram/teams"
+)
+
+// teamName is the format of the names of teams, used in their store key and in routes
+var teamName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Team groups members, the chat they are alerted in, the nodes they own and their escalation policy,
+// so that routes can reference a team instead of repeating its chat.
+type Team struct {
+	Name    string       `json:"name"`
+	Members []string     `json:"members,omitempty"`
+	Chat    telebot.Chat `json:"chat"`
+	Nodes   []string     `json:"nodes,omitempty"`
+	// Escalation is the name of an escalation policy of the routing configuration
+	Escalation string `json:"escalation,omitempty"`
+}
+
+// TeamStore writes the teams to a libkv store backend
+type TeamStore struct {
+	kv store.Store
+}
+
+// NewTeamStore stores teams in the provided kv backend
+func NewTeamStore(kv store.Store) (*TeamStore, error) {
+	return &TeamStore{kv: kv}, nil
+}
+
+// List all teams saved in the kv backend
+func (s *TeamStore) List() ([]Team, error) {
+	kvPairs, err := s.kv.List(telegramTeamsDirectory)
+	if err != nil && err != store.ErrKeyNotFound {
+		return nil, err
+	}
+
+	var teams []Team
+	for _, kv := range kvPairs {
+		var t Team
+		if err := json.Unmarshal(kv.Value, &t); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+
+	return teams, nil
+}
+
+// Add a team to the kv backend, replacing a team with the same name
+func (s *TeamStore) Add(t Team) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s", telegramTeamsDirectory, t.Name)
+
+	return s.kv.Put(key, b, nil)
+}
+
+// Remove a team from the kv backend
+func (s *TeamStore) Remove(t Team) error {
+	key := fmt.Sprintf("%s/%s", telegramTeamsDirectory, t.Name)
+	return s.kv.Delete(key)
+}
+
+// parseTeam parses 'name [members=a,b] [nodes=x,y] [escalation=policy]' into a team
+func parseTeam(params []string) (Team, error) {
+	if len(params) == 0 {
+		return Team{}, fmt.Errorf("missing team name")
+	}
+	if !teamName.MatchString(params[0]) {
+		return Team{}, fmt.Errorf("the names of teams may only have lowercase letters, digits, - and _")
+	}
+
+	t := Team{Name: params[0]}
+	for _, p := range params[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return Team{}, fmt.Errorf("invalid parameter %q", p)
+		}
+
+		switch kv[0] {
+		case "members":
+			t.Members = strings.Split(kv[1], ",")
+			sort.Strings(t.Members)
+		case "nodes":
+			t.Nodes = strings.Split(kv[1], ",")
+			sort.Strings(t.Nodes)
+		case "escalation":
+			t.Escalation = kv[1]
+		default:
+			return Team{}, fmt.Errorf("unknown parameter %q", kv[0])
+		}
+	}
+
+	return t, nil
+}
+
+func (b *Bot) handleTeam(message telebot.Message) {
+	// Right format: '/team', '/team set name [members=a,b] [nodes=x,y] [escalation=policy]' or '/team rm name'.
+	// Ex: /team set db members=vu_long,alice nodes=db01,db02 escalation=fast
+	params := strings.Fields(message.Text)[1:]
+
+	if b.teams == nil {
+		b.reply(message, "Teams are not enabled.", nil)
+		return
+	}
+
+	if len(params) == 0 {
+		b.listTeams(message)
+		return
+	}
+
+	switch {
+	case params[0] == "set" && len(params) >= 2:
+		b.setTeam(message, params[1:])
+	case params[0] == "rm" && len(params) == 2:
+		if err := b.teams.Remove(Team{Name: params[1]}); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to remove team from team store", "err", err)
+			b.reply(message, "I can't remove this team.", nil)
+			return
+		}
+		b.reply(message, responseMember, nil)
+	default:
+		b.reply(message, "Please send right format: '/team set name [members=a,b] [nodes=x,y] [escalation=policy]' or '/team rm name'. Ex: /team set db members=vu_long,alice nodes=db01 escalation=fast", nil)
+	}
+}
+
+func (b *Bot) listTeams(message telebot.Message) {
+	teams, err := b.teams.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to list teams from team store", "err", err)
+		b.reply(message, "I can't list the teams.", nil)
+		return
+	}
+
+	if len(teams) == 0 {
+		b.reply(message, "There are no teams yet.", nil)
+		return
+	}
+
+	list := ""
+	for _, t := range teams {
+		list = list + fmt.Sprintf("%s chat: %d members: %s nodes: %s escalation: %s\n",
+			t.Name, t.Chat.ID, strings.Join(t.Members, ","), strings.Join(t.Nodes, ","), t.Escalation)
+	}
+
+	b.reply(message, "Currently these teams exist:\n"+list, nil)
+}
+
+// setTeam saves a team for the current chat and moves its members to that chat,
+// so that they are escalated to for the team's alerts. Moving members away from another chat needs a confirmation.
+func (b *Bot) setTeam(message telebot.Message, params []string) {
+	team, err := parseTeam(params)
+	if err != nil {
+		b.reply(message, fmt.Sprintf("Please send a valid team. %v", err), nil)
+		return
+	}
+	team.Chat = message.Chat
+
+	if b.router != nil && team.Escalation != "" && !b.router.HasEscalationPolicy(team.Escalation) {
+		b.reply(message, fmt.Sprintf("Escalation policy %s doesn't exist.", team.Escalation), nil)
+		return
+	}
+
+	members, err := b.members.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to list members from member store", "err", err)
+		b.reply(message, "I can't list the members.", nil)
+		return
+	}
+	byName := make(map[string]Member, len(members))
+	for _, m := range members {
+		byName[m.Username] = m
+	}
+	var elsewhere []string
+	for _, name := range team.Members {
+		m, ok := byName[name]
+		if !ok {
+			b.reply(message, fmt.Sprintf("Member %s isn't added, see %s.", name, commandMembers), nil)
+			return
+		}
+		if m.Chat.ID != team.Chat.ID {
+			elsewhere = append(elsewhere, "@"+name)
+		}
+	}
+
+	if len(elsewhere) == 0 {
+		b.saveTeam(message, team, byName)
+		return
+	}
+	b.confirm(message, fmt.Sprintf("%s are alerted in another chat. Do you really want to move them to this chat for the team %s?",
+		strings.Join(elsewhere, ", "), team.Name), func() {
+		b.saveTeam(message, team, byName)
+	})
+}
+
+// saveTeam saves the team and moves its members and their nodes to the team's chat
+func (b *Bot) saveTeam(message telebot.Message, team Team, byName map[string]Member) {
+	if err := b.teams.Add(team); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to add team to team store", "err", err)
+		b.reply(message, "I can't save this team.", nil)
+		return
+	}
+
+	moved := make([]Member, 0, len(team.Members))
+	for _, name := range team.Members {
+		m := byName[name]
+		m.Chat = team.Chat
+		moved = append(moved, m)
+	}
+	if err := b.members.AddAll(moved); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to move members to team chat", "team", team.Name, "err", err)
+	} else if err := b.moveNodes(moved); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to move nodes of members to team chat", "team", team.Name, "err", err)
+	}
+
+	b.reply(message, responseMember, nil)
+	level.Info(b.logger).Log(
+		"msg", "team saved",
+		"team", team.Name,
+		"chat_id", team.Chat.ID,
+	)
+}
@@ -0,0 +1,50 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+type fakeTeamStore struct{ teams []Team }
+
+func (s *fakeTeamStore) List() ([]Team, error) { return s.teams, nil }
+func (s *fakeTeamStore) Add(t Team) error      { s.teams = append(s.teams, t); return nil }
+func (s *fakeTeamStore) Remove(Team) error     { return nil }
+
+func TestParseTeam(t *testing.T) {
+	team, err := parseTeam([]string{"db", "members=vu_long,alice", "nodes=db01", "escalation=fast"})
+	assert.NoError(t, err)
+	assert.Equal(t, Team{Name: "db", Members: []string{"alice", "vu_long"}, Nodes: []string{"db01"}, Escalation: "fast"}, team)
+
+	for _, invalid := range []string{"DB", "db/web", "../db", "db team"} {
+		_, err := parseTeam([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSetTeam(t *testing.T) {
+	db := telebot.Chat{ID: -100}
+	web := telebot.Chat{ID: -200}
+	s := newFakeSender()
+	teams := &fakeTeamStore{}
+	b := &Bot{
+		logger:        log.NewNopLogger(),
+		sender:        s,
+		confirmations: newConfirmations(),
+		teams:         teams,
+		members:       fakeMemberStore{{Username: "alice", Chat: db}, {Username: "bob", Chat: web}},
+		nodes:         fakeNodeStore{},
+	}
+	message := telebot.Message{Sender: telebot.User{ID: 1}, Chat: db}
+
+	b.setTeam(message, []string{"db", "members=alice"})
+	assert.Len(t, teams.teams, 1, "members of the chat are set right away")
+
+	b.setTeam(message, []string{"db", "members=alice,bob"})
+	assert.Len(t, teams.teams, 1, "members of another chat need a confirmation")
+	assert.Equal(t, "@bob are alerted in another chat. Do you really want to move them to this chat for the team db?", s.sent[len(s.sent)-1])
+	assert.Len(t, b.confirmations.pending, 1)
+}
@@ -0,0 +1,378 @@
+package telegram
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	tmplhtml "html/template"
+	"io"
+	"math"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	tmpltext "text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hako/durafmt"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/tucnak/telebot"
+)
+
+// DefaultTemplate is built into the bot, so it works without any template files.
+// Template files can override the templates it defines: telegram.default renders
+// a single alert with telegram.firing or telegram.resolved, the escalation messages
+// are rendered with telegram.assign, telegram.acknowledge, telegram.forward and telegram.autoforward.
+const DefaultTemplate = `{{ define "telegram.default" }}
+{{ range .Alerts }}
+{{ if eq .Status "firing"}}{{ template "telegram.firing" . }}{{ else }}{{ template "telegram.resolved" . }}{{ end }}
+{{ end }}
+{{ end }}
+
+{{ define "telegram.firing" }}🔥 <b>{{ .Status | toUpper }}</b> 🔥
+<b>{{ .Labels.alertname }}</b>
+{{ .Annotations.message }}
+<b>Started:</b> {{ .StartsAt | since }}{{ end }}
+
+{{ define "telegram.resolved" }}<b>{{ .Status | toUpper }}</b>
+<b>{{ .Labels.alertname }}</b>
+{{ .Annotations.message }}
+<b>Duration:</b> {{ duration .StartsAt .EndsAt }}
+<b>Ended:</b> {{ .EndsAt | since }}{{ end }}
+
+{{ define "telegram.compact" }}
+{{ range .Alerts }}{{ if eq .Status "firing"}}🔥{{ else }}✅{{ end }} <b>{{ .Labels.alertname }}</b> {{ .Annotations.message }}
+{{ end }}
+{{ end }}
+
+{{ define "telegram.verbose" }}
+{{ range .Alerts }}
+{{ if eq .Status "firing"}}{{ template "telegram.firing" . }}{{ else }}{{ template "telegram.resolved" . }}{{ end }}
+<b>Labels:</b>
+{{ range .Labels.SortedPairs }}  {{ .Name }} = {{ .Value }}
+{{ end }}<b>Annotations:</b>
+{{ range .Annotations.SortedPairs }}  {{ .Name }} = {{ .Value }}
+{{ end }}{{ end }}
+{{ end }}
+
+{{ define "telegram.assign" }}@{{ .To }}{{ end }}
+{{ define "telegram.acknowledge" }}Acknowledge by: @{{ .From }}{{ end }}
+{{ define "telegram.forward" }}@{{ .From }} forward to @{{ .To }}{{ end }}
+{{ define "telegram.autoforward" }}Auto forward to next level @{{ .To }}{{ end }}
+`
+
+// Output formats a template can declare by defining a template named like it with a .format suffix,
+// e.g. {{ define "telegram.compact.format" }}markdown{{ end }}. Templates without one render HTML.
+const (
+	formatHTML       = "html"
+	formatMarkdown   = "markdown"
+	formatMarkdownV2 = "markdownv2"
+	formatPlain      = "plain"
+)
+
+// formatParseModes are the Telegram parse modes of the output formats
+var formatParseModes = map[string]telebot.ParseMode{
+	formatHTML:       telebot.ModeHTML,
+	formatMarkdown:   telebot.ModeMarkdown,
+	formatMarkdownV2: telebot.ModeMarkdownV2,
+	formatPlain:      telebot.ModeDefault,
+}
+
+// parseFormat returns the parse mode of an output format, the empty format is HTML
+func parseFormat(format string) (telebot.ParseMode, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		return telebot.ModeHTML, nil
+	}
+	mode, ok := formatParseModes[format]
+	if !ok {
+		return "", fmt.Errorf("unknown format %q, expected html, markdown, markdownv2 or plain", format)
+	}
+	return mode, nil
+}
+
+// executeTemplate renders the template text for the parse mode, only HTML output escapes HTML
+func executeTemplate(t *Templates, text string, mode telebot.ParseMode, data interface{}) (string, error) {
+	return t.execute(text, mode == telebot.ModeHTML, data)
+}
+
+// markdownV2Escaper escapes the characters MarkdownV2 reserves
+var markdownV2Escaper = strings.NewReplacer(
+	"_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)", "~", "\\~", "`", "\\`",
+	">", "\\>", "#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=", "|", "\\|", "{", "\\{", "}", "\\}",
+	".", "\\.", "!", "\\!",
+)
+
+// bold formats the text as bold for the parse mode
+func bold(text string, mode telebot.ParseMode) string {
+	switch mode {
+	case telebot.ModeHTML:
+		return "<b>" + html.EscapeString(text) + "</b>"
+	case telebot.ModeMarkdown:
+		return "*" + text + "*"
+	case telebot.ModeMarkdownV2:
+		return "*" + markdownV2Escaper.Replace(text) + "*"
+	}
+	return text
+}
+
+// markdownEscaper escapes the characters Markdown reserves
+var markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+
+// escapeText escapes the text for the parse mode
+func escapeText(text string, mode telebot.ParseMode) string {
+	switch mode {
+	case telebot.ModeHTML:
+		return html.EscapeString(text)
+	case telebot.ModeMarkdown:
+		return markdownEscaper.Replace(text)
+	case telebot.ModeMarkdownV2:
+		return markdownV2Escaper.Replace(text)
+	}
+	return text
+}
+
+// templateFuncs are available in all templates in addition to the Alertmanager's
+var templateFuncs = template.FuncMap{
+	"since": func(t time.Time) string {
+		return durafmt.Parse(time.Since(t)).String()
+	},
+	"duration": func(start time.Time, end time.Time) string {
+		return durafmt.Parse(end.Sub(start)).String()
+	},
+	"humanize":         humanize,
+	"humanize1024":     humanize1024,
+	"humanizeDuration": humanizeDuration,
+	"urlencode":        url.QueryEscape,
+	"truncate":         truncate,
+	"alertmanagerURL":  alertmanagerFilterURL,
+	"karmaURL":         karmaFilterURL,
+}
+
+// toFloat converts label and annotation values to numbers
+func toFloat(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("can't convert %T to a number", v)
+}
+
+// humanize formats a number with SI prefixes, like Prometheus' humanize, 1234567 => 1.235M
+func humanize(v interface{}) (string, error) {
+	f, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	return humanizeBase(f, 1000, []string{"k", "M", "G", "T", "P", "E", "Z", "Y"}, []string{"m", "u", "n", "p", "f", "a", "z", "y"}), nil
+}
+
+// humanize1024 formats a number with binary prefixes, like Prometheus' humanize1024, 1048576 => 1Mi
+func humanize1024(v interface{}) (string, error) {
+	f, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	return humanizeBase(f, 1024, []string{"ki", "Mi", "Gi", "Ti", "Pi", "Ei", "Zi", "Yi"}, nil), nil
+}
+
+func humanizeBase(f float64, base float64, big []string, small []string) string {
+	if f == 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprintf("%.4g", f)
+	}
+
+	prefix := ""
+	if math.Abs(f) >= 1 {
+		for _, p := range big {
+			if math.Abs(f) < base {
+				break
+			}
+			prefix = p
+			f /= base
+		}
+	} else {
+		for _, p := range small {
+			if math.Abs(f) >= 1 {
+				break
+			}
+			prefix = p
+			f *= base
+		}
+	}
+	return fmt.Sprintf("%.4g%s", f, prefix)
+}
+
+// humanizeDuration formats seconds as a human readable duration, 90 => 1 minute 30 seconds
+func humanizeDuration(v interface{}) (string, error) {
+	f, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	return durafmt.Parse(time.Duration(f * float64(time.Second))).String(), nil
+}
+
+// truncate shortens s to at most n characters, ending with … if it was cut
+func truncate(n int, s string) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n < 1 {
+		return ""
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// maxCompiledTemplates bounds the texts compiled per Templates, e.g. by many chat templates
+const maxCompiledTemplates = 1000
+
+// Templates render the messages. The Alertmanager's template clones all templates and parses
+// the executed text every time, these are parsed once and compile every executed text only once.
+type Templates struct {
+	// Template is the Alertmanager's template with only its own templates, like __subject,
+	// it renders the texts using them and the data of the alerts
+	*template.Template
+
+	text *tmpltext.Template
+	html *tmplhtml.Template
+
+	mu       sync.Mutex
+	compiled map[compiledKey]compiledTemplate
+}
+
+type compiledKey struct {
+	text string
+	html bool
+}
+
+// compiledTemplate is a text/template or html/template ready to execute
+type compiledTemplate interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// defined returns whether the template files or the built-in templates define the named template
+func (t *Templates) defined(name string) bool {
+	return t.text.Lookup(name) != nil
+}
+
+// execute renders the text with the compiled templates, escaping HTML if escapeHTML is set
+func (t *Templates) execute(text string, escapeHTML bool, data interface{}) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+
+	out, err := t.executeCompiled(text, escapeHTML, data)
+	if err == nil {
+		return out, nil
+	}
+	// The Alertmanager's template defines its own templates, like __subject
+	var fallback string
+	var fallbackErr error
+	if escapeHTML {
+		fallback, fallbackErr = t.ExecuteHTMLString(text, data)
+	} else {
+		fallback, fallbackErr = t.ExecuteTextString(text, data)
+	}
+	if fallbackErr != nil {
+		return "", err
+	}
+	return fallback, nil
+}
+
+func (t *Templates) executeCompiled(text string, escapeHTML bool, data interface{}) (string, error) {
+	tmpl, err := t.compile(compiledKey{text: text, html: escapeHTML})
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// compile parses the text as entrypoint of a copy of the templates, once per text
+func (t *Templates) compile(key compiledKey) (compiledTemplate, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if tmpl, ok := t.compiled[key]; ok {
+		return tmpl, nil
+	}
+
+	var tmpl compiledTemplate
+	if key.html {
+		clone, err := t.html.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if tmpl, err = clone.New("").Option("missingkey=zero").Parse(key.text); err != nil {
+			return nil, err
+		}
+	} else {
+		clone, err := t.text.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if tmpl, err = clone.New("").Option("missingkey=zero").Parse(key.text); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(t.compiled) >= maxCompiledTemplates {
+		t.compiled = make(map[compiledKey]compiledTemplate)
+	}
+	t.compiled[key] = tmpl
+	return tmpl, nil
+}
+
+// LoadTemplates parses the built-in DefaultTemplate followed by the template files at paths
+func LoadTemplates(paths ...string) (*Templates, error) {
+	funcs := make(map[string]interface{}, len(template.DefaultFuncs)+len(templateFuncs))
+	for name, f := range template.DefaultFuncs {
+		funcs[name] = f
+	}
+	for name, f := range templateFuncs {
+		funcs[name] = f
+	}
+
+	am, err := template.FromGlobs()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{
+		Template: am,
+		text:     tmpltext.New("").Option("missingkey=zero").Funcs(funcs),
+		html:     tmplhtml.New("").Option("missingkey=zero").Funcs(funcs),
+		compiled: make(map[compiledKey]compiledTemplate),
+	}
+	if t.text, err = t.text.Parse(DefaultTemplate); err != nil {
+		return nil, err
+	}
+	if t.html, err = t.html.Parse(DefaultTemplate); err != nil {
+		return nil, err
+	}
+	// Like the Alertmanager, globs matching no files yet are allowed
+	for _, path := range paths {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		if t.text, err = t.text.ParseGlob(path); err != nil {
+			return nil, err
+		}
+		if t.html, err = t.html.ParseGlob(path); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,237 @@
+package telegram
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	data := &template.Data{Alerts: template.Alerts{{
+		Status:      "firing",
+		Labels:      template.KV{"alertname": "HighCPU"},
+		Annotations: template.KV{"message": "CPU is busy"},
+		StartsAt:    time.Now(),
+	}}}
+
+	tmpl, err := LoadTemplates()
+	assert.NoError(t, err)
+	out, err := tmpl.execute(`{{ template "telegram.compact" . }}`, true, data)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "🔥 <b>HighCPU</b> CPU is busy")
+
+	f, err := ioutil.TempFile("", "template")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString(`{{ define "telegram.compact" }}custom {{ len .Alerts }}{{ end }}`)
+	f.Close()
+
+	tmpl, err = LoadTemplates(f.Name())
+	assert.NoError(t, err)
+	out, err = tmpl.execute(`{{ template "telegram.compact" . }}`, true, data)
+	assert.NoError(t, err)
+	assert.Equal(t, "custom 1", out)
+
+	// The functions of the bot don't leak into the Alertmanager's template shared by the process
+	_, ok := template.DefaultFuncs["since"]
+	assert.False(t, ok)
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	h, err := humanize("1234567")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.235M", h)
+	h, err = humanize(0.005)
+	assert.NoError(t, err)
+	assert.Equal(t, "5m", h)
+	_, err = humanize("lots")
+	assert.Error(t, err)
+
+	h, err = humanize1024(1048576)
+	assert.NoError(t, err)
+	assert.Equal(t, "1Mi", h)
+
+	h, err = humanizeDuration("90")
+	assert.NoError(t, err)
+	assert.Equal(t, "1 minute 30 seconds", h)
+
+	assert.Equal(t, "disk…", truncate(5, "disk is full"))
+	assert.Equal(t, "disk", truncate(5, "disk"))
+
+	tmpl, err := LoadTemplates()
+	assert.NoError(t, err)
+	out, err := tmpl.execute(`{{ .CommonLabels.instance | urlencode }} {{ .CommonAnnotations.message | truncate 7 }}`, false, &template.Data{
+		CommonLabels:      template.KV{"instance": "web01:9100/a b"},
+		CommonAnnotations: template.KV{"message": "disk is full"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "web01%3A9100%2Fa+b disk i…", out)
+}
+
+func TestEscalationMessages(t *testing.T) {
+	tmpl, err := LoadTemplates()
+	assert.NoError(t, err)
+
+	a := &HandleAlert{Level: levelTwo, Templates: func() *Templates { return tmpl }}
+	for name, expected := range map[string]string{
+		tmplAssign:      "@bob",
+		tmplAcknowledge: "Acknowledge by: @alice",
+		tmplForward:     "@alice forward to @bob",
+		tmplAutoForward: "Auto forward to next level @bob",
+	} {
+		out, err := a.escalationMessage(name, "alice", "bob")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, out)
+	}
+}
+
+func TestModeTemplate(t *testing.T) {
+	warning := template.Alerts{{Labels: template.KV{"severity": "warning"}}}
+	critical := template.Alerts{{Labels: template.KV{"severity": "critical"}}}
+
+	settings := ChatSettings{}
+	assert.Equal(t, defaultTemplate, settings.modeTemplate(defaultTemplate, warning))
+
+	settings.Modes = map[string]string{"": "verbose", "warning": "compact"}
+	assert.Equal(t, "telegram.compact", settings.modeTemplate(defaultTemplate, warning))
+	assert.Equal(t, "telegram.verbose", settings.modeTemplate(defaultTemplate, critical))
+
+	tmpl, err := LoadTemplates()
+	assert.NoError(t, err)
+	out, err := tmpl.execute(`{{ template "telegram.verbose" . }}`, true, sampleData())
+	assert.NoError(t, err)
+	assert.Contains(t, out, "instance = web01:9100")
+}
+
+func TestFallbackMessage(t *testing.T) {
+	out := fallbackMessage(template.Alerts{{
+		Status:      "firing",
+		Labels:      template.KV{"alertname": "HighCPU", "instance": "<web01>"},
+		Annotations: template.KV{"message": "CPU > 90%"},
+	}})
+	assert.Contains(t, out, "<b>FIRING</b> HighCPU")
+	assert.Contains(t, out, "instance = &lt;web01&gt;")
+	assert.Contains(t, out, "message: CPU &gt; 90%")
+}
+
+func TestRenderAlertsOrFallback(t *testing.T) {
+	tmpl, err := LoadTemplates()
+	assert.NoError(t, err)
+	s := newFakeSender()
+	b := &Bot{admins: []int{1, 2}, templates: tmpl, sender: s, logger: log.NewNopLogger(), errorNotices: newDeduplicator(errorsWindow)}
+
+	broken := ChatSettings{Template: `{{ template "missing" . }}`}
+	for _, chat := range []int64{-100, -200, -100} {
+		out, mode := b.renderAlertsOrFallback(telebot.Chat{ID: chat}, broken, defaultTemplate, sampleData())
+		assert.Equal(t, telebot.ModeHTML, mode)
+		assert.Contains(t, out, "The template failed")
+	}
+	assert.Len(t, s.sent, 2, "each admin is told once about the same failure")
+}
+
+func TestTemplateFormat(t *testing.T) {
+	tmpl, err := LoadTemplates()
+	assert.NoError(t, err)
+	b := &Bot{templates: tmpl}
+
+	out, mode, err := b.renderAlerts(ChatSettings{}, defaultTemplate, sampleData())
+	assert.NoError(t, err)
+	assert.Equal(t, telebot.ModeHTML, mode)
+	assert.Contains(t, out, "Example")
+
+	settings := ChatSettings{Template: `*{{ (index .Alerts 0).Labels.alertname }}* <b>`, TemplateFormat: formatMarkdown}
+	out, mode, err = b.renderAlerts(settings, defaultTemplate, sampleData())
+	assert.NoError(t, err)
+	assert.Equal(t, telebot.ModeMarkdown, mode)
+	assert.Equal(t, "*Example* <b>", out)
+
+	settings.TemplateFormat = "rtf"
+	_, _, err = b.renderAlerts(settings, defaultTemplate, sampleData())
+	assert.Error(t, err)
+
+	// Markdown mustn't be sent as HTML because its format failed
+	f, err := ioutil.TempFile("", "template")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString(`{{ define "telegram.md" }}*{{ len .Alerts }}*{{ end }}{{ define "telegram.md.format" }}{{ template "missing" . }}{{ end }}`)
+	f.Close()
+	b.templates, err = LoadTemplates(f.Name())
+	assert.NoError(t, err)
+	_, _, err = b.renderAlerts(ChatSettings{}, "telegram.md", sampleData())
+	assert.Error(t, err)
+
+	assert.Equal(t, "*1\\.5 alerts*", bold("1.5 alerts", telebot.ModeMarkdownV2))
+	assert.Equal(t, "<b>a &lt; b</b>", bold("a < b", telebot.ModeHTML))
+	assert.Equal(t, "plain", bold("plain", telebot.ModeDefault))
+}
+
+func TestCompiledTemplates(t *testing.T) {
+	tmpl, err := LoadTemplates()
+	assert.NoError(t, err)
+	data := sampleData()
+
+	expected, err := tmpl.execute(`{{ template "telegram.compact" . }}`, true, data)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		out, err := tmpl.execute(`{{ template "telegram.compact" . }}`, true, data)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, out)
+	}
+	assert.Len(t, tmpl.compiled, 1, "the text is compiled once")
+
+	// Only HTML output escapes HTML
+	out, err := tmpl.execute(`{{ "<b>" }}`, true, data)
+	assert.NoError(t, err)
+	assert.Equal(t, "&lt;b&gt;", out)
+	out, err = tmpl.execute(`{{ "<b>" }}`, false, data)
+	assert.NoError(t, err)
+	assert.Equal(t, "<b>", out)
+
+	// The Alertmanager's own templates still render
+	out, err = tmpl.execute(`{{ template "__subject" . }}`, false, data)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "FIRING:1")
+
+	assert.True(t, tmpl.defined("telegram.compact"))
+	assert.False(t, tmpl.defined("telegram.compact.format"))
+}
+
+// The benchmarks render webhooks of an alert storm concurrently
+func BenchmarkRenderAlerts(b *testing.B) {
+	tmpl, err := LoadTemplates()
+	if err != nil {
+		b.Fatal(err)
+	}
+	bot := &Bot{templates: tmpl}
+	data := sampleData()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, _, err := bot.renderAlerts(ChatSettings{}, defaultTemplate, data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkRenderAlertsUncompiled(b *testing.B) {
+	tmpl, err := LoadTemplates()
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := sampleData()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := tmpl.execute(`{{ template "telegram.default" . }}`, true, data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
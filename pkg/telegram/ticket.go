@@ -0,0 +1,148 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/ticket"
+)
+
+const strTicketData = "Ticket"
+
+// ticketIssue pre-fills the issue of an alert with its labels and annotations
+func ticketIssue(alert template.Alert) ticket.Issue {
+	title := alert.Labels["alertname"]
+	if summary := alert.Annotations["summary"]; summary != "" {
+		title = fmt.Sprintf("%s: %s", title, summary)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "The alert %s is %s since %s.\n", alert.Labels["alertname"], alert.Status, alert.StartsAt.UTC().Format("2006-01-02 15:04:05 MST"))
+	body.WriteString("\nLabels:\n")
+	for _, p := range alert.Labels.SortedPairs() {
+		fmt.Fprintf(&body, "- %s: %s\n", p.Name, p.Value)
+	}
+	if len(alert.Annotations) > 0 {
+		body.WriteString("\nAnnotations:\n")
+		for _, p := range alert.Annotations.SortedPairs() {
+			fmt.Fprintf(&body, "- %s: %s\n", p.Name, p.Value)
+		}
+	}
+	if alert.GeneratorURL != "" {
+		fmt.Fprintf(&body, "\nSource: %s\n", alert.GeneratorURL)
+	}
+
+	return ticket.Issue{Title: title, Body: body.String()}
+}
+
+// ticket returns the ticket created for the alert, nil if there is none yet
+func (a *HandleAlert) ticket() *ticket.Ticket {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.Ticket
+}
+
+// ticketButton links the ticket of the alert, or creates one if a tracker is configured
+func (a *HandleAlert) ticketButton() ([]telebot.KeyboardButton, error) {
+	if t := a.ticket(); t != nil {
+		if !validButtonURL(t.URL) {
+			return nil, nil
+		}
+		return []telebot.KeyboardButton{{Text: "Ticket " + t.ID, URL: t.URL}}, nil
+	}
+	if a.Tracker == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(CallbackData{Button: strTicketData, AlertID: a.Group})
+	if err != nil {
+		return nil, err
+	}
+	return []telebot.KeyboardButton{{Text: "Create ticket", Data: string(data)}}, nil
+}
+
+// replyMarkup puts the ticket button below the action and link buttons of the alert
+func (a *HandleAlert) replyMarkup(actions []telebot.KeyboardButton) (telebot.ReplyMarkup, error) {
+	var extra []telebot.KeyboardButton
+	if a.GroupLink != nil {
+		extra = append(extra, *a.GroupLink)
+	}
+	markup := replyMarkup(a.Alert, actions, extra...)
+	button, err := a.ticketButton()
+	if err != nil {
+		return telebot.ReplyMarkup{}, err
+	}
+	if len(button) > 0 {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, button)
+	}
+	button, err = a.remediateButton()
+	if err != nil {
+		return telebot.ReplyMarkup{}, err
+	}
+	if len(button) > 0 {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, button)
+	}
+	return a.Callbacks.markup(markup.InlineKeyboard), nil
+}
+
+// CreateTicket creates an issue for the alert in the tracker and posts its link in reply to the alert's message
+func (a *HandleAlert) CreateTicket(sender MessageSender, callback telebot.Callback) error {
+	// The button can be pressed again before it links the ticket
+	if a.ticket() != nil {
+		return nil
+	}
+
+	t, err := a.Tracker.Create(ticketIssue(a.Alert))
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.Ticket = &t
+	a.mu.Unlock()
+	a.publishBy(eventTicket, callback.Sender.Username, fmt.Sprintf("%s by @%s", t.ID, callback.Sender.Username))
+
+	_, err = sender.SendMessage(a.Chat, fmt.Sprintf("@%s created the ticket %s: %s", callback.Sender.Username, t.ID, t.URL), &telebot.SendOptions{
+		ReplyTo: telebot.Message{ID: a.messageID()},
+	})
+	if err != nil {
+		return err
+	}
+
+	actions, err := a.actions()
+	if err != nil {
+		return err
+	}
+	markup, err := a.replyMarkup(actions)
+	if err != nil {
+		return err
+	}
+	return sender.EditMessageReplyMarkup(a.Chat, a.messageID(), &telebot.SendOptions{
+		ParseMode:   telebot.ModeHTML,
+		ReplyMarkup: markup,
+	})
+}
+
+// handleTicketCallback creates the ticket of the alert whose Create ticket button was pressed
+func (b *Bot) handleTicketCallback(callback telebot.Callback, cd CallbackData, alerts []*HandleAlert) {
+	for _, h := range alerts {
+		if h.Chat.ID != callback.Message.Chat.ID || h.messageID() != callback.Message.ID {
+			continue
+		}
+		level.Debug(b.logger).Log("msg", "creating ticket", "alert_id", h.ID)
+
+		if err := h.CreateTicket(b.sender, callback); err != nil {
+			level.Error(b.logger).Log("msg", "failed to create ticket", "alert_id", h.ID, "err", err)
+			b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{Text: "I can't create the ticket."})
+			return
+		}
+		b.saveAlert(h)
+		b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{})
+		return
+	}
+
+	b.sender.AnswerCallbackQuery(&callback, &telebot.CallbackResponse{Text: "This alert isn't tracked anymore."})
+}
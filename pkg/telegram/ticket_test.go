@@ -0,0 +1,60 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/tucnak/telebot"
+	"github.com/vu-long/alertmanager-bot/pkg/ticket"
+)
+
+type fakeTracker struct{}
+
+func (fakeTracker) Create(ticket.Issue) (ticket.Ticket, error) {
+	return ticket.Ticket{ID: "OPS-42", URL: "https://jira.example.com/browse/OPS-42"}, nil
+}
+
+func TestTicketIssue(t *testing.T) {
+	issue := ticketIssue(template.Alert{
+		Status:       "firing",
+		Labels:       template.KV{"alertname": "HighCPU", "instance": "web01:9100"},
+		Annotations:  template.KV{"summary": "CPU of web01 is high"},
+		StartsAt:     time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC),
+		GeneratorURL: "http://prometheus:9090/graph?g0.expr=up",
+	})
+	assert.Equal(t, "HighCPU: CPU of web01 is high", issue.Title)
+	assert.Equal(t, `The alert HighCPU is firing since 2026-10-15 09:00:00 UTC.
+
+Labels:
+- alertname: HighCPU
+- instance: web01:9100
+
+Annotations:
+- summary: CPU of web01 is high
+
+Source: http://prometheus:9090/graph?g0.expr=up
+`, issue.Body)
+}
+
+func TestTicketButton(t *testing.T) {
+	a := &HandleAlert{ID: "HighCPU", Group: "HighCPU"}
+	markup, err := a.replyMarkup(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, markup.InlineKeyboard, "no button without a tracker")
+
+	a.Tracker = fakeTracker{}
+	markup, err = a.replyMarkup(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]telebot.KeyboardButton{
+		{{Text: "Create ticket", Data: `{"button":"Ticket","alert":"HighCPU"}`}},
+	}, markup.InlineKeyboard)
+
+	a.Ticket = &ticket.Ticket{ID: "OPS-42", URL: "https://jira.example.com/browse/OPS-42"}
+	markup, err = a.replyMarkup(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]telebot.KeyboardButton{
+		{{Text: "Ticket OPS-42", URL: "https://jira.example.com/browse/OPS-42"}},
+	}, markup.InlineKeyboard, "created tickets are linked")
+}
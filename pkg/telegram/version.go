@@ -0,0 +1,63 @@
+package telegram
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tucnak/telebot"
+)
+
+const commandVersion = "/version"
+
+// markdownLink matches links of the changelog, only their text is shown
+var markdownLink = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+
+// BuildInfo describes the build of the bot shown by /version
+type BuildInfo struct {
+	Version   string
+	Revision  string
+	BuildDate string
+	GoVersion string
+	// Changes are the notable changes since the previous version, embedded at build time
+	Changes []string
+	// ChangelogURL links to the full changelog
+	ChangelogURL string
+}
+
+// String formats the build info as the response of /version
+func (i BuildInfo) String() string {
+	unknown := func(s string) string {
+		if s == "" {
+			return "unknown"
+		}
+		return s
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Version: %s\n", unknown(i.Version))
+	fmt.Fprintf(&b, "Revision: %s\n", unknown(i.Revision))
+	fmt.Fprintf(&b, "Build date: %s\n", unknown(i.BuildDate))
+	fmt.Fprintf(&b, "Go version: %s", unknown(i.GoVersion))
+	if len(i.Changes) > 0 {
+		b.WriteString("\n\nChanges:")
+		for _, c := range i.Changes {
+			fmt.Fprintf(&b, "\n• %s", markdownLink.ReplaceAllString(strings.TrimSpace(c), "$1"))
+		}
+	}
+	if i.ChangelogURL != "" {
+		fmt.Fprintf(&b, "\n\nChangelog: %s", i.ChangelogURL)
+	}
+	return b.String()
+}
+
+// WithBuildInfo sets the build of the bot shown by /version
+func WithBuildInfo(info BuildInfo) BotOption {
+	return func(b *Bot) {
+		b.buildInfo = info
+	}
+}
+
+func (b *Bot) handleVersion(message telebot.Message) {
+	b.reply(message, b.buildInfo.String(), &telebot.SendOptions{DisableWebPagePreview: true})
+}
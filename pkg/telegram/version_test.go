@@ -0,0 +1,28 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildInfo(t *testing.T) {
+	info := BuildInfo{
+		Version:      "v0.4.0",
+		Revision:     "2527e87",
+		GoVersion:    "go1.11.4",
+		Changes:      []string{"[BUGFIX] Escape emojis in messages [#22](https://github.com/metalmatze/alertmanager-bot/pull/22), thanks @caarlos0. "},
+		ChangelogURL: "https://github.com/vu-long/alertmanager-bot/blob/v0.4.0/CHANGELOG.md",
+	}
+	assert.Equal(t, "Version: v0.4.0\n"+
+		"Revision: 2527e87\n"+
+		"Build date: unknown\n"+
+		"Go version: go1.11.4\n\n"+
+		"Changes:\n"+
+		"• [BUGFIX] Escape emojis in messages #22, thanks @caarlos0.\n\n"+
+		"Changelog: https://github.com/vu-long/alertmanager-bot/blob/v0.4.0/CHANGELOG.md",
+		info.String(),
+	)
+
+	assert.Equal(t, "Version: unknown\nRevision: unknown\nBuild date: unknown\nGo version: unknown", BuildInfo{}.String())
+}
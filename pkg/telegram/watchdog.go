@@ -0,0 +1,158 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/hako/durafmt"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/tucnak/telebot"
+)
+
+const (
+	// watchdogCheckInterval in which the arrival of the heartbeat is checked
+	watchdogCheckInterval = 30 * time.Second
+
+	responseWatchdogMissing   = "🚨 <b>WATCHDOG MISSING</b> 🚨\nThe alert %s didn't arrive for %s. The alerting pipeline may be broken, alerts may not reach this chat!"
+	responseWatchdogRecovered = "✅ The alert %s arrives again, the alerting pipeline is alive."
+)
+
+// watchdog is a dead man's switch expecting a heartbeat alert, like the Watchdog alert
+// of the Prometheus Operator, to keep firing, proving the whole alerting pipeline is alive
+type watchdog struct {
+	alertname string
+	timeout   time.Duration
+	chats     []int64 // warned about the missing heartbeat, the admins if empty
+
+	mu      sync.Mutex
+	last    time.Time
+	missing bool
+
+	missed        prometheus.Counter
+	lastHeartbeat prometheus.Gauge
+}
+
+func newWatchdog(alertname string, timeout time.Duration, chats []int64, now time.Time) *watchdog {
+	return &watchdog{
+		alertname: alertname,
+		timeout:   timeout,
+		chats:     chats,
+		// Give the Alertmanager the timeout to send the first heartbeat after the start
+		last: now,
+		missed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "alertmanagerbot",
+			Name:      "watchdog_missed_total",
+			Help:      "Number of times the watchdog alert stopped arriving",
+		}),
+		lastHeartbeat: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "alertmanagerbot",
+			Name:      "watchdog_last_heartbeat_timestamp_seconds",
+			Help:      "Time the watchdog alert arrived last",
+		}),
+	}
+}
+
+// register the metrics with the prometheus client
+func (w *watchdog) register(reg prometheus.Registerer) error {
+	if err := reg.Register(w.missed); err != nil {
+		return err
+	}
+	return reg.Register(w.lastHeartbeat)
+}
+
+// Filter separates the firing heartbeat alerts from the alerts to deliver
+func (w *watchdog) Filter(alerts template.Alerts) (deliver template.Alerts, heartbeat bool) {
+	for _, a := range alerts {
+		if a.Labels["alertname"] != w.alertname {
+			deliver = append(deliver, a)
+			continue
+		}
+		if a.Status == string(model.AlertFiring) {
+			heartbeat = true
+		}
+	}
+	return deliver, heartbeat
+}
+
+// Heartbeat records the arrival of the heartbeat and returns whether it was missing before
+func (w *watchdog) Heartbeat(now time.Time) (recovered bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.last = now
+	w.lastHeartbeat.Set(float64(now.Unix()))
+
+	recovered = w.missing
+	w.missing = false
+	return recovered
+}
+
+// Check returns whether the heartbeat went missing, only once until it arrives again
+func (w *watchdog) Check(now time.Time) (missing bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.missing || now.Sub(w.last) < w.timeout {
+		return false
+	}
+	w.missing = true
+	w.missed.Inc()
+	return true
+}
+
+// runWatchdog warns the watchdog chats once the heartbeat stops arriving
+func (b *Bot) runWatchdog(ctx context.Context) error {
+	ticker := time.NewTicker(watchdogCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			if !b.watchdog.Check(now) {
+				continue
+			}
+			level.Error(b.logger).Log("msg", "watchdog alert stopped arriving", "alertname", b.watchdog.alertname, "timeout", b.watchdog.timeout)
+			b.notifyWatchdog(fmt.Sprintf(responseWatchdogMissing,
+				b.watchdog.alertname,
+				durafmt.Parse(b.watchdog.timeout).String(),
+			))
+		}
+	}
+}
+
+// heartbeat records the arrival of the watchdog alert and announces the recovery of the pipeline
+func (b *Bot) heartbeat(now time.Time) {
+	if !b.watchdog.Heartbeat(now) {
+		return
+	}
+	level.Info(b.logger).Log("msg", "watchdog alert arrives again", "alertname", b.watchdog.alertname)
+	b.notifyWatchdog(fmt.Sprintf(responseWatchdogRecovered, b.watchdog.alertname))
+}
+
+// notifyWatchdog sends the message to the watchdog chats, or the admins if there are none
+func (b *Bot) notifyWatchdog(text string) {
+	// Every replica watches the heartbeat, one of them notifies
+	if !b.owns(watchdogShardKey) {
+		return
+	}
+	if len(b.watchdog.chats) == 0 {
+		for _, id := range b.currentAdmins() {
+			b.sender.SendMessage(telebot.User{ID: id}, text, &telebot.SendOptions{ParseMode: telebot.ModeHTML})
+		}
+		return
+	}
+
+	for _, id := range b.watchdog.chats {
+		_, err := b.sender.SendMessage(telebot.Chat{ID: id}, text, &telebot.SendOptions{ParseMode: telebot.ModeHTML})
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to send watchdog warning", "chat_id", id, "err", err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdog(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := newWatchdog("Watchdog", 10*time.Minute, nil, start)
+
+	alerts := template.Alerts{
+		{Status: "firing", Labels: template.KV{"alertname": "Watchdog"}},
+		{Status: "firing", Labels: template.KV{"alertname": "DiskFull"}},
+	}
+	deliver, heartbeat := w.Filter(alerts)
+	assert.True(t, heartbeat)
+	assert.Len(t, deliver, 1)
+	assert.Equal(t, "DiskFull", deliver[0].Labels["alertname"])
+
+	_, heartbeat = w.Filter(template.Alerts{{Status: "resolved", Labels: template.KV{"alertname": "Watchdog"}}})
+	assert.False(t, heartbeat, "resolved watchdog alerts are no heartbeat")
+
+	assert.False(t, w.Check(start.Add(5*time.Minute)), "the first heartbeat is expected within the timeout")
+	assert.True(t, w.Check(start.Add(10*time.Minute)))
+	assert.False(t, w.Check(start.Add(20*time.Minute)), "a missing heartbeat is reported once")
+	assert.Equal(t, float64(1), testutil.ToFloat64(w.missed))
+
+	assert.True(t, w.Heartbeat(start.Add(21*time.Minute)))
+	assert.False(t, w.Heartbeat(start.Add(22*time.Minute)))
+	assert.False(t, w.Check(start.Add(31*time.Minute)))
+	assert.True(t, w.Check(start.Add(32*time.Minute)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(w.missed))
+}
@@ -0,0 +1,42 @@
+package ticket
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// GitHub creates issues in a repository of GitHub or GitHub Enterprise
+type GitHub struct {
+	// URL of the API, e.g. https://api.github.com
+	URL *url.URL
+	// Repository as owner/name
+	Repository string
+	Token      string
+}
+
+type githubIssue struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type githubCreated struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
+
+// Create the issue in the repository
+func (g *GitHub) Create(issue Issue) (Ticket, error) {
+	u := *g.URL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/repos/" + g.Repository + "/issues"
+
+	var created githubCreated
+	if err := post(u.String(), "token "+g.Token, githubIssue{Title: issue.Title, Body: issue.Body}, &created); err != nil {
+		return Ticket{}, err
+	}
+	if created.Number == 0 {
+		return Ticket{}, fmt.Errorf("github returned no issue number")
+	}
+
+	return Ticket{ID: fmt.Sprintf("#%d", created.Number), URL: created.HTMLURL}, nil
+}
@@ -0,0 +1,68 @@
+package ticket
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Jira creates issues in a project of Jira
+type Jira struct {
+	URL       *url.URL
+	Project   string
+	IssueType string
+	// User is the email of Jira Cloud's basic authentication with the API token,
+	// without it the token is sent as personal access token of Jira Data Center.
+	User  string
+	Token string
+}
+
+type jiraIssue struct {
+	Fields jiraFields `json:"fields"`
+}
+
+type jiraFields struct {
+	Project     jiraKey  `json:"project"`
+	IssueType   jiraName `json:"issuetype"`
+	Summary     string   `json:"summary"`
+	Description string   `json:"description"`
+}
+
+type jiraKey struct {
+	Key string `json:"key"`
+}
+
+type jiraName struct {
+	Name string `json:"name"`
+}
+
+// Create the issue in the project
+func (j *Jira) Create(issue Issue) (Ticket, error) {
+	auth := "Bearer " + j.Token
+	if j.User != "" {
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(j.User+":"+j.Token))
+	}
+
+	var created jiraKey
+	err := post(j.endpoint("/rest/api/2/issue"), auth, jiraIssue{Fields: jiraFields{
+		Project:     jiraKey{Key: j.Project},
+		IssueType:   jiraName{Name: j.IssueType},
+		Summary:     issue.Title,
+		Description: issue.Body,
+	}}, &created)
+	if err != nil {
+		return Ticket{}, err
+	}
+	if created.Key == "" {
+		return Ticket{}, fmt.Errorf("jira returned no issue key")
+	}
+
+	return Ticket{ID: created.Key, URL: j.endpoint("/browse/" + created.Key)}, nil
+}
+
+func (j *Jira) endpoint(path string) string {
+	u := *j.URL
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	return u.String()
+}
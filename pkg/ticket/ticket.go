@@ -0,0 +1,63 @@
+// Package ticket creates issues for alerts in trackers like Jira and GitHub Issues.
+package ticket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Issue to create in a tracker
+type Issue struct {
+	Title string
+	Body  string
+}
+
+// Ticket is an issue created in a tracker
+type Ticket struct {
+	// ID of the issue in the tracker, e.g. OPS-42 or #42
+	ID  string
+	URL string
+}
+
+// Tracker creates issues
+type Tracker interface {
+	Create(Issue) (Ticket, error)
+}
+
+// client is the HTTP client of all trackers
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// post sends the body as JSON with the authorization header and decodes the response into v
+func post(url, authorization string, body, v interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("tracker returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
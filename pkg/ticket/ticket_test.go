@@ -0,0 +1,69 @@
+package ticket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJira(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/jira/rest/api/2/issue", r.URL.Path)
+		user, token, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "bot@example.com", user)
+		assert.Equal(t, "secret", token)
+
+		var issue jiraIssue
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&issue))
+		assert.Equal(t, "OPS", issue.Fields.Project.Key)
+		assert.Equal(t, "Task", issue.Fields.IssueType.Name)
+		assert.Equal(t, "HighCPU", issue.Fields.Summary)
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"10000","key":"OPS-42","self":"https://jira.example.com/rest/api/2/issue/10000"}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL + "/jira/")
+	j := &Jira{URL: u, Project: "OPS", IssueType: "Task", User: "bot@example.com", Token: "secret"}
+	ticket, err := j.Create(Issue{Title: "HighCPU", Body: "CPU is high"})
+	assert.NoError(t, err)
+	assert.Equal(t, Ticket{ID: "OPS-42", URL: srv.URL + "/jira/browse/OPS-42"}, ticket)
+}
+
+func TestGitHub(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/vu-long/infra/issues", r.URL.Path)
+		assert.Equal(t, "token secret", r.Header.Get("Authorization"))
+
+		var issue githubIssue
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&issue))
+		assert.Equal(t, "HighCPU", issue.Title)
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"number":42,"html_url":"https://github.com/vu-long/infra/issues/42"}`))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	g := &GitHub{URL: u, Repository: "vu-long/infra", Token: "secret"}
+	ticket, err := g.Create(Issue{Title: "HighCPU", Body: "CPU is high"})
+	assert.NoError(t, err)
+	assert.Equal(t, Ticket{ID: "#42", URL: "https://github.com/vu-long/infra/issues/42"}, ticket)
+}
+
+func TestCreateFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	_, err := (&GitHub{URL: u, Repository: "vu-long/infra"}).Create(Issue{Title: "HighCPU"})
+	assert.EqualError(t, err, `tracker returned 401 Unauthorized: {"message":"Bad credentials"}`)
+}
@@ -0,0 +1,357 @@
+// Package tracing records spans of the webhook deliveries and exports them
+// with the OTLP/HTTP JSON protocol to collectors like Jaeger or Tempo.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// batchSize is the most spans exported at once
+	batchSize = 512
+	// queueSize is the most finished spans waiting for the export, more are dropped
+	queueSize = 4 * batchSize
+	// exportInterval in which the finished spans are exported
+	exportInterval = 5 * time.Second
+)
+
+// Kind describes the relationship of a span to its parent, the values are the ones of OTLP
+type Kind int
+
+// Kinds of spans
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// SpanContext identifies a span and its trace
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// IsValid returns whether the span context identifies a span
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// ParseTraceparent parses a W3C traceparent header, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceparent(header string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", header)
+	}
+
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid trace id: %v", err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid span id: %v", err)
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", header)
+	}
+	return sc, nil
+}
+
+type contextKey struct{}
+
+// ContextWithSpanContext returns a context whose spans are children of the span
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span the context belongs to, if any
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(contextKey{}).(SpanContext)
+	return sc
+}
+
+// Attribute describes a span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String creates a string attribute
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int creates an integer attribute
+func Int(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span is an operation of a trace, it's exported once it ended
+type Span struct {
+	tracer *Tracer
+
+	context SpanContext
+	parent  [8]byte
+	name    string
+	kind    Kind
+	start   time.Time
+	end     time.Time
+	attrs   []Attribute
+	err     error
+}
+
+// Context returns the span context identifying the span
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttributes adds attributes describing the span
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// SetError marks the span as failed with the error, nil errors are ignored
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.err = err
+}
+
+// End the span and queue it for the export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+
+	select {
+	case s.tracer.queue <- s:
+	default:
+		s.tracer.mu.Lock()
+		s.tracer.dropped++
+		s.tracer.mu.Unlock()
+	}
+}
+
+// Tracer creates spans and exports them to an OTLP/HTTP endpoint.
+// A nil Tracer creates no spans, so tracing can be disabled by not creating one.
+type Tracer struct {
+	service  string
+	endpoint string
+	client   *http.Client
+	logger   log.Logger
+	queue    chan *Span
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// NewTracer exports the spans of the service to the OTLP/HTTP endpoint, e.g. http://tempo:4318
+func NewTracer(service string, endpoint *url.URL, logger log.Logger) *Tracer {
+	u := *endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/traces"
+
+	return &Tracer{
+		service:  service,
+		endpoint: u.String(),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logger,
+		queue:    make(chan *Span, queueSize),
+	}
+}
+
+// Start a span as child of the context's span, or of a new trace if the context has none.
+// The returned context belongs to the new span.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind, attrs ...Attribute) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  attrs,
+	}
+
+	parent := SpanContextFromContext(ctx)
+	if parent.IsValid() {
+		s.context.TraceID = parent.TraceID
+		s.parent = parent.SpanID
+	} else {
+		rand.Read(s.context.TraceID[:])
+	}
+	rand.Read(s.context.SpanID[:])
+
+	return ContextWithSpanContext(ctx, s.context), s
+}
+
+// Run exports the finished spans until the context is canceled
+func (t *Tracer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	export := func() {
+		t.mu.Lock()
+		dropped := t.dropped
+		t.dropped = 0
+		t.mu.Unlock()
+		if dropped > 0 {
+			level.Warn(t.logger).Log("msg", "dropped spans because the export is too slow", "spans", dropped)
+		}
+
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.export(batch); err != nil {
+			level.Warn(t.logger).Log("msg", "failed to export spans", "spans", len(batch), "err", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Export the spans that already ended
+			for {
+				select {
+				case s := <-t.queue:
+					batch = append(batch, s)
+				default:
+					export()
+					return nil
+				}
+			}
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		}
+	}
+}
+
+// The OTLP/HTTP JSON encoding of spans
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              Kind            `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string            `json:"key"`
+		Value map[string]string `json:"value"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+func otlpAttributes(attrs []Attribute) []otlpAttribute {
+	out := make([]otlpAttribute, 0, len(attrs))
+	for _, a := range attrs {
+		var value map[string]string
+		switch v := a.Value.(type) {
+		case int64:
+			value = map[string]string{"intValue": strconv.FormatInt(v, 10)}
+		default:
+			value = map[string]string{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, otlpAttribute{Key: a.Key, Value: value})
+	}
+	return out
+}
+
+// encode the spans as OTLP/HTTP JSON request
+func (t *Tracer) encode(spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.context.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.context.SpanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.attrs),
+		}
+		if s.parent != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		if s.err != nil {
+			span.Status = otlpStatus{Code: 2, Message: s.err.Error()}
+		}
+		encoded = append(encoded, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes([]Attribute{String("service.name", t.service)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: t.service}, Spans: encoded}},
+	}}}
+}
+
+func (t *Tracer) export(spans []*Span) error {
+	body, err := json.Marshal(t.encode(spans))
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.NoError(t, err)
+	assert.True(t, sc.IsValid())
+	assert.Equal(t, byte(0x4b), sc.TraceID[0])
+	assert.Equal(t, byte(0xb7), sc.SpanID[7])
+
+	_, err = ParseTraceparent("")
+	assert.Error(t, err)
+	_, err = ParseTraceparent("00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	assert.Error(t, err)
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+	ctx, span := tracer.Start(context.Background(), "noop", KindInternal)
+	span.SetAttributes(String("key", "value"))
+	span.SetError(errors.New("failed"))
+	span.End()
+	assert.False(t, SpanContextFromContext(ctx).IsValid())
+}
+
+func TestExport(t *testing.T) {
+	received := make(chan otlpRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		var req otlpRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		received <- req
+	}))
+	defer srv.Close()
+
+	endpoint, _ := url.Parse(srv.URL)
+	tracer := NewTracer("alertmanager-bot", endpoint, log.NewNopLogger())
+
+	ctx, parent := tracer.Start(context.Background(), "webhook deliver", KindInternal)
+	_, child := tracer.Start(ctx, "telegram send", KindClient, Int("chat_id", -100))
+	child.SetError(errors.New("forbidden"))
+	child.End()
+	parent.End()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, tracer.Run(ctx))
+
+	req := <-received
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "telegram send", spans[0].Name)
+	assert.Equal(t, spans[1].TraceID, spans[0].TraceID)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, otlpStatus{Code: 2, Message: "forbidden"}, spans[0].Status)
+	assert.Equal(t, map[string]string{"intValue": "-100"}, spans[0].Attributes[0].Value)
+	assert.Empty(t, spans[1].ParentSpanID)
+}